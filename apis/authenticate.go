@@ -0,0 +1,142 @@
+package apis
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jordantipton/golang-restful-webservice/auth"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+)
+
+// APIKeyHeader carries the API key of a request, such as "X-API-Key: 5f0c..."
+const APIKeyHeader = "X-API-Key"
+
+// DefaultTenantClaim is the JWT claim naming the tenant unless Authenticator.TenantClaim
+// is set
+const DefaultTenantClaim = "tenant"
+
+type (
+	// Authenticator checks the credentials of requests: an HS256 bearer token signed
+	// with JWTSecret or one of APIKeys. Requests are anonymous when neither is set.
+	Authenticator struct {
+		// JWTSecret verifies HS256 bearer tokens. Tokens are rejected when it is empty.
+		JWTSecret []byte
+		// TenantClaim is the claim of a token naming its tenant, DefaultTenantClaim if
+		// it is empty
+		TenantClaim string
+		// APIKeys maps the accepted API keys to the tenant they belong to
+		APIKeys map[string]string
+		// Now returns the time tokens are checked at. It defaults to time.Now.
+		Now func() time.Time
+	}
+
+	// Principal is the caller authenticated by the credentials of a request
+	Principal struct {
+		// Subject is the sub claim of a token, or "api-key" for an API key
+		Subject string
+		// TenantID is the tenant the credentials belong to. It is empty for a token
+		// without a valid tenant claim.
+		TenantID string
+	}
+)
+
+// Enabled reports whether requests must carry credentials
+func (authenticator *Authenticator) Enabled() bool {
+	return authenticator != nil && (len(authenticator.JWTSecret) > 0 || len(authenticator.APIKeys) > 0)
+}
+
+// Authenticate returns the principal of the Authorization header or API key of a
+// request. It returns Unauthenticated when the credentials are missing or invalid, and
+// nil without an error when authentication is not Enabled.
+func (authenticator *Authenticator) Authenticate(authorization, apiKey string) (*Principal, error) {
+	if !authenticator.Enabled() {
+		return nil, nil
+	}
+	if apiKey != "" {
+		for key, tenantID := range authenticator.APIKeys {
+			if subtle.ConstantTimeCompare([]byte(apiKey), []byte(key)) == 1 {
+				return &Principal{Subject: "api-key", TenantID: tenantID}, nil
+			}
+		}
+		return nil, errors.Unauthenticated{Message: "The API key is not valid"}
+	}
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok {
+		return nil, errors.Unauthenticated{Message: "A bearer token or " + APIKeyHeader + " is required"}
+	}
+	if len(authenticator.JWTSecret) == 0 {
+		return nil, errors.Unauthenticated{Message: "Bearer tokens are not accepted"}
+	}
+	now := time.Now
+	if authenticator.Now != nil {
+		now = authenticator.Now
+	}
+	claims, err := auth.VerifyHS256(strings.TrimSpace(token), authenticator.JWTSecret, now())
+	if err != nil {
+		return nil, errors.Unauthenticated{Message: err.Error()}
+	}
+	principal := &Principal{}
+	principal.Subject, _ = claims.String("sub")
+	if tenantID, _ := claims.String(authenticator.tenantClaim()); models.ValidTenantID(tenantID) {
+		principal.TenantID = tenantID
+	}
+	return principal, nil
+}
+
+func (authenticator *Authenticator) tenantClaim() string {
+	if authenticator.TenantClaim == "" {
+		return DefaultTenantClaim
+	}
+	return authenticator.TenantClaim
+}
+
+// ParseAPIKeys parses a comma separated list of API keys, each optionally prefixed by
+// the tenant it belongs to and a colon, such as "acme:5f0c...,9a1e...". Keys without a
+// tenant belong to the default tenant.
+func ParseAPIKeys(value string) (map[string]string, error) {
+	keys := map[string]string{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		tenantID, key, ok := strings.Cut(entry, ":")
+		if !ok {
+			tenantID, key = models.DefaultTenantID, entry
+		}
+		if !models.ValidTenantID(tenantID) || key == "" {
+			return nil, fmt.Errorf("API keys must be a comma separated list of [tenant:]key, got: %s", entry)
+		}
+		keys[key] = tenantID
+	}
+	return keys, nil
+}
+
+// requestCredentials returns the Authorization header and API key of req. Browsers
+// cannot set headers on WebSocket requests, so WebSocket upgrades may also pass a
+// bearer token in the access_token query parameter.
+func requestCredentials(req *http.Request) (authorization, apiKey string) {
+	authorization, apiKey = req.Header.Get("Authorization"), req.Header.Get(APIKeyHeader)
+	if token := req.URL.Query().Get("access_token"); authorization == "" && token != "" && websocket.IsWebSocketUpgrade(req) {
+		authorization = "Bearer " + token
+	}
+	return authorization, apiKey
+}
+
+// writeAuthError writes the response of a request rejected for its credentials
+func writeAuthError(res http.ResponseWriter, err error) {
+	switch err.(type) {
+	case errors.Unauthenticated:
+		res.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		http.Error(res, err.Error(), http.StatusUnauthorized)
+	case errors.PermissionDenied:
+		http.Error(res, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(res, err.Error(), http.StatusBadRequest)
+	}
+}
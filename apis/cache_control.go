@@ -0,0 +1,166 @@
+package apis
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+type (
+	// CachePolicy describes the caching headers of the successful GET and HEAD
+	// responses of a route
+	CachePolicy struct {
+		// MaxAge is how long caches may reuse a response without revalidating it
+		MaxAge time.Duration
+		// SharedMaxAge, if set, overrides MaxAge for shared caches such as CDNs
+		SharedMaxAge time.Duration
+		// StaleWhileRevalidate lets caches serve a stale response while they revalidate it
+		StaleWhileRevalidate time.Duration
+		// Private restricts caching to the client
+		Private bool
+		// NoCache requires caches to revalidate a response before every reuse
+		NoCache bool
+		// NoStore forbids caching the response. The other directives are then omitted.
+		NoStore bool
+		// Vary lists the request headers, besides the URL, that select the response
+		Vary []string
+	}
+
+	// CachePolicies maps chi route patterns, such as "/users/{userID}", to their policy
+	CachePolicies map[string]CachePolicy
+
+	cacheControlWriter struct {
+		http.ResponseWriter
+		req         *http.Request
+		policies    CachePolicies
+		wroteHeader bool
+	}
+)
+
+// CacheControl returns middleware that sets the Cache-Control and Vary headers of the
+// policy of the matched route on 200 and 304 responses to GET and HEAD requests.
+// Routes without a policy and error responses are left unchanged, as are responses
+// whose handler set Cache-Control itself.
+func CacheControl(policies CachePolicies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			if req.Method != http.MethodGet && req.Method != http.MethodHead {
+				next.ServeHTTP(res, req)
+				return
+			}
+			next.ServeHTTP(&cacheControlWriter{ResponseWriter: res, req: req, policies: policies}, req)
+		})
+	}
+}
+
+// String formats the policy as a Cache-Control header value
+func (p CachePolicy) String() string {
+	if p.NoStore {
+		return "no-store"
+	}
+	directives := []string{"public"}
+	if p.Private {
+		directives[0] = "private"
+	}
+	directives = append(directives, "max-age="+seconds(p.MaxAge))
+	if p.SharedMaxAge > 0 && !p.Private {
+		directives = append(directives, "s-maxage="+seconds(p.SharedMaxAge))
+	}
+	if p.NoCache {
+		directives = append(directives, "no-cache")
+	}
+	if p.StaleWhileRevalidate > 0 {
+		directives = append(directives, "stale-while-revalidate="+seconds(p.StaleWhileRevalidate))
+	}
+	return strings.Join(directives, ", ")
+}
+
+// WriteHeader applies the route policy to successful responses
+func (w *cacheControlWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if status == http.StatusOK || status == http.StatusNotModified {
+			w.apply()
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write body, applying the route policy first if no status was written
+func (w *cacheControlWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush the underlying writer when it supports flushing
+func (w *cacheControlWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (w *cacheControlWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *cacheControlWriter) apply() {
+	routeContext := chi.RouteContext(w.req.Context())
+	if routeContext == nil {
+		return
+	}
+	policy, ok := w.policies[routeContext.RoutePattern()]
+	if !ok {
+		return
+	}
+	header := w.Header()
+	if header.Get("Cache-Control") == "" {
+		header.Set("Cache-Control", policy.String())
+	}
+	for _, name := range policy.Vary {
+		if !headerHasToken(header, "Vary", name) {
+			header.Add("Vary", name)
+		}
+	}
+}
+
+// checkNotModified sets Last-Modified to modified and responds 304 Not Modified to GET
+// and HEAD requests whose If-Modified-Since is not older. It returns true if it
+// responded. A zero modified time is ignored.
+func checkNotModified(res http.ResponseWriter, req *http.Request, modified time.Time) bool {
+	if modified.IsZero() {
+		return false
+	}
+	// HTTP dates have a resolution of one second
+	modified = modified.Truncate(time.Second)
+	res.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	if err != nil || modified.After(since) {
+		return false
+	}
+	res.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+func headerHasToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, existing := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(existing), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(d / time.Second))
+}
@@ -0,0 +1,138 @@
+package apis_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jordantipton/golang-restful-webservice/apis"
+	models "github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+)
+
+var userUpdatedAt = time.Date(2024, 5, 1, 12, 30, 15, 500, time.UTC)
+
+func getCachedUser(service *mockUsersServicer, header http.Header) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	r.Use(apis.CacheControl(apis.CachePolicies{
+		"/users/{userID}": {MaxAge: time.Minute, SharedMaxAge: 5 * time.Minute, Vary: []string{"Accept", "Origin"}},
+	}))
+	apis.RegisterUsersResource(r, service)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/users/1", nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	w.Header().Set("Vary", "Origin")
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCachePolicyString(t *testing.T) {
+	// Setup
+	policies := map[string]apis.CachePolicy{
+		"public, max-age=60, s-maxage=300, stale-while-revalidate=30": {MaxAge: time.Minute, SharedMaxAge: 5 * time.Minute, StaleWhileRevalidate: 30 * time.Second},
+		"private, max-age=0, no-cache":                                {Private: true, NoCache: true, SharedMaxAge: time.Minute},
+		"no-store":                                                    {NoStore: true, MaxAge: time.Minute},
+	}
+
+	for expected, policy := range policies {
+		// Execute
+		value := policy.String()
+
+		// Assert
+		if value != expected {
+			t.Errorf("Cache-Control, expected: %s, got: %s", expected, value)
+		}
+	}
+}
+
+func TestGetUserCacheHeaders(t *testing.T) {
+	// Setup
+	service := &mockUsersServicer{
+		mockGetUser: func(userID int) (*models.User, error) {
+			return &models.User{ID: userID, Name: "Bob", UpdatedAt: userUpdatedAt}, nil
+		},
+	}
+
+	// Execute
+	w := getCachedUser(service, nil)
+
+	// Assert
+	if w.Code != 200 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 200, w.Code)
+	}
+	if cacheControl := w.Header().Get("Cache-Control"); cacheControl != "public, max-age=60, s-maxage=300" {
+		t.Errorf("Cache-Control, expected: public, max-age=60, s-maxage=300, got: %s", cacheControl)
+	}
+	if lastModified := w.Header().Get("Last-Modified"); lastModified != "Wed, 01 May 2024 12:30:15 GMT" {
+		t.Errorf("Last-Modified, expected: Wed, 01 May 2024 12:30:15 GMT, got: %s", lastModified)
+	}
+	if vary := w.Header().Values("Vary"); len(vary) != 2 || vary[0] != "Origin" || vary[1] != "Accept" {
+		t.Errorf("Vary, expected: [Origin Accept], got: %v", vary)
+	}
+}
+
+func TestGetUserNotModified(t *testing.T) {
+	// Setup
+	service := &mockUsersServicer{
+		mockGetUser: func(userID int) (*models.User, error) {
+			return &models.User{ID: userID, Name: "Bob", UpdatedAt: userUpdatedAt}, nil
+		},
+	}
+
+	// Execute
+	w := getCachedUser(service, http.Header{"If-Modified-Since": {"Wed, 01 May 2024 12:30:15 GMT"}})
+
+	// Assert
+	if w.Code != 304 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 304, w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("Response body, expected: empty, got: %s", w.Body.String())
+	}
+	if cacheControl := w.Header().Get("Cache-Control"); cacheControl == "" {
+		t.Errorf("Expected Cache-Control on the 304 response")
+	}
+}
+
+func TestGetUserModifiedSince(t *testing.T) {
+	// Setup
+	service := &mockUsersServicer{
+		mockGetUser: func(userID int) (*models.User, error) {
+			return &models.User{ID: userID, Name: "Bob", UpdatedAt: userUpdatedAt}, nil
+		},
+	}
+
+	// Execute
+	w := getCachedUser(service, http.Header{"If-Modified-Since": {"Wed, 01 May 2024 12:30:14 GMT"}})
+
+	// Assert
+	if w.Code != 200 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 200, w.Code)
+	}
+}
+
+func TestGetUserErrorIsNotCached(t *testing.T) {
+	// Setup
+	service := &mockUsersServicer{
+		mockGetUser: func(userID int) (*models.User, error) {
+			return nil, errors.NotFound{}
+		},
+	}
+
+	// Execute
+	w := getCachedUser(service, nil)
+
+	// Assert
+	if w.Code != 404 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 404, w.Code)
+	}
+	if cacheControl := w.Header().Get("Cache-Control"); cacheControl != "" {
+		t.Errorf("Cache-Control, expected: none, got: %s", cacheControl)
+	}
+}
@@ -0,0 +1,262 @@
+package codecs
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+type (
+	// Codec encodes and decodes one media type
+	Codec interface {
+		MediaType() string
+		Encode(w io.Writer, v interface{}) error
+		Decode(r io.Reader, v interface{}) error
+	}
+
+	// Registry selects a Codec by media type. The first codec is used when a request
+	// does not state a preference.
+	Registry struct {
+		Codecs []Codec
+	}
+
+	// Restricted is implemented by codecs that cannot encode every value
+	Restricted interface {
+		CanEncode(v interface{}) bool
+	}
+
+	// ProtoEncodable is implemented by values with a protobuf representation
+	ProtoEncodable interface {
+		ToProto() proto.Message
+	}
+
+	// ProtoDecodable is implemented by values that can be read from a protobuf message.
+	// NewProto returns the empty message that FromProto reads.
+	ProtoDecodable interface {
+		NewProto() proto.Message
+		FromProto(message proto.Message)
+	}
+
+	// JSON codec
+	JSON struct{}
+	// XML codec
+	XML struct{}
+	// MessagePack codec. Fields are named by their json tags.
+	MessagePack struct{}
+	// CBOR codec. Fields are named by their json tags.
+	CBOR struct{}
+	// Protobuf codec for proto messages and ProtoEncodable and ProtoDecodable values
+	Protobuf struct{}
+
+	acceptRange struct {
+		mediaType string
+		q         float64
+	}
+)
+
+// Default registry with JSON as the default codec
+var Default = &Registry{Codecs: []Codec{JSON{}, XML{}, MessagePack{}, CBOR{}, Protobuf{}}}
+
+// ForAccept returns the codec preferred by an Accept header value among those that can
+// encode v, which may be a nil pointer of the response type. An empty header selects
+// the default codec. It returns false if no codec is acceptable.
+func (r *Registry) ForAccept(accept string, v interface{}) (Codec, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return r.Codecs[0], true
+	}
+	ranges, refused := parseAccept(accept)
+	for _, acceptable := range ranges {
+		for _, codec := range r.Codecs {
+			if restricted, ok := codec.(Restricted); ok && !restricted.CanEncode(v) {
+				continue
+			}
+			if refused[codec.MediaType()] {
+				continue
+			}
+			if matchesRange(codec.MediaType(), acceptable.mediaType) {
+				return codec, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// ForContentType returns the codec of a Content-Type header value. An empty header
+// selects the default codec. It returns false if the media type is not supported.
+func (r *Registry) ForContentType(contentType string) (Codec, bool) {
+	if strings.TrimSpace(contentType) == "" {
+		return r.Codecs[0], true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	for _, codec := range r.Codecs {
+		if codec.MediaType() == mediaType {
+			return codec, true
+		}
+	}
+	return nil, false
+}
+
+// MediaTypes lists the media types of the registered codecs
+func (r *Registry) MediaTypes() []string {
+	mediaTypes := make([]string, len(r.Codecs))
+	for i, codec := range r.Codecs {
+		mediaTypes[i] = codec.MediaType()
+	}
+	return mediaTypes
+}
+
+// MediaTypesFor lists the media types of the registered codecs that can encode v, which
+// may be a nil pointer of the type
+func (r *Registry) MediaTypesFor(v interface{}) []string {
+	var mediaTypes []string
+	for _, codec := range r.Codecs {
+		if restricted, ok := codec.(Restricted); ok && !restricted.CanEncode(v) {
+			continue
+		}
+		mediaTypes = append(mediaTypes, codec.MediaType())
+	}
+	return mediaTypes
+}
+
+// parseAccept returns the acceptable media ranges in order of preference and the media
+// types refused with q=0
+func parseAccept(accept string) ([]acceptRange, map[string]bool) {
+	var ranges []acceptRange
+	refused := map[string]bool{}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+		} else {
+			refused[mediaType] = true
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+	return ranges, refused
+}
+
+// matchesRange reports whether mediaType is in a media range such as */* or application/*
+func matchesRange(mediaType, mediaRange string) bool {
+	if mediaRange == "*/*" || mediaRange == mediaType {
+		return true
+	}
+	if strings.HasSuffix(mediaRange, "/*") {
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*"))
+	}
+	return false
+}
+
+// MediaType of JSON
+func (JSON) MediaType() string { return "application/json" }
+
+// Encode v as JSON
+func (JSON) Encode(w io.Writer, v interface{}) error { return json.NewEncoder(w).Encode(v) }
+
+// Decode JSON into v
+func (JSON) Decode(r io.Reader, v interface{}) error { return json.NewDecoder(r).Decode(v) }
+
+// MediaType of XML
+func (XML) MediaType() string { return "application/xml" }
+
+// Encode v as XML
+func (XML) Encode(w io.Writer, v interface{}) error { return xml.NewEncoder(w).Encode(v) }
+
+// Decode XML into v
+func (XML) Decode(r io.Reader, v interface{}) error { return xml.NewDecoder(r).Decode(v) }
+
+// MediaType of MessagePack
+func (MessagePack) MediaType() string { return "application/msgpack" }
+
+// Encode v as MessagePack
+func (MessagePack) Encode(w io.Writer, v interface{}) error {
+	encoder := msgpack.NewEncoder(w)
+	encoder.SetCustomStructTag("json")
+	return encoder.Encode(v)
+}
+
+// Decode MessagePack into v
+func (MessagePack) Decode(r io.Reader, v interface{}) error {
+	decoder := msgpack.NewDecoder(r)
+	decoder.SetCustomStructTag("json")
+	return decoder.Decode(v)
+}
+
+// MediaType of CBOR
+func (CBOR) MediaType() string { return "application/cbor" }
+
+// Encode v as CBOR
+func (CBOR) Encode(w io.Writer, v interface{}) error { return cbor.NewEncoder(w).Encode(v) }
+
+// Decode CBOR into v
+func (CBOR) Decode(r io.Reader, v interface{}) error { return cbor.NewDecoder(r).Decode(v) }
+
+// MediaType of Protobuf
+func (Protobuf) MediaType() string { return "application/x-protobuf" }
+
+// CanEncode reports whether v has a protobuf representation
+func (Protobuf) CanEncode(v interface{}) bool {
+	switch v.(type) {
+	case proto.Message, ProtoEncodable:
+		return true
+	}
+	return false
+}
+
+// Encode v as a protobuf message
+func (Protobuf) Encode(w io.Writer, v interface{}) error {
+	message, ok := v.(proto.Message)
+	if encodable, isEncodable := v.(ProtoEncodable); !ok && isEncodable {
+		message, ok = encodable.ToProto(), true
+	}
+	if !ok {
+		return fmt.Errorf("%T has no protobuf representation", v)
+	}
+	data, err := proto.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Decode a protobuf message into v
+func (Protobuf) Decode(r io.Reader, v interface{}) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if message, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(data, message)
+	}
+	decodable, ok := v.(ProtoDecodable)
+	if !ok {
+		return fmt.Errorf("%T has no protobuf representation", v)
+	}
+	message := decodable.NewProto()
+	if err := proto.Unmarshal(data, message); err != nil {
+		return err
+	}
+	decodable.FromProto(message)
+	return nil
+}
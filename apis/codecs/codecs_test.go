@@ -0,0 +1,133 @@
+package codecs_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/jordantipton/golang-restful-webservice/apis/codecs"
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+)
+
+func TestForAccept(t *testing.T) {
+	// Setup
+	cases := map[string]string{
+		"":                                  "application/json",
+		"*/*":                               "application/json",
+		"application/xml":                   "application/xml",
+		"text/html, application/cbor;q=0.9": "application/cbor",
+		"application/json;q=0.5, application/msgpack": "application/msgpack",
+		"application/json;q=0, application/*":         "application/xml",
+		"application/x-protobuf;q=0.8, */*;q=0.1":     "application/x-protobuf",
+	}
+
+	for accept, expected := range cases {
+		// Execute
+		codec, ok := codecs.Default.ForAccept(accept, (*dtos.User)(nil))
+
+		// Assert
+		if !ok {
+			t.Errorf("Accept %q, expected: %s, got: none", accept, expected)
+		} else if codec.MediaType() != expected {
+			t.Errorf("Accept %q, expected: %s, got: %s", accept, expected, codec.MediaType())
+		}
+	}
+}
+
+func TestForAcceptNotAcceptable(t *testing.T) {
+	// Execute
+	_, ok := codecs.Default.ForAccept("text/html", (*dtos.User)(nil))
+	_, protobufOK := codecs.Default.ForAccept("application/x-protobuf", (*dtos.ImportReport)(nil))
+
+	// Assert
+	if ok {
+		t.Errorf("Expected text/html not to be acceptable")
+	}
+	if protobufOK {
+		t.Errorf("Expected protobuf not to be acceptable for a type without a protobuf representation")
+	}
+}
+
+func TestForContentType(t *testing.T) {
+	// Execute
+	codec, ok := codecs.Default.ForContentType("application/cbor; charset=binary")
+	_, unsupportedOK := codecs.Default.ForContentType("text/plain")
+
+	// Assert
+	if !ok || codec.MediaType() != "application/cbor" {
+		t.Errorf("Codec, expected: application/cbor, got: %v", codec)
+	}
+	if unsupportedOK {
+		t.Errorf("Expected text/plain not to be supported")
+	}
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	for _, codec := range codecs.Default.Codecs {
+		// Setup
+		var body bytes.Buffer
+		user := dtos.User{ID: 7, Name: "Bob"}
+
+		// Execute
+		err := codec.Encode(&body, &user)
+		var decoded dtos.User
+		decodeErr := codec.Decode(&body, &decoded)
+
+		// Assert
+		if err != nil || decodeErr != nil {
+			t.Fatalf("%s: encode error: %v, decode error: %v", codec.MediaType(), err, decodeErr)
+		}
+		if decoded.ID != 7 || decoded.Name != "Bob" {
+			t.Errorf("%s: decoded, expected: {7 Bob}, got: %+v", codec.MediaType(), decoded)
+		}
+	}
+}
+
+func TestProtobufRoundTripsEveryField(t *testing.T) {
+	// Setup
+	codec, _ := codecs.Default.ForContentType("application/x-protobuf")
+	updatedAt := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	user := dtos.User{ID: 7, Name: "Bob", Email: "bob@example.com", AvatarURL: "/users/7/avatar?v=1", UpdatedAt: &updatedAt}
+	var body bytes.Buffer
+
+	// Execute
+	err := codec.Encode(&body, &user)
+	var decoded dtos.User
+	decodeErr := codec.Decode(&body, &decoded)
+
+	// Assert
+	if err != nil || decodeErr != nil {
+		t.Fatalf("Encode error: %v, decode error: %v", err, decodeErr)
+	}
+	if decoded.ID != 7 || decoded.Name != "Bob" || decoded.Email != "bob@example.com" || decoded.AvatarURL != user.AvatarURL {
+		t.Errorf("Decoded, expected: %+v, got: %+v", user, decoded)
+	}
+	if decoded.UpdatedAt == nil || !decoded.UpdatedAt.Equal(updatedAt) {
+		t.Errorf("Decoded updatedAt, expected: %s, got: %v", updatedAt, decoded.UpdatedAt)
+	}
+}
+
+func TestCodecsUseFieldNames(t *testing.T) {
+	// Setup
+	user := dtos.User{ID: 7, Name: "Bob"}
+	expected := map[string]string{
+		"application/json": "{\"id\":7,\"name\":\"Bob\"}\n",
+		"application/xml":  "<User><id>7</id><name>Bob</name></User>",
+	}
+
+	for _, codec := range codecs.Default.Codecs {
+		want, ok := expected[codec.MediaType()]
+		if !ok {
+			continue
+		}
+		var body bytes.Buffer
+
+		// Execute
+		codec.Encode(&body, &user)
+
+		// Assert
+		if body.String() != want {
+			t.Errorf("%s, expected: %s, got: %s", codec.MediaType(), want, body.String())
+		}
+	}
+}
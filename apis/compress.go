@@ -0,0 +1,363 @@
+package apis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// DefaultCompressMinSize is the smallest response body compressed by default.
+	// Smaller bodies fit in a single packet and gain nothing from compression.
+	DefaultCompressMinSize = 1024
+	// MaxDecompressedBodySize is the largest request body in bytes that
+	// DecompressRequest decodes, so a small compressed body cannot expand without bound
+	MaxDecompressedBodySize = 256 << 20
+)
+
+// DefaultCompressContentTypes are the media types compressed by default. Binary
+// formats that are already compact, and event streams, are left alone.
+var DefaultCompressContentTypes = []string{
+	"application/json",
+	"application/problem+json",
+	"application/xml",
+	"application/x-ndjson",
+	"text/csv",
+	"text/plain",
+	"text/html",
+}
+
+type (
+	// CompressOptions configures response compression
+	CompressOptions struct {
+		// MinSize is the smallest response body, in bytes, that is compressed. Defaults to
+		// DefaultCompressMinSize. Responses that are flushed before reaching it are
+		// compressed regardless, since they are streamed.
+		MinSize int
+		// ContentTypes lists the media types that are compressed. An entry such as text/*
+		// matches a whole type. Defaults to DefaultCompressContentTypes.
+		ContentTypes []string
+	}
+
+	// compressor is implemented by the gzip, brotli and zstd writers
+	compressor interface {
+		io.WriteCloser
+		Flush() error
+		Reset(w io.Writer)
+	}
+
+	// contentEncoding is a supported Content-Encoding with a pool of its compressors
+	contentEncoding struct {
+		name string
+		pool sync.Pool
+	}
+
+	compressWriter struct {
+		http.ResponseWriter
+		req      *http.Request
+		options  *CompressOptions
+		encoding *contentEncoding
+		status   int
+		buf      []byte
+		eligible bool
+		started  bool
+		hijacked bool
+		encoder  compressor
+	}
+
+	decompressReader struct {
+		io.Reader
+		close func()
+		body  io.Closer
+	}
+)
+
+// contentEncodings are the supported encodings in order of preference when a client
+// accepts several equally
+var contentEncodings = []*contentEncoding{
+	{name: "zstd", pool: sync.Pool{New: func() interface{} {
+		encoder, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithLowerEncoderMem(true))
+		return encoder
+	}}},
+	{name: "br", pool: sync.Pool{New: func() interface{} {
+		return brotli.NewWriterLevel(nil, 5)
+	}}},
+	{name: "gzip", pool: sync.Pool{New: func() interface{} {
+		encoder, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return encoder
+	}}},
+}
+
+// Compress returns middleware that compresses responses with the best encoding of the
+// request's Accept-Encoding. Only responses of the allowed content types that reach
+// the minimum size are compressed, and those get Vary: Accept-Encoding whether or not
+// the client accepted an encoding. Responses that already have a Content-Encoding are
+// left unchanged.
+func Compress(options CompressOptions) func(http.Handler) http.Handler {
+	if options.MinSize == 0 {
+		options.MinSize = DefaultCompressMinSize
+	}
+	if options.ContentTypes == nil {
+		options.ContentTypes = DefaultCompressContentTypes
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			writer := &compressWriter{
+				ResponseWriter: res,
+				req:            req,
+				options:        &options,
+				encoding:       negotiateEncoding(req.Header.Get("Accept-Encoding")),
+			}
+			next.ServeHTTP(writer, req)
+			writer.close()
+		})
+	}
+}
+
+// DecompressRequest is middleware that decodes gzip and zstd request bodies of up to
+// MaxDecompressedBodySize bytes. Requests with any other Content-Encoding are rejected
+// with 415 Unsupported Media Type.
+func DecompressRequest(next http.Handler) http.Handler {
+	return DecompressRequestLimit(MaxDecompressedBodySize)(next)
+}
+
+// DecompressRequestLimit returns DecompressRequest middleware that decodes at most
+// maxSize bytes. Reading further fails with an *http.MaxBytesError, which handlers
+// report as 413 Request Entity Too Large.
+func DecompressRequestLimit(maxSize int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return decompressRequest(next, maxSize)
+	}
+}
+
+func decompressRequest(next http.Handler, maxSize int64) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		encoding := strings.ToLower(strings.TrimSpace(req.Header.Get("Content-Encoding")))
+		switch encoding {
+		case "", "identity":
+			next.ServeHTTP(res, req)
+			return
+		case "gzip", "x-gzip":
+			decoder, err := gzip.NewReader(req.Body)
+			if err != nil {
+				http.Error(res, "Request body is not valid gzip", http.StatusBadRequest)
+				return
+			}
+			req.Body = &decompressReader{Reader: decoder, close: func() { decoder.Close() }, body: req.Body}
+		case "zstd":
+			decoder, err := zstd.NewReader(req.Body, zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				http.Error(res, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			req.Body = &decompressReader{Reader: decoder, close: decoder.Close, body: req.Body}
+		default:
+			res.Header().Set("Accept-Encoding", "gzip, zstd")
+			http.Error(res, fmt.Sprintf("Content-Encoding %s is not supported", encoding), http.StatusUnsupportedMediaType)
+			return
+		}
+		req.Body = http.MaxBytesReader(res, req.Body, maxSize)
+		req.Header.Del("Content-Encoding")
+		req.Header.Del("Content-Length")
+		req.ContentLength = -1
+		next.ServeHTTP(res, req)
+	})
+}
+
+// negotiateEncoding returns the supported encoding with the highest q-value in
+// accept, or nil if the client accepts none of them
+func negotiateEncoding(accept string) *contentEncoding {
+	if accept == "" {
+		return nil
+	}
+	qValues := map[string]float64{}
+	for _, part := range strings.Split(accept, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			var err error
+			if q, err = strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil {
+				continue
+			}
+		}
+		qValues[strings.ToLower(strings.TrimSpace(coding))] = q
+	}
+	var best *contentEncoding
+	bestQ := 0.0
+	for _, encoding := range contentEncodings {
+		q, ok := qValues[encoding.name]
+		if !ok {
+			q = qValues["*"]
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+func (e *contentEncoding) get(w io.Writer) compressor {
+	encoder := e.pool.Get().(compressor)
+	encoder.Reset(w)
+	return encoder
+}
+
+func (e *contentEncoding) put(encoder compressor) {
+	encoder.Reset(nil)
+	e.pool.Put(encoder)
+}
+
+// WriteHeader records status. Writing it is deferred until enough of the body has
+// been written to decide whether to compress it.
+func (w *compressWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	if status < http.StatusOK {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
+	w.eligible = w.compressible()
+	if w.eligible && !headerHasToken(w.Header(), "Vary", "Accept-Encoding") {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+	if !w.eligible || w.encoding == nil {
+		w.start(false)
+	}
+}
+
+// Write body, buffering it until it reaches the minimum size
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.started {
+		if w.encoder != nil {
+			return w.encoder.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.options.MinSize {
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush the compressed body written so far and the underlying writer when it
+// supports flushing
+func (w *compressWriter) Flush() {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.started {
+		w.start(true)
+	}
+	if w.encoder != nil {
+		w.encoder.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack the connection of the underlying writer, such as for a websocket upgrade
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not implement http.Hijacker")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// compressible reports whether the response may be compressed, ignoring its size.
+// Partial content is not, since its Content-Range counts bytes of the uncompressed
+// representation.
+func (w *compressWriter) compressible() bool {
+	if w.req.Method == http.MethodHead || w.status == http.StatusNoContent || w.status == http.StatusNotModified || w.status == http.StatusPartialContent {
+		return false
+	}
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	for _, allowed := range w.options.ContentTypes {
+		if allowed == mediaType || (strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(allowed, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// start writes the status and the buffered body, compressing it if compress is true
+// and the response is eligible
+func (w *compressWriter) start(compress bool) error {
+	w.started = true
+	if compress && w.eligible && w.encoding != nil {
+		header := w.Header()
+		header.Set("Content-Encoding", w.encoding.name)
+		header.Del("Content-Length")
+		w.encoder = w.encoding.get(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.encoder != nil {
+		_, err = w.encoder.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// close writes a body below the minimum size uncompressed, or finishes the compressed
+// body and returns the compressor to its pool
+func (w *compressWriter) close() {
+	if w.hijacked || w.status == 0 {
+		return
+	}
+	if !w.started {
+		w.start(false)
+	}
+	if w.encoder != nil {
+		w.encoder.Close()
+		w.encoding.put(w.encoder)
+		w.encoder = nil
+	}
+}
+
+// Close the decoder and the original request body
+func (r *decompressReader) Close() error {
+	r.close()
+	return r.body.Close()
+}
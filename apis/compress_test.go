@@ -0,0 +1,265 @@
+package apis_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/go-chi/chi"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/jordantipton/golang-restful-webservice/apis"
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+	models "github.com/jordantipton/golang-restful-webservice/models"
+)
+
+/*
+	Test objects
+*/
+
+var largeBody = strings.Repeat("{\"id\":1,\"name\":\"Bob\"}\n", 100)
+
+func serveCompressed(handler http.HandlerFunc, acceptEncoding string) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	r.Use(apis.Compress(apis.CompressOptions{}))
+	r.Get("/", handler)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func writeBody(contentType, body string) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", contentType)
+		io.WriteString(res, body)
+	}
+}
+
+func decompress(t *testing.T, encoding string, body io.Reader) string {
+	t.Helper()
+	var reader io.Reader
+	switch encoding {
+	case "gzip":
+		gzipReader, err := gzip.NewReader(body)
+		if err != nil {
+			t.Fatalf("gzip.NewReader returned error: %s", err.Error())
+		}
+		reader = gzipReader
+	case "br":
+		reader = brotli.NewReader(body)
+	case "zstd":
+		zstdReader, err := zstd.NewReader(body)
+		if err != nil {
+			t.Fatalf("zstd.NewReader returned error: %s", err.Error())
+		}
+		defer zstdReader.Close()
+		reader = zstdReader
+	default:
+		reader = body
+	}
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Decompressing %s returned error: %s", encoding, err.Error())
+	}
+	return string(decoded)
+}
+
+/*
+	Test functions
+*/
+
+func TestCompressNegotiatesEncoding(t *testing.T) {
+	// Setup
+	acceptEncodings := map[string]string{
+		"gzip":                       "gzip",
+		"gzip;q=0.5, br, zstd;q=0.1": "br",
+		"gzip, br, zstd":             "zstd",
+		"*":                          "zstd",
+		"*;q=0.2, zstd;q=0, br;q=0":  "gzip",
+		"identity":                   "",
+		"deflate, gzip;q=0":          "",
+	}
+
+	for acceptEncoding, expected := range acceptEncodings {
+		// Execute
+		w := serveCompressed(writeBody("application/json", largeBody), acceptEncoding)
+
+		// Assert
+		if encoding := w.Header().Get("Content-Encoding"); encoding != expected {
+			t.Errorf("Content-Encoding for %s, expected: %s, got: %s", acceptEncoding, expected, encoding)
+		}
+		if vary := w.Header().Get("Vary"); vary != "Accept-Encoding" {
+			t.Errorf("Vary for %s, expected: Accept-Encoding, got: %s", acceptEncoding, vary)
+		}
+		if body := decompress(t, expected, w.Body); body != largeBody {
+			t.Errorf("Body for %s, expected %d bytes, got: %d", acceptEncoding, len(largeBody), len(body))
+		}
+	}
+}
+
+func TestCompressSkipsSmallResponses(t *testing.T) {
+	// Execute
+	w := serveCompressed(writeBody("application/json", "{\"id\":1}"), "gzip")
+
+	// Assert
+	if encoding := w.Header().Get("Content-Encoding"); encoding != "" {
+		t.Errorf("Content-Encoding, expected none, got: %s", encoding)
+	}
+	if vary := w.Header().Get("Vary"); vary != "Accept-Encoding" {
+		t.Errorf("Vary, expected: Accept-Encoding, got: %s", vary)
+	}
+	if body := w.Body.String(); body != "{\"id\":1}" {
+		t.Errorf("Body, expected: {\"id\":1}, got: %s", body)
+	}
+}
+
+func TestCompressSkipsUnlistedContentTypes(t *testing.T) {
+	// Execute
+	w := serveCompressed(writeBody("application/vnd.apache.parquet", largeBody), "gzip")
+
+	// Assert
+	if encoding := w.Header().Get("Content-Encoding"); encoding != "" {
+		t.Errorf("Content-Encoding, expected none, got: %s", encoding)
+	}
+	if vary := w.Header().Get("Vary"); vary != "" {
+		t.Errorf("Vary, expected none, got: %s", vary)
+	}
+	if body := w.Body.String(); body != largeBody {
+		t.Errorf("Body, expected %d bytes, got: %d", len(largeBody), len(body))
+	}
+}
+
+func TestCompressStreamsFlushedResponses(t *testing.T) {
+	// Setup
+	handler := func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "text/csv")
+		io.WriteString(res, "id,name\n")
+		res.(http.Flusher).Flush()
+		io.WriteString(res, "1,Bob\n")
+	}
+
+	// Execute
+	w := serveCompressed(handler, "zstd")
+
+	// Assert
+	if encoding := w.Header().Get("Content-Encoding"); encoding != "zstd" {
+		t.Errorf("Content-Encoding, expected: zstd, got: %s", encoding)
+	}
+	if !w.Flushed {
+		t.Errorf("Expected the response to be flushed")
+	}
+	if body := decompress(t, "zstd", w.Body); body != "id,name\n1,Bob\n" {
+		t.Errorf("Body, expected: id,name\\n1,Bob\\n, got: %q", body)
+	}
+}
+
+func TestCompressSkipsPartialContent(t *testing.T) {
+	// Setup
+	handler := func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "text/plain")
+		res.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(largeBody)-1, 2*len(largeBody)))
+		res.WriteHeader(http.StatusPartialContent)
+		io.WriteString(res, largeBody)
+	}
+
+	// Execute
+	w := serveCompressed(handler, "gzip")
+
+	// Assert
+	if encoding := w.Header().Get("Content-Encoding"); encoding != "" {
+		t.Errorf("Content-Encoding, expected: none, got: %s", encoding)
+	}
+	if w.Body.String() != largeBody {
+		t.Errorf("Expected the partial body to be written unchanged")
+	}
+}
+
+func TestImportUsersCompressedBody(t *testing.T) {
+	// Setup
+	mockUsersServicer := mockUsersServicer{mockImportUsers: createAll}
+	r := chi.NewRouter()
+	apis.RegisterUsersResource(r, &mockUsersServicer)
+	var body bytes.Buffer
+	gzipWriter := gzip.NewWriter(&body)
+	io.WriteString(gzipWriter, "{\"name\":\"Alice\"}\n{\"name\":\"Bob\"}\n")
+	gzipWriter.Close()
+	req := httptest.NewRequest("POST", "http://localhost:8080/users:import", &body)
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 200 {
+		t.Fatalf("HTTP status code, expected: %d, got: %d", 200, w.Code)
+	}
+	report := dtos.ImportReport{}
+	json.NewDecoder(w.Body).Decode(&report)
+	if report.Created != 2 {
+		t.Errorf("Created, expected: %d, got: %d", 2, report.Created)
+	}
+}
+
+func TestImportUsersUnsupportedContentEncoding(t *testing.T) {
+	// Setup
+	mockUsersServicer := mockUsersServicer{
+		mockImportUsers: func(users []*models.User, options models.ImportOptions) ([]models.ImportResult, error) {
+			t.Errorf("Expected no users to be imported")
+			return nil, nil
+		},
+	}
+	r := chi.NewRouter()
+	apis.RegisterUsersResource(r, &mockUsersServicer)
+	req := httptest.NewRequest("POST", "http://localhost:8080/users:import", strings.NewReader("compressed"))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Content-Encoding", "compress")
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 415 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 415, w.Code)
+	}
+	if acceptEncoding := w.Header().Get("Accept-Encoding"); acceptEncoding != "gzip, zstd" {
+		t.Errorf("Accept-Encoding, expected: gzip, zstd, got: %s", acceptEncoding)
+	}
+}
+
+func TestImportUsersDecompressedBodyTooLarge(t *testing.T) {
+	// Setup
+	mockUsersServicer := mockUsersServicer{mockImportUsers: createAll}
+	resource := &apis.UsersResource{Service: &mockUsersServicer}
+	handler := apis.DecompressRequestLimit(1024)(http.HandlerFunc(resource.ImportUsers))
+	var body bytes.Buffer
+	gzipWriter := gzip.NewWriter(&body)
+	io.WriteString(gzipWriter, strings.Repeat("{\"name\":\"Alice\"}\n", 1000))
+	gzipWriter.Close()
+	req := httptest.NewRequest("POST", "http://localhost:8080/users:import", &body)
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+
+	// Execute
+	handler.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 413 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 413, w.Code)
+	}
+}
@@ -0,0 +1,29 @@
+package converters
+
+import (
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+	domainModels "github.com/jordantipton/golang-restful-webservice/models"
+)
+
+// ToGroup converts domain Group to api Group
+func ToGroup(serviceGroup *domainModels.Group) *dtos.Group {
+	group := &dtos.Group{
+		ID:          serviceGroup.ID,
+		Name:        serviceGroup.Name,
+		Description: serviceGroup.Description,
+	}
+	if !serviceGroup.UpdatedAt.IsZero() {
+		updatedAt := serviceGroup.UpdatedAt
+		group.UpdatedAt = &updatedAt
+	}
+	return group
+}
+
+// FromGroup converts api Group to domain Group
+func FromGroup(apiGroup *dtos.Group) *domainModels.Group {
+	return &domainModels.Group{
+		ID:          apiGroup.ID,
+		Name:        apiGroup.Name,
+		Description: apiGroup.Description,
+	}
+}
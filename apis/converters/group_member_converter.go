@@ -0,0 +1,24 @@
+package converters
+
+import (
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+	domainModels "github.com/jordantipton/golang-restful-webservice/models"
+)
+
+// ToGroupMember converts domain GroupMember to api GroupMember
+func ToGroupMember(serviceGroupMember *domainModels.GroupMember) *dtos.GroupMember {
+	return &dtos.GroupMember{
+		GroupID: serviceGroupMember.GroupID,
+		UserID:  serviceGroupMember.UserID,
+		Role:    serviceGroupMember.Role,
+	}
+}
+
+// FromGroupMember converts api GroupMember to domain GroupMember
+func FromGroupMember(apiGroupMember *dtos.GroupMember) *domainModels.GroupMember {
+	return &domainModels.GroupMember{
+		GroupID: apiGroupMember.GroupID,
+		UserID:  apiGroupMember.UserID,
+		Role:    apiGroupMember.Role,
+	}
+}
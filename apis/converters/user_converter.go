@@ -1,16 +1,74 @@
 package converters
 
 import (
+	"fmt"
+	"sort"
+
 	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
 	domainModels "github.com/jordantipton/golang-restful-webservice/models"
 )
 
 // ToUser converts domain User to api User
 func ToUser(serviceUser *domainModels.User) *dtos.User {
-	return &dtos.User{ID: serviceUser.ID, Name: serviceUser.Name}
+	user := &dtos.User{ID: serviceUser.ID, Name: serviceUser.Name, Email: serviceUser.Email, AvatarURL: AvatarURL(serviceUser)}
+	if !serviceUser.UpdatedAt.IsZero() {
+		updatedAt := serviceUser.UpdatedAt
+		user.UpdatedAt = &updatedAt
+	}
+	return user
+}
+
+// AvatarURL returns the URL of the avatar of user, or "" if it has none. The URL changes
+// with every upload, so that it can be cached.
+func AvatarURL(user *domainModels.User) string {
+	if user.Avatar == "" {
+		return ""
+	}
+	return fmt.Sprintf("/users/%d/avatar?v=%s", user.ID, user.Avatar)
+}
+
+// ToUserSearchResult converts domain UserSearchHit to an api UserSearchResult, with
+// the highlights ordered by field
+func ToUserSearchResult(hit *domainModels.UserSearchHit) *dtos.UserSearchResult {
+	result := &dtos.UserSearchResult{User: *ToUser(hit.User), Score: hit.Score, Highlights: []dtos.Highlight{}}
+	for field, text := range hit.Highlights {
+		result.Highlights = append(result.Highlights, dtos.Highlight{Field: field, Text: text})
+	}
+	sort.Slice(result.Highlights, func(i, j int) bool { return result.Highlights[i].Field < result.Highlights[j].Field })
+	return result
 }
 
 // FromUser converts api User to domain User
 func FromUser(apiUser *dtos.User) *domainModels.User {
-	return &domainModels.User{ID: apiUser.ID, Name: apiUser.Name}
+	return &domainModels.User{ID: apiUser.ID, Name: apiUser.Name, Email: apiUser.Email}
+}
+
+// ToUserEvent converts domain UserEvent to api UserEvent
+func ToUserEvent(serviceEvent *domainModels.UserEvent) *dtos.UserEvent {
+	return &dtos.UserEvent{
+		Seq:  serviceEvent.Seq,
+		Type: serviceEvent.Type,
+		User: dtos.User{ID: serviceEvent.UserID, Name: serviceEvent.Name, Email: serviceEvent.Email},
+	}
+}
+
+// ToImportRow converts the domain ImportResult of row to an api ImportRow
+func ToImportRow(row int, result *domainModels.ImportResult) *dtos.ImportRow {
+	return &dtos.ImportRow{Row: row, ID: result.ID, Status: result.Status, Error: result.Error}
+}
+
+// ToJob converts domain Job to api Job
+func ToJob(serviceJob *domainModels.Job) *dtos.Job {
+	job := &dtos.Job{
+		ID:       serviceJob.ID,
+		Type:     serviceJob.Type,
+		Status:   serviceJob.Status,
+		Progress: serviceJob.Progress,
+		Total:    serviceJob.Total,
+		Error:    serviceJob.Error,
+	}
+	if serviceJob.Status == domainModels.JobSucceeded && serviceJob.ArtifactName != "" {
+		job.ArtifactURL = fmt.Sprintf("/jobs/%d/artifact", serviceJob.ID)
+	}
+	return job
 }
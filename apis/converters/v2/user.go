@@ -0,0 +1,27 @@
+// Package v2 converts between the domain models and the dtos of version 2 of the users
+// routes
+package v2
+
+import (
+	"strconv"
+
+	"github.com/jordantipton/golang-restful-webservice/apis/converters"
+	dtos "github.com/jordantipton/golang-restful-webservice/apis/dtos/v2"
+	domainModels "github.com/jordantipton/golang-restful-webservice/models"
+)
+
+// ToUser converts domain User to api User
+func ToUser(serviceUser *domainModels.User) *dtos.User {
+	user := &dtos.User{ID: strconv.Itoa(serviceUser.ID), DisplayName: serviceUser.Name, Email: serviceUser.Email, AvatarURL: converters.AvatarURL(serviceUser)}
+	if !serviceUser.UpdatedAt.IsZero() {
+		updatedAt := serviceUser.UpdatedAt
+		user.UpdatedAt = &updatedAt
+	}
+	return user
+}
+
+// FromUser converts api User to domain User. The ID is read-only and comes from the
+// route instead.
+func FromUser(apiUser *dtos.User) *domainModels.User {
+	return &domainModels.User{Name: apiUser.DisplayName, Email: apiUser.Email}
+}
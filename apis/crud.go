@@ -0,0 +1,410 @@
+package apis
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-chi/chi"
+	"github.com/jordantipton/golang-restful-webservice/apis/codecs"
+	"github.com/jordantipton/golang-restful-webservice/apis/openapi"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/services"
+)
+
+type (
+	// Resource defines the list, get, create, update and delete handlers of entities of
+	// type M with IDs of type ID, represented as DTO. Representations are negotiated
+	// with Codecs, or codecs.Default if it is nil.
+	Resource[DTO any, M any, ID comparable] struct {
+		Service services.Servicer[M, ID]
+		Codecs  *codecs.Registry
+		// Name names the entity in errors and documentation, such as "User"
+		Name string
+		// Plural names the entities in documentation, such as "Users". It defaults to
+		// Name followed by an s.
+		Plural string
+		// IDParam is the route parameter of the ID, such as "userID"
+		IDParam string
+		// ParseID parses an ID of a route or page token. Its error is the message of the
+		// 400 Bad Request response of routes.
+		ParseID func(s string) (ID, error)
+		// FormatID formats the ID of the last entity of a page as the next page token
+		FormatID func(id ID) string
+		ID       func(m *M) ID
+		SetID    func(m *M, id ID)
+		ToDTO    func(m *M) *DTO
+		FromDTO  func(dto *DTO) *M
+		// NewPage returns the page dto of items. It is called with no items to negotiate
+		// the representation of pages.
+		NewPage func(items []DTO, nextPageToken string) interface{}
+		// ModifiedAt returns when m last changed, for conditional requests. It is
+		// optional.
+		ModifiedAt func(m *M) time.Time
+		// DefaultPageSize and MaxPageSize bound the page_size query parameter
+		DefaultPageSize int
+		MaxPageSize     int
+		// PrefixParam is the query parameter of the Prefix of ListOptions, such as
+		// "name_prefix". It is optional.
+		PrefixParam string
+	}
+)
+
+// RegisterResource sets up the routing of r under path, such as "/users" and
+// "/users/{userID}". The routes are documented from the dtos and settings of r.
+func RegisterResource[DTO any, M any, ID comparable](router chi.Router, path string, r *Resource[DTO, M, ID]) {
+	item := path + "/{" + r.IDParam + "}"
+	router.Method(http.MethodGet, item, openapi.Handler(r.getOperation(), r.Get))
+	router.Method(http.MethodGet, path, openapi.Handler(r.listOperation(), r.List))
+	router.Method(http.MethodPost, path, openapi.Handler(r.createOperation(), r.Create))
+	router.Method(http.MethodPut, item, openapi.Handler(r.updateOperation(), r.Update))
+	router.Method(http.MethodDelete, item, openapi.Handler(r.deleteOperation(), r.Delete))
+}
+
+// Get by ID. Responds 304 Not Modified if the entity has not changed since
+// If-Modified-Since.
+func (r *Resource[DTO, M, ID]) Get(res http.ResponseWriter, req *http.Request) {
+	codec, ok := negotiate(res, req, r.codecs(), (*DTO)(nil))
+	if !ok {
+		return
+	}
+	id, ok := r.routeID(res, req)
+	if !ok {
+		return
+	}
+	m, err := r.Service.Get(id)
+	if err != nil {
+		r.writeError(res, err, id)
+		return
+	}
+	if r.ModifiedAt != nil && checkNotModified(res, req, r.ModifiedAt(m)) {
+		return
+	}
+	render(res, codec, http.StatusOK, r.ToDTO(m))
+}
+
+// List writes the page of entities ordered by ID that follows the page_token query
+// parameter. The next page token is the ID of the last entity of a full page.
+func (r *Resource[DTO, M, ID]) List(res http.ResponseWriter, req *http.Request) {
+	codec, ok := negotiate(res, req, r.codecs(), r.NewPage(nil, ""))
+	if !ok {
+		return
+	}
+	options, ok := r.listOptions(res, req)
+	if !ok {
+		return
+	}
+	entities, err := r.Service.List(options)
+	if err != nil {
+		var id ID
+		r.writeError(res, err, id)
+		return
+	}
+	items := []DTO{}
+	for _, m := range entities {
+		items = append(items, *r.ToDTO(m))
+	}
+	nextPageToken := ""
+	if len(entities) > 0 && len(entities) >= options.Limit {
+		nextPageToken = r.FormatID(r.ID(entities[len(entities)-1]))
+	}
+	render(res, codec, http.StatusOK, r.NewPage(items, nextPageToken))
+}
+
+// Create and return result
+func (r *Resource[DTO, M, ID]) Create(res http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	codec, ok := negotiate(res, req, r.codecs(), (*DTO)(nil))
+	if !ok {
+		return
+	}
+	var dto DTO
+	if !bind(res, req, r.codecs(), &dto) {
+		return
+	}
+	m, err := r.Service.Create(r.FromDTO(&dto))
+	if err != nil {
+		var id ID
+		r.writeError(res, err, id)
+		return
+	}
+	render(res, codec, http.StatusCreated, r.ToDTO(m))
+}
+
+// Update the entity with the ID of the route and return the result
+func (r *Resource[DTO, M, ID]) Update(res http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	codec, ok := negotiate(res, req, r.codecs(), (*DTO)(nil))
+	if !ok {
+		return
+	}
+	id, ok := r.routeID(res, req)
+	if !ok {
+		return
+	}
+	var dto DTO
+	if !bind(res, req, r.codecs(), &dto) {
+		return
+	}
+	m := r.FromDTO(&dto)
+	r.SetID(m, id)
+	m, err := r.Service.Update(m)
+	if err != nil {
+		r.writeError(res, err, id)
+		return
+	}
+	render(res, codec, http.StatusOK, r.ToDTO(m))
+}
+
+// Delete by ID
+func (r *Resource[DTO, M, ID]) Delete(res http.ResponseWriter, req *http.Request) {
+	id, ok := r.routeID(res, req)
+	if !ok {
+		return
+	}
+	if err := r.Service.Delete(id); err != nil {
+		r.writeError(res, err, id)
+		return
+	}
+	res.WriteHeader(http.StatusNoContent)
+}
+
+// routeID parses the ID route parameter of req. It responds 400 Bad Request and returns
+// false if it is invalid.
+func (r *Resource[DTO, M, ID]) routeID(res http.ResponseWriter, req *http.Request) (ID, bool) {
+	id, err := r.ParseID(chi.URLParam(req, r.IDParam))
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return id, false
+	}
+	return id, true
+}
+
+// listOptions reads the page_size and page_token query parameters of req, and the
+// PrefixParam if there is one. It writes an error response and returns false if they
+// are invalid.
+func (r *Resource[DTO, M, ID]) listOptions(res http.ResponseWriter, req *http.Request) (models.ListOptions[ID], bool) {
+	query := req.URL.Query()
+	options := models.ListOptions[ID]{Limit: r.DefaultPageSize}
+	if r.PrefixParam != "" {
+		options.Prefix = query.Get(r.PrefixParam)
+	}
+	if pageSize := query.Get("page_size"); pageSize != "" {
+		limit, err := strconv.Atoi(pageSize)
+		if err != nil || limit <= 0 {
+			http.Error(res, "page_size must be a positive integer", http.StatusBadRequest)
+			return options, false
+		}
+		options.Limit = limit
+	}
+	if r.MaxPageSize > 0 && options.Limit > r.MaxPageSize {
+		options.Limit = r.MaxPageSize
+	}
+	if pageToken := query.Get("page_token"); pageToken != "" {
+		afterID, err := r.ParseID(pageToken)
+		if err != nil {
+			http.Error(res, "Invalid page token", http.StatusBadRequest)
+			return options, false
+		}
+		options.AfterID = afterID
+	}
+	return options, true
+}
+
+// writeError writes the response of a service error. Entities that are not found are
+// reported by id.
+func (r *Resource[DTO, M, ID]) writeError(res http.ResponseWriter, err error, id ID) {
+	switch err.(type) {
+	case errors.NotFound:
+		http.Error(res, fmt.Sprintf("%s with ID %v not found", r.Name, id), http.StatusNotFound)
+	case errors.InvalidArgument:
+		http.Error(res, err.Error(), http.StatusBadRequest)
+	case errors.AlreadyExists:
+		http.Error(res, err.Error(), http.StatusConflict)
+	default:
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (r *Resource[DTO, M, ID]) codecs() *codecs.Registry {
+	if r.Codecs == nil {
+		return codecs.Default
+	}
+	return r.Codecs
+}
+
+func (r *Resource[DTO, M, ID]) getOperation() openapi.Operation {
+	label := r.label()
+	ok := openapi.Response{Status: http.StatusOK, Body: new(DTO), MediaTypes: r.codecs().MediaTypesFor((*DTO)(nil))}
+	responses := []openapi.Response{ok}
+	if r.ModifiedAt != nil {
+		responses[0].Headers = map[string]string{"Last-Modified": "When the " + label + " was last updated"}
+		responses = append(responses, openapi.Response{Status: http.StatusNotModified, Description: "The " + label + " has not changed since If-Modified-Since"})
+	}
+	return openapi.Operation{
+		ID:         "get" + r.Name,
+		Summary:    "Get " + withArticle(label),
+		Tags:       r.tags(),
+		Parameters: []openapi.Parameter{r.idParameter()},
+		Responses: append(responses,
+			parameterErrorResponse(r.idError()),
+			textResponse(http.StatusNotFound, "The "+label+" does not exist"),
+			notAcceptableResponse,
+		),
+	}
+}
+
+func (r *Resource[DTO, M, ID]) listOperation() openapi.Operation {
+	plural := r.pluralLabel()
+	pageSize := "Maximum number of " + plural + " of a page"
+	if r.MaxPageSize > 0 {
+		pageSize += fmt.Sprintf(", at most %d", r.MaxPageSize)
+	}
+	parameters := []openapi.Parameter{
+		{Name: "page_size", In: "query", Description: pageSize, Schema: &openapi.Schema{Type: "integer"}},
+		{Name: "page_token", In: "query", Description: "The nextPageToken of the previous page", Schema: &openapi.Schema{Type: "string"}},
+	}
+	if r.PrefixParam != "" {
+		field := strings.ReplaceAll(strings.TrimSuffix(r.PrefixParam, "_prefix"), "_", " ")
+		parameters = append(parameters, openapi.Parameter{Name: r.PrefixParam, In: "query", Description: "Only list " + plural + " whose " + field + " starts with it", Schema: &openapi.Schema{Type: "string"}})
+	}
+	page := r.NewPage(nil, "")
+	return openapi.Operation{
+		ID:         "list" + r.plural(),
+		Summary:    "List " + plural,
+		Tags:       r.tags(),
+		Parameters: parameters,
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Body: page, MediaTypes: r.codecs().MediaTypesFor(page)},
+			parameterErrorResponse("The page size or page token are malformed"),
+			notAcceptableResponse,
+		},
+	}
+}
+
+func (r *Resource[DTO, M, ID]) createOperation() openapi.Operation {
+	label := r.label()
+	return openapi.Operation{
+		ID:                "create" + r.Name,
+		Summary:           "Create " + withArticle(label),
+		Tags:              r.tags(),
+		Request:           new(DTO),
+		RequestMediaTypes: r.codecs().MediaTypes(),
+		Responses: append([]openapi.Response{
+			{Status: http.StatusCreated, Body: new(DTO), MediaTypes: r.codecs().MediaTypesFor((*DTO)(nil))},
+			textResponse(http.StatusBadRequest, "The body is malformed or the "+label+" is invalid"),
+			notAcceptableResponse,
+			textResponse(http.StatusConflict, "The "+label+" already exists"),
+		}, bindResponses...),
+	}
+}
+
+func (r *Resource[DTO, M, ID]) updateOperation() openapi.Operation {
+	label := r.label()
+	return openapi.Operation{
+		ID:                "update" + r.Name,
+		Summary:           "Update " + withArticle(label),
+		Tags:              r.tags(),
+		Parameters:        []openapi.Parameter{r.idParameter()},
+		Request:           new(DTO),
+		RequestMediaTypes: r.codecs().MediaTypes(),
+		Responses: append([]openapi.Response{
+			{Status: http.StatusOK, Body: new(DTO), MediaTypes: r.codecs().MediaTypesFor((*DTO)(nil))},
+			parameterErrorResponse(r.idError() + " or the " + label + " is invalid"),
+			textResponse(http.StatusNotFound, "The "+label+" does not exist"),
+			notAcceptableResponse,
+		}, bindResponses...),
+	}
+}
+
+func (r *Resource[DTO, M, ID]) deleteOperation() openapi.Operation {
+	label := r.label()
+	return openapi.Operation{
+		ID:         "delete" + r.Name,
+		Summary:    "Delete " + withArticle(label),
+		Tags:       r.tags(),
+		Parameters: []openapi.Parameter{r.idParameter()},
+		Responses: []openapi.Response{
+			{Status: http.StatusNoContent, Description: "The " + label + " was deleted"},
+			parameterErrorResponse(r.idError()),
+			textResponse(http.StatusNotFound, "The "+label+" does not exist"),
+		},
+	}
+}
+
+// idParameter documents the ID route parameter, an integer for integer IDs
+func (r *Resource[DTO, M, ID]) idParameter() openapi.Parameter {
+	schema := &openapi.Schema{Type: "string"}
+	if r.integerIDs() {
+		schema.Type = "integer"
+	}
+	return openapi.Parameter{Name: r.IDParam, In: "path", Required: true, Schema: schema}
+}
+
+// idError describes the 400 Bad Request of a malformed ID route parameter
+func (r *Resource[DTO, M, ID]) idError() string {
+	if r.integerIDs() {
+		return "The " + r.label() + " ID is not an integer"
+	}
+	return "The " + r.label() + " ID is malformed"
+}
+
+func (r *Resource[DTO, M, ID]) integerIDs() bool {
+	switch reflect.TypeOf((*ID)(nil)).Elem().Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func (r *Resource[DTO, M, ID]) plural() string {
+	if r.Plural == "" {
+		return r.Name + "s"
+	}
+	return r.Plural
+}
+
+// tags tags the operations with the plural, such as "orderLines"
+func (r *Resource[DTO, M, ID]) tags() []string {
+	plural := r.plural()
+	return []string{strings.ToLower(plural[:1]) + plural[1:]}
+}
+
+// label is the entity in prose, such as "order line"
+func (r *Resource[DTO, M, ID]) label() string {
+	return words(r.Name)
+}
+
+// pluralLabel is the entities in prose, such as "order lines"
+func (r *Resource[DTO, M, ID]) pluralLabel() string {
+	return words(r.plural())
+}
+
+// words splits a Go identifier such as OrderLine into lower case words
+func words(name string) string {
+	var b strings.Builder
+	for i, c := range name {
+		if unicode.IsUpper(c) {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			c = unicode.ToLower(c)
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// withArticle prefixes label with its indefinite article, such as "an order line".
+// Words starting with u take a, as in "a user".
+func withArticle(label string) string {
+	if strings.ContainsRune("aeio", rune(label[0])) {
+		return "an " + label
+	}
+	return "a " + label
+}
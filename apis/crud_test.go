@@ -0,0 +1,202 @@
+package apis_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jordantipton/golang-restful-webservice/apis"
+	"github.com/jordantipton/golang-restful-webservice/apis/openapi"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+)
+
+/*
+	Test objects
+*/
+
+type tag struct {
+	Slug  string
+	Label string
+}
+
+type tagDTO struct {
+	Slug  string `json:"slug" validate:"readonly"`
+	Label string `json:"label" validate:"required"`
+}
+
+type tagPage struct {
+	Tags          []tagDTO `json:"tags"`
+	NextPageToken string   `json:"nextPageToken,omitempty"`
+}
+
+// mockTagServicer keeps tags ordered by slug
+type mockTagServicer struct {
+	tags []*tag
+}
+
+func (m *mockTagServicer) Get(slug string) (*tag, error) {
+	for _, t := range m.tags {
+		if t.Slug == slug {
+			return t, nil
+		}
+	}
+	return nil, errors.NotFound{Message: "no such tag"}
+}
+
+func (m *mockTagServicer) GetMany(slugs []string) ([]*tag, error) { return nil, nil }
+
+func (m *mockTagServicer) List(options models.ListOptions[string]) ([]*tag, error) {
+	page := []*tag{}
+	for _, t := range m.tags {
+		if t.Slug > options.AfterID && strings.HasPrefix(t.Label, options.Prefix) && len(page) < options.Limit {
+			page = append(page, t)
+		}
+	}
+	return page, nil
+}
+
+func (m *mockTagServicer) Create(t *tag) (*tag, error) {
+	if t.Label == "taken" {
+		return nil, errors.AlreadyExists{Message: "Label is taken"}
+	}
+	t.Slug = strings.ToLower(t.Label)
+	m.tags = append(m.tags, t)
+	return t, nil
+}
+
+func (m *mockTagServicer) Update(t *tag) (*tag, error) { return t, nil }
+
+func (m *mockTagServicer) Delete(slug string) error {
+	_, err := m.Get(slug)
+	return err
+}
+
+func tagRouter(service *mockTagServicer) *chi.Mux {
+	r := chi.NewRouter()
+	apis.RegisterResource(r, "/tags", &apis.Resource[tagDTO, tag, string]{
+		Service: service,
+		Name:    "Tag",
+		IDParam: "slug",
+		ParseID: func(s string) (string, error) {
+			if s == "" {
+				return "", fmt.Errorf("Slug cannot be empty")
+			}
+			return s, nil
+		},
+		FormatID: func(slug string) string { return slug },
+		ID:       func(t *tag) string { return t.Slug },
+		SetID:    func(t *tag, slug string) { t.Slug = slug },
+		ToDTO:    func(t *tag) *tagDTO { return &tagDTO{Slug: t.Slug, Label: t.Label} },
+		FromDTO:  func(dto *tagDTO) *tag { return &tag{Label: dto.Label} },
+		NewPage: func(tags []tagDTO, nextPageToken string) interface{} {
+			return &tagPage{Tags: tags, NextPageToken: nextPageToken}
+		},
+		DefaultPageSize: 2,
+		PrefixParam:     "label_prefix",
+	})
+	return r
+}
+
+/*
+	Test functions
+*/
+
+func TestResourceListPages(t *testing.T) {
+	// Setup
+	service := &mockTagServicer{tags: []*tag{{"a", "Alpha"}, {"b", "Beta"}, {"c", "Gamma"}}}
+	r := tagRouter(service)
+	req := httptest.NewRequest("GET", "http://localhost:8080/tags", nil)
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 200 {
+		t.Fatalf("HTTP status code, expected: %d, got: %d", 200, w.Code)
+	}
+	page := tagPage{}
+	json.NewDecoder(w.Body).Decode(&page)
+	if len(page.Tags) != 2 || page.NextPageToken != "b" {
+		t.Fatalf("Page, expected: 2 tags and token b, got: %+v", page)
+	}
+
+	req = httptest.NewRequest("GET", "http://localhost:8080/tags?page_token=b", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	page = tagPage{}
+	json.NewDecoder(w.Body).Decode(&page)
+	if len(page.Tags) != 1 || page.Tags[0].Slug != "c" || page.NextPageToken != "" {
+		t.Errorf("Last page, expected: [c] without token, got: %+v", page)
+	}
+}
+
+func TestResourceGetNotFound(t *testing.T) {
+	// Setup
+	r := tagRouter(&mockTagServicer{})
+	req := httptest.NewRequest("GET", "http://localhost:8080/tags/missing", nil)
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 404 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 404, w.Code)
+	}
+	if body := w.Body.String(); body != "Tag with ID missing not found\n" {
+		t.Errorf("Body, expected: %q, got: %q", "Tag with ID missing not found\n", body)
+	}
+}
+
+func TestResourceCreateConflict(t *testing.T) {
+	// Setup
+	r := tagRouter(&mockTagServicer{})
+	req := httptest.NewRequest("POST", "http://localhost:8080/tags", strings.NewReader(`{"label":"taken"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 409 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 409, w.Code)
+	}
+}
+
+func TestResourceDocumentsRoutes(t *testing.T) {
+	// Setup
+	r := tagRouter(&mockTagServicer{})
+
+	// Execute
+	operations, undocumented, err := openapi.Collect(r)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Collect returned error: %s", err.Error())
+	}
+	if len(operations) != 5 || len(undocumented) != 0 {
+		t.Fatalf("Operations, expected: 5 documented routes, got: %v and undocumented %v", operations, undocumented)
+	}
+	get := operations["GET /tags/{slug}"]
+	if get.ID != "getTag" || get.Summary != "Get a tag" || get.Parameters[0].Schema.Type != "string" {
+		t.Errorf("GET /tags/{slug}, expected: getTag with a string slug, got: %+v", get)
+	}
+	list := operations["GET /tags"]
+	if list.ID != "listTags" || len(list.Parameters) != 3 || list.Parameters[2].Description != "Only list tags whose label starts with it" {
+		t.Errorf("GET /tags, expected: listTags with a label_prefix parameter, got: %+v", list)
+	}
+	if _, ok := list.Responses[0].Body.(*tagPage); !ok {
+		t.Errorf("GET /tags body, expected: *tagPage, got: %T", list.Responses[0].Body)
+	}
+	create := operations["POST /tags"]
+	if _, ok := create.Request.(*tagDTO); !ok || create.Tags[0] != "tags" {
+		t.Errorf("POST /tags, expected: a *tagDTO request tagged tags, got: %+v", create)
+	}
+}
@@ -0,0 +1,40 @@
+package dtos
+
+import "time"
+
+// Group represents a group dto. The validate tags apply to request bodies.
+type Group struct {
+	ID          int        `json:"id" xml:"id" validate:"readonly"`
+	Name        string     `json:"name" xml:"name" validate:"required,max=groupName"`
+	Description string     `json:"description" xml:"description" validate:"max=groupDescription"`
+	UpdatedAt   *time.Time `json:"updatedAt,omitempty" xml:"updatedAt,omitempty" validate:"readonly"`
+}
+
+// GroupPage represents a page of groups ordered by ID. NextPageToken is empty on the
+// last page.
+type GroupPage struct {
+	Groups        []Group `json:"groups" xml:"groups>group"`
+	NextPageToken string  `json:"nextPageToken,omitempty" xml:"nextPageToken,omitempty"`
+}
+
+// GroupMember represents a group membership dto. The group and user IDs are taken from
+// the route of requests.
+type GroupMember struct {
+	GroupID int    `json:"groupId" xml:"groupId" validate:"readonly"`
+	UserID  int    `json:"userId" xml:"userId" validate:"readonly"`
+	Role    string `json:"role" xml:"role" validate:"required,format=role"`
+}
+
+// GroupMemberPage represents a page of the members of a group ordered by user ID.
+// NextPageToken is empty on the last page.
+type GroupMemberPage struct {
+	Members       []GroupMember `json:"members" xml:"members>member"`
+	NextPageToken string        `json:"nextPageToken,omitempty" xml:"nextPageToken,omitempty"`
+}
+
+// UserGroupPage represents a page of the memberships of a user ordered by group ID.
+// NextPageToken is empty on the last page.
+type UserGroupPage struct {
+	Groups        []GroupMember `json:"groups" xml:"groups>group"`
+	NextPageToken string        `json:"nextPageToken,omitempty" xml:"nextPageToken,omitempty"`
+}
@@ -0,0 +1,13 @@
+package dtos
+
+// Job represents a background job dto. ArtifactURL is set once the job has succeeded
+// with a result file.
+type Job struct {
+	ID          int    `json:"id"`
+	Type        string `json:"type"`
+	Status      string `json:"status"`
+	Progress    int64  `json:"progress"`
+	Total       int64  `json:"total,omitempty"`
+	Error       string `json:"error,omitempty"`
+	ArtifactURL string `json:"artifactUrl,omitempty"`
+}
@@ -0,0 +1,18 @@
+package dtos
+
+// Problem represents an RFC 9457 problem details dto
+type Problem struct {
+	Type   string         `json:"type,omitempty"`
+	Title  string         `json:"title"`
+	Status int            `json:"status"`
+	Detail string         `json:"detail,omitempty"`
+	Errors []ProblemError `json:"errors,omitempty"`
+}
+
+// ProblemError represents an invalid field of a body, identified by its JSON pointer,
+// or an invalid request parameter
+type ProblemError struct {
+	Pointer   string `json:"pointer,omitempty"`
+	Parameter string `json:"parameter,omitempty"`
+	Detail    string `json:"detail"`
+}
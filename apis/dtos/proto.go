@@ -0,0 +1,57 @@
+package dtos
+
+import (
+	"github.com/jordantipton/golang-restful-webservice/proto/userspb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ToProto returns the protobuf representation of the user
+func (u *User) ToProto() proto.Message {
+	user := &userspb.User{Id: int64(u.ID), Name: u.Name, Email: u.Email, AvatarUrl: u.AvatarURL}
+	if u.UpdatedAt != nil {
+		user.UpdatedAt = timestamppb.New(*u.UpdatedAt)
+	}
+	return user
+}
+
+// NewProto returns an empty protobuf user
+func (u *User) NewProto() proto.Message {
+	return &userspb.User{}
+}
+
+// FromProto reads a protobuf user
+func (u *User) FromProto(message proto.Message) {
+	user := message.(*userspb.User)
+	u.ID, u.Name, u.Email, u.AvatarURL = int(user.GetId()), user.GetName(), user.GetEmail(), user.GetAvatarUrl()
+	u.UpdatedAt = nil
+	if user.GetUpdatedAt() != nil {
+		updatedAt := user.GetUpdatedAt().AsTime()
+		u.UpdatedAt = &updatedAt
+	}
+}
+
+// ToProto returns the protobuf representation of the batch
+func (b *UserBatch) ToProto() proto.Message {
+	batch := &userspb.UserBatch{}
+	for i := range b.Users {
+		batch.Users = append(batch.Users, b.Users[i].ToProto().(*userspb.User))
+	}
+	for _, userID := range b.MissingIDs {
+		batch.MissingIds = append(batch.MissingIds, int64(userID))
+	}
+	return batch
+}
+
+// NewProto returns an empty protobuf batch request
+func (b *UserBatchRequest) NewProto() proto.Message {
+	return &userspb.UserBatchRequest{}
+}
+
+// FromProto reads a protobuf batch request
+func (b *UserBatchRequest) FromProto(message proto.Message) {
+	b.IDs = nil
+	for _, userID := range message.(*userspb.UserBatchRequest).GetIds() {
+		b.IDs = append(b.IDs, int(userID))
+	}
+}
@@ -1,7 +1,30 @@
 package dtos
 
-// User represents a user dto
+import "time"
+
+// User represents a user dto. The validate tags apply to request bodies.
 type User struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID        int        `json:"id" xml:"id" validate:"readonly"`
+	Name      string     `json:"name" xml:"name" validate:"required,max=userName,format=username"`
+	Email     string     `json:"email,omitempty" xml:"email,omitempty" validate:"max=userEmail,format=email"`
+	AvatarURL string     `json:"avatarUrl,omitempty" xml:"avatarUrl,omitempty" validate:"readonly"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty" xml:"updatedAt,omitempty" validate:"readonly"`
+}
+
+// UserBatchRequest represents a batch lookup request dto
+type UserBatchRequest struct {
+	IDs []int `json:"ids" xml:"ids>id" validate:"required"`
+}
+
+// UserBatch represents the result of a batch lookup. Users are in request order.
+type UserBatch struct {
+	Users      []User `json:"users" xml:"users>user"`
+	MissingIDs []int  `json:"missingIds" xml:"missingIds>id"`
+}
+
+// UserPage represents a page of users ordered by ID. NextPageToken is empty on the last
+// page.
+type UserPage struct {
+	Users         []User `json:"users" xml:"users>user"`
+	NextPageToken string `json:"nextPageToken,omitempty" xml:"nextPageToken,omitempty"`
 }
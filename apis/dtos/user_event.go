@@ -0,0 +1,9 @@
+package dtos
+
+// UserEvent represents a user change event dto. Events sent over the WebSocket have no
+// sequence number.
+type UserEvent struct {
+	Seq  int64  `json:"seq,omitempty"`
+	Type string `json:"type"`
+	User User   `json:"user"`
+}
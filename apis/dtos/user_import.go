@@ -0,0 +1,21 @@
+package dtos
+
+// ImportReport represents the outcome of a bulk user import
+type ImportReport struct {
+	DryRun  bool        `json:"dryRun" xml:"dryRun"`
+	Created int         `json:"created" xml:"created"`
+	Updated int         `json:"updated" xml:"updated"`
+	Skipped int         `json:"skipped" xml:"skipped"`
+	Failed  int         `json:"failed" xml:"failed"`
+	Error   string      `json:"error,omitempty" xml:"error,omitempty"`
+	Rows    []ImportRow `json:"rows" xml:"rows>row"`
+}
+
+// ImportRow represents the outcome of a single imported row. Row numbers start at 1
+// and do not count the CSV header.
+type ImportRow struct {
+	Row    int    `json:"row" xml:"row"`
+	ID     int    `json:"id,omitempty" xml:"id,omitempty"`
+	Status string `json:"status" xml:"status"`
+	Error  string `json:"error,omitempty" xml:"error,omitempty"`
+}
@@ -0,0 +1,21 @@
+package dtos
+
+// UserSearchResults represents the users matching a search query, best first
+type UserSearchResults struct {
+	Results []UserSearchResult `json:"results" xml:"results>result"`
+}
+
+// UserSearchResult represents a user matching a search query. Highlights holds the
+// fields of the user with matches.
+type UserSearchResult struct {
+	User       User        `json:"user" xml:"user"`
+	Score      float64     `json:"score" xml:"score"`
+	Highlights []Highlight `json:"highlights" xml:"highlights>highlight"`
+}
+
+// Highlight represents a field matching a search query, as HTML with the matched words
+// in mark elements
+type Highlight struct {
+	Field string `json:"field" xml:"field"`
+	Text  string `json:"text" xml:"text"`
+}
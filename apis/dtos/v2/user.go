@@ -0,0 +1,33 @@
+// Package v2 holds the dtos of version 2 of the users routes. Version 2 represents user
+// IDs as strings, which JavaScript clients can hold without losing precision, and calls
+// the name of a user its displayName.
+package v2
+
+import "time"
+
+// User represents a user dto. The validate tags apply to request bodies.
+type User struct {
+	ID          string     `json:"id" xml:"id" validate:"readonly"`
+	DisplayName string     `json:"displayName" xml:"displayName" validate:"required,max=userName,format=username"`
+	Email       string     `json:"email,omitempty" xml:"email,omitempty" validate:"max=userEmail,format=email"`
+	AvatarURL   string     `json:"avatarUrl,omitempty" xml:"avatarUrl,omitempty" validate:"readonly"`
+	UpdatedAt   *time.Time `json:"updatedAt,omitempty" xml:"updatedAt,omitempty" validate:"readonly"`
+}
+
+// UserBatchRequest represents a batch lookup request dto
+type UserBatchRequest struct {
+	IDs []string `json:"ids" xml:"ids>id" validate:"required"`
+}
+
+// UserBatch represents the result of a batch lookup. Users are in request order.
+type UserBatch struct {
+	Users      []User   `json:"users" xml:"users>user"`
+	MissingIDs []string `json:"missingIds" xml:"missingIds>id"`
+}
+
+// UserPage represents a page of users ordered by ID. NextPageToken is empty on the last
+// page.
+type UserPage struct {
+	Users         []User `json:"users" xml:"users>user"`
+	NextPageToken string `json:"nextPageToken,omitempty" xml:"nextPageToken,omitempty"`
+}
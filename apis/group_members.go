@@ -0,0 +1,261 @@
+package apis
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/jordantipton/golang-restful-webservice/apis/codecs"
+	"github.com/jordantipton/golang-restful-webservice/apis/converters"
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+	"github.com/jordantipton/golang-restful-webservice/apis/openapi"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/services"
+)
+
+type (
+	// GroupMembersResource defines handlers for the memberships of users in groups.
+	// Representations are negotiated with Codecs, or codecs.Default if it is nil.
+	GroupMembersResource struct {
+		Service services.GroupMembersServicer
+		Codecs  *codecs.Registry
+	}
+)
+
+var (
+	// addGroupMemberOperation documents POST /groups/{groupID}/members/{userID}
+	addGroupMemberOperation = openapi.Operation{
+		ID:                "addGroupMember",
+		Summary:           "Add a user to a group",
+		Description:       "Changes the role of the user if they are already a member.",
+		Tags:              []string{"groups"},
+		Parameters:        []openapi.Parameter{groupIDParameter, userIDParameter},
+		Request:           &dtos.GroupMember{},
+		RequestMediaTypes: codecs.Default.MediaTypes(),
+		Responses: append([]openapi.Response{
+			{Status: http.StatusOK, Body: &dtos.GroupMember{}, MediaTypes: codecs.Default.MediaTypesFor((*dtos.GroupMember)(nil))},
+			parameterErrorResponse("The group or user ID is not an integer, or the role is invalid"),
+			textResponse(http.StatusNotFound, "The group or the user does not exist"),
+			notAcceptableResponse,
+		}, bindResponses...),
+	}
+
+	// removeGroupMemberOperation documents DELETE /groups/{groupID}/members/{userID}
+	removeGroupMemberOperation = openapi.Operation{
+		ID:         "removeGroupMember",
+		Summary:    "Remove a user from a group",
+		Tags:       []string{"groups"},
+		Parameters: []openapi.Parameter{groupIDParameter, userIDParameter},
+		Responses: []openapi.Response{
+			{Status: http.StatusNoContent, Description: "The user was removed from the group"},
+			parameterErrorResponse("The group or user ID is not an integer"),
+			textResponse(http.StatusNotFound, "The user is not a member of the group"),
+		},
+	}
+
+	// listGroupMembersOperation documents GET /groups/{groupID}/members
+	listGroupMembersOperation = openapi.Operation{
+		ID:         "listGroupMembers",
+		Summary:    "List the members of a group",
+		Tags:       []string{"groups"},
+		Parameters: append([]openapi.Parameter{groupIDParameter}, memberListParameters...),
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Body: &dtos.GroupMemberPage{}, MediaTypes: codecs.Default.MediaTypesFor((*dtos.GroupMemberPage)(nil))},
+			parameterErrorResponse("The group ID, page size or page token are malformed"),
+			textResponse(http.StatusNotFound, "The group does not exist"),
+			notAcceptableResponse,
+		},
+	}
+
+	// listUserGroupsOperation documents GET /users/{userID}/groups
+	listUserGroupsOperation = openapi.Operation{
+		ID:         "listUserGroups",
+		Summary:    "List the groups of a user",
+		Tags:       []string{"users", "groups"},
+		Parameters: append([]openapi.Parameter{userIDParameter}, memberListParameters...),
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Body: &dtos.UserGroupPage{}, MediaTypes: codecs.Default.MediaTypesFor((*dtos.UserGroupPage)(nil))},
+			parameterErrorResponse("The user ID, page size or page token are malformed"),
+			textResponse(http.StatusNotFound, "The user does not exist"),
+			notAcceptableResponse,
+		},
+	}
+)
+
+var memberListParameters = []openapi.Parameter{
+	{Name: "page_size", In: "query", Description: "Maximum number of memberships of a page, at most 1000", Schema: &openapi.Schema{Type: "integer"}},
+	{Name: "page_token", In: "query", Description: "The nextPageToken of the previous page", Schema: &openapi.Schema{Type: "string"}},
+}
+
+// RegisterGroupMembersResource sets up the routing of group membership endpoints and
+// handlers
+func RegisterGroupMembersResource(router chi.Router, service services.GroupMembersServicer) {
+	r := &GroupMembersResource{Service: service}
+	router.Method(http.MethodPost, "/groups/{groupID}/members/{userID}", openapi.Handler(addGroupMemberOperation, r.AddGroupMember))
+	router.Method(http.MethodDelete, "/groups/{groupID}/members/{userID}", openapi.Handler(removeGroupMemberOperation, r.RemoveGroupMember))
+	router.Method(http.MethodGet, "/groups/{groupID}/members", openapi.Handler(listGroupMembersOperation, r.ListGroupMembers))
+	router.Method(http.MethodGet, "/users/{userID}/groups", openapi.Handler(listUserGroupsOperation, r.ListUserGroups))
+}
+
+// AddGroupMember adds the user of the route to its group with the role of the body
+func (r *GroupMembersResource) AddGroupMember(res http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	codec, ok := negotiate(res, req, r.codecs(), (*dtos.GroupMember)(nil))
+	if !ok {
+		return
+	}
+	groupID, userID, ok := membershipIDs(res, req)
+	if !ok {
+		return
+	}
+	var dto dtos.GroupMember
+	if !bind(res, req, r.codecs(), &dto) {
+		return
+	}
+	member := converters.FromGroupMember(&dto)
+	member.GroupID, member.UserID = groupID, userID
+	member, err := r.Service.AddGroupMember(member)
+	if err != nil {
+		writeGroupMemberError(res, err)
+		return
+	}
+	render(res, codec, http.StatusOK, converters.ToGroupMember(member))
+}
+
+// RemoveGroupMember removes the user of the route from its group
+func (r *GroupMembersResource) RemoveGroupMember(res http.ResponseWriter, req *http.Request) {
+	groupID, userID, ok := membershipIDs(res, req)
+	if !ok {
+		return
+	}
+	if err := r.Service.RemoveGroupMember(groupID, userID); err != nil {
+		writeGroupMemberError(res, err)
+		return
+	}
+	res.WriteHeader(http.StatusNoContent)
+}
+
+// ListGroupMembers writes the page of the members of the group of the route that
+// follows the page_token query parameter
+func (r *GroupMembersResource) ListGroupMembers(res http.ResponseWriter, req *http.Request) {
+	codec, ok := negotiate(res, req, r.codecs(), (*dtos.GroupMemberPage)(nil))
+	if !ok {
+		return
+	}
+	groupID, err := parseGroupID(chi.URLParam(req, "groupID"))
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	options, ok := memberListOptions(res, req)
+	if !ok {
+		return
+	}
+	members, err := r.Service.ListGroupMembers(groupID, options)
+	if err != nil {
+		writeGroupMemberError(res, err)
+		return
+	}
+	page := dtos.GroupMemberPage{Members: []dtos.GroupMember{}}
+	for _, member := range members {
+		page.Members = append(page.Members, *converters.ToGroupMember(member))
+	}
+	if len(members) > 0 && len(members) >= options.Limit {
+		page.NextPageToken = strconv.Itoa(members[len(members)-1].UserID)
+	}
+	render(res, codec, http.StatusOK, &page)
+}
+
+// ListUserGroups writes the page of the memberships of the user of the route that
+// follows the page_token query parameter
+func (r *GroupMembersResource) ListUserGroups(res http.ResponseWriter, req *http.Request) {
+	codec, ok := negotiate(res, req, r.codecs(), (*dtos.UserGroupPage)(nil))
+	if !ok {
+		return
+	}
+	userID, err := parseUserID(chi.URLParam(req, "userID"))
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	options, ok := memberListOptions(res, req)
+	if !ok {
+		return
+	}
+	members, err := r.Service.ListUserGroups(userID, options)
+	if err != nil {
+		writeGroupMemberError(res, err)
+		return
+	}
+	page := dtos.UserGroupPage{Groups: []dtos.GroupMember{}}
+	for _, member := range members {
+		page.Groups = append(page.Groups, *converters.ToGroupMember(member))
+	}
+	if len(members) > 0 && len(members) >= options.Limit {
+		page.NextPageToken = strconv.Itoa(members[len(members)-1].GroupID)
+	}
+	render(res, codec, http.StatusOK, &page)
+}
+
+// membershipIDs parses the group and user IDs of the route. It responds 400 Bad
+// Request and returns false if one is invalid.
+func membershipIDs(res http.ResponseWriter, req *http.Request) (int, int, bool) {
+	groupID, err := parseGroupID(chi.URLParam(req, "groupID"))
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return 0, 0, false
+	}
+	userID, err := parseUserID(chi.URLParam(req, "userID"))
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return groupID, userID, true
+}
+
+// memberListOptions reads the page_size and page_token query parameters of req. It
+// responds 400 Bad Request and returns false if they are invalid.
+func memberListOptions(res http.ResponseWriter, req *http.Request) (models.ListOptions[int], bool) {
+	query := req.URL.Query()
+	options := models.ListOptions[int]{Limit: services.DefaultListGroupMembersLimit}
+	if pageSize := query.Get("page_size"); pageSize != "" {
+		limit, err := strconv.Atoi(pageSize)
+		if err != nil || limit <= 0 {
+			http.Error(res, "page_size must be a positive integer", http.StatusBadRequest)
+			return options, false
+		}
+		options.Limit = limit
+	}
+	if options.Limit > services.MaxListGroupMembersLimit {
+		options.Limit = services.MaxListGroupMembersLimit
+	}
+	if pageToken := query.Get("page_token"); pageToken != "" {
+		afterID, err := strconv.Atoi(pageToken)
+		if err != nil {
+			http.Error(res, "Invalid page token", http.StatusBadRequest)
+			return options, false
+		}
+		options.AfterID = afterID
+	}
+	return options, true
+}
+
+// writeGroupMemberError writes the response of a group membership service error
+func writeGroupMemberError(res http.ResponseWriter, err error) {
+	switch err.(type) {
+	case errors.NotFound:
+		http.Error(res, err.Error(), http.StatusNotFound)
+	case errors.InvalidArgument:
+		http.Error(res, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (r *GroupMembersResource) codecs() *codecs.Registry {
+	if r.Codecs == nil {
+		return codecs.Default
+	}
+	return r.Codecs
+}
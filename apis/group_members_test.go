@@ -0,0 +1,171 @@
+package apis_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jordantipton/golang-restful-webservice/apis"
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+)
+
+/*
+	Test objects
+*/
+
+type mockGroupMembersServicer struct {
+	mockAddGroupMember    func(member *models.GroupMember) (*models.GroupMember, error)
+	mockRemoveGroupMember func(groupID, userID int) error
+	mockListGroupMembers  func(groupID int, options models.ListOptions[int]) ([]*models.GroupMember, error)
+	mockListUserGroups    func(userID int, options models.ListOptions[int]) ([]*models.GroupMember, error)
+}
+
+func (m *mockGroupMembersServicer) AddGroupMember(member *models.GroupMember) (*models.GroupMember, error) {
+	if m.mockAddGroupMember != nil {
+		return m.mockAddGroupMember(member)
+	}
+	return member, nil
+}
+
+func (m *mockGroupMembersServicer) RemoveGroupMember(groupID, userID int) error {
+	if m.mockRemoveGroupMember != nil {
+		return m.mockRemoveGroupMember(groupID, userID)
+	}
+	return nil
+}
+
+func (m *mockGroupMembersServicer) ListGroupMembers(groupID int, options models.ListOptions[int]) ([]*models.GroupMember, error) {
+	if m.mockListGroupMembers != nil {
+		return m.mockListGroupMembers(groupID, options)
+	}
+	return nil, nil
+}
+
+func (m *mockGroupMembersServicer) ListUserGroups(userID int, options models.ListOptions[int]) ([]*models.GroupMember, error) {
+	if m.mockListUserGroups != nil {
+		return m.mockListUserGroups(userID, options)
+	}
+	return nil, nil
+}
+
+/*
+	Test functions
+*/
+
+func TestGroupMembersResource(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		body           string
+		service        mockGroupMembersServicer
+		expectedStatus int
+	}{
+		{name: "add", method: "POST", url: "/groups/1/members/2", body: `{"role":"owner"}`, expectedStatus: 200},
+		{name: "add with an invalid role", method: "POST", url: "/groups/1/members/2", body: `{"role":"admin"}`, expectedStatus: 422},
+		{name: "add with an invalid user ID", method: "POST", url: "/groups/1/members/bob", body: `{"role":"owner"}`, expectedStatus: 400},
+		{
+			name:   "add to a missing group",
+			method: "POST",
+			url:    "/groups/9/members/2",
+			body:   `{"role":"member"}`,
+			service: mockGroupMembersServicer{
+				mockAddGroupMember: func(member *models.GroupMember) (*models.GroupMember, error) {
+					return nil, errors.NotFound{Message: "Group with ID 9 not found"}
+				},
+			},
+			expectedStatus: 404,
+		},
+		{name: "remove", method: "DELETE", url: "/groups/1/members/2", expectedStatus: 204},
+		{
+			name:   "remove a non-member",
+			method: "DELETE",
+			url:    "/groups/1/members/3",
+			service: mockGroupMembersServicer{
+				mockRemoveGroupMember: func(groupID, userID int) error {
+					return errors.NotFound{Message: "User with ID 3 is not a member of group 1"}
+				},
+			},
+			expectedStatus: 404,
+		},
+		{name: "list members", method: "GET", url: "/groups/1/members", expectedStatus: 200},
+		{name: "list members with an invalid page token", method: "GET", url: "/groups/1/members?page_token=x", expectedStatus: 400},
+		{name: "list groups of a user", method: "GET", url: "/users/2/groups", expectedStatus: 200},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Setup
+			r := chi.NewRouter()
+			apis.RegisterGroupMembersResource(r, &test.service)
+			req := httptest.NewRequest(test.method, "http://localhost:8080"+test.url, strings.NewReader(test.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			// Execute
+			r.ServeHTTP(w, req)
+
+			// Assert
+			if w.Code != test.expectedStatus {
+				t.Errorf("HTTP status code, expected: %d, got: %d (%s)", test.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAddGroupMemberUsesRouteIDs(t *testing.T) {
+	// Setup
+	var added *models.GroupMember
+	r := chi.NewRouter()
+	apis.RegisterGroupMembersResource(r, &mockGroupMembersServicer{
+		mockAddGroupMember: func(member *models.GroupMember) (*models.GroupMember, error) {
+			added = member
+			return member, nil
+		},
+	})
+	req := httptest.NewRequest("POST", "http://localhost:8080/groups/4/members/7", strings.NewReader(`{"role":"member"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if added == nil || added.GroupID != 4 || added.UserID != 7 || added.Role != models.GroupRoleMember {
+		t.Fatalf("Added member, expected: group 4, user 7, member, got: %v", added)
+	}
+	var member dtos.GroupMember
+	json.NewDecoder(w.Body).Decode(&member)
+	if member.GroupID != 4 || member.UserID != 7 {
+		t.Errorf("Response, expected: group 4, user 7, got: %+v", member)
+	}
+}
+
+func TestListGroupMembersNextPageToken(t *testing.T) {
+	// Setup
+	r := chi.NewRouter()
+	apis.RegisterGroupMembersResource(r, &mockGroupMembersServicer{
+		mockListGroupMembers: func(groupID int, options models.ListOptions[int]) ([]*models.GroupMember, error) {
+			return []*models.GroupMember{
+				{GroupID: groupID, UserID: options.AfterID + 1, Role: models.GroupRoleOwner},
+				{GroupID: groupID, UserID: options.AfterID + 4, Role: models.GroupRoleMember},
+			}, nil
+		},
+	})
+	req := httptest.NewRequest("GET", "http://localhost:8080/groups/1/members?page_size=2&page_token=10", nil)
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	var page dtos.GroupMemberPage
+	json.NewDecoder(w.Body).Decode(&page)
+	if len(page.Members) != 2 || page.NextPageToken != "14" {
+		t.Errorf("Page, expected: 2 members and next page token 14, got: %+v", page)
+	}
+}
@@ -0,0 +1,64 @@
+package apis
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/jordantipton/golang-restful-webservice/apis/codecs"
+	"github.com/jordantipton/golang-restful-webservice/apis/converters"
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+	"github.com/jordantipton/golang-restful-webservice/apis/openapi"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/services"
+)
+
+type (
+	// GroupsResource defines handlers for the group APIs. Representations are
+	// negotiated with Codecs, or codecs.Default if it is nil.
+	GroupsResource struct {
+		Service services.GroupsServicer
+		Codecs  *codecs.Registry
+	}
+)
+
+var groupIDParameter = openapi.Parameter{Name: "groupID", In: "path", Required: true, Schema: &openapi.Schema{Type: "integer"}}
+
+// RegisterGroupsResource sets up the routing of group endpoints and handlers
+func RegisterGroupsResource(router chi.Router, service services.GroupsServicer) {
+	r := &GroupsResource{Service: service}
+	RegisterResource(router, "/groups", r.crud())
+}
+
+// crud returns the generic resource of the group routes
+func (r *GroupsResource) crud() *Resource[dtos.Group, models.Group, int] {
+	return &Resource[dtos.Group, models.Group, int]{
+		Service:  r.Service,
+		Codecs:   r.Codecs,
+		Name:     "Group",
+		IDParam:  "groupID",
+		ParseID:  parseGroupID,
+		FormatID: strconv.Itoa,
+		ID:       func(group *models.Group) int { return group.ID },
+		SetID:    func(group *models.Group, groupID int) { group.ID = groupID },
+		ToDTO:    converters.ToGroup,
+		FromDTO:  converters.FromGroup,
+		NewPage: func(groups []dtos.Group, nextPageToken string) interface{} {
+			return &dtos.GroupPage{Groups: groups, NextPageToken: nextPageToken}
+		},
+		ModifiedAt:      func(group *models.Group) time.Time { return group.UpdatedAt },
+		DefaultPageSize: services.DefaultListGroupsLimit,
+		MaxPageSize:     services.MaxListGroupsLimit,
+		PrefixParam:     "name_prefix",
+	}
+}
+
+// parseGroupID parses the group ID of a route or page token
+func parseGroupID(s string) (int, error) {
+	groupID, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errors.InvalidArgument{Message: "GroupID must be an integer"}
+	}
+	return groupID, nil
+}
@@ -0,0 +1,191 @@
+package apis_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jordantipton/golang-restful-webservice/apis"
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+)
+
+/*
+	Test objects
+*/
+
+type mockGroupsServicer struct {
+	mockGet     func(groupID int) (*models.Group, error)
+	mockGetMany func(groupIDs []int) ([]*models.Group, error)
+	mockList    func(options models.ListOptions[int]) ([]*models.Group, error)
+	mockCreate  func(group *models.Group) (*models.Group, error)
+	mockUpdate  func(group *models.Group) (*models.Group, error)
+	mockDelete  func(groupID int) error
+}
+
+func (m *mockGroupsServicer) Get(groupID int) (*models.Group, error) {
+	if m.mockGet != nil {
+		return m.mockGet(groupID)
+	}
+	return nil, nil
+}
+
+func (m *mockGroupsServicer) GetMany(groupIDs []int) ([]*models.Group, error) {
+	if m.mockGetMany != nil {
+		return m.mockGetMany(groupIDs)
+	}
+	return nil, nil
+}
+
+func (m *mockGroupsServicer) List(options models.ListOptions[int]) ([]*models.Group, error) {
+	if m.mockList != nil {
+		return m.mockList(options)
+	}
+	return nil, nil
+}
+
+func (m *mockGroupsServicer) Create(group *models.Group) (*models.Group, error) {
+	if m.mockCreate != nil {
+		return m.mockCreate(group)
+	}
+	return nil, nil
+}
+
+func (m *mockGroupsServicer) Update(group *models.Group) (*models.Group, error) {
+	if m.mockUpdate != nil {
+		return m.mockUpdate(group)
+	}
+	return nil, nil
+}
+
+func (m *mockGroupsServicer) Delete(groupID int) error {
+	if m.mockDelete != nil {
+		return m.mockDelete(groupID)
+	}
+	return nil
+}
+
+func sampleGroup() *models.Group {
+	return &models.Group{
+		ID:          1,
+		Name:        "Sample",
+		Description: "Sample",
+	}
+}
+
+/*
+	Test functions
+*/
+
+func TestGroupsResource(t *testing.T) {
+	body, _ := json.Marshal(dtos.Group{
+		Name:        "Sample",
+		Description: "Sample",
+	})
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		body           []byte
+		service        mockGroupsServicer
+		expectedStatus int
+	}{
+		{
+			name:   "get",
+			method: "GET",
+			url:    "/groups/1",
+			service: mockGroupsServicer{
+				mockGet: func(groupID int) (*models.Group, error) { return sampleGroup(), nil },
+			},
+			expectedStatus: 200,
+		},
+		{
+			name:           "get with an invalid ID",
+			method:         "GET",
+			url:            "/groups/nan",
+			expectedStatus: 400,
+		},
+		{
+			name:   "get missing",
+			method: "GET",
+			url:    "/groups/2",
+			service: mockGroupsServicer{
+				mockGet: func(groupID int) (*models.Group, error) {
+					return nil, errors.NotFound{Message: "Group with ID 2 not found"}
+				},
+			},
+			expectedStatus: 404,
+		},
+		{
+			name:   "list",
+			method: "GET",
+			url:    "/groups?page_size=10",
+			service: mockGroupsServicer{
+				mockList: func(options models.ListOptions[int]) ([]*models.Group, error) {
+					return []*models.Group{sampleGroup()}, nil
+				},
+			},
+			expectedStatus: 200,
+		},
+		{
+			name:   "create",
+			method: "POST",
+			url:    "/groups",
+			body:   body,
+			service: mockGroupsServicer{
+				mockCreate: func(group *models.Group) (*models.Group, error) { return sampleGroup(), nil },
+			},
+			expectedStatus: 201,
+		},
+		{
+			name:   "create invalid",
+			method: "POST",
+			url:    "/groups",
+			body:   body,
+			service: mockGroupsServicer{
+				mockCreate: func(group *models.Group) (*models.Group, error) {
+					return nil, errors.InvalidArgument{Message: "invalid"}
+				},
+			},
+			expectedStatus: 400,
+		},
+		{
+			name:   "update",
+			method: "PUT",
+			url:    "/groups/1",
+			body:   body,
+			service: mockGroupsServicer{
+				mockUpdate: func(group *models.Group) (*models.Group, error) { return group, nil },
+			},
+			expectedStatus: 200,
+		},
+		{
+			name:           "delete",
+			method:         "DELETE",
+			url:            "/groups/1",
+			expectedStatus: 204,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Setup
+			r := chi.NewRouter()
+			apis.RegisterGroupsResource(r, &test.service)
+			req := httptest.NewRequest(test.method, "http://localhost:8080"+test.url, bytes.NewReader(test.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			// Execute
+			r.ServeHTTP(w, req)
+
+			// Assert
+			if w.Code != test.expectedStatus {
+				t.Errorf("HTTP status code, expected: %d, got: %d (%s)", test.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
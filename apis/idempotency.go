@@ -0,0 +1,265 @@
+package apis
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/jordantipton/golang-restful-webservice/apis/openapi"
+	"github.com/jordantipton/golang-restful-webservice/cache"
+)
+
+// IdempotencyKeyHeader names the key of a POST request that may be retried, such as
+// "Idempotency-Key: 8e03978e40d5"
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const (
+	// IdempotencyKeyTTL is how long the response to a key is replayed
+	IdempotencyKeyTTL = 24 * time.Hour
+	// maxIdempotencyKeyLength bounds the keys kept in the store
+	maxIdempotencyKeyLength = 255
+)
+
+// IdempotentUserRoutes are the route patterns of the user creations, which clients
+// retry with an Idempotency-Key
+var IdempotentUserRoutes = []string{"/users", "/" + V1.Name + "/users", "/" + V2.Name + "/users"}
+
+type (
+	// idempotentResponse is the response stored for an Idempotency-Key. Pending is set
+	// while the first request with the key runs.
+	idempotentResponse struct {
+		Fingerprint []byte      `json:"fingerprint"`
+		Pending     bool        `json:"pending,omitempty"`
+		Status      int         `json:"status,omitempty"`
+		Header      http.Header `json:"header,omitempty"`
+		Body        []byte      `json:"body,omitempty"`
+	}
+
+	// idempotencyWriter records the response it writes
+	idempotencyWriter struct {
+		http.ResponseWriter
+		status int
+		body   bytes.Buffer
+	}
+)
+
+// Idempotency returns middleware that applies POST requests to the route patterns of
+// routes once per Idempotency-Key header. The response to the first request with a key
+// is kept in store for IdempotencyKeyTTL and replayed, with an Idempotent-Replayed
+// header, to the requests that repeat the key, so that a client can retry a create that
+// may have been applied. Requests repeating a key that is still running are rejected
+// with 409 Conflict and those reusing a key for another body with 422 Unprocessable
+// Entity. 5xx responses are not kept so that their retries run again. Requests without
+// the header run every time.
+func Idempotency(store cache.Store, routes ...string) func(http.Handler) http.Handler {
+	idempotent := map[string]bool{}
+	for _, route := range routes {
+		idempotent[route] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			key := req.Header.Get(IdempotencyKeyHeader)
+			if req.Method != http.MethodPost || key == "" {
+				next.ServeHTTP(res, req)
+				return
+			}
+			route, ok := matchRoute(req)
+			if !ok || !idempotent[route] {
+				next.ServeHTTP(res, req)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				http.Error(res, IdempotencyKeyHeader+" is longer than 255 characters", http.StatusBadRequest)
+				return
+			}
+			// Read as much as bind accepts, and leave the rest for bind to reject
+			body, err := io.ReadAll(io.LimitReader(req.Body, MaxRequestBodySize+1))
+			if err != nil {
+				http.Error(res, err.Error(), http.StatusBadRequest)
+				return
+			}
+			req.Body = &readCloser{Reader: io.MultiReader(bytes.NewReader(body), req.Body), Closer: req.Body}
+			fingerprint := sha256.Sum256(body)
+
+			storeKey := "idempotency:" + route + ":" + key
+			stored, found, err := loadIdempotentResponse(store, storeKey)
+			if err != nil {
+				log.Printf("Idempotency-Key lookup failed: %s", err)
+				http.Error(res, "The Idempotency-Key cannot be checked, retry later", http.StatusServiceUnavailable)
+				return
+			}
+			if found {
+				switch {
+				case !bytes.Equal(stored.Fingerprint, fingerprint[:]):
+					http.Error(res, IdempotencyKeyHeader+" was used for another request body", http.StatusUnprocessableEntity)
+				case stored.Pending:
+					res.Header().Set("Retry-After", "1")
+					http.Error(res, "A request with this "+IdempotencyKeyHeader+" is in progress", http.StatusConflict)
+				default:
+					stored.replay(res)
+				}
+				return
+			}
+			// The key is reserved atomically, so that of concurrent requests repeating it
+			// only one runs
+			reserved, err := reserveIdempotencyKey(store, storeKey, fingerprint[:])
+			if err != nil {
+				log.Printf("Idempotency-Key reservation failed: %s", err)
+				http.Error(res, "The Idempotency-Key cannot be checked, retry later", http.StatusServiceUnavailable)
+				return
+			}
+			if !reserved {
+				res.Header().Set("Retry-After", "1")
+				http.Error(res, "A request with this "+IdempotencyKeyHeader+" is in progress", http.StatusConflict)
+				return
+			}
+
+			writer := &idempotencyWriter{ResponseWriter: res}
+			completed := false
+			defer func() {
+				if !completed || writer.status >= http.StatusInternalServerError {
+					if err := store.Delete(storeKey); err != nil {
+						log.Printf("Idempotency-Key release failed: %s", err)
+					}
+				}
+			}()
+			next.ServeHTTP(writer, req)
+			completed = true
+			if writer.status == 0 {
+				writer.status = http.StatusOK
+			}
+			if writer.status >= http.StatusInternalServerError {
+				return
+			}
+			response := &idempotentResponse{Fingerprint: fingerprint[:], Status: writer.status, Header: replayedHeader(res.Header()), Body: writer.body.Bytes()}
+			if err := saveIdempotentResponse(store, storeKey, response); err != nil {
+				log.Printf("Idempotency-Key response not saved: %s", err)
+			}
+		})
+	}
+}
+
+// WithIdempotency returns operations documenting IdempotencyKeyHeader and its responses
+// on the POST routes of routes
+func WithIdempotency(operations openapi.Operations, routes ...string) openapi.Operations {
+	idempotentOperations := openapi.Operations{}
+	for key, operation := range operations {
+		for _, route := range routes {
+			if key != http.MethodPost+" "+route {
+				continue
+			}
+			operation.Parameters = append(append([]openapi.Parameter{}, operation.Parameters...), idempotencyKeyParameter)
+			responses := append([]openapi.Response{}, operation.Responses...)
+			for _, response := range idempotencyResponses {
+				if !hasResponse(responses, response.Status) {
+					responses = append(responses, response)
+				}
+			}
+			operation.Responses = responses
+		}
+		idempotentOperations[key] = operation
+	}
+	return idempotentOperations
+}
+
+var (
+	idempotencyKeyParameter = openapi.Parameter{
+		Name:        IdempotencyKeyHeader,
+		In:          "header",
+		Description: "Unique key of the request, at most 255 characters. Retries with the same key and body get the response of the first request.",
+		Schema:      &openapi.Schema{Type: "string"},
+	}
+	idempotencyResponses = []openapi.Response{
+		textResponse(http.StatusConflict, "A request with the Idempotency-Key is in progress"),
+		textResponse(http.StatusUnprocessableEntity, "The Idempotency-Key was used for another request body"),
+		textResponse(http.StatusServiceUnavailable, "The Idempotency-Key cannot be checked"),
+	}
+)
+
+// WriteHeader records status
+func (w *idempotencyWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write records b
+func (w *idempotencyWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (w *idempotencyWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// replay writes the stored response
+func (r *idempotentResponse) replay(res http.ResponseWriter) {
+	for name, values := range r.Header {
+		res.Header()[name] = values
+	}
+	res.Header().Set("Idempotent-Replayed", "true")
+	res.WriteHeader(r.Status)
+	res.Write(r.Body)
+}
+
+// replayedHeader returns the headers of header that describe the response body and the
+// created resource
+func replayedHeader(header http.Header) http.Header {
+	replayed := http.Header{}
+	for _, name := range []string{"Content-Type", "Location", "Last-Modified", VersionHeader} {
+		if values := header.Values(name); len(values) > 0 {
+			replayed[name] = values
+		}
+	}
+	return replayed
+}
+
+// matchRoute returns the pattern of the route of req
+func matchRoute(req *http.Request) (string, bool) {
+	routeContext := chi.RouteContext(req.Context())
+	match := chi.NewRouteContext()
+	if routeContext == nil || !routeContext.Routes.Match(match, req.Method, req.URL.Path) {
+		return "", false
+	}
+	return match.RoutePattern(), true
+}
+
+func loadIdempotentResponse(store cache.Store, key string) (*idempotentResponse, bool, error) {
+	data, found, err := store.Get(key)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	var response idempotentResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, false, err
+	}
+	return &response, true, nil
+}
+
+// reserveIdempotencyKey stores a pending response under key unless one is stored
+func reserveIdempotencyKey(store cache.Store, key string, fingerprint []byte) (bool, error) {
+	data, err := json.Marshal(&idempotentResponse{Fingerprint: fingerprint, Pending: true})
+	if err != nil {
+		return false, err
+	}
+	return store.SetNX(key, data, IdempotencyKeyTTL)
+}
+
+func saveIdempotentResponse(store cache.Store, key string, response *idempotentResponse) error {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	return store.Set(key, data, IdempotencyKeyTTL)
+}
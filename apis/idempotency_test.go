@@ -0,0 +1,186 @@
+package apis_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jordantipton/golang-restful-webservice/apis"
+	"github.com/jordantipton/golang-restful-webservice/cache"
+	"github.com/jordantipton/golang-restful-webservice/models"
+)
+
+/*
+	Test objects
+*/
+
+// idempotentRouter serves the users resource behind Idempotency
+func idempotentRouter(service *mockUsersServicer) *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(apis.Idempotency(&cache.LRU{}, apis.IdempotentUserRoutes...))
+	apis.RegisterUsersResource(r, service)
+	return r
+}
+
+// slowLookupStore delays lookups so that concurrent requests all look up a key before
+// any of them stores it
+type slowLookupStore struct {
+	*cache.LRU
+}
+
+func (s slowLookupStore) Get(key string) ([]byte, bool, error) {
+	value, found, err := s.LRU.Get(key)
+	time.Sleep(20 * time.Millisecond)
+	return value, found, err
+}
+
+func createUserRequest(key, body string) *http.Request {
+	req := httptest.NewRequest("POST", "http://localhost:8080/users", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(apis.IdempotencyKeyHeader, key)
+	return req
+}
+
+/*
+	Test functions
+*/
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	// Setup
+	created := 0
+	r := idempotentRouter(&mockUsersServicer{
+		mockCreateUser: func(user *models.User) (*models.User, error) {
+			created++
+			user.ID = created
+			return user, nil
+		},
+	})
+	first := httptest.NewRecorder()
+	r.ServeHTTP(first, createUserRequest("key-1", `{"name":"Bob"}`))
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, createUserRequest("key-1", `{"name":"Bob"}`))
+
+	// Assert
+	if created != 1 {
+		t.Errorf("Users created, expected: 1, got: %d", created)
+	}
+	if w.Code != http.StatusCreated || w.Body.String() != first.Body.String() {
+		t.Errorf("Response, expected: %d %s, got: %d %s", http.StatusCreated, first.Body.String(), w.Code, w.Body.String())
+	}
+	if w.Header().Get("Idempotent-Replayed") != "true" || w.Header().Get("Content-Type") != first.Header().Get("Content-Type") {
+		t.Errorf("Headers, expected: Idempotent-Replayed and the Content-Type of the first response, got: %v", w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, createUserRequest("key-2", `{"name":"Bob"}`))
+	if created != 2 {
+		t.Errorf("Users created with another key, expected: 2, got: %d", created)
+	}
+}
+
+func TestIdempotencyRejectsAnotherBody(t *testing.T) {
+	// Setup
+	r := idempotentRouter(&mockUsersServicer{
+		mockCreateUser: func(user *models.User) (*models.User, error) {
+			user.ID = 1
+			return user, nil
+		},
+	})
+	r.ServeHTTP(httptest.NewRecorder(), createUserRequest("key-1", `{"name":"Bob"}`))
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, createUserRequest("key-1", `{"name":"Alice"}`))
+
+	// Assert
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("HTTP status code, expected: %d, got: %d", http.StatusUnprocessableEntity, w.Code)
+	}
+}
+
+func TestIdempotencyRunsRetryOfServerError(t *testing.T) {
+	// Setup
+	attempts := 0
+	r := idempotentRouter(&mockUsersServicer{
+		mockCreateUser: func(user *models.User) (*models.User, error) {
+			attempts++
+			if attempts == 1 {
+				return nil, fmt.Errorf("database unavailable")
+			}
+			user.ID = 1
+			return user, nil
+		},
+	})
+	first := httptest.NewRecorder()
+	r.ServeHTTP(first, createUserRequest("key-1", `{"name":"Bob"}`))
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, createUserRequest("key-1", `{"name":"Bob"}`))
+
+	// Assert
+	if first.Code != http.StatusInternalServerError || w.Code != http.StatusCreated {
+		t.Errorf("HTTP status codes, expected: 500 then 201, got: %d then %d", first.Code, w.Code)
+	}
+	if attempts != 2 {
+		t.Errorf("Attempts, expected: 2, got: %d", attempts)
+	}
+}
+
+func TestIdempotencyRunsConcurrentRequestsOnce(t *testing.T) {
+	// Setup
+	const requests = 10
+	var created int32
+	release := make(chan struct{})
+	r := chi.NewRouter()
+	r.Use(apis.Idempotency(slowLookupStore{&cache.LRU{}}, apis.IdempotentUserRoutes...))
+	apis.RegisterUsersResource(r, &mockUsersServicer{
+		mockCreateUser: func(user *models.User) (*models.User, error) {
+			user.ID = int(atomic.AddInt32(&created, 1))
+			<-release
+			return user, nil
+		},
+	})
+	codes := make(chan int, requests)
+
+	// Execute
+	for i := 0; i < requests; i++ {
+		go func() {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, createUserRequest("key-1", `{"name":"Bob"}`))
+			codes <- w.Code
+		}()
+	}
+	// The create blocks until the other requests have been answered
+	conflicts := 0
+	timeout := time.After(5 * time.Second)
+	for conflicts < requests-1 {
+		select {
+		case code := <-codes:
+			if code != http.StatusConflict {
+				t.Errorf("HTTP status code of a concurrent request, expected: %d, got: %d", http.StatusConflict, code)
+			}
+			conflicts++
+		case <-timeout:
+			t.Errorf("Concurrent requests, expected: %d answered with %d, got: %d", requests-1, http.StatusConflict, conflicts)
+			conflicts = requests
+		}
+	}
+	close(release)
+
+	// Assert
+	if code := <-codes; code != http.StatusCreated && conflicts == requests-1 {
+		t.Errorf("HTTP status code of the first request, expected: %d, got: %d", http.StatusCreated, code)
+	}
+	if n := atomic.LoadInt32(&created); n != 1 {
+		t.Errorf("Users created, expected: 1, got: %d", n)
+	}
+}
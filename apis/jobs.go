@@ -0,0 +1,160 @@
+package apis
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/jordantipton/golang-restful-webservice/apis/converters"
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+	"github.com/jordantipton/golang-restful-webservice/apis/openapi"
+	"github.com/jordantipton/golang-restful-webservice/jobs"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+)
+
+type (
+	// JobsResource defines handlers for tracking background jobs
+	JobsResource struct {
+		Jobs jobs.Scheduler
+	}
+)
+
+var (
+	// getJobOperation documents GET /jobs/{jobID}
+	getJobOperation = openapi.Operation{
+		ID:         "getJob",
+		Summary:    "Get a background job",
+		Tags:       []string{"jobs"},
+		Parameters: []openapi.Parameter{jobIDParameter},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Body: &dtos.Job{}},
+			parameterErrorResponse("The job ID is not an integer"),
+			textResponse(http.StatusNotFound, "The job does not exist"),
+		},
+	}
+
+	// cancelJobOperation documents DELETE /jobs/{jobID}
+	cancelJobOperation = openapi.Operation{
+		ID:         "cancelJob",
+		Summary:    "Cancel a background job",
+		Tags:       []string{"jobs"},
+		Parameters: []openapi.Parameter{jobIDParameter},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Description: "The job is cancelled or had already finished", Body: &dtos.Job{}},
+			{Status: http.StatusAccepted, Description: "The running job is stopping", Body: &dtos.Job{}},
+			parameterErrorResponse("The job ID is not an integer"),
+			textResponse(http.StatusNotFound, "The job does not exist"),
+		},
+	}
+
+	// getJobArtifactOperation documents GET /jobs/{jobID}/artifact
+	getJobArtifactOperation = openapi.Operation{
+		ID:         "getJobArtifact",
+		Summary:    "Download the result file of a succeeded job",
+		Tags:       []string{"jobs"},
+		Parameters: []openapi.Parameter{jobIDParameter},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, MediaTypes: []string{"application/json", "text/csv", "application/x-ndjson", "application/vnd.apache.parquet"}},
+			parameterErrorResponse("The job ID is not an integer"),
+			textResponse(http.StatusNotFound, "The job does not exist or has no artifact"),
+		},
+	}
+)
+
+// RegisterJobsResource sets up the routing of job endpoints and handlers
+func RegisterJobsResource(router chi.Router, scheduler jobs.Scheduler) {
+	r := &JobsResource{Jobs: scheduler}
+	router.Method(http.MethodGet, "/jobs/{jobID}", openapi.Handler(getJobOperation, r.GetJob))
+	router.Method(http.MethodDelete, "/jobs/{jobID}", openapi.Handler(cancelJobOperation, r.CancelJob))
+	router.Method(http.MethodGet, "/jobs/{jobID}/artifact", openapi.Handler(getJobArtifactOperation, r.GetJobArtifact))
+}
+
+// GetJob by ID
+func (r *JobsResource) GetJob(res http.ResponseWriter, req *http.Request) {
+	jobID, ok := jobIDParam(res, req)
+	if !ok {
+		return
+	}
+	job, err := r.Jobs.GetJob(jobID)
+	if err != nil {
+		writeJobError(res, err)
+		return
+	}
+	res.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(res).Encode(converters.ToJob(job))
+}
+
+// CancelJob by ID. Responds 202 while a running job is stopping and 200 otherwise.
+func (r *JobsResource) CancelJob(res http.ResponseWriter, req *http.Request) {
+	jobID, ok := jobIDParam(res, req)
+	if !ok {
+		return
+	}
+	job, err := r.Jobs.CancelJob(jobID)
+	if err != nil {
+		writeJobError(res, err)
+		return
+	}
+	res.Header().Set("Content-Type", "application/json")
+	if job.Status == models.JobRunning {
+		res.WriteHeader(http.StatusAccepted)
+	}
+	json.NewEncoder(res).Encode(converters.ToJob(job))
+}
+
+// GetJobArtifact downloads the result file of a succeeded job
+func (r *JobsResource) GetJobArtifact(res http.ResponseWriter, req *http.Request) {
+	jobID, ok := jobIDParam(res, req)
+	if !ok {
+		return
+	}
+	job, file, err := r.Jobs.OpenArtifact(jobID)
+	if err != nil {
+		writeJobError(res, err)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res.Header().Set("Content-Type", job.ArtifactType)
+	res.Header().Set("Content-Disposition", "attachment; filename=\""+job.ArtifactName+"\"")
+	http.ServeContent(res, req, job.ArtifactName, info.ModTime(), file)
+}
+
+// writeJobAccepted responds to the submission of job with its location
+func writeJobAccepted(res http.ResponseWriter, job *models.Job) {
+	res.Header().Set("Location", fmt.Sprintf("/jobs/%d", job.ID))
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(res).Encode(converters.ToJob(job))
+}
+
+func jobIDParam(res http.ResponseWriter, req *http.Request) (int, bool) {
+	jobID, err := strconv.Atoi(chi.URLParam(req, "jobID"))
+	if err != nil {
+		http.Error(res, "JobID must be an integer", http.StatusBadRequest)
+		return 0, false
+	}
+	return jobID, true
+}
+
+func writeJobError(res http.ResponseWriter, err error) {
+	if bodyTooLarge(err) {
+		http.Error(res, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	switch err.(type) {
+	case errors.NotFound:
+		http.Error(res, err.Error(), http.StatusNotFound)
+	case errors.InvalidArgument:
+		http.Error(res, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+	}
+}
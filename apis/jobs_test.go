@@ -0,0 +1,158 @@
+package apis_test
+
+import (
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jordantipton/golang-restful-webservice/apis"
+	models "github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+)
+
+/*
+	Test objects
+*/
+
+type mockScheduler struct {
+	mockSubmit       func(jobType string, params interface{}, input io.Reader) (*models.Job, error)
+	mockGetJob       func(jobID int) (*models.Job, error)
+	mockCancelJob    func(jobID int) (*models.Job, error)
+	mockOpenArtifact func(jobID int) (*models.Job, *os.File, error)
+}
+
+func (m *mockScheduler) Submit(jobType string, params interface{}, input io.Reader) (*models.Job, error) {
+	if m.mockSubmit != nil {
+		return m.mockSubmit(jobType, params, input)
+	}
+	return &models.Job{ID: 1, Type: jobType, Status: models.JobQueued}, nil
+}
+
+func (m *mockScheduler) GetJob(jobID int) (*models.Job, error) {
+	if m.mockGetJob != nil {
+		return m.mockGetJob(jobID)
+	}
+	return nil, nil
+}
+
+func (m *mockScheduler) CancelJob(jobID int) (*models.Job, error) {
+	if m.mockCancelJob != nil {
+		return m.mockCancelJob(jobID)
+	}
+	return nil, nil
+}
+
+func (m *mockScheduler) OpenArtifact(jobID int) (*models.Job, *os.File, error) {
+	if m.mockOpenArtifact != nil {
+		return m.mockOpenArtifact(jobID)
+	}
+	return nil, nil, nil
+}
+
+func serveJobs(scheduler *mockScheduler, method, path string) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	apis.RegisterJobsResource(r, scheduler)
+
+	req := httptest.NewRequest(method, "http://localhost:8080"+path, nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+/*
+	Test functions
+*/
+
+func TestGetJob(t *testing.T) {
+	// Setup
+	scheduler := &mockScheduler{
+		mockGetJob: func(jobID int) (*models.Job, error) {
+			return &models.Job{ID: jobID, Type: "users.export", Status: models.JobSucceeded, Progress: 3, Total: 3, ArtifactName: "users.csv"}, nil
+		},
+	}
+
+	// Execute
+	w := serveJobs(scheduler, "GET", "/jobs/4")
+
+	// Assert
+	if w.Code != 200 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 200, w.Code)
+	}
+	expectedBody := `{"id":4,"type":"users.export","status":"succeeded","progress":3,"total":3,"artifactUrl":"/jobs/4/artifact"}` + "\n"
+	if body := w.Body.String(); body != expectedBody {
+		t.Errorf("Response body, expected: %s, got: %s", expectedBody, body)
+	}
+}
+
+func TestGetJobNotFound(t *testing.T) {
+	// Setup
+	scheduler := &mockScheduler{
+		mockGetJob: func(jobID int) (*models.Job, error) {
+			return nil, errors.NotFound{Message: "Job with ID 4 not found"}
+		},
+	}
+
+	// Execute
+	w := serveJobs(scheduler, "GET", "/jobs/4")
+
+	// Assert
+	if w.Code != 404 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 404, w.Code)
+	}
+}
+
+func TestCancelRunningJob(t *testing.T) {
+	// Setup
+	var cancelled int
+	scheduler := &mockScheduler{
+		mockCancelJob: func(jobID int) (*models.Job, error) {
+			cancelled = jobID
+			return &models.Job{ID: jobID, Status: models.JobRunning}, nil
+		},
+	}
+
+	// Execute
+	w := serveJobs(scheduler, "DELETE", "/jobs/9")
+
+	// Assert
+	if w.Code != 202 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 202, w.Code)
+	}
+	if cancelled != 9 {
+		t.Errorf("Cancelled job, expected: %d, got: %d", 9, cancelled)
+	}
+}
+
+func TestGetJobArtifact(t *testing.T) {
+	// Setup
+	path := filepath.Join(t.TempDir(), "artifact")
+	os.WriteFile(path, []byte("id,name\n1,Bob\n"), 0o644)
+	scheduler := &mockScheduler{
+		mockOpenArtifact: func(jobID int) (*models.Job, *os.File, error) {
+			file, err := os.Open(path)
+			return &models.Job{ID: jobID, ArtifactName: "users.csv", ArtifactType: "text/csv"}, file, err
+		},
+	}
+
+	// Execute
+	w := serveJobs(scheduler, "GET", "/jobs/2/artifact")
+
+	// Assert
+	if w.Code != 200 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 200, w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "text/csv" {
+		t.Errorf("Content-Type, expected: text/csv, got: %s", contentType)
+	}
+	if disposition := w.Header().Get("Content-Disposition"); !strings.Contains(disposition, "users.csv") {
+		t.Errorf("Content-Disposition, expected to name users.csv, got: %s", disposition)
+	}
+	if body := w.Body.String(); body != "id,name\n1,Bob\n" {
+		t.Errorf("Response body, expected: the artifact, got: %q", body)
+	}
+}
@@ -0,0 +1,189 @@
+package apis
+
+import (
+	"embed"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/go-chi/chi"
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+	"github.com/jordantipton/golang-restful-webservice/apis/openapi"
+)
+
+type (
+	// OpenAPIResource serves the OpenAPI document of Routes and a page to browse it.
+	// The document is built on first use, once all routes are registered, from the
+	// operations the routes were registered with.
+	OpenAPIResource struct {
+		Info   openapi.Info
+		Routes chi.Routes
+		// Adjust changes the operations of Routes before they are documented, such as
+		// WithTenant. It is optional.
+		Adjust func(openapi.Operations) openapi.Operations
+
+		once     sync.Once
+		document *openapi.Document
+		body     []byte
+		err      error
+	}
+)
+
+// APIInfo describes the REST API in its OpenAPI document
+var APIInfo = openapi.Info{
+	Title:       "Users API",
+	Version:     "1.0.0",
+	Description: "Manage users, their groups, imports, exports and change feeds.",
+}
+
+// docsAssets holds the page of /docs and the Redoc bundle it loads, which is served
+// from here rather than a CDN. The bundle is pinned to Redoc 2.1.5 and committed along
+// with its license; go generate refreshes both. TestGetDocsScript fails without it.
+//
+//go:generate curl -fsSL -o docs/redoc.standalone.js https://cdn.redoc.ly/redoc/v2.1.5/bundles/redoc.standalone.js
+//go:generate curl -fsSL -o docs/redoc.LICENSE https://raw.githubusercontent.com/Redocly/redoc/v2.1.5/LICENSE
+//go:embed docs
+var docsAssets embed.FS
+
+var (
+	userIDParameter = openapi.Parameter{Name: "userID", In: "path", Required: true, Schema: &openapi.Schema{Type: "integer"}}
+	jobIDParameter  = openapi.Parameter{Name: "jobID", In: "path", Required: true, Schema: &openapi.Schema{Type: "integer"}}
+
+	importMediaTypes = []string{"text/csv", "application/x-ndjson"}
+	importParameters = []openapi.Parameter{
+		{Name: "on_conflict", In: "query", Description: "What to do with rows whose ID exists: fail, skip or update", Schema: &openapi.Schema{Type: "string", Enum: []string{"fail", "skip", "update"}}},
+		{Name: "dry_run", In: "query", Description: "Validate the rows without writing them", Schema: &openapi.Schema{Type: "boolean"}},
+	}
+	listParameters = []openapi.Parameter{
+		{Name: "page_size", In: "query", Description: "Maximum number of users of a page, at most 1000", Schema: &openapi.Schema{Type: "integer"}},
+		{Name: "page_token", In: "query", Description: "The nextPageToken of the previous page", Schema: &openapi.Schema{Type: "string"}},
+		{Name: "name_prefix", In: "query", Description: "Only list users whose name starts with it", Schema: &openapi.Schema{Type: "string"}},
+	}
+	exportParameters = []openapi.Parameter{
+		{Name: "format", In: "query", Description: "File format, csv by default", Schema: &openapi.Schema{Type: "string", Enum: []string{ExportCSV, ExportNDJSON, ExportParquet}}},
+		{Name: "name_prefix", In: "query", Description: "Only export users whose name starts with it", Schema: &openapi.Schema{Type: "string"}},
+	}
+
+	// bindResponses are the errors of bodies read with bind
+	bindResponses = []openapi.Response{
+		textResponse(http.StatusRequestEntityTooLarge, "The body is larger than 1 MiB"),
+		textResponse(http.StatusUnsupportedMediaType, "The Content-Type is not supported"),
+		{Status: http.StatusUnprocessableEntity, Description: "Fields of the body are unknown, of the wrong type or invalid", Body: &dtos.Problem{}, MediaTypes: []string{"application/problem+json"}},
+	}
+	notAcceptableResponse = textResponse(http.StatusNotAcceptable, "No representation matches the Accept header")
+	jobAcceptedResponse   = openapi.Response{Status: http.StatusAccepted, Description: "The job was queued", Body: &dtos.Job{}, Headers: map[string]string{"Location": "URL of the job"}}
+)
+
+var (
+	// getOpenAPIOperation documents GET /openapi.json
+	getOpenAPIOperation = openapi.Operation{
+		ID:      "getOpenAPI",
+		Summary: "Get this OpenAPI document",
+		Tags:    []string{"docs"},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, MediaTypes: []string{"application/json"}},
+		},
+	}
+
+	// getDocsOperation documents GET /docs
+	getDocsOperation = openapi.Operation{
+		ID:      "getDocs",
+		Summary: "Browse this OpenAPI document",
+		Tags:    []string{"docs"},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, MediaTypes: []string{"text/html"}},
+		},
+	}
+
+	// getDocsScriptOperation documents GET /docs/redoc.standalone.js
+	getDocsScriptOperation = openapi.Operation{
+		ID:      "getDocsScript",
+		Summary: "Get the Redoc script of the docs page",
+		Tags:    []string{"docs"},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, MediaTypes: []string{"text/javascript"}},
+		},
+	}
+)
+
+// RegisterOpenAPIResource serves the OpenAPI document of r at /openapi.json and a page
+// to browse it at /docs. Routes that were not registered with an openapi.Handler are
+// left out of the document.
+func RegisterOpenAPIResource(router chi.Router, r *OpenAPIResource) {
+	router.Method(http.MethodGet, "/openapi.json", openapi.Handler(getOpenAPIOperation, r.GetDocument))
+	router.Method(http.MethodGet, "/docs", openapi.Handler(getDocsOperation, r.GetDocs))
+	router.Method(http.MethodGet, "/docs/redoc.standalone.js", openapi.Handler(getDocsScriptOperation, r.GetDocsScript))
+}
+
+// RouteOperations returns the operations of the documented routes of routes, with the
+// IDs of versioned routes prefixed by their version and the unversioned routes of a
+// deprecated successor marked deprecated, and the undocumented routes
+func RouteOperations(routes chi.Routes) (openapi.Operations, []string, error) {
+	operations, undocumented, err := openapi.Collect(routes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return documentVersions(operations), undocumented, nil
+}
+
+// Document returns the OpenAPI document, building it on the first call
+func (r *OpenAPIResource) Document() (*openapi.Document, error) {
+	r.once.Do(func() {
+		var operations openapi.Operations
+		if operations, _, r.err = RouteOperations(r.Routes); r.err != nil {
+			return
+		}
+		if r.Adjust != nil {
+			operations = r.Adjust(operations)
+		}
+		if r.document, _, r.err = openapi.Build(r.Info, r.Routes, operations); r.err == nil {
+			r.body, r.err = json.Marshal(r.document)
+		}
+	})
+	return r.document, r.err
+}
+
+// GetDocument writes the OpenAPI document
+func (r *OpenAPIResource) GetDocument(res http.ResponseWriter, req *http.Request) {
+	if _, err := r.Document(); err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res.Header().Set("Content-Type", "application/json")
+	res.Write(r.body)
+}
+
+// GetDocs writes a page that renders /openapi.json. It only runs the scripts of this
+// service.
+func (r *OpenAPIResource) GetDocs(res http.ResponseWriter, req *http.Request) {
+	page, err := docsAssets.ReadFile("docs/index.html")
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	res.Header().Set("Content-Security-Policy", "script-src 'self'; worker-src blob:")
+	res.Write(page)
+}
+
+// GetDocsScript writes the Redoc bundle of the docs page
+func (r *OpenAPIResource) GetDocsScript(res http.ResponseWriter, req *http.Request) {
+	script, err := docsAssets.ReadFile("docs/redoc.standalone.js")
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	res.Write(script)
+}
+
+// textResponse documents a plain text error written with http.Error
+func textResponse(status int, description string) openapi.Response {
+	return openapi.Response{Status: status, Description: description, Text: true}
+}
+
+// parameterErrorResponse documents the 400 Bad Request of a route with parameters.
+// ValidateOpenAPI rejects invalid parameters with a problem and handlers with text.
+func parameterErrorResponse(description string) openapi.Response {
+	return openapi.Response{Status: http.StatusBadRequest, Description: description, Body: &dtos.Problem{}, MediaTypes: []string{"application/problem+json"}, Text: true}
+}
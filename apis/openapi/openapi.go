@@ -0,0 +1,421 @@
+// Package openapi builds an OpenAPI 3.1 document from the routes of a chi router and
+// the documentation of their operations. Routes registered with a Handler carry their
+// operation, so the document follows the registered routes. Schemas are derived from
+// the dto types: their json tags name the properties and their validate tags add the
+// constraints.
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/jordantipton/golang-restful-webservice/apis/validation"
+)
+
+// Version of the OpenAPI specification of the documents
+const Version = "3.1.0"
+
+type (
+	// Info describes the API
+	Info struct {
+		Title       string `json:"title"`
+		Version     string `json:"version"`
+		Description string `json:"description,omitempty"`
+	}
+
+	// Operations documents routes by method and chi route pattern, such as
+	// "GET /users/{userID}"
+	Operations map[string]Operation
+
+	// Operation documents a route
+	Operation struct {
+		ID          string
+		Summary     string
+		Description string
+		Tags        []string
+		Deprecated  bool
+		// Parameters of the route. Undocumented path parameters are described as strings.
+		Parameters []Parameter
+		// Request is a value of the request body type, or nil. Bodies of other media
+		// types, such as CSV uploads, are described as strings.
+		Request interface{}
+		// RequestMediaTypes default to application/json
+		RequestMediaTypes []string
+		Responses         []Response
+	}
+
+	// DocumentedHandler is the handler of a route along with the operation documenting
+	// it. Collect finds the operations of the routes registered with one.
+	DocumentedHandler struct {
+		http.Handler
+		Operation Operation
+	}
+
+	// AnyOf is a Request or response Body that is a value of any of its types
+	AnyOf []interface{}
+
+	// Parameter documents a path, query or header parameter
+	Parameter struct {
+		Name        string  `json:"name"`
+		In          string  `json:"in"`
+		Description string  `json:"description,omitempty"`
+		Required    bool    `json:"required,omitempty"`
+		Schema      *Schema `json:"schema"`
+	}
+
+	// Response documents a response status of an operation
+	Response struct {
+		Status      int
+		Description string
+		// Body is a value of the response body type, or nil. Bodies of other media types,
+		// such as plain text errors and file downloads, are described as strings.
+		Body interface{}
+		// MediaTypes default to application/json when Body is set
+		MediaTypes []string
+		// Text adds a text/plain body, such as the message of http.Error
+		Text bool
+		// Headers maps the names of response headers to their descriptions
+		Headers map[string]string
+	}
+
+	// Schema is a JSON Schema
+	Schema struct {
+		Ref                  string             `json:"$ref,omitempty"`
+		Type                 string             `json:"type,omitempty"`
+		Format               string             `json:"format,omitempty"`
+		Description          string             `json:"description,omitempty"`
+		Properties           map[string]*Schema `json:"properties,omitempty"`
+		Required             []string           `json:"required,omitempty"`
+		Items                *Schema            `json:"items,omitempty"`
+		AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+		AnyOf                []*Schema          `json:"anyOf,omitempty"`
+		Enum                 []string           `json:"enum,omitempty"`
+		ReadOnly             bool               `json:"readOnly,omitempty"`
+		MinLength            *int               `json:"minLength,omitempty"`
+		MaxLength            *int               `json:"maxLength,omitempty"`
+		MinItems             *int               `json:"minItems,omitempty"`
+		MaxItems             *int               `json:"maxItems,omitempty"`
+		Minimum              *int               `json:"minimum,omitempty"`
+		Maximum              *int               `json:"maximum,omitempty"`
+	}
+
+	// Document is an OpenAPI document. Paths map route templates to the operations of
+	// each lower case method.
+	Document struct {
+		OpenAPI    string                                 `json:"openapi"`
+		Info       Info                                   `json:"info"`
+		Paths      map[string]map[string]*OperationObject `json:"paths"`
+		Components Components                             `json:"components"`
+	}
+
+	// Components holds the schemas of the named dto types
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	}
+
+	// OperationObject is the OpenAPI representation of an Operation
+	OperationObject struct {
+		OperationID string                     `json:"operationId,omitempty"`
+		Summary     string                     `json:"summary,omitempty"`
+		Description string                     `json:"description,omitempty"`
+		Tags        []string                   `json:"tags,omitempty"`
+		Deprecated  bool                       `json:"deprecated,omitempty"`
+		Parameters  []Parameter                `json:"parameters,omitempty"`
+		RequestBody *RequestBodyObject         `json:"requestBody,omitempty"`
+		Responses   map[string]*ResponseObject `json:"responses"`
+	}
+
+	// RequestBodyObject is the OpenAPI representation of a request body
+	RequestBodyObject struct {
+		Required bool                        `json:"required"`
+		Content  map[string]*MediaTypeObject `json:"content"`
+	}
+
+	// ResponseObject is the OpenAPI representation of a Response
+	ResponseObject struct {
+		Description string                      `json:"description"`
+		Headers     map[string]*HeaderObject    `json:"headers,omitempty"`
+		Content     map[string]*MediaTypeObject `json:"content,omitempty"`
+	}
+
+	// HeaderObject is the OpenAPI representation of a response header
+	HeaderObject struct {
+		Description string  `json:"description,omitempty"`
+		Schema      *Schema `json:"schema"`
+	}
+
+	// MediaTypeObject is the OpenAPI representation of a body of one media type
+	MediaTypeObject struct {
+		Schema *Schema `json:"schema"`
+	}
+
+	schemaBuilder struct {
+		components map[string]*Schema
+	}
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	pathParamsRe = regexp.MustCompile(`{([^}:]+)(:[^}]*)?}`)
+	// versionPackageRe matches package names such as v2
+	versionPackageRe = regexp.MustCompile(`^v[0-9]+$`)
+)
+
+// Build documents the routes of router with operations. Routes that operations does
+// not document are left out of the document and returned, sorted, as "METHOD pattern".
+func Build(info Info, router chi.Routes, operations Operations) (*Document, []string, error) {
+	document := &Document{
+		OpenAPI:    Version,
+		Info:       info,
+		Paths:      map[string]map[string]*OperationObject{},
+		Components: Components{Schemas: map[string]*Schema{}},
+	}
+	builder := &schemaBuilder{components: document.Components.Schemas}
+	var undocumented []string
+	err := chi.Walk(router, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		key := method + " " + route
+		operation, ok := operations[key]
+		if !ok {
+			undocumented = append(undocumented, key)
+			return nil
+		}
+		path := pathParamsRe.ReplaceAllString(route, "{$1}")
+		if document.Paths[path] == nil {
+			document.Paths[path] = map[string]*OperationObject{}
+		}
+		document.Paths[path][strings.ToLower(method)] = builder.operation(route, operation)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(undocumented)
+	return document, undocumented, nil
+}
+
+// Handler returns handler documented by operation, to register with the Method of a
+// chi router, such as router.Method(http.MethodGet, "/users", openapi.Handler(op, fn))
+func Handler(operation Operation, handler http.HandlerFunc) *DocumentedHandler {
+	return &DocumentedHandler{Handler: handler, Operation: operation}
+}
+
+// Collect returns the operations of the routes of router that were registered with a
+// DocumentedHandler, by "METHOD pattern", and the other routes, sorted
+func Collect(router chi.Routes) (Operations, []string, error) {
+	operations := Operations{}
+	var undocumented []string
+	err := chi.Walk(router, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		key := method + " " + route
+		if documented, ok := handler.(*DocumentedHandler); ok {
+			operations[key] = documented.Operation
+		} else {
+			undocumented = append(undocumented, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(undocumented)
+	return operations, undocumented, nil
+}
+
+// schemaOf returns the schema of the type of v. Struct types are defined in the
+// components of the document and referenced.
+func (b *schemaBuilder) schemaOf(v interface{}) *Schema {
+	if alternatives, ok := v.(AnyOf); ok {
+		schema := &Schema{}
+		for _, alternative := range alternatives {
+			schema.AnyOf = append(schema.AnyOf, b.schemaOf(alternative))
+		}
+		return schema
+	}
+	return b.schema(reflect.TypeOf(v))
+}
+
+func (b *schemaBuilder) operation(route string, operation Operation) *OperationObject {
+	object := &OperationObject{
+		OperationID: operation.ID,
+		Summary:     operation.Summary,
+		Description: operation.Description,
+		Tags:        operation.Tags,
+		Deprecated:  operation.Deprecated,
+		Parameters:  append([]Parameter(nil), operation.Parameters...),
+		Responses:   map[string]*ResponseObject{},
+	}
+	for _, match := range pathParamsRe.FindAllStringSubmatch(route, -1) {
+		if !hasParameter(operation.Parameters, match[1]) {
+			object.Parameters = append(object.Parameters, Parameter{Name: match[1], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+	if operation.Request != nil || len(operation.RequestMediaTypes) > 0 {
+		object.RequestBody = &RequestBodyObject{Required: true, Content: b.content(operation.Request, operation.RequestMediaTypes)}
+	}
+	for _, response := range operation.Responses {
+		responseObject := &ResponseObject{Description: response.Description}
+		if response.Description == "" {
+			responseObject.Description = http.StatusText(response.Status)
+		}
+		if response.Body != nil || len(response.MediaTypes) > 0 {
+			responseObject.Content = b.content(response.Body, response.MediaTypes)
+		}
+		if response.Text {
+			if responseObject.Content == nil {
+				responseObject.Content = map[string]*MediaTypeObject{}
+			}
+			responseObject.Content["text/plain"] = &MediaTypeObject{Schema: &Schema{Type: "string"}}
+		}
+		for name, description := range response.Headers {
+			if responseObject.Headers == nil {
+				responseObject.Headers = map[string]*HeaderObject{}
+			}
+			responseObject.Headers[name] = &HeaderObject{Description: description, Schema: &Schema{Type: "string"}}
+		}
+		object.Responses[strconv.Itoa(response.Status)] = responseObject
+	}
+	return object
+}
+
+func (b *schemaBuilder) content(body interface{}, mediaTypes []string) map[string]*MediaTypeObject {
+	if len(mediaTypes) == 0 {
+		mediaTypes = []string{"application/json"}
+	}
+	schema := &Schema{Type: "string"}
+	if body != nil {
+		schema = b.schemaOf(body)
+	}
+	content := map[string]*MediaTypeObject{}
+	for _, mediaType := range mediaTypes {
+		content[mediaType] = &MediaTypeObject{Schema: schema}
+	}
+	return content
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schema(t.Elem())}
+	case reflect.Struct:
+		name := componentName(t)
+		if _, ok := b.components[name]; !ok {
+			// Reserve the name first so that recursive types terminate
+			b.components[name] = &Schema{}
+			*b.components[name] = *b.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{}
+}
+
+func (b *schemaBuilder) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, ok := validation.FieldName(field)
+		if !ok {
+			continue
+		}
+		property := b.schema(field.Type)
+		if tag, ok := field.Tag.Lookup("validate"); ok {
+			if property.Ref != "" {
+				// Sibling keywords of $ref apply in OpenAPI 3.1, but keep the shared
+				// component unchanged
+				property = &Schema{Ref: property.Ref}
+			}
+			if applyRules(property, tag) {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+		schema.Properties[name] = property
+	}
+	return schema
+}
+
+// applyRules adds the constraints of the validate rules in tag to schema and returns
+// whether the field is required
+func applyRules(schema *Schema, tag string) bool {
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		name, argument, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		limit, _ := validation.Limit(argument)
+		switch name {
+		case "required":
+			required = true
+		case "readonly":
+			schema.ReadOnly = true
+		case "min":
+			switch schema.Type {
+			case "string":
+				schema.MinLength = &limit
+			case "array":
+				schema.MinItems = &limit
+			default:
+				schema.Minimum = &limit
+			}
+		case "max":
+			switch schema.Type {
+			case "string":
+				schema.MaxLength = &limit
+			case "array":
+				schema.MaxItems = &limit
+			default:
+				schema.Maximum = &limit
+			}
+		case "format":
+			schema.Format = argument
+			if format, ok := validation.Formats[argument]; ok {
+				schema.Description = fmt.Sprintf("Must be a %s: it %s", argument, format.Detail)
+			}
+		}
+	}
+	return required
+}
+
+// componentName names the schema of a struct type after the type. Types of packages
+// named after an API version, such as v2, are prefixed with it, like V2User, so that
+// they do not collide with the types of other versions.
+func componentName(t reflect.Type) string {
+	pkg := path.Base(t.PkgPath())
+	if versionPackageRe.MatchString(pkg) {
+		return strings.ToUpper(pkg[:1]) + pkg[1:] + t.Name()
+	}
+	return t.Name()
+}
+
+func hasParameter(parameters []Parameter, name string) bool {
+	for _, parameter := range parameters {
+		if parameter.In == "path" && parameter.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package openapi_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+
+	"github.com/jordantipton/golang-restful-webservice/apis/openapi"
+)
+
+/*
+	Test objects
+*/
+
+type widget struct {
+	ID    int      `json:"id" validate:"readonly"`
+	Name  string   `json:"name" validate:"required,max=10"`
+	Parts []widget `json:"parts,omitempty" validate:"max=3"`
+	Notes string   `json:"-"`
+}
+
+func noop(res http.ResponseWriter, req *http.Request) {}
+
+/*
+	Test functions
+*/
+
+func TestBuild(t *testing.T) {
+	// Setup
+	router := chi.NewRouter()
+	router.Get("/widgets/{widgetID}", noop)
+	router.Post("/widgets", noop)
+	router.Delete("/widgets/{widgetID}", noop)
+	operations := openapi.Operations{
+		"GET /widgets/{widgetID}": {
+			ID:        "getWidget",
+			Responses: []openapi.Response{{Status: http.StatusOK, Body: &widget{}}},
+		},
+		"POST /widgets": {
+			ID:        "createWidget",
+			Request:   &widget{},
+			Responses: []openapi.Response{{Status: http.StatusCreated, Body: &widget{}}},
+		},
+	}
+
+	// Execute
+	document, undocumented, err := openapi.Build(openapi.Info{Title: "Widgets"}, router, operations)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Build returned error: %s", err.Error())
+	}
+	if len(undocumented) != 1 || undocumented[0] != "DELETE /widgets/{widgetID}" {
+		t.Errorf("Undocumented, expected: [DELETE /widgets/{widgetID}], got: %v", undocumented)
+	}
+	get := document.Paths["/widgets/{widgetID}"]["get"]
+	if get == nil || len(get.Parameters) != 1 || get.Parameters[0].Name != "widgetID" || get.Parameters[0].In != "path" {
+		t.Fatalf("GET parameters, expected: [widgetID in path], got: %+v", get)
+	}
+	if ref := get.Responses["200"].Content["application/json"].Schema.Ref; ref != "#/components/schemas/widget" {
+		t.Errorf("Response schema, expected: #/components/schemas/widget, got: %s", ref)
+	}
+	schema := document.Components.Schemas["widget"]
+	if len(schema.Properties) != 3 {
+		t.Errorf("Properties, expected: %d, got: %d", 3, len(schema.Properties))
+	}
+	if len(schema.Required) != 1 || schema.Required[0] != "name" {
+		t.Errorf("Required, expected: [name], got: %v", schema.Required)
+	}
+	if !schema.Properties["id"].ReadOnly {
+		t.Errorf("Expected id to be read-only")
+	}
+	if maxLength := schema.Properties["name"].MaxLength; maxLength == nil || *maxLength != 10 {
+		t.Errorf("Name maxLength, expected: 10, got: %v", maxLength)
+	}
+	if maxItems := schema.Properties["parts"].MaxItems; maxItems == nil || *maxItems != 3 {
+		t.Errorf("Parts maxItems, expected: 3, got: %v", maxItems)
+	}
+	if ref := schema.Properties["parts"].Items.Ref; ref != "#/components/schemas/widget" {
+		t.Errorf("Parts items, expected: #/components/schemas/widget, got: %s", ref)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	// Setup
+	router := chi.NewRouter()
+	router.Method(http.MethodGet, "/widgets/{widgetID}", openapi.Handler(openapi.Operation{ID: "getWidget"}, noop))
+	router.Route("/v1", func(r chi.Router) {
+		r.With(middleware.NoCache).Method(http.MethodPost, "/widgets", openapi.Handler(openapi.Operation{ID: "createWidget"}, noop))
+	})
+	router.Delete("/widgets/{widgetID}", noop)
+
+	// Execute
+	operations, undocumented, err := openapi.Collect(router)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Collect returned error: %s", err.Error())
+	}
+	if len(operations) != 2 || operations["GET /widgets/{widgetID}"].ID != "getWidget" || operations["POST /v1/widgets"].ID != "createWidget" {
+		t.Errorf("Operations, expected: getWidget and createWidget, got: %v", operations)
+	}
+	if len(undocumented) != 1 || undocumented[0] != "DELETE /widgets/{widgetID}" {
+		t.Errorf("Undocumented, expected: [DELETE /widgets/{widgetID}], got: %v", undocumented)
+	}
+}
@@ -0,0 +1,281 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jordantipton/golang-restful-webservice/apis/validation"
+)
+
+// Operation returns the documented operation of a method and chi route pattern, or
+// nil if the route is not documented
+func (d *Document) Operation(method, route string) *OperationObject {
+	pathItem := d.Paths[pathParamsRe.ReplaceAllString(route, "{$1}")]
+	if pathItem == nil {
+		return nil
+	}
+	return pathItem[strings.ToLower(method)]
+}
+
+// ValidateParameters checks the path parameters in pathParams and the query and header
+// parameters of req against operation
+func (d *Document) ValidateParameters(operation *OperationObject, req *http.Request, pathParams map[string]string) validation.Errors {
+	var errs validation.Errors
+	query := req.URL.Query()
+	for _, parameter := range operation.Parameters {
+		var value string
+		var ok bool
+		switch parameter.In {
+		case "path":
+			value, ok = pathParams[parameter.Name]
+		case "query":
+			value = query.Get(parameter.Name)
+			ok = value != ""
+		case "header":
+			value = req.Header.Get(parameter.Name)
+			ok = value != ""
+		}
+		if !ok {
+			if parameter.Required {
+				errs = append(errs, validation.Error{Parameter: parameter.Name, Detail: "is required"})
+			}
+			continue
+		}
+		if detail := checkParameter(parameter.Schema, value); detail != "" {
+			errs = append(errs, validation.Error{Parameter: parameter.Name, Detail: detail})
+		}
+	}
+	return errs
+}
+
+// ValidateRequestBody checks that operation accepts contentType and that a JSON body is
+// valid for its schema. It returns false if operation has no body of contentType.
+// Malformed JSON is left for the handler to reject.
+func (d *Document) ValidateRequestBody(operation *OperationObject, contentType string, body []byte) (validation.Errors, bool) {
+	if operation.RequestBody == nil {
+		return nil, true
+	}
+	mediaType, ok := matchContent(operation.RequestBody.Content, contentType)
+	if !ok {
+		return nil, false
+	}
+	errs, _ := d.validateJSON(mediaType, body)
+	return errs, true
+}
+
+// ValidateResponse checks that operation documents the status and Content-Type of a
+// response and that a JSON body is valid for its schema
+func (d *Document) ValidateResponse(operation *OperationObject, status int, header http.Header, body []byte) validation.Errors {
+	response, ok := operation.Responses[strconv.Itoa(status)]
+	if !ok {
+		response, ok = operation.Responses["default"]
+	}
+	if !ok {
+		return validation.Errors{{Detail: fmt.Sprintf("status %d is not documented", status)}}
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	contentType := header.Get("Content-Type")
+	mediaType, ok := matchContent(response.Content, contentType)
+	if !ok {
+		return validation.Errors{{Detail: fmt.Sprintf("Content-Type %q is not documented for status %d", contentType, status)}}
+	}
+	errs, err := d.validateJSON(mediaType, body)
+	if err != nil {
+		return validation.Errors{{Detail: "body is not valid JSON: " + err.Error()}}
+	}
+	return errs
+}
+
+// ValidateValue checks a decoded JSON value against schema. Numbers must be decoded as
+// json.Number.
+func (d *Document) ValidateValue(schema *Schema, value interface{}) validation.Errors {
+	var errs validation.Errors
+	d.checkValue(&errs, "", schema, value)
+	return errs
+}
+
+// validateJSON checks body against the schema of mediaType if it is a JSON media type.
+// It returns an error if body is not valid JSON.
+func (d *Document) validateJSON(mediaType *MediaTypeObject, body []byte) (validation.Errors, error) {
+	if mediaType.Schema == nil || mediaType.Schema.Type == "string" {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return d.ValidateValue(mediaType.Schema, value), nil
+}
+
+func (d *Document) checkValue(errs *validation.Errors, pointer string, schema *Schema, value interface{}) {
+	if schema.Ref != "" {
+		resolved, ok := d.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+		if !ok {
+			*errs = append(*errs, validation.Error{Pointer: pointer, Detail: "has an unknown schema " + schema.Ref})
+			return
+		}
+		schema = resolved
+	}
+	if len(schema.AnyOf) > 0 {
+		// Report the violations of the closest alternative
+		var closest validation.Errors
+		for i, alternative := range schema.AnyOf {
+			var alternativeErrs validation.Errors
+			d.checkValue(&alternativeErrs, pointer, alternative, value)
+			if len(alternativeErrs) == 0 {
+				return
+			}
+			if i == 0 || len(alternativeErrs) < len(closest) {
+				closest = alternativeErrs
+			}
+		}
+		*errs = append(*errs, closest...)
+		return
+	}
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, validation.Error{Pointer: pointer, Detail: fmt.Sprintf(format, args...)})
+	}
+	switch typed := value.(type) {
+	case nil:
+		if schema.Type != "" {
+			fail("must be %s, not null", schema.Type)
+		}
+	case bool:
+		if schema.Type != "" && schema.Type != "boolean" {
+			fail("must be %s, not boolean", schema.Type)
+		}
+	case json.Number:
+		number, _ := typed.Float64()
+		switch {
+		case schema.Type != "" && schema.Type != "number" && schema.Type != "integer":
+			fail("must be %s, not number", schema.Type)
+		case schema.Type == "integer" && number != math.Trunc(number):
+			fail("must be integer, not number")
+		case schema.Minimum != nil && number < float64(*schema.Minimum):
+			fail("must be at least %d", *schema.Minimum)
+		case schema.Maximum != nil && number > float64(*schema.Maximum):
+			fail("must be at most %d", *schema.Maximum)
+		}
+	case string:
+		if detail := checkString(schema, typed); detail != "" {
+			fail("%s", detail)
+		}
+	case []interface{}:
+		switch {
+		case schema.Type != "" && schema.Type != "array":
+			fail("must be %s, not array", schema.Type)
+		case schema.MinItems != nil && len(typed) < *schema.MinItems:
+			fail("must have at least %d items", *schema.MinItems)
+		case schema.MaxItems != nil && len(typed) > *schema.MaxItems:
+			fail("must have at most %d items", *schema.MaxItems)
+		case schema.Items != nil:
+			for i, item := range typed {
+				d.checkValue(errs, pointer+validation.Pointer(strconv.Itoa(i)), schema.Items, item)
+			}
+		}
+	case map[string]interface{}:
+		if schema.Type != "" && schema.Type != "object" {
+			fail("must be %s, not object", schema.Type)
+			return
+		}
+		for _, name := range schema.Required {
+			if _, ok := typed[name]; !ok {
+				*errs = append(*errs, validation.Error{Pointer: pointer + validation.Pointer(name), Detail: "is required"})
+			}
+		}
+		names := make([]string, 0, len(typed))
+		for name := range typed {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := schema.Properties[name]; ok {
+				d.checkValue(errs, pointer+validation.Pointer(name), property, typed[name])
+			} else if schema.AdditionalProperties != nil {
+				d.checkValue(errs, pointer+validation.Pointer(name), schema.AdditionalProperties, typed[name])
+			}
+		}
+	}
+}
+
+// checkParameter returns the violation of schema by a parameter value, or an empty string
+func checkParameter(schema *Schema, value string) string {
+	if schema == nil {
+		return ""
+	}
+	switch schema.Type {
+	case "integer":
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return "must be an integer"
+		}
+	case "number":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return "must be a number"
+		}
+	case "boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return "must be a boolean"
+		}
+	case "string":
+		return checkString(schema, value)
+	}
+	return ""
+}
+
+// checkString returns the violation of schema by a string, or an empty string
+func checkString(schema *Schema, value string) string {
+	if schema.Type != "" && schema.Type != "string" {
+		return fmt.Sprintf("must be %s, not string", schema.Type)
+	}
+	if schema.MinLength != nil && utf8.RuneCountInString(value) < *schema.MinLength {
+		return fmt.Sprintf("must be at least %d characters long", *schema.MinLength)
+	}
+	if schema.MaxLength != nil && utf8.RuneCountInString(value) > *schema.MaxLength {
+		return fmt.Sprintf("must be at most %d characters long", *schema.MaxLength)
+	}
+	if len(schema.Enum) > 0 {
+		for _, allowed := range schema.Enum {
+			if value == allowed {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(schema.Enum, ", ")
+	}
+	if schema.Format == "date-time" {
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return "must be an RFC 3339 date-time"
+		}
+	}
+	return ""
+}
+
+// matchContent returns the body of contentType in content. It only returns JSON
+// bodies with their schema; other bodies are returned without one, so they are not
+// checked.
+func matchContent(content map[string]*MediaTypeObject, contentType string) (*MediaTypeObject, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	body, ok := content[mediaType]
+	if !ok {
+		return nil, false
+	}
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return &MediaTypeObject{}, true
+	}
+	return body, true
+}
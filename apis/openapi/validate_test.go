@@ -0,0 +1,112 @@
+package openapi_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jordantipton/golang-restful-webservice/apis/openapi"
+)
+
+/*
+	Test objects
+*/
+
+func widgetsDocument(t *testing.T) *openapi.Document {
+	router := chi.NewRouter()
+	router.Get("/widgets/{widgetID:[0-9]+}", noop)
+	router.Post("/widgets", noop)
+	operations := openapi.Operations{
+		"GET /widgets/{widgetID:[0-9]+}": {
+			Parameters: []openapi.Parameter{
+				{Name: "widgetID", In: "path", Required: true, Schema: &openapi.Schema{Type: "integer"}},
+				{Name: "view", In: "query", Schema: &openapi.Schema{Type: "string", Enum: []string{"basic", "full"}}},
+			},
+			Responses: []openapi.Response{{Status: http.StatusOK, Body: &widget{}}},
+		},
+		"POST /widgets": {
+			Request:   &widget{},
+			Responses: []openapi.Response{{Status: http.StatusCreated, Body: &widget{}}, {Status: http.StatusBadRequest, Text: true}},
+		},
+	}
+	document, _, err := openapi.Build(openapi.Info{Title: "Widgets"}, router, operations)
+	if err != nil {
+		t.Fatalf("Build returned error: %s", err.Error())
+	}
+	return document
+}
+
+/*
+	Test functions
+*/
+
+func TestValidateParameters(t *testing.T) {
+	// Setup
+	document := widgetsDocument(t)
+	operation := document.Operation("GET", "/widgets/{widgetID:[0-9]+}")
+	req := httptest.NewRequest("GET", "/widgets/x?view=everything", nil)
+
+	// Execute
+	errs := document.ValidateParameters(operation, req, map[string]string{"widgetID": "x"})
+
+	// Assert
+	if len(errs) != 2 || errs[0].Parameter != "widgetID" || errs[1].Parameter != "view" {
+		t.Fatalf("Errors, expected: widgetID and view, got: %v", errs)
+	}
+	if errs[1].Detail != "must be one of basic, full" {
+		t.Errorf("Detail, expected: must be one of basic, full, got: %s", errs[1].Detail)
+	}
+}
+
+func TestValidateRequestBody(t *testing.T) {
+	// Setup
+	document := widgetsDocument(t)
+	operation := document.Operation("POST", "/widgets")
+	body := []byte(`{"name": "a very long name", "parts": [{"name": 7}]}`)
+
+	// Execute
+	errs, ok := document.ValidateRequestBody(operation, "application/json; charset=utf-8", body)
+	_, csvOK := document.ValidateRequestBody(operation, "text/csv", body)
+
+	// Assert
+	if !ok || csvOK {
+		t.Errorf("Accepted, expected: json true and csv false, got: %v and %v", ok, csvOK)
+	}
+	if len(errs) != 2 || errs[0].Pointer != "/name" || errs[1].Pointer != "/parts/0/name" {
+		t.Fatalf("Errors, expected: /name and /parts/0/name, got: %v", errs)
+	}
+	if errs[1].Detail != "must be string, not number" {
+		t.Errorf("Detail, expected: must be string, not number, got: %s", errs[1].Detail)
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	// Setup
+	document := widgetsDocument(t)
+	operation := document.Operation("POST", "/widgets")
+	jsonHeader := http.Header{"Content-Type": {"application/json"}}
+	textHeader := http.Header{"Content-Type": {"text/plain; charset=utf-8"}}
+
+	// Execute
+	valid := document.ValidateResponse(operation, http.StatusCreated, jsonHeader, []byte(`{"id": 1, "name": "gear"}`))
+	text := document.ValidateResponse(operation, http.StatusBadRequest, textHeader, []byte("bad request\n"))
+	wrongBody := document.ValidateResponse(operation, http.StatusCreated, jsonHeader, []byte(`{"id": "1", "name": "gear"}`))
+	wrongStatus := document.ValidateResponse(operation, http.StatusTeapot, jsonHeader, nil)
+	wrongType := document.ValidateResponse(operation, http.StatusCreated, textHeader, []byte("gear"))
+
+	// Assert
+	if len(valid) != 0 || len(text) != 0 {
+		t.Errorf("Errors, expected none, got: %v and %v", valid, text)
+	}
+	if len(wrongBody) != 1 || wrongBody[0].Pointer != "/id" {
+		t.Errorf("Body errors, expected: /id, got: %v", wrongBody)
+	}
+	if len(wrongStatus) != 1 || wrongStatus[0].Detail != "status 418 is not documented" {
+		t.Errorf("Status errors, expected: status 418 is not documented, got: %v", wrongStatus)
+	}
+	if len(wrongType) != 1 {
+		t.Errorf("Content-Type errors, expected one, got: %v", wrongType)
+	}
+}
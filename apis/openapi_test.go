@@ -0,0 +1,98 @@
+package apis_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jordantipton/golang-restful-webservice/apis"
+	"github.com/jordantipton/golang-restful-webservice/apis/openapi"
+)
+
+func TestGetOpenAPIDocument(t *testing.T) {
+	// Setup
+	r := chi.NewRouter()
+	apis.RegisterUsersResource(r, &mockUsersServicer{})
+	apis.RegisterOpenAPIResource(r, &apis.OpenAPIResource{Info: apis.APIInfo, Routes: r})
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/openapi.json", nil)
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 200 {
+		t.Fatalf("HTTP status code, expected: %d, got: %d", 200, w.Code)
+	}
+	document := openapi.Document{}
+	if err := json.NewDecoder(w.Body).Decode(&document); err != nil {
+		t.Fatalf("Failed to decode OpenAPI document. Error: %s", err.Error())
+	}
+	if document.OpenAPI != "3.1.0" {
+		t.Errorf("OpenAPI version, expected: 3.1.0, got: %s", document.OpenAPI)
+	}
+	createUser := document.Paths["/users"]["post"]
+	if createUser == nil || createUser.Responses["422"] == nil {
+		t.Fatalf("Expected POST /users to document a 422 response, got: %+v", createUser)
+	}
+	if _, ok := createUser.RequestBody.Content["application/x-protobuf"]; !ok {
+		t.Errorf("Expected POST /users to accept application/x-protobuf")
+	}
+	user := document.Components.Schemas["User"]
+	if user == nil || len(user.Required) != 1 || user.Required[0] != "name" {
+		t.Errorf("User required, expected: [name], got: %+v", user)
+	}
+}
+
+func TestGetDocs(t *testing.T) {
+	// Setup
+	r := chi.NewRouter()
+	apis.RegisterOpenAPIResource(r, &apis.OpenAPIResource{Info: apis.APIInfo, Routes: r})
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/docs", nil)
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 200 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 200, w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "text/html; charset=utf-8" {
+		t.Errorf("Content-Type, expected: text/html; charset=utf-8, got: %s", contentType)
+	}
+	if policy := w.Header().Get("Content-Security-Policy"); !strings.Contains(policy, "script-src 'self'") {
+		t.Errorf("Content-Security-Policy, expected script-src 'self', got: %s", policy)
+	}
+	if body := w.Body.String(); !strings.Contains(body, `src="docs/redoc.standalone.js"`) || strings.Contains(body, "https://") {
+		t.Errorf("Expected the page to load the bundled Redoc script only, got: %s", body)
+	}
+}
+
+func TestGetDocsScript(t *testing.T) {
+	// Setup
+	r := chi.NewRouter()
+	apis.RegisterOpenAPIResource(r, &apis.OpenAPIResource{Info: apis.APIInfo, Routes: r})
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/docs/redoc.standalone.js", nil)
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 200 {
+		t.Fatalf("HTTP status code, expected: %d, got: %d; the Redoc bundle is missing, run go generate ./apis and commit apis/docs", 200, w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "text/javascript; charset=utf-8" {
+		t.Errorf("Content-Type, expected: text/javascript; charset=utf-8, got: %s", contentType)
+	}
+	if w.Body.Len() == 0 {
+		t.Errorf("Expected the Redoc bundle, got an empty body")
+	}
+}
@@ -0,0 +1,205 @@
+package apis
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/jordantipton/golang-restful-webservice/apis/openapi"
+)
+
+// ResponseValidation selects what ValidateOpenAPI does with responses
+type ResponseValidation int
+
+const (
+	// ResponseValidationOff sends responses without checking them
+	ResponseValidationOff ResponseValidation = iota
+	// ResponseValidationLog logs responses that do not match the document and sends
+	// them unchanged
+	ResponseValidationLog
+	// ResponseValidationStrict logs responses that do not match the document and
+	// replaces them with a 500 problem. Use it in tests and development.
+	ResponseValidationStrict
+)
+
+type (
+	// validatingWriter buffers a response so that it can be checked before it is sent.
+	// Flushed and hijacked responses are streamed and not checked.
+	validatingWriter struct {
+		http.ResponseWriter
+		status    int
+		body      bytes.Buffer
+		streaming bool
+	}
+
+	readCloser struct {
+		io.Reader
+		io.Closer
+	}
+)
+
+// ParseResponseValidation parses off, log or strict. An empty value is off.
+func ParseResponseValidation(value string) (ResponseValidation, error) {
+	switch strings.ToLower(value) {
+	case "", "off":
+		return ResponseValidationOff, nil
+	case "log":
+		return ResponseValidationLog, nil
+	case "strict":
+		return ResponseValidationStrict, nil
+	}
+	return ResponseValidationOff, fmt.Errorf("response validation must be one of off, log or strict, got: %s", value)
+}
+
+// ValidateOpenAPI returns middleware that checks requests to the routes of document
+// against their operation. Invalid parameters are rejected with a 400 problem and JSON
+// bodies that do not match their schema with a 422 problem, like bind. Other bodies and
+// Content-Types are left for the handlers to check, as are routes that document does
+// not describe. Responses are checked according to responses.
+func ValidateOpenAPI(document func() (*openapi.Document, error), responses ResponseValidation) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			doc, err := document()
+			if err != nil {
+				http.Error(res, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			routeContext := chi.RouteContext(req.Context())
+			match := chi.NewRouteContext()
+			if routeContext == nil || !routeContext.Routes.Match(match, req.Method, req.URL.Path) {
+				next.ServeHTTP(res, req)
+				return
+			}
+			operation := doc.Operation(req.Method, match.RoutePattern())
+			if operation == nil {
+				next.ServeHTTP(res, req)
+				return
+			}
+			if !validateRequest(res, req, doc, operation, match) {
+				return
+			}
+			if responses == ResponseValidationOff {
+				next.ServeHTTP(res, req)
+				return
+			}
+			writer := &validatingWriter{ResponseWriter: res}
+			next.ServeHTTP(writer, req)
+			if writer.streaming {
+				return
+			}
+			if writer.status == 0 {
+				writer.status = http.StatusOK
+			}
+			violations := doc.ValidateResponse(operation, writer.status, res.Header(), writer.body.Bytes())
+			if len(violations) > 0 {
+				log.Printf("openapi: response %d to %s %s does not match the API description: %s", writer.status, req.Method, req.URL.Path, violations.Error())
+				if responses == ResponseValidationStrict {
+					res.Header().Del("Content-Length")
+					res.Header().Del("Content-Disposition")
+					writeProblem(res, http.StatusInternalServerError, "Response does not match the API description", "", violations)
+					return
+				}
+			}
+			writer.send()
+		})
+	}
+}
+
+// validateRequest checks the parameters and JSON body of req against operation. It
+// responds with a problem and returns false if they are invalid.
+func validateRequest(res http.ResponseWriter, req *http.Request, document *openapi.Document, operation *openapi.OperationObject, match *chi.Context) bool {
+	pathParams := map[string]string{}
+	for i, key := range match.URLParams.Keys {
+		pathParams[key] = match.URLParams.Values[i]
+	}
+	if violations := document.ValidateParameters(operation, req, pathParams); len(violations) > 0 {
+		writeProblem(res, http.StatusBadRequest, "Request parameters are invalid", "", violations)
+		return false
+	}
+	if operation.RequestBody == nil {
+		return true
+	}
+	contentType := req.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+		return true
+	}
+	// Bodies over the limit are left for bind to reject
+	body, err := io.ReadAll(io.LimitReader(req.Body, MaxRequestBodySize+1))
+	req.Body = &readCloser{Reader: io.MultiReader(bytes.NewReader(body), req.Body), Closer: req.Body}
+	if err != nil || len(body) > MaxRequestBodySize {
+		return true
+	}
+	if violations, _ := document.ValidateRequestBody(operation, contentType, body); len(violations) > 0 {
+		writeValidationProblem(res, violations)
+		return false
+	}
+	return true
+}
+
+// WriteHeader records status until the response is checked
+func (w *validatingWriter) WriteHeader(status int) {
+	if w.streaming {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// Write buffers body until the response is checked
+func (w *validatingWriter) Write(b []byte) (int, error) {
+	if w.streaming {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+// Flush sends the response written so far unchecked and streams the rest
+func (w *validatingWriter) Flush() {
+	if !w.streaming {
+		w.streaming = true
+		w.send()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack the connection of the underlying writer, such as for a websocket upgrade
+func (w *validatingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not implement http.Hijacker")
+	}
+	w.streaming = true
+	return hijacker.Hijack()
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (w *validatingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// send writes the buffered status and body
+func (w *validatingWriter) send() {
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	w.ResponseWriter.Write(w.body.Bytes())
+	w.body.Reset()
+}
@@ -0,0 +1,154 @@
+package apis_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jordantipton/golang-restful-webservice/apis"
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+	"github.com/jordantipton/golang-restful-webservice/apis/openapi"
+	"github.com/jordantipton/golang-restful-webservice/models"
+)
+
+/*
+	Test objects
+*/
+
+// validatedRouter serves the users resource and GET /teapot, which is documented to
+// respond 200 but responds 418, behind ValidateOpenAPI
+func validatedRouter(service *mockUsersServicer, responses apis.ResponseValidation) *chi.Mux {
+	r := chi.NewRouter()
+	resource := &apis.OpenAPIResource{Info: apis.APIInfo, Routes: r}
+	r.Use(apis.ValidateOpenAPI(resource.Document, responses))
+	apis.RegisterUsersResource(r, service)
+	teapot := openapi.Operation{Responses: []openapi.Response{{Status: http.StatusOK, Text: true}}}
+	r.Method(http.MethodGet, "/teapot", openapi.Handler(teapot, func(res http.ResponseWriter, req *http.Request) {
+		http.Error(res, "I'm a teapot", http.StatusTeapot)
+	}))
+	apis.RegisterOpenAPIResource(r, resource)
+	return r
+}
+
+/*
+	Test functions
+*/
+
+func TestValidateOpenAPIInvalidParameter(t *testing.T) {
+	// Setup
+	called := false
+	r := validatedRouter(&mockUsersServicer{
+		mockImportUsers: func(users []*models.User, options models.ImportOptions) ([]models.ImportResult, error) {
+			called = true
+			return nil, nil
+		},
+	}, apis.ResponseValidationOff)
+
+	req := httptest.NewRequest("POST", "http://localhost:8080/users:import?on_conflict=overwrite", nil)
+	req.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("HTTP status code, expected: %d, got: %d", http.StatusBadRequest, w.Code)
+	}
+	if called {
+		t.Errorf("Expected the import not to run")
+	}
+	problem := dtos.Problem{}
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatalf("Failed to decode problem. Error: %s", err.Error())
+	}
+	if len(problem.Errors) != 1 || problem.Errors[0].Parameter != "on_conflict" {
+		t.Errorf("Errors, expected: on_conflict, got: %+v", problem.Errors)
+	}
+}
+
+func TestValidateOpenAPIInvalidBody(t *testing.T) {
+	// Setup
+	r := validatedRouter(&mockUsersServicer{}, apis.ResponseValidationOff)
+
+	req := httptest.NewRequest("POST", "http://localhost:8080/users", strings.NewReader(`{"name": ["Bob"]}`))
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("HTTP status code, expected: %d, got: %d", http.StatusUnprocessableEntity, w.Code)
+	}
+	problem := dtos.Problem{}
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatalf("Failed to decode problem. Error: %s", err.Error())
+	}
+	if len(problem.Errors) != 1 || problem.Errors[0].Pointer != "/name" {
+		t.Errorf("Errors, expected: /name, got: %+v", problem.Errors)
+	}
+}
+
+func TestValidateOpenAPIValidRequest(t *testing.T) {
+	// Setup
+	r := validatedRouter(&mockUsersServicer{
+		mockCreateUser: func(user *models.User) (*models.User, error) {
+			user.ID = 1
+			return user, nil
+		},
+	}, apis.ResponseValidationStrict)
+
+	req := httptest.NewRequest("POST", "http://localhost:8080/users", strings.NewReader(`{"name": "Bob"}`))
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != http.StatusCreated {
+		t.Errorf("HTTP status code, expected: %d, got: %d, body: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+}
+
+func TestValidateOpenAPIStrictResponse(t *testing.T) {
+	// Setup
+	r := validatedRouter(&mockUsersServicer{}, apis.ResponseValidationStrict)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/teapot", nil)
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("HTTP status code, expected: %d, got: %d", http.StatusInternalServerError, w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/problem+json" {
+		t.Errorf("Content-Type, expected: application/problem+json, got: %s", contentType)
+	}
+}
+
+func TestValidateOpenAPILoggedResponse(t *testing.T) {
+	// Setup
+	r := validatedRouter(&mockUsersServicer{}, apis.ResponseValidationLog)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/teapot", nil)
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != http.StatusTeapot {
+		t.Errorf("HTTP status code, expected: %d, got: %d", http.StatusTeapot, w.Code)
+	}
+	if w.Body.String() != "I'm a teapot\n" {
+		t.Errorf("Body, expected: I'm a teapot, got: %s", w.Body.String())
+	}
+}
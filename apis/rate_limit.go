@@ -0,0 +1,90 @@
+package apis
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type (
+	// RateLimit allows RequestsPerSecond requests on average and bursts of up to Burst
+	// requests. Requests are not limited when RequestsPerSecond is 0. Burst defaults to
+	// RequestsPerSecond rounded up.
+	RateLimit struct {
+		RequestsPerSecond float64 `yaml:"requests-per-second"`
+		Burst             int     `yaml:"burst"`
+	}
+
+	// RateLimiter limits the requests of each tenant with a token bucket per tenant.
+	// The buckets are kept in memory, so every instance of the service allows the full
+	// limit. The zero value does not limit requests.
+	RateLimiter struct {
+		// Limits returns the limit of a tenant. A changed limit takes effect with a
+		// full bucket.
+		Limits func(tenantID string) RateLimit
+		// Now returns the current time. It defaults to time.Now.
+		Now func() time.Time
+
+		mu      sync.Mutex
+		buckets map[string]*tokenBucket
+	}
+
+	// tokenBucket holds the tokens of a tenant at the time they were last counted
+	tokenBucket struct {
+		limit  RateLimit
+		tokens float64
+		at     time.Time
+	}
+)
+
+// Allow takes a token from the bucket of tenantID. If the bucket is empty, it returns
+// false and how long until a token is available.
+func (limiter *RateLimiter) Allow(tenantID string) (bool, time.Duration) {
+	if limiter.Limits == nil {
+		return true, 0
+	}
+	limit := limiter.Limits(tenantID)
+	if limit.RequestsPerSecond <= 0 {
+		return true, 0
+	}
+	burst := float64(limit.Burst)
+	if burst <= 0 {
+		burst = math.Ceil(limit.RequestsPerSecond)
+	}
+	now := time.Now()
+	if limiter.Now != nil {
+		now = limiter.Now()
+	}
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	if limiter.buckets == nil {
+		limiter.buckets = map[string]*tokenBucket{}
+	}
+	bucket, ok := limiter.buckets[tenantID]
+	if !ok || bucket.limit != limit {
+		bucket = &tokenBucket{limit: limit, tokens: burst, at: now}
+		limiter.buckets[tenantID] = bucket
+	}
+	bucket.tokens = math.Min(burst, bucket.tokens+now.Sub(bucket.at).Seconds()*limit.RequestsPerSecond)
+	bucket.at = now
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / limit.RequestsPerSecond * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// Handler is middleware that rejects the requests of tenants over their limit with
+// 429 Too Many Requests and a Retry-After header. It must run after ResolveTenant.
+func (limiter *RateLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if ok, wait := limiter.Allow(TenantID(req.Context())); !ok {
+			res.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(res, "Rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(res, req)
+	})
+}
@@ -0,0 +1,92 @@
+package apis_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jordantipton/golang-restful-webservice/apis"
+)
+
+/*
+	Test objects
+*/
+
+// rateLimiter limits acme to 2 requests per second in bursts of 2 and globex to 1, at
+// the time returned by now
+func rateLimiter(now *time.Time) *apis.RateLimiter {
+	return &apis.RateLimiter{
+		Limits: func(tenantID string) apis.RateLimit {
+			if tenantID == "acme" {
+				return apis.RateLimit{RequestsPerSecond: 2, Burst: 2}
+			}
+			return apis.RateLimit{RequestsPerSecond: 1}
+		},
+		Now: func() time.Time { return *now },
+	}
+}
+
+func serveRateLimited(limiter *apis.RateLimiter, tenantID string) *httptest.ResponseRecorder {
+	handler := limiter.Handler(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {}))
+	req := httptest.NewRequest("GET", "http://localhost:8080/users/1", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req.WithContext(apis.WithTenantID(req.Context(), tenantID)))
+	return w
+}
+
+/*
+	Test functions
+*/
+
+func TestRateLimiter(t *testing.T) {
+	// Setup
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	limiter := rateLimiter(&now)
+
+	// Execute
+	first, second, third := serveRateLimited(limiter, "acme"), serveRateLimited(limiter, "acme"), serveRateLimited(limiter, "acme")
+
+	// Assert
+	if first.Code != 200 || second.Code != 200 {
+		t.Errorf("HTTP status codes of the burst, expected: 200, 200, got: %d, %d", first.Code, second.Code)
+	}
+	if third.Code != 429 || third.Header().Get("Retry-After") != "1" {
+		t.Errorf("Over the limit, expected: 429 with Retry-After 1, got: %d with %s", third.Code, third.Header().Get("Retry-After"))
+	}
+	now = now.Add(500 * time.Millisecond)
+	if w := serveRateLimited(limiter, "acme"); w.Code != 200 {
+		t.Errorf("HTTP status code after refill, expected: 200, got: %d", w.Code)
+	}
+}
+
+func TestRateLimiterIsolatesTenants(t *testing.T) {
+	// Setup
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	limiter := rateLimiter(&now)
+	serveRateLimited(limiter, "globex")
+
+	// Execute
+	globex, acme := serveRateLimited(limiter, "globex"), serveRateLimited(limiter, "acme")
+
+	// Assert
+	if globex.Code != 429 {
+		t.Errorf("HTTP status code of globex, expected: 429, got: %d", globex.Code)
+	}
+	if acme.Code != 200 {
+		t.Errorf("HTTP status code of acme, expected: 200, got: %d", acme.Code)
+	}
+}
+
+func TestRateLimiterUnlimited(t *testing.T) {
+	// Setup
+	limiter := &apis.RateLimiter{}
+
+	// Execute
+	for i := 0; i < 100; i++ {
+		// Assert
+		if ok, _ := limiter.Allow("acme"); !ok {
+			t.Fatalf("Request %d, expected: allowed", i)
+		}
+	}
+}
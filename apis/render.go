@@ -0,0 +1,148 @@
+package apis
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/jordantipton/golang-restful-webservice/apis/codecs"
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+	"github.com/jordantipton/golang-restful-webservice/apis/validation"
+)
+
+// MaxRequestBodySize is the largest body in bytes that bind decodes. Bulk imports are
+// streamed and not limited by it.
+const MaxRequestBodySize = 1 << 20
+
+// negotiate returns the codec for the response to req, which will be of the type of v,
+// or responds 406 Not Acceptable and returns false
+func negotiate(res http.ResponseWriter, req *http.Request, registry *codecs.Registry, v interface{}) (codecs.Codec, bool) {
+	if !headerHasToken(res.Header(), "Vary", "Accept") {
+		res.Header().Add("Vary", "Accept")
+	}
+	codec, ok := registry.ForAccept(req.Header.Get("Accept"), v)
+	if !ok {
+		http.Error(res, "Accept must allow one of "+strings.Join(registry.MediaTypes(), ", "), http.StatusNotAcceptable)
+		return nil, false
+	}
+	return codec, true
+}
+
+// bind decodes the body of req into v with the codec of its Content-Type and checks
+// it against the validate tags of v. It responds 415 Unsupported Media Type, 413 Request
+// Entity Too Large or 400 Bad Request and returns false if the body cannot be decoded.
+// Fields that v does not have, fields of the wrong type and fields that break their
+// rules are all reported in a single 422 Unprocessable Entity problem.
+func bind(res http.ResponseWriter, req *http.Request, registry *codecs.Registry, v interface{}) bool {
+	codec, ok := registry.ForContentType(req.Header.Get("Content-Type"))
+	if !ok {
+		http.Error(res, "Content-Type must be one of "+strings.Join(registry.MediaTypes(), ", "), http.StatusUnsupportedMediaType)
+		return false
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(res, req.Body, MaxRequestBodySize))
+	if err != nil {
+		if bodyTooLarge(err) {
+			http.Error(res, fmt.Sprintf("Request body cannot be larger than %d bytes", MaxRequestBodySize), http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+		}
+		return false
+	}
+	var violations validation.Errors
+	// Only self-describing formats such as JSON decode into a generic document. XML and
+	// protobuf bodies are not checked for unknown fields.
+	var document interface{}
+	if codec.Decode(bytes.NewReader(body), &document) == nil {
+		violations = validation.UnknownFields(document, v)
+	}
+	if err := codec.Decode(bytes.NewReader(body), v); err != nil {
+		var typeError *json.UnmarshalTypeError
+		if !errors.As(err, &typeError) {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return false
+		}
+		violations = append(violations, validation.Error{
+			Pointer: validation.Pointer(strings.Split(typeError.Field, ".")...),
+			Detail:  fmt.Sprintf("must be %s, not %s", jsonTypeName(typeError.Type), typeError.Value),
+		})
+	}
+	invalid := map[string]bool{}
+	for _, violation := range violations {
+		invalid[violation.Pointer] = true
+	}
+	for _, violation := range validation.Struct(v) {
+		if !invalid[violation.Pointer] {
+			violations = append(violations, violation)
+		}
+	}
+	if len(violations) > 0 {
+		writeValidationProblem(res, violations)
+		return false
+	}
+	return true
+}
+
+// writeValidationProblem responds 422 Unprocessable Entity with an
+// application/problem+json body listing violations
+func writeValidationProblem(res http.ResponseWriter, violations validation.Errors) {
+	detail := fmt.Sprintf("%d fields of the request body are invalid", len(violations))
+	if len(violations) == 1 {
+		detail = "1 field of the request body is invalid"
+	}
+	writeProblem(res, http.StatusUnprocessableEntity, "Request body is invalid", detail, violations)
+}
+
+// writeProblem responds status with an application/problem+json body listing violations
+func writeProblem(res http.ResponseWriter, status int, title, detail string, violations validation.Errors) {
+	problem := dtos.Problem{Title: title, Status: status, Detail: detail}
+	for _, violation := range violations {
+		problem.Errors = append(problem.Errors, dtos.ProblemError{Pointer: violation.Pointer, Parameter: violation.Parameter, Detail: violation.Detail})
+	}
+	res.Header().Set("Content-Type", "application/problem+json")
+	res.WriteHeader(status)
+	json.NewEncoder(res).Encode(&problem)
+}
+
+// jsonTypeName returns the JSON type that decodes into t, with an article
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	}
+	return "a " + t.String()
+}
+
+// render writes v encoded with codec
+func render(res http.ResponseWriter, codec codecs.Codec, status int, v interface{}) {
+	var body bytes.Buffer
+	if err := codec.Encode(&body, v); err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res.Header().Set("Content-Type", codec.MediaType())
+	res.WriteHeader(status)
+	res.Write(body.Bytes())
+}
+
+// bodyTooLarge tells whether err comes from reading a body past the limit of an
+// http.MaxBytesReader
+func bodyTooLarge(err error) bool {
+	var tooLarge *http.MaxBytesError
+	return errors.As(err, &tooLarge)
+}
@@ -0,0 +1,181 @@
+package apis
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/jordantipton/golang-restful-webservice/apis/openapi"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+)
+
+// TenantHeader names the tenant of a request, such as "X-Tenant-ID: acme"
+const TenantHeader = "X-Tenant-ID"
+
+type (
+	// TenantResolver tells the tenant of a request. When Authenticator is Enabled,
+	// every request needs valid credentials and is served for their tenant; its
+	// TenantHeader and subdomain, if any, must name the same tenant. Otherwise the
+	// tenant is named by the TenantHeader or the subdomain of the host, which is only
+	// accepted with TrustTenantHeader.
+	TenantResolver struct {
+		// Authenticator checks the bearer tokens and API keys of requests
+		Authenticator *Authenticator
+		// TrustTenantHeader accepts the tenant named by the TenantHeader or subdomain of
+		// requests without credentials when Authenticator is not Enabled. Set it only
+		// when the service is reachable from trusted networks alone, since any caller
+		// can then name any tenant.
+		TrustTenantHeader bool
+		// Domain is the parent domain of the tenant subdomains, such as
+		// "users.example.com" for acme.users.example.com. Hosts are ignored when it is
+		// empty.
+		Domain string
+		// Default is the tenant of requests that name none. Such requests are rejected
+		// when it is empty.
+		Default string
+	}
+
+	tenantContextKey struct{}
+)
+
+// Resolve returns the tenant of a request with the given authorization header, API key,
+// tenant header and host. It returns Unauthenticated for missing or invalid
+// credentials, PermissionDenied when the header or host name another tenant than the
+// credentials or are not trusted, and InvalidArgument when no valid tenant is named.
+func (resolver *TenantResolver) Resolve(authorization, apiKey, header, host string) (string, error) {
+	subdomain, err := resolver.subdomain(host)
+	if err != nil {
+		return "", err
+	}
+	if resolver.Authenticator.Enabled() {
+		principal, err := resolver.Authenticator.Authenticate(authorization, apiKey)
+		if err != nil {
+			return "", err
+		}
+		if principal.TenantID == "" {
+			return "", errors.PermissionDenied{Message: fmt.Sprintf("The token has no valid %s claim", resolver.Authenticator.tenantClaim())}
+		}
+		if (header != "" && header != principal.TenantID) || (subdomain != "" && subdomain != principal.TenantID) {
+			return "", errors.PermissionDenied{Message: "The credentials are not valid for tenant " + firstNonEmpty(header, subdomain)}
+		}
+		return principal.TenantID, nil
+	}
+	if (header != "" || subdomain != "") && !resolver.TrustTenantHeader {
+		return "", errors.PermissionDenied{Message: "Tenants are not named by " + TenantHeader + " or the subdomain unless they are trusted"}
+	}
+	if header != "" && subdomain != "" && header != subdomain {
+		return "", errors.InvalidArgument{Message: fmt.Sprintf("%s %s does not match the tenant %s of the host", TenantHeader, header, subdomain)}
+	}
+	tenantID := firstNonEmpty(header, subdomain, resolver.Default)
+	if tenantID == "" {
+		return "", errors.InvalidArgument{Message: "A tenant is required: set " + TenantHeader}
+	}
+	if !models.ValidTenantID(tenantID) {
+		return "", errors.InvalidArgument{Message: fmt.Sprintf("Tenant ID %q must be a lower case DNS label", tenantID)}
+	}
+	return tenantID, nil
+}
+
+// subdomain returns the tenant label of host below Domain, or "" if host is not a
+// subdomain of Domain
+func (resolver *TenantResolver) subdomain(host string) (string, error) {
+	if resolver.Domain == "" {
+		return "", nil
+	}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	label, ok := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(resolver.Domain))
+	if !ok || label == "" {
+		return "", nil
+	}
+	if !models.ValidTenantID(label) {
+		return "", errors.InvalidArgument{Message: fmt.Sprintf("Subdomain %q is not a tenant", label)}
+	}
+	return label, nil
+}
+
+// ResolveTenant returns middleware that stores the tenant of each request, as told by
+// resolver, in its context for TenantID. Requests without a valid tenant are rejected.
+// Responses vary by the tenant header and, when credentials name tenants, by
+// Authorization and APIKeyHeader.
+func ResolveTenant(resolver *TenantResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			res.Header().Add("Vary", TenantHeader)
+			if resolver.Authenticator.Enabled() {
+				res.Header().Add("Vary", "Authorization")
+				res.Header().Add("Vary", APIKeyHeader)
+			}
+			authorization, apiKey := requestCredentials(req)
+			tenantID, err := resolver.Resolve(authorization, apiKey, req.Header.Get(TenantHeader), req.Host)
+			if err != nil {
+				writeAuthError(res, err)
+				return
+			}
+			next.ServeHTTP(res, req.WithContext(WithTenantID(req.Context(), tenantID)))
+		})
+	}
+}
+
+// WithTenantID returns a copy of ctx carrying tenantID
+func WithTenantID(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenantID)
+}
+
+// TenantID returns the tenant stored in ctx by ResolveTenant, or "" if there is none
+func TenantID(ctx context.Context) string {
+	tenantID, _ := ctx.Value(tenantContextKey{}).(string)
+	return tenantID
+}
+
+// WithTenant returns operations documenting TenantHeader and the responses of
+// ResolveTenant and RateLimiter on every route
+func WithTenant(operations openapi.Operations) openapi.Operations {
+	tenantOperations := openapi.Operations{}
+	for key, operation := range operations {
+		operation.Parameters = append(append([]openapi.Parameter{}, operation.Parameters...), tenantParameter)
+		responses := append([]openapi.Response{}, operation.Responses...)
+		if !hasResponse(responses, http.StatusBadRequest) {
+			responses = append(responses, parameterErrorResponse("No valid tenant is named"))
+		}
+		operation.Responses = append(responses, tenantResponses...)
+		tenantOperations[key] = operation
+	}
+	return tenantOperations
+}
+
+var (
+	tenantParameter = openapi.Parameter{
+		Name:        TenantHeader,
+		In:          "header",
+		Description: "The tenant of the request. It must match the tenant of the credentials, and is only accepted without credentials from trusted networks.",
+		Schema:      &openapi.Schema{Type: "string"},
+	}
+	tenantResponses = []openapi.Response{
+		textResponse(http.StatusUnauthorized, "The bearer token or API key is missing, invalid or expired"),
+		textResponse(http.StatusForbidden, "The credentials are not valid for the tenant named by the header or subdomain, or the tenant is named from an untrusted network"),
+		{Status: http.StatusTooManyRequests, Description: "The rate limit of the tenant is exceeded", Text: true, Headers: map[string]string{"Retry-After": "Seconds until a request is allowed"}},
+	}
+)
+
+func hasResponse(responses []openapi.Response, status int) bool {
+	for _, response := range responses {
+		if response.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,188 @@
+package apis_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jordantipton/golang-restful-webservice/apis"
+	"github.com/jordantipton/golang-restful-webservice/apis/openapi"
+	"github.com/jordantipton/golang-restful-webservice/auth"
+)
+
+/*
+	Test objects
+*/
+
+var tenantSecret = []byte("secret")
+
+// tenantResolver returns a resolver accepting tokens signed with tenantSecret and the
+// API key "acme-key" of acme
+func tenantResolver() *apis.TenantResolver {
+	authenticator := &apis.Authenticator{JWTSecret: tenantSecret, APIKeys: map[string]string{"acme-key": "acme"}}
+	return &apis.TenantResolver{Authenticator: authenticator, Domain: "users.example.com"}
+}
+
+// trustingResolver returns a resolver without authentication that trusts tenant headers
+// and subdomains
+func trustingResolver() *apis.TenantResolver {
+	return &apis.TenantResolver{TrustTenantHeader: true, Domain: "users.example.com"}
+}
+
+func bearer(t *testing.T, claims auth.Claims) string {
+	token, err := auth.SignHS256(claims, tenantSecret)
+	if err != nil {
+		t.Fatalf("SignHS256 returned error: %v", err)
+	}
+	return "Bearer " + token
+}
+
+/*
+	Test functions
+*/
+
+func TestResolveTenant(t *testing.T) {
+	tests := []struct {
+		name          string
+		resolver      *apis.TenantResolver
+		authorization string
+		apiKey        string
+		header        string
+		host          string
+		expected      string
+	}{
+		{name: "token", resolver: tenantResolver(), authorization: bearer(t, auth.Claims{"tenant": "acme"}), host: "localhost", expected: "acme"},
+		{name: "token and header", resolver: tenantResolver(), authorization: bearer(t, auth.Claims{"tenant": "acme"}), header: "acme", host: "localhost", expected: "acme"},
+		{name: "token and subdomain", resolver: tenantResolver(), authorization: bearer(t, auth.Claims{"tenant": "acme"}), host: "acme.users.example.com", expected: "acme"},
+		{name: "API key", resolver: tenantResolver(), apiKey: "acme-key", host: "localhost", expected: "acme"},
+		{name: "trusted header", resolver: trustingResolver(), header: "acme", host: "localhost:8080", expected: "acme"},
+		{name: "trusted subdomain", resolver: trustingResolver(), host: "acme.users.example.com:8080", expected: "acme"},
+		{name: "trusted subdomain and header", resolver: trustingResolver(), header: "acme", host: "Acme.Users.Example.com", expected: "acme"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Execute
+			tenantID, err := test.resolver.Resolve(test.authorization, test.apiKey, test.header, test.host)
+
+			// Assert
+			if err != nil || tenantID != test.expected {
+				t.Errorf("Tenant, expected: %s, got: %s, %v", test.expected, tenantID, err)
+			}
+		})
+	}
+}
+
+func TestResolveTenantErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		resolver       *apis.TenantResolver
+		authorization  string
+		apiKey         string
+		header         string
+		host           string
+		expectedStatus int
+	}{
+		{name: "no credentials", resolver: tenantResolver(), host: "localhost", expectedStatus: 401},
+		{name: "invalid token", resolver: tenantResolver(), authorization: "Bearer not-a-token", header: "acme", expectedStatus: 401},
+		{name: "expired token", resolver: tenantResolver(), authorization: bearer(t, auth.Claims{"tenant": "acme", "exp": float64(time.Now().Add(-time.Hour).Unix())}), expectedStatus: 401},
+		{name: "invalid API key", resolver: tenantResolver(), apiKey: "globex-key", header: "globex", expectedStatus: 401},
+		{name: "token of another tenant", resolver: tenantResolver(), authorization: bearer(t, auth.Claims{"tenant": "acme"}), header: "globex", expectedStatus: 403},
+		{name: "token of another subdomain", resolver: tenantResolver(), authorization: bearer(t, auth.Claims{"tenant": "acme"}), host: "globex.users.example.com", expectedStatus: 403},
+		{name: "token without tenant", resolver: tenantResolver(), authorization: bearer(t, auth.Claims{"sub": "ada"}), expectedStatus: 403},
+		{name: "API key of another tenant", resolver: tenantResolver(), apiKey: "acme-key", header: "globex", expectedStatus: 403},
+		{name: "untrusted header", resolver: &apis.TenantResolver{Default: "default"}, header: "acme", expectedStatus: 403},
+		{name: "untrusted subdomain", resolver: &apis.TenantResolver{Domain: "users.example.com", Default: "default"}, host: "acme.users.example.com", expectedStatus: 403},
+		{name: "no tenant", resolver: trustingResolver(), host: "localhost", expectedStatus: 400},
+		{name: "invalid tenant", resolver: trustingResolver(), header: "Acme_Corp", host: "localhost", expectedStatus: 400},
+		{name: "header and subdomain disagree", resolver: trustingResolver(), header: "globex", host: "acme.users.example.com", expectedStatus: 400},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Setup
+			handler := apis.ResolveTenant(test.resolver)(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				t.Errorf("Handler called for tenant %s", apis.TenantID(req.Context()))
+			}))
+			req := httptest.NewRequest("GET", "http://localhost:8080/users/1", nil)
+			if test.host != "" {
+				req.Host = test.host
+			}
+			req.Header.Set("Authorization", test.authorization)
+			req.Header.Set(apis.APIKeyHeader, test.apiKey)
+			req.Header.Set(apis.TenantHeader, test.header)
+			w := httptest.NewRecorder()
+
+			// Execute
+			handler.ServeHTTP(w, req)
+
+			// Assert
+			if w.Code != test.expectedStatus {
+				t.Errorf("HTTP status code, expected: %d, got: %d (%s)", test.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestResolveTenantRejectsUnsignedRequestForAnotherTenant(t *testing.T) {
+	// Setup
+	handler := apis.ResolveTenant(tenantResolver())(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		t.Errorf("Handler called for tenant %s", apis.TenantID(req.Context()))
+	}))
+	req := httptest.NewRequest("GET", "http://globex.users.example.com/users/1", nil)
+	req.Header.Set(apis.TenantHeader, "globex")
+	w := httptest.NewRecorder()
+
+	// Execute
+	handler.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 401 {
+		t.Errorf("HTTP status code, expected: 401, got: %d (%s)", w.Code, w.Body.String())
+	}
+	if authenticate := w.Header().Get("WWW-Authenticate"); authenticate == "" {
+		t.Errorf("WWW-Authenticate, expected: a Bearer challenge, got: none")
+	}
+}
+
+func TestResolveTenantDefault(t *testing.T) {
+	// Setup
+	resolver := &apis.TenantResolver{Default: "default"}
+	var tenantID string
+	handler := apis.ResolveTenant(resolver)(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		tenantID = apis.TenantID(req.Context())
+	}))
+	w := httptest.NewRecorder()
+
+	// Execute
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/users/1", nil))
+
+	// Assert
+	if w.Code != 200 || tenantID != "default" {
+		t.Errorf("Tenant, expected: default, got: %d %s", w.Code, tenantID)
+	}
+	if vary := w.Header().Get("Vary"); vary != apis.TenantHeader {
+		t.Errorf("Vary, expected: %s, got: %s", apis.TenantHeader, vary)
+	}
+}
+
+func TestWithTenantDocumentsTenantResponses(t *testing.T) {
+	// Setup
+	operations := openapi.Operations{"GET /users/{userID}": {ID: "getUser"}}
+
+	// Execute
+	operation := apis.WithTenant(operations)["GET /users/{userID}"]
+
+	// Assert
+	if len(operation.Parameters) != 1 || operation.Parameters[0].Name != apis.TenantHeader {
+		t.Errorf("Parameters, expected: %s, got: %v", apis.TenantHeader, operation.Parameters)
+	}
+	statuses := map[int]bool{}
+	for _, response := range operation.Responses {
+		statuses[response.Status] = true
+	}
+	for _, status := range []int{400, 401, 403, 429} {
+		if !statuses[status] {
+			t.Errorf("Responses, expected: %d, got: %v", status, operation.Responses)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package apis
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/jordantipton/golang-restful-webservice/apis/codecs"
+	"github.com/jordantipton/golang-restful-webservice/apis/converters"
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+	"github.com/jordantipton/golang-restful-webservice/apis/openapi"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/services"
+)
+
+// maxAvatarFormOverhead is the room left for the boundaries and headers of a
+// multipart/form-data upload around the image
+const maxAvatarFormOverhead = 64 << 10
+
+// avatarFormField is the name of the multipart/form-data part holding the image
+const avatarFormField = "avatar"
+
+type (
+	// UserAvatarResource defines handlers for the avatars of users. Representations of
+	// users are negotiated with Codecs, or codecs.Default if it is nil.
+	UserAvatarResource struct {
+		Service services.AvatarsServicer
+		Codecs  *codecs.Registry
+	}
+)
+
+var (
+	// setUserAvatarOperation documents PUT /users/{userID}/avatar
+	setUserAvatarOperation = openapi.Operation{
+		ID:                "setUserAvatar",
+		Summary:           "Upload the avatar of a user",
+		Description:       "The body is the image, or a multipart/form-data form with the image in its avatar part. Thumbnails of 64, 128 and 256 pixels are generated from it.",
+		Tags:              []string{"users"},
+		Parameters:        []openapi.Parameter{userIDParameter},
+		RequestMediaTypes: append(append([]string{}, models.AvatarContentTypes...), "multipart/form-data"),
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Body: &dtos.User{}, MediaTypes: codecs.Default.MediaTypesFor((*dtos.User)(nil))},
+			parameterErrorResponse("The user ID is not an integer, or the image is invalid or larger than 4096 pixels"),
+			textResponse(http.StatusNotFound, "The user does not exist"),
+			notAcceptableResponse,
+			textResponse(http.StatusRequestEntityTooLarge, "The image is larger than 5 MiB"),
+			textResponse(http.StatusUnsupportedMediaType, "The image is not PNG, JPEG or GIF, or its content does not match its Content-Type"),
+		},
+	}
+
+	// getUserAvatarOperation documents GET /users/{userID}/avatar
+	getUserAvatarOperation = openapi.Operation{
+		ID:          "getUserAvatar",
+		Summary:     "Download the avatar of a user",
+		Description: "Serves the smallest thumbnail at least size pixels wide, or the original image without size. Supports conditional and range requests.",
+		Tags:        []string{"users"},
+		Parameters: []openapi.Parameter{
+			userIDParameter,
+			{Name: "size", In: "query", Description: "Minimum width in pixels", Schema: &openapi.Schema{Type: "integer"}},
+		},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, MediaTypes: models.AvatarContentTypes, Headers: map[string]string{"ETag": "Identifies the image"}},
+			{Status: http.StatusPartialContent, Description: "The requested ranges of the image", MediaTypes: append(append([]string{}, models.AvatarContentTypes...), "multipart/byteranges")},
+			{Status: http.StatusNotModified, Description: "The image matches If-None-Match"},
+			parameterErrorResponse("The user ID or the size is not a non-negative integer"),
+			textResponse(http.StatusNotFound, "The user does not exist or has no avatar"),
+			{Status: http.StatusPreconditionFailed, Description: "The image does not match If-Match"},
+			textResponse(http.StatusRequestedRangeNotSatisfiable, "The Range is outside the image"),
+		},
+	}
+)
+
+// RegisterUserAvatarResource sets up the routing of avatar endpoints and handlers
+func RegisterUserAvatarResource(router chi.Router, service services.AvatarsServicer) {
+	r := &UserAvatarResource{Service: service}
+	router.Method(http.MethodPut, "/users/{userID}/avatar", openapi.Handler(setUserAvatarOperation, r.SetAvatar))
+	router.Method(http.MethodGet, "/users/{userID}/avatar", openapi.Handler(getUserAvatarOperation, r.GetAvatar))
+}
+
+// SetAvatar replaces the avatar of the user of the route with the uploaded image
+func (r *UserAvatarResource) SetAvatar(res http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	codec, ok := negotiate(res, req, r.codecs(), (*dtos.User)(nil))
+	if !ok {
+		return
+	}
+	userID, err := parseUserID(chi.URLParam(req, "userID"))
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	body, ok := readAvatar(res, req)
+	if !ok {
+		return
+	}
+	user, err := r.Service.SetAvatar(userID, body)
+	if err != nil {
+		writeAvatarError(res, err)
+		return
+	}
+	render(res, codec, http.StatusOK, converters.ToUser(user))
+}
+
+// GetAvatar serves the avatar of the user of the route in the size of the size query
+// parameter
+func (r *UserAvatarResource) GetAvatar(res http.ResponseWriter, req *http.Request) {
+	userID, err := parseUserID(chi.URLParam(req, "userID"))
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	size := 0
+	if param := req.URL.Query().Get("size"); param != "" {
+		if size, err = strconv.Atoi(param); err != nil || size < 0 {
+			http.Error(res, "size must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+	}
+	blob, err := r.Service.GetAvatar(userID, size)
+	if err != nil {
+		writeAvatarError(res, err)
+		return
+	}
+	res.Header().Set("Content-Type", blob.ContentType)
+	res.Header().Set("ETag", blob.ETag)
+	http.ServeContent(res, req, "", time.Time{}, bytes.NewReader(blob.Body))
+}
+
+// readAvatar reads the image uploaded in req, either as the body or as the avatar part of
+// a multipart/form-data body. It writes an error response and returns false if the
+// image is too large, or if it is not of an accepted type or does not match its type.
+func readAvatar(res http.ResponseWriter, req *http.Request) ([]byte, bool) {
+	mediaType, params, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	var image io.Reader = http.MaxBytesReader(res, req.Body, models.AvatarMaxBytes+maxAvatarFormOverhead)
+	if mediaType == "multipart/form-data" {
+		part, err := avatarPart(multipart.NewReader(image, params["boundary"]))
+		if err != nil {
+			writeAvatarReadError(res, err)
+			return nil, false
+		}
+		mediaType, _, _ = mime.ParseMediaType(part.Header.Get("Content-Type"))
+		image = part
+	}
+	if !acceptedAvatarType(mediaType) {
+		http.Error(res, "Content-Type of the image must be one of "+strings.Join(models.AvatarContentTypes, ", "), http.StatusUnsupportedMediaType)
+		return nil, false
+	}
+	body, err := io.ReadAll(io.LimitReader(image, models.AvatarMaxBytes+1))
+	if err != nil {
+		writeAvatarReadError(res, err)
+		return nil, false
+	}
+	if len(body) > models.AvatarMaxBytes {
+		http.Error(res, fmt.Sprintf("Avatar cannot be larger than %d bytes", models.AvatarMaxBytes), http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+	if sniffed := http.DetectContentType(body); sniffed != mediaType {
+		http.Error(res, fmt.Sprintf("Content of the image is %s, not %s", sniffed, mediaType), http.StatusUnsupportedMediaType)
+		return nil, false
+	}
+	return body, true
+}
+
+// avatarPart returns the avatar part of form
+func avatarPart(form *multipart.Reader) (*multipart.Part, error) {
+	for {
+		part, err := form.NextPart()
+		if err == io.EOF {
+			return nil, errors.InvalidArgument{Message: "Form has no " + avatarFormField + " part"}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == avatarFormField {
+			return part, nil
+		}
+	}
+}
+
+// acceptedAvatarType tells whether images of mediaType are accepted as avatars
+func acceptedAvatarType(mediaType string) bool {
+	for _, accepted := range models.AvatarContentTypes {
+		if mediaType == accepted {
+			return true
+		}
+	}
+	return false
+}
+
+// writeAvatarReadError writes the response of an error reading an uploaded avatar
+func writeAvatarReadError(res http.ResponseWriter, err error) {
+	if bodyTooLarge(err) {
+		http.Error(res, fmt.Sprintf("Avatar cannot be larger than %d bytes", models.AvatarMaxBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(res, err.Error(), http.StatusBadRequest)
+}
+
+// writeAvatarError writes the response of an avatar service error
+func writeAvatarError(res http.ResponseWriter, err error) {
+	switch err.(type) {
+	case errors.NotFound:
+		http.Error(res, err.Error(), http.StatusNotFound)
+	case errors.InvalidArgument:
+		http.Error(res, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (r *UserAvatarResource) codecs() *codecs.Registry {
+	if r.Codecs == nil {
+		return codecs.Default
+	}
+	return r.Codecs
+}
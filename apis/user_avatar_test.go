@@ -0,0 +1,240 @@
+package apis_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jordantipton/golang-restful-webservice/apis"
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+)
+
+/*
+	Test objects
+*/
+
+type mockAvatarsServicer struct {
+	mockSetAvatar func(userID int, body []byte) (*models.User, error)
+	mockGetAvatar func(userID, size int) (*models.Blob, error)
+}
+
+func (m *mockAvatarsServicer) SetAvatar(userID int, body []byte) (*models.User, error) {
+	if m.mockSetAvatar != nil {
+		return m.mockSetAvatar(userID, body)
+	}
+	return &models.User{ID: userID, Name: "Ada", Avatar: "0123456789abcdef"}, nil
+}
+
+func (m *mockAvatarsServicer) GetAvatar(userID, size int) (*models.Blob, error) {
+	if m.mockGetAvatar != nil {
+		return m.mockGetAvatar(userID, size)
+	}
+	return &models.Blob{Body: avatarPNG, ContentType: "image/png", ETag: `"0123"`}, nil
+}
+
+var avatarPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01")
+
+// avatarForm returns a multipart/form-data body with body in a part named field
+func avatarForm(t *testing.T, field, contentType string, body []byte) (io.Reader, string) {
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="`+field+`"; filename="avatar"`)
+	header.Set("Content-Type", contentType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(body)
+	writer.Close()
+	return &form, writer.FormDataContentType()
+}
+
+func serveAvatar(service *mockAvatarsServicer, method, url string, body io.Reader, header map[string]string) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	apis.RegisterUserAvatarResource(r, service)
+	req := httptest.NewRequest(method, "http://localhost:8080"+url, body)
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+/*
+	Test functions
+*/
+
+func TestSetAvatar(t *testing.T) {
+	// Setup
+	var uploaded []byte
+	service := &mockAvatarsServicer{
+		mockSetAvatar: func(userID int, body []byte) (*models.User, error) {
+			uploaded = body
+			return &models.User{ID: userID, Name: "Ada", Avatar: "0123456789abcdef"}, nil
+		},
+	}
+
+	// Execute
+	w := serveAvatar(service, "PUT", "/users/7/avatar", bytes.NewReader(avatarPNG), map[string]string{"Content-Type": "image/png"})
+
+	// Assert
+	if w.Code != 200 {
+		t.Fatalf("HTTP status code, expected: 200, got: %d (%s)", w.Code, w.Body.String())
+	}
+	var user dtos.User
+	json.NewDecoder(w.Body).Decode(&user)
+	if user.AvatarURL != "/users/7/avatar?v=0123456789abcdef" {
+		t.Errorf("Avatar URL, expected: /users/7/avatar?v=0123456789abcdef, got: %s", user.AvatarURL)
+	}
+	if !bytes.Equal(uploaded, avatarPNG) {
+		t.Errorf("Uploaded, expected: the body, got: %q", uploaded)
+	}
+}
+
+func TestSetAvatarMultipart(t *testing.T) {
+	// Setup
+	var uploaded []byte
+	service := &mockAvatarsServicer{
+		mockSetAvatar: func(userID int, body []byte) (*models.User, error) {
+			uploaded = body
+			return &models.User{ID: userID}, nil
+		},
+	}
+	form, contentType := avatarForm(t, "avatar", "image/png", avatarPNG)
+
+	// Execute
+	w := serveAvatar(service, "PUT", "/users/7/avatar", form, map[string]string{"Content-Type": contentType})
+
+	// Assert
+	if w.Code != 200 {
+		t.Fatalf("HTTP status code, expected: 200, got: %d (%s)", w.Code, w.Body.String())
+	}
+	if !bytes.Equal(uploaded, avatarPNG) {
+		t.Errorf("Uploaded, expected: the avatar part, got: %q", uploaded)
+	}
+}
+
+func TestSetAvatarErrors(t *testing.T) {
+	missingPart, missingPartType := avatarForm(t, "picture", "image/png", avatarPNG)
+	mismatchedPart, mismatchedPartType := avatarForm(t, "avatar", "image/jpeg", avatarPNG)
+	tests := []struct {
+		name           string
+		url            string
+		contentType    string
+		body           io.Reader
+		service        mockAvatarsServicer
+		expectedStatus int
+	}{
+		{name: "unsupported type", url: "/users/7/avatar", contentType: "image/webp", body: bytes.NewReader(avatarPNG), expectedStatus: 415},
+		{name: "content not matching the type", url: "/users/7/avatar", contentType: "image/gif", body: bytes.NewReader(avatarPNG), expectedStatus: 415},
+		{name: "form part not matching its type", url: "/users/7/avatar", contentType: mismatchedPartType, body: mismatchedPart, expectedStatus: 415},
+		{name: "form without the avatar part", url: "/users/7/avatar", contentType: missingPartType, body: missingPart, expectedStatus: 400},
+		{name: "too large", url: "/users/7/avatar", contentType: "image/png", body: io.MultiReader(bytes.NewReader(avatarPNG), bytes.NewReader(make([]byte, models.AvatarMaxBytes))), expectedStatus: 413},
+		{name: "invalid user ID", url: "/users/ada/avatar", contentType: "image/png", body: bytes.NewReader(avatarPNG), expectedStatus: 400},
+		{
+			name:        "invalid image",
+			url:         "/users/7/avatar",
+			contentType: "image/png",
+			body:        bytes.NewReader(avatarPNG),
+			service: mockAvatarsServicer{
+				mockSetAvatar: func(userID int, body []byte) (*models.User, error) {
+					return nil, errors.InvalidArgument{Message: "Avatar must be a PNG, JPEG or GIF image"}
+				},
+			},
+			expectedStatus: 400,
+		},
+		{
+			name:        "missing user",
+			url:         "/users/9/avatar",
+			contentType: "image/png",
+			body:        bytes.NewReader(avatarPNG),
+			service: mockAvatarsServicer{
+				mockSetAvatar: func(userID int, body []byte) (*models.User, error) {
+					return nil, errors.NotFound{Message: "User with ID 9 not found"}
+				},
+			},
+			expectedStatus: 404,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Execute
+			w := serveAvatar(&test.service, "PUT", test.url, test.body, map[string]string{"Content-Type": test.contentType})
+
+			// Assert
+			if w.Code != test.expectedStatus {
+				t.Errorf("HTTP status code, expected: %d, got: %d (%s)", test.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetAvatar(t *testing.T) {
+	tests := []struct {
+		name           string
+		url            string
+		header         map[string]string
+		expectedStatus int
+		expectedBody   []byte
+	}{
+		{name: "image", url: "/users/7/avatar?size=64", expectedStatus: 200, expectedBody: avatarPNG},
+		{name: "range", url: "/users/7/avatar", header: map[string]string{"Range": "bytes=0-3"}, expectedStatus: 206, expectedBody: avatarPNG[:4]},
+		{name: "unsatisfiable range", url: "/users/7/avatar", header: map[string]string{"Range": "bytes=1000-"}, expectedStatus: 416},
+		{name: "not modified", url: "/users/7/avatar", header: map[string]string{"If-None-Match": `"0123"`}, expectedStatus: 304},
+		{name: "precondition failed", url: "/users/7/avatar", header: map[string]string{"If-Match": `"4567"`}, expectedStatus: 412},
+		{name: "negative size", url: "/users/7/avatar?size=-1", expectedStatus: 400},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Setup
+			var requestedSize int
+			service := &mockAvatarsServicer{
+				mockGetAvatar: func(userID, size int) (*models.Blob, error) {
+					requestedSize = size
+					return &models.Blob{Body: avatarPNG, ContentType: "image/png", ETag: `"0123"`}, nil
+				},
+			}
+
+			// Execute
+			w := serveAvatar(service, "GET", test.url, nil, test.header)
+
+			// Assert
+			if w.Code != test.expectedStatus {
+				t.Fatalf("HTTP status code, expected: %d, got: %d (%s)", test.expectedStatus, w.Code, w.Body.String())
+			}
+			if test.expectedBody != nil && !bytes.Equal(w.Body.Bytes(), test.expectedBody) {
+				t.Errorf("Body, expected: %q, got: %q", test.expectedBody, w.Body.Bytes())
+			}
+			if test.name == "image" && (requestedSize != 64 || w.Header().Get("ETag") != `"0123"` || w.Header().Get("Content-Type") != "image/png") {
+				t.Errorf("Response, expected: size 64 with ETag and Content-Type, got: %d, %v", requestedSize, w.Header())
+			}
+		})
+	}
+}
+
+func TestGetAvatarNotFound(t *testing.T) {
+	// Setup
+	service := &mockAvatarsServicer{
+		mockGetAvatar: func(userID, size int) (*models.Blob, error) {
+			return nil, errors.NotFound{Message: "User with ID 7 has no avatar"}
+		},
+	}
+
+	// Execute
+	w := serveAvatar(service, "GET", "/users/7/avatar", nil, nil)
+
+	// Assert
+	if w.Code != 404 {
+		t.Errorf("HTTP status code, expected: 404, got: %d", w.Code)
+	}
+}
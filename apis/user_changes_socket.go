@@ -0,0 +1,307 @@
+package apis
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/gorilla/websocket"
+	"github.com/jordantipton/golang-restful-webservice/apis/converters"
+	"github.com/jordantipton/golang-restful-webservice/apis/openapi"
+	"github.com/jordantipton/golang-restful-webservice/events"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+)
+
+const (
+	defaultSocketPingInterval   = 30 * time.Second
+	defaultSocketPongTimeout    = 60 * time.Second
+	defaultSocketSendBufferSize = 64
+	socketWriteTimeout          = 10 * time.Second
+	socketMaxMessageSize        = 4096
+	socketMaxSubscriptions      = 100
+)
+
+type (
+	// UserChangesSocket notifies WebSocket clients about changes to the users they subscribe to.
+	// It is fed the domain events published by the services through Publish, which never
+	// blocks: clients that fall SendBufferSize messages behind are disconnected instead.
+	UserChangesSocket struct {
+		// Authenticator checks the credentials of a connection before it is upgraded
+		Authenticator  *Authenticator
+		PingInterval   time.Duration
+		PongTimeout    time.Duration
+		SendBufferSize int
+
+		upgrader websocket.Upgrader
+		mu       sync.Mutex
+		clients  map[*socketClient]struct{}
+	}
+
+	// socketMessage is a subscription request sent by a client
+	socketMessage struct {
+		Action       string   `json:"action"`
+		UserIDs      []int    `json:"userIds"`
+		NamePrefixes []string `json:"namePrefixes"`
+	}
+
+	// socketError is sent to a client whose request could not be applied
+	socketError struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	}
+
+	socketClient struct {
+		conn        *websocket.Conn
+		send        chan []byte
+		closeReason string
+
+		mu           sync.Mutex
+		userIDs      map[int]struct{}
+		namePrefixes map[string]struct{}
+	}
+)
+
+// subscribeUserChangesOperation documents GET /ws
+var subscribeUserChangesOperation = openapi.Operation{
+	ID:          "subscribeUserChanges",
+	Summary:     "Subscribe to user changes over a WebSocket",
+	Description: "Clients send subscribe and unsubscribe messages with userIds and namePrefixes and receive the changes to the matching users. The connection authenticates with a bearer token or API key when the service requires credentials.",
+	Tags:        []string{"users", "events"},
+	Parameters: []openapi.Parameter{
+		{Name: APIKeyHeader, In: "header", Description: "API key, instead of a bearer token", Schema: &openapi.Schema{Type: "string"}},
+		{Name: "access_token", In: "query", Description: "Bearer token, for browsers that cannot set headers on WebSocket requests", Schema: &openapi.Schema{Type: "string"}},
+	},
+	Responses: []openapi.Response{
+		{Status: http.StatusSwitchingProtocols, Description: "The connection was upgraded to a WebSocket"},
+		textResponse(http.StatusBadRequest, "The request is not a WebSocket upgrade"),
+		textResponse(http.StatusUnauthorized, "The credentials are missing or invalid"),
+		textResponse(http.StatusForbidden, "The credentials are not valid for the tenant"),
+	},
+}
+
+// RegisterUserChangesSocket sets up the routing of the user changes WebSocket, fed by
+// the user events published to bus. Connections are long lived, so it must not be
+// registered behind a request timeout.
+func RegisterUserChangesSocket(router chi.Router, bus *events.Bus, authenticator *Authenticator) {
+	s := &UserChangesSocket{
+		Authenticator:  authenticator,
+		PingInterval:   defaultSocketPingInterval,
+		PongTimeout:    defaultSocketPongTimeout,
+		SendBufferSize: defaultSocketSendBufferSize,
+	}
+	bus.Subscribe("user-changes-socket", events.Sync, s.Publish)
+	router.Method(http.MethodGet, "/ws", openapi.Handler(subscribeUserChangesOperation, s.ServeHTTP))
+}
+
+// ServeHTTP authenticates the request, upgrades it and serves subscriptions until the
+// client goes away. A principal of another tenant than the request is forbidden.
+func (s *UserChangesSocket) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	authorization, apiKey := requestCredentials(req)
+	principal, err := s.Authenticator.Authenticate(authorization, apiKey)
+	if err == nil && principal != nil && TenantID(req.Context()) != "" && principal.TenantID != TenantID(req.Context()) {
+		err = errors.PermissionDenied{Message: "The credentials are not valid for tenant " + TenantID(req.Context())}
+	}
+	if err != nil {
+		writeAuthError(res, err)
+		return
+	}
+	conn, err := s.upgrader.Upgrade(res, req, nil)
+	if err != nil {
+		return // Upgrade has already replied to the client
+	}
+	client := &socketClient{
+		conn:         conn,
+		send:         make(chan []byte, s.SendBufferSize),
+		userIDs:      map[int]struct{}{},
+		namePrefixes: map[string]struct{}{},
+	}
+	s.register(client)
+	go s.writePump(client)
+	s.readPump(client)
+}
+
+// Publish notifies the interested clients of the user created, renamed, deleted or
+// given a new email by event. Other events are ignored. Events carry the current name
+// of the user, and renames reach the clients subscribed to the old name too, so that
+// they learn the user no longer matches.
+func (s *UserChangesSocket) Publish(event models.Event) {
+	switch event := event.(type) {
+	case models.UserCreated:
+		s.broadcast(&models.UserEvent{Type: models.UserEventCreated, UserID: event.User.ID, Name: event.User.Name, Email: event.User.Email}, "")
+	case models.UserRenamed:
+		s.broadcast(&models.UserEvent{Type: models.UserEventRenamed, UserID: event.UserID, Name: event.NewName}, event.OldName)
+	case models.UserEmailChanged:
+		s.broadcast(&models.UserEvent{Type: models.UserEventEmailChanged, UserID: event.UserID, Name: event.Name, Email: event.NewEmail}, "")
+	case models.UserDeleted:
+		s.broadcast(&models.UserEvent{Type: models.UserEventDeleted, UserID: event.UserID, Name: event.Name}, "")
+	}
+}
+
+// register adds a client
+func (s *UserChangesSocket) register(client *socketClient) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.clients == nil {
+		s.clients = map[*socketClient]struct{}{}
+	}
+	s.clients[client] = struct{}{}
+}
+
+// unregister removes a client and closes its send channel. A non-empty reason is sent
+// to the client in the close frame.
+func (s *UserChangesSocket) unregister(client *socketClient, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.clients[client]; !ok {
+		return
+	}
+	delete(s.clients, client)
+	client.closeReason = reason
+	close(client.send)
+}
+
+// broadcast queues event for every client interested in its user, or in oldName, the
+// name the user had if it is not empty, without blocking
+func (s *UserChangesSocket) broadcast(event *models.UserEvent, oldName string) {
+	data, err := json.Marshal(converters.ToUserEvent(event))
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	var slow []*socketClient
+	for client := range s.clients {
+		if !client.matches(event, oldName) {
+			continue
+		}
+		select {
+		case client.send <- data:
+		default:
+			slow = append(slow, client)
+		}
+	}
+	s.mu.Unlock()
+	for _, client := range slow {
+		s.unregister(client, "slow consumer")
+	}
+}
+
+func (s *UserChangesSocket) readPump(client *socketClient) {
+	defer func() {
+		s.unregister(client, "")
+		client.conn.Close()
+	}()
+	client.conn.SetReadLimit(socketMaxMessageSize)
+	client.conn.SetReadDeadline(time.Now().Add(s.PongTimeout))
+	client.conn.SetPongHandler(func(string) error {
+		return client.conn.SetReadDeadline(time.Now().Add(s.PongTimeout))
+	})
+	for {
+		_, data, err := client.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var message socketMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			s.reply(client, "Message must be a JSON subscription request")
+			continue
+		}
+		if err := client.apply(&message); err != "" {
+			s.reply(client, err)
+		}
+	}
+}
+
+func (s *UserChangesSocket) writePump(client *socketClient) {
+	ticker := time.NewTicker(s.PingInterval)
+	defer func() {
+		ticker.Stop()
+		client.conn.Close()
+	}()
+	for {
+		select {
+		case data, ok := <-client.send:
+			client.conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+			if !ok {
+				code := websocket.CloseNormalClosure
+				if client.closeReason != "" {
+					code = websocket.CloseTryAgainLater
+				}
+				client.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, client.closeReason))
+				return
+			}
+			if err := client.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			client.conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+			if err := client.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// reply sends an error message to the client, dropping it if the client is too far behind
+func (s *UserChangesSocket) reply(client *socketClient, message string) {
+	data, _ := json.Marshal(socketError{Type: "error", Message: message})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.clients[client]; !ok {
+		return
+	}
+	select {
+	case client.send <- data:
+	default:
+	}
+}
+
+// apply updates the client's subscriptions, returning a message if the request is invalid
+func (client *socketClient) apply(message *socketMessage) string {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	switch message.Action {
+	case "subscribe":
+		if len(client.userIDs)+len(message.UserIDs)+len(client.namePrefixes)+len(message.NamePrefixes) > socketMaxSubscriptions {
+			return "Too many subscriptions"
+		}
+		for _, userID := range message.UserIDs {
+			client.userIDs[userID] = struct{}{}
+		}
+		for _, prefix := range message.NamePrefixes {
+			if prefix == "" {
+				return "Name prefix cannot be empty"
+			}
+			client.namePrefixes[prefix] = struct{}{}
+		}
+	case "unsubscribe":
+		for _, userID := range message.UserIDs {
+			delete(client.userIDs, userID)
+		}
+		for _, prefix := range message.NamePrefixes {
+			delete(client.namePrefixes, prefix)
+		}
+	default:
+		return "Action must be subscribe or unsubscribe"
+	}
+	return ""
+}
+
+// matches reports whether the client subscribed to the user of event, or to a prefix of
+// its name or of oldName
+func (client *socketClient) matches(event *models.UserEvent, oldName string) bool {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if _, ok := client.userIDs[event.UserID]; ok {
+		return true
+	}
+	for prefix := range client.namePrefixes {
+		if strings.HasPrefix(event.Name, prefix) || (oldName != "" && strings.HasPrefix(oldName, prefix)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,229 @@
+package apis_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/jordantipton/golang-restful-webservice/apis"
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+	"github.com/jordantipton/golang-restful-webservice/auth"
+	models "github.com/jordantipton/golang-restful-webservice/models"
+)
+
+/*
+	Test objects
+*/
+
+func newUserChangesSocketServer(authenticator *apis.Authenticator, sendBufferSize int) (*apis.UserChangesSocket, *httptest.Server, string) {
+	socket := &apis.UserChangesSocket{
+		Authenticator:  authenticator,
+		PingInterval:   time.Second,
+		PongTimeout:    5 * time.Second,
+		SendBufferSize: sendBufferSize,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		socket.ServeHTTP(res, req.WithContext(apis.WithTenantID(req.Context(), "acme")))
+	}))
+	return socket, server, "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// subscribe dials url and subscribes to the users with userIDs and namePrefixes
+func subscribe(t *testing.T, url string, userIDs []int, namePrefixes []string) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial returned error: %s", err.Error())
+	}
+	if err := conn.WriteJSON(map[string]interface{}{"action": "subscribe", "userIds": userIDs, "namePrefixes": namePrefixes}); err != nil {
+		t.Fatalf("WriteJSON returned error: %s", err.Error())
+	}
+	// Let the socket apply the subscription
+	time.Sleep(20 * time.Millisecond)
+	return conn
+}
+
+/*
+	Test functions
+*/
+
+func TestUserChangesSocketFilters(t *testing.T) {
+	// Setup
+	socket, server, url := newUserChangesSocketServer(nil, 16)
+	defer server.Close()
+	conn := subscribe(t, url, []int{3}, []string{"Bo"})
+	defer conn.Close()
+
+	// Execute
+	socket.Publish(models.UserCreated{User: models.User{ID: 1, Name: "Carol"}})
+	socket.Publish(models.UserCreated{User: models.User{ID: 2, Name: "Bob"}})
+	socket.Publish(models.UserRenamed{UserID: 3, OldName: "Al", NewName: "Alice"})
+	socket.Publish(models.UserAvatarChanged{UserID: 3, Avatar: "v1"})
+
+	// Assert
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var received []dtos.UserEvent
+	for len(received) < 2 {
+		var event dtos.UserEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("ReadJSON returned error: %s", err.Error())
+		}
+		received = append(received, event)
+	}
+	if received[0].User.Name != "Bob" || received[1].User.Name != "Alice" {
+		t.Errorf("Events, expected: Bob, Alice, got: %s, %s", received[0].User.Name, received[1].User.Name)
+	}
+	if received[1].Type != models.UserEventRenamed {
+		t.Errorf("Type, expected: %s, got: %s", models.UserEventRenamed, received[1].Type)
+	}
+}
+
+func TestUserChangesSocketMatchesNamesOfEveryEvent(t *testing.T) {
+	// Setup
+	socket, server, url := newUserChangesSocketServer(nil, 16)
+	defer server.Close()
+	conn := subscribe(t, url, nil, []string{"Ada"})
+	defer conn.Close()
+
+	// Execute
+	socket.Publish(models.UserEmailChanged{UserID: 1, Name: "Ada", OldEmail: "ada@old.com", NewEmail: "ada@new.com"})
+	socket.Publish(models.UserEmailChanged{UserID: 2, Name: "Bob", OldEmail: "bob@old.com", NewEmail: "bob@new.com"})
+	socket.Publish(models.UserRenamed{UserID: 1, OldName: "Ada", NewName: "Grace"})
+	socket.Publish(models.UserDeleted{UserID: 3, Name: "Adam"})
+	socket.Publish(models.UserDeleted{UserID: 4, Name: "Carol"})
+
+	// Assert
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var received []dtos.UserEvent
+	for len(received) < 3 {
+		var event dtos.UserEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("ReadJSON returned error: %s", err.Error())
+		}
+		received = append(received, event)
+	}
+	expected := []dtos.UserEvent{
+		{Type: models.UserEventEmailChanged, User: dtos.User{ID: 1, Name: "Ada", Email: "ada@new.com"}},
+		{Type: models.UserEventRenamed, User: dtos.User{ID: 1, Name: "Grace"}},
+		{Type: models.UserEventDeleted, User: dtos.User{ID: 3, Name: "Adam"}},
+	}
+	for i := range expected {
+		if received[i].Type != expected[i].Type || received[i].User.ID != expected[i].User.ID ||
+			received[i].User.Name != expected[i].User.Name || received[i].User.Email != expected[i].User.Email {
+			t.Errorf("Event %d, expected: %+v, got: %+v", i, expected[i], received[i])
+		}
+	}
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	var extra dtos.UserEvent
+	if err := conn.ReadJSON(&extra); err == nil {
+		t.Errorf("Unexpected event: %+v", extra)
+	}
+}
+
+func TestUserChangesSocketInvalidMessage(t *testing.T) {
+	// Setup
+	_, server, url := newUserChangesSocketServer(nil, 16)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial returned error: %s", err.Error())
+	}
+	defer conn.Close()
+
+	// Execute
+	conn.WriteJSON(map[string]interface{}{"action": "publish"})
+
+	// Assert
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var reply map[string]string
+	if err := conn.ReadJSON(&reply); err != nil {
+		t.Fatalf("ReadJSON returned error: %s", err.Error())
+	}
+	if reply["type"] != "error" {
+		t.Errorf("Reply type, expected: error, got: %s", reply["type"])
+	}
+}
+
+func TestUserChangesSocketDropsSlowConsumer(t *testing.T) {
+	// Setup
+	socket, server, url := newUserChangesSocketServer(nil, 2)
+	defer server.Close()
+	conn := subscribe(t, url, []int{1}, nil)
+	defer conn.Close()
+
+	// Execute
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			socket.Publish(models.UserRenamed{UserID: 1, NewName: "Bob"})
+		}
+	}()
+
+	// Assert
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Publish blocked on a slow consumer")
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+		if !websocket.IsCloseError(err, websocket.CloseTryAgainLater) {
+			t.Errorf("Close error, expected: %d, got: %s", websocket.CloseTryAgainLater, err.Error())
+		}
+		break
+	}
+}
+
+func TestUserChangesSocketAuthentication(t *testing.T) {
+	secret := []byte("secret")
+	token := func(tenantID string) string {
+		token, err := auth.SignHS256(auth.Claims{"sub": "ada", "tenant": tenantID}, secret)
+		if err != nil {
+			t.Fatalf("SignHS256 returned error: %v", err)
+		}
+		return token
+	}
+	tests := []struct {
+		name           string
+		header         http.Header
+		query          string
+		expectedStatus int
+	}{
+		{name: "no credentials", expectedStatus: 401},
+		{name: "invalid token", header: http.Header{"Authorization": {"Bearer not-a-token"}}, expectedStatus: 401},
+		{name: "invalid API key", header: http.Header{apis.APIKeyHeader: {"wrong"}}, expectedStatus: 401},
+		{name: "token of another tenant", header: http.Header{"Authorization": {"Bearer " + token("globex")}}, expectedStatus: 403},
+		{name: "API key of another tenant", header: http.Header{apis.APIKeyHeader: {"globex-key"}}, expectedStatus: 403},
+		{name: "token", header: http.Header{"Authorization": {"Bearer " + token("acme")}}, expectedStatus: 101},
+		{name: "token in query", query: "?access_token=" + token("acme"), expectedStatus: 101},
+		{name: "API key", header: http.Header{apis.APIKeyHeader: {"acme-key"}}, expectedStatus: 101},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Setup
+			authenticator := &apis.Authenticator{JWTSecret: secret, APIKeys: map[string]string{"acme-key": "acme", "globex-key": "globex"}}
+			_, server, url := newUserChangesSocketServer(authenticator, 16)
+			defer server.Close()
+
+			// Execute
+			conn, resp, _ := websocket.DefaultDialer.Dial(url+test.query, test.header)
+
+			// Assert
+			if conn != nil {
+				conn.Close()
+			}
+			if resp == nil || resp.StatusCode != test.expectedStatus {
+				t.Errorf("HTTP status code, expected: %d, got: %v", test.expectedStatus, resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package apis
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/jordantipton/golang-restful-webservice/apis/converters"
+	"github.com/jordantipton/golang-restful-webservice/apis/openapi"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/services"
+)
+
+const (
+	defaultEventsPollInterval      = time.Second
+	defaultEventsHeartbeatInterval = 15 * time.Second
+	eventsBatchSize                = 100
+)
+
+type (
+	// UserEventsResourcer provides an interface for user event resources
+	UserEventsResourcer interface {
+		StreamUserEvents(res http.ResponseWriter, req *http.Request)
+	}
+
+	// UserEventsResource streams user change events as server-sent events
+	UserEventsResource struct {
+		Service           services.UserEventsServicer
+		PollInterval      time.Duration
+		HeartbeatInterval time.Duration
+	}
+)
+
+// streamUserEventsOperation documents GET /users/events
+var streamUserEventsOperation = openapi.Operation{
+	ID:          "streamUserEvents",
+	Summary:     "Stream user changes",
+	Description: "Server-sent events of user changes. Each event is a UserEvent in JSON.",
+	Tags:        []string{"users", "events"},
+	Parameters: []openapi.Parameter{
+		{Name: "Last-Event-ID", In: "header", Description: "Sequence number of the last event received", Schema: &openapi.Schema{Type: "integer", Format: "int64"}},
+		{Name: "lastEventId", In: "query", Description: "Sequence number of the last event received, for clients that cannot set headers", Schema: &openapi.Schema{Type: "integer", Format: "int64"}},
+	},
+	Responses: []openapi.Response{
+		{Status: http.StatusOK, MediaTypes: []string{"text/event-stream"}},
+		parameterErrorResponse("The last event ID is invalid"),
+	},
+}
+
+// RegisterUserEventsResource sets up the routing of the user events stream. The
+// stream is long lived, so it must not be registered behind a request timeout.
+func RegisterUserEventsResource(router chi.Router, service services.UserEventsServicer) {
+	r := &UserEventsResource{
+		Service:           service,
+		PollInterval:      defaultEventsPollInterval,
+		HeartbeatInterval: defaultEventsHeartbeatInterval,
+	}
+	router.Method(http.MethodGet, "/users/events", openapi.Handler(streamUserEventsOperation, r.StreamUserEvents))
+}
+
+// StreamUserEvents writes user change events to the client until it disconnects.
+// Clients resume from the Last-Event-ID header (or lastEventId query parameter).
+// Events are streamed once they are repositories.UserEventSettleTime old, so that
+// resuming does not skip an event committed out of sequence order, as long as its
+// transaction committed within that time of recording it.
+func (r *UserEventsResource) StreamUserEvents(res http.ResponseWriter, req *http.Request) {
+	flusher, ok := res.(http.Flusher)
+	if !ok {
+		http.Error(res, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	lastSeq, err := lastEventID(req)
+	if err != nil {
+		http.Error(res, "Last-Event-ID must be a non-negative integer", http.StatusBadRequest)
+		return
+	}
+	// Surface storage errors as a regular response before the stream starts
+	events, err := r.Service.ListUserEvents(lastSeq, eventsBatchSize)
+	if err != nil {
+		if _, ok := err.(errors.InvalidArgument); ok {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no") // Disable proxy buffering
+	res.WriteHeader(http.StatusOK)
+	fmt.Fprintf(res, "retry: %d\n\n", r.PollInterval.Milliseconds())
+	flusher.Flush()
+
+	poll := time.NewTicker(r.PollInterval)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(r.HeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		for _, event := range events {
+			data, err := json.Marshal(converters.ToUserEvent(event))
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(res, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, data)
+			lastSeq = event.Seq
+		}
+		if len(events) > 0 {
+			flusher.Flush()
+		}
+		if len(events) < eventsBatchSize {
+			select {
+			case <-req.Context().Done():
+				return
+			case <-heartbeat.C:
+				fmt.Fprint(res, ": heartbeat\n\n")
+				flusher.Flush()
+			case <-poll.C:
+			}
+		} else if req.Context().Err() != nil {
+			return
+		}
+		events, err = r.Service.ListUserEvents(lastSeq, eventsBatchSize)
+		if err != nil {
+			// Let the client reconnect with its Last-Event-ID
+			return
+		}
+	}
+}
+
+func lastEventID(req *http.Request) (int64, error) {
+	value := req.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = req.URL.Query().Get("lastEventId")
+	}
+	if value == "" {
+		return 0, nil
+	}
+	seq, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seq < 0 {
+		return 0, fmt.Errorf("invalid event ID %q", value)
+	}
+	return seq, nil
+}
@@ -0,0 +1,146 @@
+package apis_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jordantipton/golang-restful-webservice/apis"
+	models "github.com/jordantipton/golang-restful-webservice/models"
+)
+
+/*
+	Test objects
+*/
+
+type mockUserEventsServicer struct {
+	mockListUserEvents        func(afterSeq int64, limit int) ([]*models.UserEvent, error)
+	mockGetLatestUserEventSeq func() (int64, error)
+}
+
+func (m *mockUserEventsServicer) ListUserEvents(afterSeq int64, limit int) ([]*models.UserEvent, error) {
+	if m.mockListUserEvents != nil {
+		return m.mockListUserEvents(afterSeq, limit)
+	}
+	return nil, nil
+}
+
+func (m *mockUserEventsServicer) GetLatestUserEventSeq() (int64, error) {
+	if m.mockGetLatestUserEventSeq != nil {
+		return m.mockGetLatestUserEventSeq()
+	}
+	return 0, nil
+}
+
+/*
+	Test functions
+*/
+
+func TestStreamUserEventsResumesFromLastEventID(t *testing.T) {
+	// Setup
+	var requestedSeqs []int64
+	mockUserEventsServicer := mockUserEventsServicer{
+		mockListUserEvents: func(afterSeq int64, limit int) ([]*models.UserEvent, error) {
+			requestedSeqs = append(requestedSeqs, afterSeq)
+			if afterSeq == 5 {
+				return []*models.UserEvent{{Seq: 6, Type: models.UserEventCreated, UserID: 1, Name: "Name"}}, nil
+			}
+			return nil, nil
+		},
+	}
+	resource := apis.UserEventsResource{
+		Service:           &mockUserEventsServicer,
+		PollInterval:      5 * time.Millisecond,
+		HeartbeatInterval: time.Hour,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest("GET", "http://localhost:8080/users/events", nil).WithContext(ctx)
+	req.Header.Set("Last-Event-ID", "5")
+	w := httptest.NewRecorder()
+
+	// Execute
+	resource.StreamUserEvents(w, req)
+
+	// Assert
+	if w.Code != 200 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 200, w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Content-Type, expected: text/event-stream, got: %s", contentType)
+	}
+	expectedEvent := "id: 6\nevent: user.created\ndata: {\"seq\":6,\"type\":\"user.created\",\"user\":{\"id\":1,\"name\":\"Name\"}}\n\n"
+	if body := w.Body.String(); !strings.Contains(body, expectedEvent) {
+		t.Errorf("Response body, expected to contain: %q, got: %q", expectedEvent, body)
+	}
+	if len(requestedSeqs) < 2 || requestedSeqs[0] != 5 || requestedSeqs[1] != 6 {
+		t.Errorf("Requested sequences, expected to start with: [5 6], got: %v", requestedSeqs)
+	}
+}
+
+func TestStreamUserEventsHeartbeat(t *testing.T) {
+	// Setup
+	resource := apis.UserEventsResource{
+		Service:           &mockUserEventsServicer{},
+		PollInterval:      time.Hour,
+		HeartbeatInterval: 5 * time.Millisecond,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest("GET", "http://localhost:8080/users/events", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	// Execute
+	resource.StreamUserEvents(w, req)
+
+	// Assert
+	if body := w.Body.String(); !strings.Contains(body, ": heartbeat\n\n") {
+		t.Errorf("Response body, expected heartbeat comment, got: %q", body)
+	}
+}
+
+func TestStreamUserEventsBadLastEventID(t *testing.T) {
+	// Setup
+	r := chi.NewRouter()
+	apis.RegisterUserEventsResource(r, &mockUserEventsServicer{})
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/users/events", nil)
+	req.Header.Set("Last-Event-ID", "abc")
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 400 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 400, w.Code)
+	}
+}
+
+func TestStreamUserEventsError(t *testing.T) {
+	// Setup
+	mockUserEventsServicer := mockUserEventsServicer{
+		mockListUserEvents: func(afterSeq int64, limit int) ([]*models.UserEvent, error) {
+			return nil, mockError("some error")
+		},
+	}
+	r := chi.NewRouter()
+	apis.RegisterUserEventsResource(r, &mockUserEventsServicer)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/users/events", nil)
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 500 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 500, w.Code)
+	}
+}
@@ -0,0 +1,191 @@
+package apis
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/jordantipton/golang-restful-webservice/apis/converters"
+	"github.com/jordantipton/golang-restful-webservice/apis/openapi"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/services"
+	"github.com/parquet-go/parquet-go"
+)
+
+// Export formats
+const (
+	ExportCSV     = "csv"
+	ExportNDJSON  = "ndjson"
+	ExportParquet = "parquet"
+)
+
+const (
+	// parquetRowGroupSize bounds the rows buffered in memory before a row group is written
+	parquetRowGroupSize = 10000
+	// exportFlushInterval is the number of users written between flushes to the client
+	exportFlushInterval = 1000
+)
+
+type (
+	// UserExportResource streams users in bulk
+	UserExportResource struct {
+		Service services.UsersServicer
+	}
+
+	// userExportWriter encodes users to an export stream
+	userExportWriter interface {
+		Write(user *models.User) error
+		Close() error
+	}
+
+	csvExportWriter struct {
+		writer *csv.Writer
+		record []string
+	}
+
+	ndjsonExportWriter struct {
+		encoder *json.Encoder
+	}
+
+	parquetUser struct {
+		ID    int64  `parquet:"id"`
+		Name  string `parquet:"name"`
+		Email string `parquet:"email"`
+	}
+
+	parquetExportWriter struct {
+		writer *parquet.GenericWriter[parquetUser]
+		rows   []parquetUser
+	}
+)
+
+// exportUsersOperation documents GET /users:export
+var exportUsersOperation = openapi.Operation{
+	ID:          "exportUsers",
+	Summary:     "Export users",
+	Description: "Streams every user matching the filter as a file download.",
+	Tags:        []string{"users"},
+	Parameters:  exportParameters,
+	Responses: []openapi.Response{
+		{Status: http.StatusOK, MediaTypes: []string{"text/csv", "application/x-ndjson", "application/vnd.apache.parquet"}},
+		parameterErrorResponse("The format is not supported"),
+	},
+}
+
+// RegisterUserExportResource sets up the routing of the export endpoint. Exports can
+// take longer than a request timeout, so it must not be registered behind one.
+func RegisterUserExportResource(router chi.Router, service services.UsersServicer) {
+	r := &UserExportResource{Service: service}
+	router.Method(http.MethodGet, "/users:export", openapi.Handler(exportUsersOperation, r.ExportUsers))
+}
+
+// ExportUsers streams all users, or those whose name starts with name_prefix, as
+// csv, ndjson or parquet
+func (r *UserExportResource) ExportUsers(res http.ResponseWriter, req *http.Request) {
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = ExportCSV
+	}
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		http.Error(res, "format must be one of csv, ndjson or parquet", http.StatusBadRequest)
+		return
+	}
+	res.Header().Set("Content-Type", contentType)
+	res.Header().Set("Content-Disposition", "attachment; filename=\"users."+format+"\"")
+	writer := newUserExportWriter(format, res)
+	flusher, _ := res.(http.Flusher)
+	count := 0
+	filter := models.UserFilter{NamePrefix: req.URL.Query().Get("name_prefix")}
+	err := r.Service.ExportUsers(filter, func(user *models.User) error {
+		if err := writer.Write(user); err != nil {
+			return err
+		}
+		if count++; count%exportFlushInterval == 0 && flusher != nil {
+			flusher.Flush()
+		}
+		return req.Context().Err()
+	})
+	if err != nil {
+		if count == 0 {
+			// Nothing has been sent yet, so the status can still be changed
+			res.Header().Del("Content-Disposition")
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+		}
+		// Otherwise abandon the truncated body; the client sees an incomplete response
+		return
+	}
+	writer.Close()
+}
+
+var exportContentTypes = map[string]string{
+	ExportCSV:     "text/csv",
+	ExportNDJSON:  "application/x-ndjson",
+	ExportParquet: "application/vnd.apache.parquet",
+}
+
+func newUserExportWriter(format string, w io.Writer) userExportWriter {
+	switch format {
+	case ExportNDJSON:
+		return &ndjsonExportWriter{encoder: json.NewEncoder(w)}
+	case ExportParquet:
+		return &parquetExportWriter{writer: parquet.NewGenericWriter[parquetUser](w)}
+	default:
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"id", "name", "email"})
+		return &csvExportWriter{writer: writer, record: make([]string, 3)}
+	}
+}
+
+// Write CSV row
+func (c *csvExportWriter) Write(user *models.User) error {
+	c.record[0], c.record[1], c.record[2] = strconv.Itoa(user.ID), user.Name, user.Email
+	return c.writer.Write(c.record)
+}
+
+// Close flushes buffered CSV rows
+func (c *csvExportWriter) Close() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// Write NDJSON line
+func (n *ndjsonExportWriter) Write(user *models.User) error {
+	return n.encoder.Encode(converters.ToUser(user))
+}
+
+// Close is a no-op; every line is written as it is encoded
+func (n *ndjsonExportWriter) Close() error {
+	return nil
+}
+
+// Write buffers a Parquet row, writing a row group once enough rows have accumulated
+func (p *parquetExportWriter) Write(user *models.User) error {
+	p.rows = append(p.rows, parquetUser{ID: int64(user.ID), Name: user.Name, Email: user.Email})
+	if len(p.rows) < parquetRowGroupSize {
+		return nil
+	}
+	return p.flush()
+}
+
+// Close writes the remaining rows and the Parquet footer
+func (p *parquetExportWriter) Close() error {
+	if err := p.flush(); err != nil {
+		return err
+	}
+	return p.writer.Close()
+}
+
+func (p *parquetExportWriter) flush() error {
+	if len(p.rows) == 0 {
+		return nil
+	}
+	if _, err := p.writer.Write(p.rows); err != nil {
+		return err
+	}
+	p.rows = p.rows[:0]
+	return p.writer.Flush()
+}
@@ -0,0 +1,127 @@
+package apis_test
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/jordantipton/golang-restful-webservice/apis"
+	models "github.com/jordantipton/golang-restful-webservice/models"
+)
+
+func exportUsers(service *mockUsersServicer, query string) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	apis.RegisterUserExportResource(r, service)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/users:export"+query, nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func exportTwoUsers(filter models.UserFilter, fn func(user *models.User) error) error {
+	for _, user := range []*models.User{{ID: 1, Name: "Alice"}, {ID: 2, Name: "Bob, Jr.", Email: "bob@example.com"}} {
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestExportUsersCSV(t *testing.T) {
+	// Setup
+	var requestedFilter models.UserFilter
+	mockUsersServicer := mockUsersServicer{
+		mockExportUsers: func(filter models.UserFilter, fn func(user *models.User) error) error {
+			requestedFilter = filter
+			return exportTwoUsers(filter, fn)
+		},
+	}
+
+	// Execute
+	w := exportUsers(&mockUsersServicer, "?name_prefix=A")
+
+	// Assert
+	if w.Code != 200 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 200, w.Code)
+	}
+	if requestedFilter.NamePrefix != "A" {
+		t.Errorf("NamePrefix, expected: A, got: %s", requestedFilter.NamePrefix)
+	}
+	expectedBody := "id,name,email\n1,Alice,\n2,\"Bob, Jr.\",bob@example.com\n"
+	if body := w.Body.String(); body != expectedBody {
+		t.Errorf("Response body, expected: %q, got: %q", expectedBody, body)
+	}
+}
+
+func TestExportUsersNDJSON(t *testing.T) {
+	// Setup
+	mockUsersServicer := mockUsersServicer{mockExportUsers: exportTwoUsers}
+
+	// Execute
+	w := exportUsers(&mockUsersServicer, "?format=ndjson")
+
+	// Assert
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/x-ndjson" {
+		t.Errorf("Content-Type, expected: application/x-ndjson, got: %s", contentType)
+	}
+	expectedBody := "{\"id\":1,\"name\":\"Alice\"}\n{\"id\":2,\"name\":\"Bob, Jr.\",\"email\":\"bob@example.com\"}\n"
+	if body := w.Body.String(); body != expectedBody {
+		t.Errorf("Response body, expected: %q, got: %q", expectedBody, body)
+	}
+}
+
+func TestExportUsersParquet(t *testing.T) {
+	// Setup
+	mockUsersServicer := mockUsersServicer{mockExportUsers: exportTwoUsers}
+
+	// Execute
+	w := exportUsers(&mockUsersServicer, "?format=parquet")
+
+	// Assert
+	type row struct {
+		ID   int64  `parquet:"id"`
+		Name string `parquet:"name"`
+	}
+	rows, err := parquet.Read[row](bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("Failed to read parquet body. Error: %s", err.Error())
+	}
+	if len(rows) != 2 || rows[1].ID != 2 || rows[1].Name != "Bob, Jr." {
+		t.Errorf("Rows, expected: Alice, Bob, Jr., got: %v", rows)
+	}
+}
+
+func TestExportUsersUnknownFormat(t *testing.T) {
+	// Execute
+	w := exportUsers(&mockUsersServicer{}, "?format=xlsx")
+
+	// Assert
+	if w.Code != 400 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 400, w.Code)
+	}
+}
+
+func TestExportUsersErrorBeforeFirstRow(t *testing.T) {
+	// Setup
+	mockUsersServicer := mockUsersServicer{
+		mockExportUsers: func(filter models.UserFilter, fn func(user *models.User) error) error {
+			return mockError("some error")
+		},
+	}
+
+	// Execute
+	w := exportUsers(&mockUsersServicer, "")
+
+	// Assert
+	if w.Code != 500 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 500, w.Code)
+	}
+	if body := w.Body.String(); !strings.HasPrefix(body, "some error") {
+		t.Errorf("Response body, expected: some error, got: %q", body)
+	}
+}
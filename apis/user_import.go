@@ -0,0 +1,276 @@
+package apis
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jordantipton/golang-restful-webservice/apis/converters"
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/services"
+)
+
+// importChunkSize is the number of rows written per transaction
+const importChunkSize = 500
+
+type (
+	// userRowReader reads users from an import stream. Next returns io.EOF at the end of
+	// the stream and a rowError for a row that cannot be parsed; any other error is fatal.
+	userRowReader interface {
+		Next() (*dtos.User, error)
+	}
+
+	rowError struct {
+		message string
+	}
+
+	csvUserReader struct {
+		reader  *csv.Reader
+		columns map[string]int
+	}
+
+	ndjsonUserReader struct {
+		scanner *bufio.Scanner
+	}
+
+	// userImporter imports the rows of a userRowReader chunk by chunk
+	userImporter struct {
+		service   services.UsersServicer
+		options   models.ImportOptions
+		rows      userRowReader
+		rowNumber int
+		// jobID is the import job recording its progress with each chunk, if any
+		jobID int
+	}
+
+	// importStreamError reports an import body that cannot be read any further
+	importStreamError struct {
+		error
+	}
+)
+
+func (e rowError) Error() string { return e.message }
+
+func (e importStreamError) Unwrap() error { return e.error }
+
+// ImportUsers reads users from a text/csv or application/x-ndjson body and creates them
+// in chunks. CSV bodies need a header row with a name column and optional id and email
+// columns. The response reports the outcome of every row. Chunks that were written
+// before a conflict in on_conflict=fail mode, or before a malformed or too large
+// stream, stay written. Bodies may be sent with Content-Encoding gzip or zstd.
+func (r *UsersResource) ImportUsers(res http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	codec, ok := negotiate(res, req, r.codecs(), (*dtos.ImportReport)(nil))
+	if !ok {
+		return
+	}
+	options, mediaType, ok := parseImportRequest(res, req)
+	if !ok {
+		return
+	}
+	rows, err := newUserRowReader(mediaType, req.Body)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report := dtos.ImportReport{DryRun: options.DryRun, Rows: []dtos.ImportRow{}}
+	status := http.StatusOK
+	importer := &userImporter{service: r.Service, options: options, rows: rows}
+	for done := false; !done; {
+		chunkRows, err := importer.nextChunk()
+		for _, row := range chunkRows {
+			addImportRow(&report, row)
+		}
+		switch err.(type) {
+		case nil:
+			continue
+		case importStreamError:
+			report.Error = err.Error()
+			status = http.StatusBadRequest
+			if bodyTooLarge(err) {
+				status = http.StatusRequestEntityTooLarge
+			}
+		case errors.AlreadyExists:
+			report.Error = "Import stopped: " + err.Error()
+			status = http.StatusConflict
+		case errors.InvalidArgument:
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		default:
+			if err != io.EOF {
+				http.Error(res, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		done = true
+	}
+	render(res, codec, status, &report)
+}
+
+// nextChunk reads up to importChunkSize rows and imports them. It returns the outcome
+// of every row read, in row order, and io.EOF after the last chunk. A malformed stream
+// ends the import with an importStreamError after the rows read so far are imported,
+// and a conflict in on_conflict=fail mode ends it with errors.AlreadyExists. Any other
+// error means the chunk was not imported and no rows are returned.
+func (i *userImporter) nextChunk() ([]dtos.ImportRow, error) {
+	var report []dtos.ImportRow
+	var chunk []*models.User
+	var chunkRows []int
+	var end error
+	for end == nil && len(chunk) < importChunkSize {
+		user, err := i.rows.Next()
+		switch err.(type) {
+		case nil:
+			i.rowNumber++
+			chunk = append(chunk, converters.FromUser(user))
+			chunkRows = append(chunkRows, i.rowNumber)
+		case rowError:
+			i.rowNumber++
+			report = append(report, dtos.ImportRow{Row: i.rowNumber, Status: models.ImportInvalid, Error: err.Error()})
+		default:
+			end = err
+			if err != io.EOF {
+				end = importStreamError{err}
+			}
+		}
+	}
+	if len(chunk) > 0 {
+		options := i.options
+		if i.jobID != 0 {
+			options.Checkpoint = &models.ImportCheckpoint{JobID: i.jobID, Progress: int64(i.rowNumber)}
+		}
+		results, err := i.service.ImportUsers(chunk, options)
+		if results == nil {
+			return nil, err
+		}
+		for j, result := range results {
+			report = append(report, *converters.ToImportRow(chunkRows[j], &result))
+		}
+		if _, ok := err.(errors.AlreadyExists); ok {
+			end = err
+		}
+	}
+	// Unparseable rows are reported before the chunk they belong to is written
+	sort.SliceStable(report, func(a, b int) bool { return report[a].Row < report[b].Row })
+	return report, end
+}
+
+// parseImportRequest reads the import options and the media type of the body of req.
+// It writes an error response and returns false if either is invalid.
+func parseImportRequest(res http.ResponseWriter, req *http.Request) (models.ImportOptions, string, bool) {
+	options := models.ImportOptions{OnConflict: req.URL.Query().Get("on_conflict")}
+	if dryRun := req.URL.Query().Get("dry_run"); dryRun != "" {
+		var err error
+		if options.DryRun, err = strconv.ParseBool(dryRun); err != nil {
+			http.Error(res, "dry_run must be a boolean", http.StatusBadRequest)
+			return options, "", false
+		}
+	}
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if mediaType != "text/csv" && mediaType != "application/x-ndjson" {
+		http.Error(res, "Content-Type must be text/csv or application/x-ndjson", http.StatusUnsupportedMediaType)
+		return options, "", false
+	}
+	return options, mediaType, true
+}
+
+// newUserRowReader reads body as text/csv or application/x-ndjson
+func newUserRowReader(mediaType string, body io.Reader) (userRowReader, error) {
+	if mediaType == "text/csv" {
+		reader, err := newCSVUserReader(body)
+		if err != nil {
+			return nil, err
+		}
+		return reader, nil
+	}
+	return newNDJSONUserReader(body), nil
+}
+
+// addImportRow appends row to report and updates the totals
+func addImportRow(report *dtos.ImportReport, row dtos.ImportRow) {
+	report.Rows = append(report.Rows, row)
+	switch row.Status {
+	case models.ImportCreated:
+		report.Created++
+	case models.ImportUpdated:
+		report.Updated++
+	case models.ImportSkipped:
+		report.Skipped++
+	default:
+		report.Failed++
+	}
+}
+
+func newCSVUserReader(body io.Reader) (*csvUserReader, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("CSV header row is required: %s", err.Error())
+	}
+	columns := map[string]int{}
+	for i, column := range header {
+		columns[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, fmt.Errorf("CSV header must include a name column")
+	}
+	return &csvUserReader{reader: reader, columns: columns}, nil
+}
+
+// Next CSV row
+func (c *csvUserReader) Next() (*dtos.User, error) {
+	record, err := c.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	user := dtos.User{}
+	if i := c.columns["name"]; i < len(record) {
+		user.Name = record[i]
+	}
+	if i, ok := c.columns["email"]; ok && i < len(record) {
+		user.Email = record[i]
+	}
+	if i, ok := c.columns["id"]; ok && i < len(record) && record[i] != "" {
+		if user.ID, err = strconv.Atoi(record[i]); err != nil {
+			return nil, rowError{"id must be an integer"}
+		}
+	}
+	return &user, nil
+}
+
+func newNDJSONUserReader(body io.Reader) *ndjsonUserReader {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	return &ndjsonUserReader{scanner: scanner}
+}
+
+// Next NDJSON line. Blank lines are skipped.
+func (n *ndjsonUserReader) Next() (*dtos.User, error) {
+	for n.scanner.Scan() {
+		line := strings.TrimSpace(n.scanner.Text())
+		if line == "" {
+			continue
+		}
+		user := dtos.User{}
+		if err := json.Unmarshal([]byte(line), &user); err != nil {
+			return nil, rowError{"Row must be a JSON user object"}
+		}
+		return &user, nil
+	}
+	if err := n.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
@@ -0,0 +1,144 @@
+package apis_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jordantipton/golang-restful-webservice/apis"
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+	models "github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+)
+
+// createAll pretends every imported user is created with sequential IDs from 100
+func createAll(users []*models.User, options models.ImportOptions) ([]models.ImportResult, error) {
+	results := make([]models.ImportResult, len(users))
+	for i, user := range users {
+		if user.Name == "" {
+			results[i] = models.ImportResult{Status: models.ImportInvalid, Error: "User name cannot be empty"}
+			continue
+		}
+		results[i] = models.ImportResult{ID: 100 + i, Status: models.ImportCreated}
+	}
+	return results, nil
+}
+
+func importUsers(service *mockUsersServicer, contentType, query, body string) (int, dtos.ImportReport) {
+	r := chi.NewRouter()
+	apis.RegisterUsersResource(r, service)
+
+	req := httptest.NewRequest("POST", "http://localhost:8080/users:import"+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	report := dtos.ImportReport{}
+	json.NewDecoder(w.Body).Decode(&report)
+	return w.Code, report
+}
+
+func TestImportUsersCSV(t *testing.T) {
+	// Setup
+	var requested []*models.User
+	var requestedOptions models.ImportOptions
+	mockUsersServicer := mockUsersServicer{
+		mockImportUsers: func(users []*models.User, options models.ImportOptions) ([]models.ImportResult, error) {
+			requested, requestedOptions = users, options
+			return createAll(users, options)
+		},
+	}
+	body := "id,name\n,Alice\nabc,Bob\n7,Carol\n,\n"
+
+	// Execute
+	code, report := importUsers(&mockUsersServicer, "text/csv; charset=utf-8", "?on_conflict=skip", body)
+
+	// Assert
+	if code != 200 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 200, code)
+	}
+	if requestedOptions.OnConflict != models.ImportConflictSkip {
+		t.Errorf("OnConflict, expected: skip, got: %s", requestedOptions.OnConflict)
+	}
+	if len(requested) != 3 || requested[1].ID != 7 || requested[1].Name != "Carol" {
+		t.Errorf("Imported users, expected: Alice, 7 Carol and an empty row, got: %v", requested)
+	}
+	if report.Created != 2 || report.Failed != 2 || len(report.Rows) != 4 {
+		t.Errorf("Report, expected: 2 created 2 failed 4 rows, got: %+v", report)
+	}
+	if report.Rows[1].Row != 2 || report.Rows[1].Status != models.ImportInvalid {
+		t.Errorf("Row 2, expected: invalid, got: %+v", report.Rows[1])
+	}
+}
+
+func TestImportUsersNDJSONDryRun(t *testing.T) {
+	// Setup
+	var requestedOptions models.ImportOptions
+	mockUsersServicer := mockUsersServicer{
+		mockImportUsers: func(users []*models.User, options models.ImportOptions) ([]models.ImportResult, error) {
+			requestedOptions = options
+			return createAll(users, options)
+		},
+	}
+	body := "{\"name\":\"Alice\"}\n\nnot json\n{\"name\":\"Bob\"}\n"
+
+	// Execute
+	code, report := importUsers(&mockUsersServicer, "application/x-ndjson", "?dry_run=true", body)
+
+	// Assert
+	if code != 200 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 200, code)
+	}
+	if !requestedOptions.DryRun || !report.DryRun {
+		t.Errorf("DryRun, expected: true, got: %v %v", requestedOptions.DryRun, report.DryRun)
+	}
+	if report.Created != 2 || report.Failed != 1 {
+		t.Errorf("Report, expected: 2 created 1 failed, got: %+v", report)
+	}
+}
+
+func TestImportUsersConflictStops(t *testing.T) {
+	// Setup
+	mockUsersServicer := mockUsersServicer{
+		mockImportUsers: func(users []*models.User, options models.ImportOptions) ([]models.ImportResult, error) {
+			return []models.ImportResult{
+				{Status: models.ImportAborted},
+				{ID: 7, Status: models.ImportConflict, Error: "User with ID 7 already exists"},
+			}, errors.AlreadyExists{Message: "1 users already exist"}
+		},
+	}
+
+	// Execute
+	code, report := importUsers(&mockUsersServicer, "text/csv", "", "id,name\n,Alice\n7,Carol\n")
+
+	// Assert
+	if code != 409 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 409, code)
+	}
+	if report.Failed != 2 || report.Error == "" {
+		t.Errorf("Report, expected: 2 failed with error, got: %+v", report)
+	}
+}
+
+func TestImportUsersUnsupportedMediaType(t *testing.T) {
+	// Execute
+	code, _ := importUsers(&mockUsersServicer{}, "application/json", "", "[]")
+
+	// Assert
+	if code != 415 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 415, code)
+	}
+}
+
+func TestImportUsersCSVWithoutNameColumn(t *testing.T) {
+	// Execute
+	code, _ := importUsers(&mockUsersServicer{}, "text/csv", "", "id\n1\n")
+
+	// Assert
+	if code != 400 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 400, code)
+	}
+}
@@ -0,0 +1,277 @@
+package apis
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi"
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+	"github.com/jordantipton/golang-restful-webservice/apis/openapi"
+	"github.com/jordantipton/golang-restful-webservice/jobs"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/services"
+)
+
+// User job types
+const (
+	ImportUsersJob = "users.import"
+	ExportUsersJob = "users.export"
+)
+
+// importRowsFile keeps the reported rows of an import job so a resumed job can skip them
+const importRowsFile = "rows.ndjson"
+
+type (
+	// UserJobsResource starts imports and exports as background jobs
+	UserJobsResource struct {
+		Jobs jobs.Scheduler
+	}
+
+	importJobParams struct {
+		MediaType string               `json:"mediaType"`
+		Options   models.ImportOptions `json:"options"`
+	}
+
+	exportJobParams struct {
+		Format     string `json:"format"`
+		NamePrefix string `json:"namePrefix"`
+	}
+)
+
+// RegisterUserJobs registers the handlers of the user job types with runner
+func RegisterUserJobs(runner *jobs.Runner, service services.UsersServicer) {
+	runner.Register(ImportUsersJob, importUsersJob(service))
+	runner.Register(ExportUsersJob, exportUsersJob(service))
+}
+
+var (
+	// importUsersAsyncOperation documents POST /users:importAsync
+	importUsersAsyncOperation = openapi.Operation{
+		ID:                "importUsersAsync",
+		Summary:           "Import users in a background job",
+		Description:       "Accepts the same body and parameters as importUsers. The job artifact is the import report.",
+		Tags:              []string{"users", "jobs"},
+		Parameters:        importParameters,
+		RequestMediaTypes: importMediaTypes,
+		Responses: []openapi.Response{
+			jobAcceptedResponse,
+			parameterErrorResponse("The query parameters are invalid"),
+			textResponse(http.StatusRequestEntityTooLarge, "The decompressed body is larger than 256 MiB"),
+			textResponse(http.StatusUnsupportedMediaType, "The body is not CSV or NDJSON, or has an unsupported Content-Encoding"),
+		},
+	}
+
+	// exportUsersAsyncOperation documents POST /users:exportAsync
+	exportUsersAsyncOperation = openapi.Operation{
+		ID:          "exportUsersAsync",
+		Summary:     "Export users in a background job",
+		Description: "Accepts the same parameters as exportUsers. The job artifact is the export file.",
+		Tags:        []string{"users", "jobs"},
+		Parameters:  exportParameters,
+		Responses: []openapi.Response{
+			jobAcceptedResponse,
+			parameterErrorResponse("The format is not supported"),
+		},
+	}
+)
+
+// RegisterUserJobsResource sets up the routing of the asynchronous import and export
+// endpoints. Uploads can outlast a request timeout, so it must not be registered
+// behind one.
+func RegisterUserJobsResource(router chi.Router, scheduler jobs.Scheduler) {
+	r := &UserJobsResource{Jobs: scheduler}
+	router.With(DecompressRequest).Method(http.MethodPost, "/users:importAsync", openapi.Handler(importUsersAsyncOperation, r.ImportUsers))
+	router.Method(http.MethodPost, "/users:exportAsync", openapi.Handler(exportUsersAsyncOperation, r.ExportUsers))
+}
+
+// ImportUsers stores the body and imports it in a background job. It accepts the same
+// body and query parameters as POST /users:import; the job artifact is the import report.
+func (r *UserJobsResource) ImportUsers(res http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	options, mediaType, ok := parseImportRequest(res, req)
+	if !ok {
+		return
+	}
+	job, err := r.Jobs.Submit(ImportUsersJob, importJobParams{MediaType: mediaType, Options: options}, req.Body)
+	if err != nil {
+		writeJobError(res, err)
+		return
+	}
+	writeJobAccepted(res, job)
+}
+
+// ExportUsers exports users in a background job. It accepts the same query parameters
+// as GET /users:export; the job artifact is the export file.
+func (r *UserJobsResource) ExportUsers(res http.ResponseWriter, req *http.Request) {
+	params := exportJobParams{Format: req.URL.Query().Get("format"), NamePrefix: req.URL.Query().Get("name_prefix")}
+	if params.Format == "" {
+		params.Format = ExportCSV
+	}
+	if _, ok := exportContentTypes[params.Format]; !ok {
+		http.Error(res, "format must be one of csv, ndjson or parquet", http.StatusBadRequest)
+		return
+	}
+	job, err := r.Jobs.Submit(ExportUsersJob, params, nil)
+	if err != nil {
+		writeJobError(res, err)
+		return
+	}
+	writeJobAccepted(res, job)
+}
+
+// importUsersJob imports the job input chunk by chunk and reports progress in rows.
+// Progress is recorded in the transaction writing each chunk, so a resumed job
+// continues after the last written chunk and never imports a chunk twice. The report
+// rows of a chunk interrupted after it was written are missing from the report.
+// Cancelling the job keeps the chunks already written.
+func importUsersJob(service services.UsersServicer) jobs.Handler {
+	return func(ctx context.Context, run *jobs.Run) error {
+		var params importJobParams
+		if err := run.Params(&params); err != nil {
+			return err
+		}
+		input, err := run.Input()
+		if err != nil {
+			return err
+		}
+		defer input.Close()
+		rows, err := newUserRowReader(params.MediaType, input)
+		if err != nil {
+			return err
+		}
+		report := dtos.ImportReport{DryRun: params.Options.DryRun, Rows: []dtos.ImportRow{}}
+		rowsFile, err := resumeImportRows(run, &report)
+		if err != nil {
+			return err
+		}
+		defer rowsFile.Close()
+
+		importer := &userImporter{service: service, options: params.Options, rows: rows, jobID: run.Job.ID}
+		for int64(importer.rowNumber) < run.Job.Progress {
+			if _, err := rows.Next(); err != nil {
+				if _, ok := err.(rowError); !ok {
+					return err
+				}
+			}
+			importer.rowNumber++
+		}
+		encoder := json.NewEncoder(rowsFile)
+		for done := false; !done; {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			chunkRows, err := importer.nextChunk()
+			switch err.(type) {
+			case nil:
+			case importStreamError:
+				report.Error = err.Error()
+				done = true
+			case errors.AlreadyExists:
+				report.Error = "Import stopped: " + err.Error()
+				done = true
+			default:
+				if err != io.EOF {
+					return err
+				}
+				done = true
+			}
+			for _, row := range chunkRows {
+				addImportRow(&report, row)
+				if err := encoder.Encode(row); err != nil {
+					return err
+				}
+			}
+			if err := run.SetProgress(int64(importer.rowNumber), 0); err != nil {
+				return err
+			}
+		}
+
+		artifact, err := run.CreateArtifact("import-report.json", "application/json")
+		if err != nil {
+			return err
+		}
+		if err := json.NewEncoder(artifact).Encode(report); err != nil {
+			artifact.Close()
+			return err
+		}
+		return artifact.Close()
+	}
+}
+
+// resumeImportRows adds the rows reported before the recorded progress of run to
+// report and returns the rows file, positioned for appending the next rows
+func resumeImportRows(run *jobs.Run, report *dtos.ImportReport) (*os.File, error) {
+	file, err := os.OpenFile(run.Path(importRowsFile), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	var kept int64
+	decoder := json.NewDecoder(file)
+	for {
+		var row dtos.ImportRow
+		if err := decoder.Decode(&row); err != nil || int64(row.Row) > run.Job.Progress {
+			break
+		}
+		addImportRow(report, row)
+		// Every row is followed by a newline
+		kept = decoder.InputOffset() + 1
+	}
+	// Drop rows that were written after the last recorded progress
+	if err := file.Truncate(kept); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(kept, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// exportUsersJob writes the export file as the job artifact. An interrupted export
+// starts over when it is resumed.
+func exportUsersJob(service services.UsersServicer) jobs.Handler {
+	return func(ctx context.Context, run *jobs.Run) error {
+		var params exportJobParams
+		if err := run.Params(&params); err != nil {
+			return err
+		}
+		artifact, err := run.CreateArtifact("users."+params.Format, exportContentTypes[params.Format])
+		if err != nil {
+			return err
+		}
+		defer artifact.Close()
+		buffered := bufio.NewWriter(artifact)
+		writer := newUserExportWriter(params.Format, buffered)
+		var count int64
+		err = service.ExportUsers(models.UserFilter{NamePrefix: params.NamePrefix}, func(user *models.User) error {
+			if err := writer.Write(user); err != nil {
+				return err
+			}
+			if count++; count%exportFlushInterval == 0 {
+				if err := run.SetProgress(count, 0); err != nil {
+					return err
+				}
+			}
+			return ctx.Err()
+		})
+		if err != nil {
+			return err
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+		if err := buffered.Flush(); err != nil {
+			return err
+		}
+		if err := artifact.Sync(); err != nil {
+			return err
+		}
+		return run.SetProgress(count, count)
+	}
+}
@@ -0,0 +1,225 @@
+package apis_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jordantipton/golang-restful-webservice/apis"
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+	"github.com/jordantipton/golang-restful-webservice/jobs"
+	models "github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+)
+
+/*
+	Test objects
+*/
+
+// memoryJobsPersister stores jobs in memory
+type memoryJobsPersister struct {
+	mu   sync.Mutex
+	jobs []models.Job
+}
+
+func (m *memoryJobsPersister) CreateJob(job *models.Job) (*models.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	created := *job
+	created.ID = len(m.jobs) + 1
+	m.jobs = append(m.jobs, created)
+	return &created, nil
+}
+
+func (m *memoryJobsPersister) GetJob(jobID int) (*models.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if jobID < 1 || jobID > len(m.jobs) {
+		return nil, errors.NotFound{Message: "not found"}
+	}
+	job := m.jobs[jobID-1]
+	return &job, nil
+}
+
+func (m *memoryJobsPersister) UpdateJob(job *models.Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[job.ID-1] = *job
+	return nil
+}
+
+func (m *memoryJobsPersister) ListJobsByStatus(statuses []string) ([]*models.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := []*models.Job{}
+	for i := range m.jobs {
+		for _, status := range statuses {
+			if m.jobs[i].Status == status {
+				job := m.jobs[i]
+				jobs = append(jobs, &job)
+			}
+		}
+	}
+	return jobs, nil
+}
+
+// startUserJobs serves the user job endpoints backed by a running jobs.Runner
+func startUserJobs(t *testing.T, service *mockUsersServicer, persister *memoryJobsPersister, dir string) (*chi.Mux, *jobs.Runner) {
+	runner := &jobs.Runner{Persister: persister, Dir: dir, Workers: 1}
+	apis.RegisterUserJobs(runner, service)
+	if err := runner.Start(); err != nil {
+		t.Fatalf("Start returned error: %s", err.Error())
+	}
+	t.Cleanup(func() { runner.Stop(context.Background()) })
+	r := chi.NewRouter()
+	apis.RegisterUserJobsResource(r, runner)
+	apis.RegisterJobsResource(r, runner)
+	return r, runner
+}
+
+func waitForJob(t *testing.T, runner *jobs.Runner, jobID int) *models.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := runner.GetJob(jobID)
+		if err != nil {
+			t.Fatalf("GetJob returned error: %s", err.Error())
+		}
+		if job.Finished() {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Job %d did not finish, status: %s", jobID, job.Status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+/*
+	Test functions
+*/
+
+func TestImportUsersAsync(t *testing.T) {
+	// Setup
+	service := &mockUsersServicer{mockImportUsers: createAll}
+	r, runner := startUserJobs(t, service, &memoryJobsPersister{}, t.TempDir())
+	req := httptest.NewRequest("POST", "http://localhost:8080/users:importAsync", strings.NewReader("name\nAlice\n\nBob\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 202 {
+		t.Fatalf("HTTP status code, expected: %d, got: %d", 202, w.Code)
+	}
+	if location := w.Header().Get("Location"); location != "/jobs/1" {
+		t.Errorf("Location, expected: /jobs/1, got: %s", location)
+	}
+	job := waitForJob(t, runner, 1)
+	if job.Status != models.JobSucceeded || job.Progress != 2 {
+		t.Fatalf("Job, expected: succeeded after 2 rows, got: %s after %d (%s)", job.Status, job.Progress, job.Error)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/jobs/1/artifact", nil))
+	var report dtos.ImportReport
+	json.NewDecoder(w.Body).Decode(&report)
+	if report.Created != 2 || len(report.Rows) != 2 || report.Rows[1].ID != 101 {
+		t.Errorf("Report, expected: 2 created, got: %+v", report)
+	}
+}
+
+func TestImportUsersAsyncResumesAfterRecordedProgress(t *testing.T) {
+	// Setup
+	dir := t.TempDir()
+	persister := &memoryJobsPersister{}
+	var imported []string
+	var checkpoints []models.ImportCheckpoint
+	service := &mockUsersServicer{
+		mockImportUsers: func(users []*models.User, options models.ImportOptions) ([]models.ImportResult, error) {
+			for _, user := range users {
+				imported = append(imported, user.Name)
+			}
+			if options.Checkpoint != nil {
+				checkpoints = append(checkpoints, *options.Checkpoint)
+			}
+			return createAll(users, options)
+		},
+	}
+	// A previous runner recorded the first row and was stopped
+	first := &jobs.Runner{Persister: persister, Dir: dir}
+	apis.RegisterUserJobs(first, service)
+	job, err := first.Submit(apis.ImportUsersJob, map[string]interface{}{"mediaType": "application/x-ndjson"},
+		strings.NewReader("{\"name\":\"Alice\"}\n{\"name\":\"Bob\"}\n"))
+	if err != nil {
+		t.Fatalf("Submit returned error: %s", err.Error())
+	}
+	job.Progress = 1
+	persister.UpdateJob(job)
+
+	// Execute
+	_, runner := startUserJobs(t, service, persister, dir)
+
+	// Assert
+	if finished := waitForJob(t, runner, job.ID); finished.Status != models.JobSucceeded || finished.Progress != 2 {
+		t.Fatalf("Job, expected: succeeded after 2 rows, got: %s after %d", finished.Status, finished.Progress)
+	}
+	if len(imported) != 1 || imported[0] != "Bob" {
+		t.Errorf("Imported, expected: [Bob], got: %v", imported)
+	}
+	// The progress is written along with the chunk
+	if len(checkpoints) != 1 || checkpoints[0] != (models.ImportCheckpoint{JobID: job.ID, Progress: 2}) {
+		t.Errorf("Checkpoints, expected: [{%d 2}], got: %v", job.ID, checkpoints)
+	}
+}
+
+func TestExportUsersAsync(t *testing.T) {
+	// Setup
+	service := &mockUsersServicer{mockExportUsers: exportTwoUsers}
+	r, runner := startUserJobs(t, service, &memoryJobsPersister{}, t.TempDir())
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, httptest.NewRequest("POST", "http://localhost:8080/users:exportAsync?format=ndjson", nil))
+
+	// Assert
+	if w.Code != 202 {
+		t.Fatalf("HTTP status code, expected: %d, got: %d", 202, w.Code)
+	}
+	if job := waitForJob(t, runner, 1); job.Status != models.JobSucceeded || job.Total != 2 {
+		t.Fatalf("Job, expected: succeeded with 2 users, got: %s with %d (%s)", job.Status, job.Total, job.Error)
+	}
+	_, file, err := runner.OpenArtifact(1)
+	if err != nil {
+		t.Fatalf("OpenArtifact returned error: %s", err.Error())
+	}
+	defer file.Close()
+	body, _ := io.ReadAll(file)
+	expectedBody := "{\"id\":1,\"name\":\"Alice\"}\n{\"id\":2,\"name\":\"Bob, Jr.\",\"email\":\"bob@example.com\"}\n"
+	if string(body) != expectedBody {
+		t.Errorf("Artifact, expected: %q, got: %q", expectedBody, body)
+	}
+}
+
+func TestExportUsersAsyncUnknownFormat(t *testing.T) {
+	// Setup
+	r := chi.NewRouter()
+	apis.RegisterUserJobsResource(r, &mockScheduler{})
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, httptest.NewRequest("POST", "http://localhost:8080/users:exportAsync?format=xlsx", nil))
+
+	// Assert
+	if w.Code != 400 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 400, w.Code)
+	}
+}
@@ -0,0 +1,130 @@
+package apis
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/jordantipton/golang-restful-webservice/apis/codecs"
+	"github.com/jordantipton/golang-restful-webservice/apis/converters"
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+	"github.com/jordantipton/golang-restful-webservice/apis/openapi"
+	"github.com/jordantipton/golang-restful-webservice/jobs"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/services"
+)
+
+// RebuildUserSearchIndexJob is the job type of search index rebuilds
+const RebuildUserSearchIndexJob = "users.search.rebuild"
+
+type (
+	// UserSearchResource defines handlers for searching users. Representations are
+	// negotiated with Codecs, or codecs.Default if it is nil.
+	UserSearchResource struct {
+		Service services.UserSearchServicer
+		Jobs    jobs.Scheduler
+		Codecs  *codecs.Registry
+	}
+)
+
+var (
+	// searchUsersOperation documents GET /users/search
+	searchUsersOperation = openapi.Operation{
+		ID:          "searchUsers",
+		Summary:     "Search users",
+		Description: "Full-text search of the names and emails of users. Names rank above emails. Matching ignores case and accents, completes the last letters of words and tolerates typos in longer words; every word of the query must match. Changes show up within seconds.",
+		Tags:        []string{"users"},
+		Parameters: []openapi.Parameter{
+			{Name: "q", In: "query", Required: true, Description: "The words to search for", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "limit", In: "query", Description: "Maximum number of results, 20 by default and at most 100", Schema: &openapi.Schema{Type: "integer"}},
+		},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Body: &dtos.UserSearchResults{}, MediaTypes: codecs.Default.MediaTypesFor((*dtos.UserSearchResults)(nil))},
+			parameterErrorResponse("The query has no words or is too long, or the limit is not an integer"),
+			notAcceptableResponse,
+		},
+	}
+
+	// rebuildUserSearchIndexOperation documents POST /users/search:rebuild
+	rebuildUserSearchIndexOperation = openapi.Operation{
+		ID:          "rebuildUserSearchIndex",
+		Summary:     "Rebuild the user search index in a background job",
+		Description: "Indexes every user anew. Searches are served from the current index until the job succeeds.",
+		Tags:        []string{"users", "jobs"},
+		Responses: []openapi.Response{
+			jobAcceptedResponse,
+		},
+	}
+)
+
+// RegisterUserSearchJobs registers the handler of search index rebuilds with runner
+func RegisterUserSearchJobs(runner *jobs.Runner, service services.UserSearchServicer) {
+	runner.Register(RebuildUserSearchIndexJob, rebuildUserSearchIndexJob(service))
+}
+
+// RegisterUserSearchResource sets up the routing of user search endpoints and handlers
+func RegisterUserSearchResource(router chi.Router, service services.UserSearchServicer, scheduler jobs.Scheduler) {
+	r := &UserSearchResource{Service: service, Jobs: scheduler}
+	router.Method(http.MethodGet, "/users/search", openapi.Handler(searchUsersOperation, r.SearchUsers))
+	router.Method(http.MethodPost, "/users/search:rebuild", openapi.Handler(rebuildUserSearchIndexOperation, r.RebuildIndex))
+}
+
+// SearchUsers writes the users best matching the q query parameter
+func (r *UserSearchResource) SearchUsers(res http.ResponseWriter, req *http.Request) {
+	codec, ok := negotiate(res, req, r.codecs(), (*dtos.UserSearchResults)(nil))
+	if !ok {
+		return
+	}
+	limit := 0
+	if param := req.URL.Query().Get("limit"); param != "" {
+		var err error
+		if limit, err = strconv.Atoi(param); err != nil {
+			http.Error(res, "limit must be an integer", http.StatusBadRequest)
+			return
+		}
+	}
+	hits, err := r.Service.SearchUsers(req.URL.Query().Get("q"), limit)
+	if err != nil {
+		if _, ok := err.(errors.InvalidArgument); ok {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	results := dtos.UserSearchResults{Results: []dtos.UserSearchResult{}}
+	for _, hit := range hits {
+		results.Results = append(results.Results, *converters.ToUserSearchResult(hit))
+	}
+	render(res, codec, http.StatusOK, &results)
+}
+
+// RebuildIndex rebuilds the search index in a background job
+func (r *UserSearchResource) RebuildIndex(res http.ResponseWriter, req *http.Request) {
+	job, err := r.Jobs.Submit(RebuildUserSearchIndexJob, struct{}{}, nil)
+	if err != nil {
+		writeJobError(res, err)
+		return
+	}
+	writeJobAccepted(res, job)
+}
+
+func (r *UserSearchResource) codecs() *codecs.Registry {
+	if r.Codecs == nil {
+		return codecs.Default
+	}
+	return r.Codecs
+}
+
+// rebuildUserSearchIndexJob rebuilds the index and reports the number of indexed users
+// as its progress. An interrupted rebuild starts over when it is resumed.
+func rebuildUserSearchIndexJob(service services.UserSearchServicer) jobs.Handler {
+	return func(ctx context.Context, run *jobs.Run) error {
+		count, err := service.RebuildUserSearchIndex(ctx)
+		if err != nil {
+			return err
+		}
+		return run.SetProgress(int64(count), int64(count))
+	}
+}
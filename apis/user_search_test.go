@@ -0,0 +1,141 @@
+package apis_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jordantipton/golang-restful-webservice/apis"
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+)
+
+/*
+	Test objects
+*/
+
+type mockUserSearchServicer struct {
+	mockSearchUsers            func(query string, limit int) ([]*models.UserSearchHit, error)
+	mockRebuildUserSearchIndex func(ctx context.Context) (int, error)
+}
+
+func (m *mockUserSearchServicer) SearchUsers(query string, limit int) ([]*models.UserSearchHit, error) {
+	if m.mockSearchUsers != nil {
+		return m.mockSearchUsers(query, limit)
+	}
+	return []*models.UserSearchHit{}, nil
+}
+
+func (m *mockUserSearchServicer) RebuildUserSearchIndex(ctx context.Context) (int, error) {
+	if m.mockRebuildUserSearchIndex != nil {
+		return m.mockRebuildUserSearchIndex(ctx)
+	}
+	return 0, nil
+}
+
+func serveUserSearch(service *mockUserSearchServicer, scheduler *mockScheduler, method, url string) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	apis.RegisterUserSearchResource(r, service, scheduler)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, "http://localhost:8080"+url, nil))
+	return w
+}
+
+/*
+	Test functions
+*/
+
+func TestSearchUsers(t *testing.T) {
+	// Setup
+	var query string
+	var limit int
+	service := &mockUserSearchServicer{
+		mockSearchUsers: func(q string, l int) ([]*models.UserSearchHit, error) {
+			query, limit = q, l
+			return []*models.UserSearchHit{
+				{User: &models.User{ID: 1, Name: "Ada Lovelace"}, Score: 1.5, Highlights: map[string]string{"name": "<mark>Ada</mark> Lovelace"}},
+			}, nil
+		},
+	}
+
+	// Execute
+	w := serveUserSearch(service, nil, "GET", "/users/search?q=ada&limit=5")
+
+	// Assert
+	if w.Code != 200 {
+		t.Fatalf("HTTP status code, expected: 200, got: %d (%s)", w.Code, w.Body.String())
+	}
+	if query != "ada" || limit != 5 {
+		t.Errorf("Search, expected: ada, 5, got: %s, %d", query, limit)
+	}
+	var results dtos.UserSearchResults
+	json.NewDecoder(w.Body).Decode(&results)
+	expected := dtos.UserSearchResults{Results: []dtos.UserSearchResult{{
+		User:       dtos.User{ID: 1, Name: "Ada Lovelace"},
+		Score:      1.5,
+		Highlights: []dtos.Highlight{{Field: "name", Text: "<mark>Ada</mark> Lovelace"}},
+	}}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Results, expected: %v, got: %v", expected, results)
+	}
+}
+
+func TestSearchUsersErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		url            string
+		service        mockUserSearchServicer
+		expectedStatus int
+	}{
+		{name: "invalid limit", url: "/users/search?q=ada&limit=ten", expectedStatus: 400},
+		{
+			name: "invalid query",
+			url:  "/users/search?q=@",
+			service: mockUserSearchServicer{
+				mockSearchUsers: func(query string, limit int) ([]*models.UserSearchHit, error) {
+					return nil, errors.InvalidArgument{Message: "Query must contain a letter or digit"}
+				},
+			},
+			expectedStatus: 400,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Execute
+			w := serveUserSearch(&test.service, nil, "GET", test.url)
+
+			// Assert
+			if w.Code != test.expectedStatus {
+				t.Errorf("HTTP status code, expected: %d, got: %d (%s)", test.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRebuildUserSearchIndex(t *testing.T) {
+	// Setup
+	var submittedType string
+	scheduler := &mockScheduler{
+		mockSubmit: func(jobType string, params interface{}, input io.Reader) (*models.Job, error) {
+			submittedType = jobType
+			return &models.Job{ID: 3, Type: jobType, Status: models.JobQueued}, nil
+		},
+	}
+
+	// Execute
+	w := serveUserSearch(&mockUserSearchServicer{}, scheduler, "POST", "/users/search:rebuild")
+
+	// Assert
+	if w.Code != 202 {
+		t.Fatalf("HTTP status code, expected: 202, got: %d (%s)", w.Code, w.Body.String())
+	}
+	if submittedType != apis.RebuildUserSearchIndexJob || w.Header().Get("Location") != "/jobs/3" {
+		t.Errorf("Job, expected: %s at /jobs/3, got: %s at %s", apis.RebuildUserSearchIndexJob, submittedType, w.Header().Get("Location"))
+	}
+}
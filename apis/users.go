@@ -1,14 +1,17 @@
 package apis
 
 import (
-	"encoding/json"
-	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi"
+	"github.com/jordantipton/golang-restful-webservice/apis/codecs"
 	"github.com/jordantipton/golang-restful-webservice/apis/converters"
 	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+	"github.com/jordantipton/golang-restful-webservice/apis/openapi"
+	"github.com/jordantipton/golang-restful-webservice/models"
 	"github.com/jordantipton/golang-restful-webservice/models/errors"
 	"github.com/jordantipton/golang-restful-webservice/services"
 )
@@ -17,53 +20,195 @@ type (
 	// UsersResourcer provides an inverface for user resources
 	UsersResourcer interface {
 		GetUser(res http.ResponseWriter, req *http.Request)
+		GetUsers(res http.ResponseWriter, req *http.Request)
+		BatchGetUsers(res http.ResponseWriter, req *http.Request)
 		CreateUser(res http.ResponseWriter, req *http.Request)
+		UpdateUser(res http.ResponseWriter, req *http.Request)
+		DeleteUser(res http.ResponseWriter, req *http.Request)
+		ImportUsers(res http.ResponseWriter, req *http.Request)
 	}
 
-	// UsersResource defines handlers for the APIs
+	// UsersResource defines handlers for the APIs. Representations are negotiated with
+	// Codecs, or codecs.Default if it is nil.
 	UsersResource struct {
 		Service services.UsersServicer
+		Codecs  *codecs.Registry
+	}
+)
+
+var (
+	// getUserOperation documents GET /users/{userID}
+	getUserOperation = openapi.Operation{
+		ID:         "getUser",
+		Summary:    "Get a user",
+		Tags:       []string{"users"},
+		Parameters: []openapi.Parameter{userIDParameter},
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Body: &dtos.User{}, MediaTypes: codecs.Default.MediaTypesFor((*dtos.User)(nil)), Headers: map[string]string{"Last-Modified": "When the user was last updated"}},
+			{Status: http.StatusNotModified, Description: "The user has not changed since If-Modified-Since"},
+			parameterErrorResponse("The user ID is not an integer"),
+			textResponse(http.StatusNotFound, "The user does not exist"),
+			notAcceptableResponse,
+		},
+	}
+
+	// getUsersOperation documents GET /users
+	getUsersOperation = openapi.Operation{
+		ID:          "getUsers",
+		Summary:     "Get users by ID or list users",
+		Description: "Responds with a UserBatch of the users with the given ids, or a UserPage of users ordered by ID without ids. Pages have no protobuf representation.",
+		Tags:        []string{"users"},
+		Parameters: append([]openapi.Parameter{
+			{Name: "ids", In: "query", Description: "Comma separated user IDs", Schema: &openapi.Schema{Type: "string"}},
+		}, listParameters...),
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Body: openapi.AnyOf{&dtos.UserBatch{}, &dtos.UserPage{}}, MediaTypes: codecs.Default.MediaTypesFor((*dtos.UserBatch)(nil))},
+			parameterErrorResponse("The IDs, page size or page token are malformed, or there are too many IDs"),
+			notAcceptableResponse,
+		},
+	}
+
+	// batchGetUsersOperation documents POST /users:batchGet
+	batchGetUsersOperation = openapi.Operation{
+		ID:                "batchGetUsers",
+		Summary:           "Get users by the IDs in the body",
+		Tags:              []string{"users"},
+		Request:           &dtos.UserBatchRequest{},
+		RequestMediaTypes: codecs.Default.MediaTypes(),
+		Responses: append([]openapi.Response{
+			{Status: http.StatusOK, Body: &dtos.UserBatch{}, MediaTypes: codecs.Default.MediaTypesFor((*dtos.UserBatch)(nil))},
+			textResponse(http.StatusBadRequest, "The body is malformed or has too many IDs"),
+			notAcceptableResponse,
+		}, bindResponses...),
+	}
+
+	// createUserOperation documents POST /users
+	createUserOperation = openapi.Operation{
+		ID:                "createUser",
+		Summary:           "Create a user",
+		Tags:              []string{"users"},
+		Request:           &dtos.User{},
+		RequestMediaTypes: codecs.Default.MediaTypes(),
+		Responses: append([]openapi.Response{
+			{Status: http.StatusCreated, Body: &dtos.User{}, MediaTypes: codecs.Default.MediaTypesFor((*dtos.User)(nil))},
+			textResponse(http.StatusBadRequest, "The body is malformed or the user is invalid"),
+			notAcceptableResponse,
+		}, bindResponses...),
+	}
+
+	// updateUserOperation documents PUT /users/{userID}
+	updateUserOperation = openapi.Operation{
+		ID:                "updateUser",
+		Summary:           "Rename a user",
+		Tags:              []string{"users"},
+		Parameters:        []openapi.Parameter{userIDParameter},
+		Request:           &dtos.User{},
+		RequestMediaTypes: codecs.Default.MediaTypes(),
+		Responses: append([]openapi.Response{
+			{Status: http.StatusOK, Body: &dtos.User{}, MediaTypes: codecs.Default.MediaTypesFor((*dtos.User)(nil))},
+			parameterErrorResponse("The user ID is not an integer or the user is invalid"),
+			textResponse(http.StatusNotFound, "The user does not exist"),
+			notAcceptableResponse,
+		}, bindResponses...),
+	}
+
+	// deleteUserOperation documents DELETE /users/{userID}
+	deleteUserOperation = openapi.Operation{
+		ID:         "deleteUser",
+		Summary:    "Delete a user",
+		Tags:       []string{"users"},
+		Parameters: []openapi.Parameter{userIDParameter},
+		Responses: []openapi.Response{
+			{Status: http.StatusNoContent, Description: "The user was deleted"},
+			parameterErrorResponse("The user ID is not an integer"),
+			textResponse(http.StatusNotFound, "The user does not exist"),
+		},
+	}
+
+	// importUsersOperation documents POST /users:import
+	importUsersOperation = openapi.Operation{
+		ID:                "importUsers",
+		Summary:           "Import users",
+		Description:       "Creates users from a CSV or NDJSON body in chunks and reports the outcome of every row. The body may be compressed with gzip or zstd.",
+		Tags:              []string{"users"},
+		Parameters:        importParameters,
+		RequestMediaTypes: importMediaTypes,
+		Responses: []openapi.Response{
+			{Status: http.StatusOK, Body: &dtos.ImportReport{}, MediaTypes: codecs.Default.MediaTypesFor((*dtos.ImportReport)(nil))},
+			parameterErrorResponse("The query parameters are invalid"),
+			notAcceptableResponse,
+			{Status: http.StatusRequestEntityTooLarge, Description: "The decompressed body is larger than 256 MiB. Chunks read before the limit stay written.", Body: &dtos.ImportReport{}, MediaTypes: codecs.Default.MediaTypesFor((*dtos.ImportReport)(nil))},
+			textResponse(http.StatusUnsupportedMediaType, "The body is not CSV or NDJSON, or has an unsupported Content-Encoding"),
+		},
 	}
 )
 
 // RegisterUsersResource sets up the routing of users endpoints and handlers
-func RegisterUsersResource(router *chi.Mux, service services.UsersServicer) {
-	r := &UsersResource{service}
-	router.Get("/users/{userID}", r.GetUser)
-	router.Post("/users", r.CreateUser)
+func RegisterUsersResource(router chi.Router, service services.UsersServicer) {
+	r := &UsersResource{Service: service}
+	router.Method(http.MethodGet, "/users/{userID}", openapi.Handler(getUserOperation, r.GetUser))
+	router.Method(http.MethodGet, "/users", openapi.Handler(getUsersOperation, r.GetUsers))
+	router.Method(http.MethodPost, "/users:batchGet", openapi.Handler(batchGetUsersOperation, r.BatchGetUsers))
+	router.With(DecompressRequest).Method(http.MethodPost, "/users:import", openapi.Handler(importUsersOperation, r.ImportUsers))
+	router.Method(http.MethodPost, "/users", openapi.Handler(createUserOperation, r.CreateUser))
+	router.Method(http.MethodPut, "/users/{userID}", openapi.Handler(updateUserOperation, r.UpdateUser))
+	router.Method(http.MethodDelete, "/users/{userID}", openapi.Handler(deleteUserOperation, r.DeleteUser))
 }
 
-// GetUser by ID
+// GetUser by ID. Responds 304 Not Modified if the user has not changed since
+// If-Modified-Since.
 func (r *UsersResource) GetUser(res http.ResponseWriter, req *http.Request) {
-	userIDString := chi.URLParam(req, "userID")
-	userID, err := strconv.Atoi(userIDString)
-	if err != nil {
-		http.Error(res, "UserID must be an integer", http.StatusBadRequest)
+	r.crud().Get(res, req)
+}
+
+// GetUsers by the comma separated IDs in the ids query parameter. Without it, GetUsers
+// lists a page of users.
+func (r *UsersResource) GetUsers(res http.ResponseWriter, req *http.Request) {
+	idsString := req.URL.Query().Get("ids")
+	if idsString == "" {
+		r.listUsers(res, req)
 		return
 	}
-	serviceUser, err := r.Service.GetUser(userID)
-	if err != nil {
-		if _, ok := err.(errors.NotFound); ok {
-			http.Error(res, fmt.Sprintf("User with ID %d not found", userID), http.StatusNotFound)
-		} else {
-			http.Error(res, err.Error(), http.StatusInternalServerError)
-		}
+	codec, ok := negotiate(res, req, r.codecs(), (*dtos.UserBatch)(nil))
+	if !ok {
 		return
 	}
-	user := converters.ToUser(serviceUser)
-	json.NewEncoder(res).Encode(user)
+	var userIDs []int
+	for _, userIDString := range strings.Split(idsString, ",") {
+		userID, err := strconv.Atoi(strings.TrimSpace(userIDString))
+		if err != nil {
+			http.Error(res, "ids must be a comma separated list of integers", http.StatusBadRequest)
+			return
+		}
+		userIDs = append(userIDs, userID)
+	}
+	r.writeUserBatch(res, codec, userIDs)
 }
 
-// CreateUser and return result
-func (r *UsersResource) CreateUser(res http.ResponseWriter, req *http.Request) {
-	var user dtos.User
+// listUsers writes the page of users ordered by ID that follows the page_token query
+// parameter. The next page token is the ID of the last user of a full page.
+func (r *UsersResource) listUsers(res http.ResponseWriter, req *http.Request) {
+	r.crud().List(res, req)
+}
+
+// BatchGetUsers by the IDs in the request body
+func (r *UsersResource) BatchGetUsers(res http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
-	err := json.NewDecoder(req.Body).Decode(&user)
-	if err != nil {
-		http.Error(res, err.Error(), 400)
+	codec, ok := negotiate(res, req, r.codecs(), (*dtos.UserBatch)(nil))
+	if !ok {
+		return
+	}
+	var batch dtos.UserBatchRequest
+	if !bind(res, req, r.codecs(), &batch) {
 		return
 	}
-	serviceUser, err := r.Service.CreateUser(converters.FromUser(&user))
+	r.writeUserBatch(res, codec, batch.IDs)
+}
+
+// writeUserBatch looks up userIDs and writes the found users in request order
+// along with the IDs that were not found
+func (r *UsersResource) writeUserBatch(res http.ResponseWriter, codec codecs.Codec, userIDs []int) {
+	serviceUsers, err := r.Service.GetUsers(userIDs)
 	if err != nil {
 		if _, ok := err.(errors.InvalidArgument); ok {
 			http.Error(res, err.Error(), http.StatusBadRequest)
@@ -72,7 +217,71 @@ func (r *UsersResource) CreateUser(res http.ResponseWriter, req *http.Request) {
 		}
 		return
 	}
-	resultUser := converters.ToUser(serviceUser)
-	res.WriteHeader(http.StatusCreated)
-	json.NewEncoder(res).Encode(resultUser)
+	batch := dtos.UserBatch{Users: []dtos.User{}, MissingIDs: []int{}}
+	found := map[int]bool{}
+	for _, serviceUser := range serviceUsers {
+		batch.Users = append(batch.Users, *converters.ToUser(serviceUser))
+		found[serviceUser.ID] = true
+	}
+	for _, userID := range userIDs {
+		if !found[userID] {
+			batch.MissingIDs = append(batch.MissingIDs, userID)
+			found[userID] = true
+		}
+	}
+	render(res, codec, http.StatusOK, &batch)
+}
+
+// CreateUser and return result
+func (r *UsersResource) CreateUser(res http.ResponseWriter, req *http.Request) {
+	r.crud().Create(res, req)
+}
+
+// UpdateUser renames the user and returns the result
+func (r *UsersResource) UpdateUser(res http.ResponseWriter, req *http.Request) {
+	r.crud().Update(res, req)
+}
+
+// DeleteUser by ID
+func (r *UsersResource) DeleteUser(res http.ResponseWriter, req *http.Request) {
+	r.crud().Delete(res, req)
+}
+
+// crud returns the generic resource of the users routes
+func (r *UsersResource) crud() *Resource[dtos.User, models.User, int] {
+	return &Resource[dtos.User, models.User, int]{
+		Service:  services.UsersCRUD{UsersServicer: r.Service},
+		Codecs:   r.Codecs,
+		Name:     "User",
+		IDParam:  "userID",
+		ParseID:  parseUserID,
+		FormatID: strconv.Itoa,
+		ID:       func(user *models.User) int { return user.ID },
+		SetID:    func(user *models.User, userID int) { user.ID = userID },
+		ToDTO:    converters.ToUser,
+		FromDTO:  converters.FromUser,
+		NewPage: func(users []dtos.User, nextPageToken string) interface{} {
+			return &dtos.UserPage{Users: users, NextPageToken: nextPageToken}
+		},
+		ModifiedAt:      func(user *models.User) time.Time { return user.UpdatedAt },
+		DefaultPageSize: services.DefaultListUsersLimit,
+		MaxPageSize:     services.MaxListUsersLimit,
+		PrefixParam:     "name_prefix",
+	}
+}
+
+// parseUserID parses the user ID of a route or page token
+func parseUserID(s string) (int, error) {
+	userID, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errors.InvalidArgument{Message: "UserID must be an integer"}
+	}
+	return userID, nil
+}
+
+func (r *UsersResource) codecs() *codecs.Registry {
+	if r.Codecs == nil {
+		return codecs.Default
+	}
+	return r.Codecs
 }
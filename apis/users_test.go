@@ -5,14 +5,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/go-chi/chi"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/jordantipton/golang-restful-webservice/apis"
+	"github.com/jordantipton/golang-restful-webservice/apis/codecs"
 	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
 	models "github.com/jordantipton/golang-restful-webservice/models"
 	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/proto/userspb"
 )
 
 /*
@@ -20,8 +24,14 @@ import (
 */
 
 type mockUsersServicer struct {
-	mockGetUser    func(userID int) (*models.User, error)
-	mockCreateUser func(user *models.User) (*models.User, error)
+	mockGetUser     func(userID int) (*models.User, error)
+	mockGetUsers    func(userIDs []int) ([]*models.User, error)
+	mockListUsers   func(options models.UserListOptions) ([]*models.User, error)
+	mockCreateUser  func(user *models.User) (*models.User, error)
+	mockUpdateUser  func(user *models.User) (*models.User, error)
+	mockDeleteUser  func(userID int) error
+	mockImportUsers func(users []*models.User, options models.ImportOptions) ([]models.ImportResult, error)
+	mockExportUsers func(filter models.UserFilter, fn func(user *models.User) error) error
 }
 
 func (m *mockUsersServicer) GetUser(userID int) (*models.User, error) {
@@ -38,6 +48,52 @@ func (m *mockUsersServicer) CreateUser(user *models.User) (*models.User, error)
 	return nil, nil
 }
 
+func (m *mockUsersServicer) GetUsers(userIDs []int) ([]*models.User, error) {
+	if m.mockGetUsers != nil {
+		return m.mockGetUsers(userIDs)
+	}
+	return nil, nil
+}
+
+func (m *mockUsersServicer) ListUsers(options models.UserListOptions) ([]*models.User, error) {
+	if m.mockListUsers != nil {
+		return m.mockListUsers(options)
+	}
+	return nil, nil
+}
+
+func (m *mockUsersServicer) UpdateUser(user *models.User) (*models.User, error) {
+	if m.mockUpdateUser != nil {
+		return m.mockUpdateUser(user)
+	}
+	return nil, nil
+}
+
+func (m *mockUsersServicer) RenameUser(userID int, name string) (*models.User, error) {
+	return nil, nil
+}
+
+func (m *mockUsersServicer) DeleteUser(userID int) error {
+	if m.mockDeleteUser != nil {
+		return m.mockDeleteUser(userID)
+	}
+	return nil
+}
+
+func (m *mockUsersServicer) ImportUsers(users []*models.User, options models.ImportOptions) ([]models.ImportResult, error) {
+	if m.mockImportUsers != nil {
+		return m.mockImportUsers(users, options)
+	}
+	return make([]models.ImportResult, len(users)), nil
+}
+
+func (m *mockUsersServicer) ExportUsers(filter models.UserFilter, fn func(user *models.User) error) error {
+	if m.mockExportUsers != nil {
+		return m.mockExportUsers(filter, fn)
+	}
+	return nil
+}
+
 type mockError string
 
 func (e mockError) Error() string { return string(e) }
@@ -267,12 +323,12 @@ func TestCreateUserNilBodyBadRequest(t *testing.T) {
 	}
 }
 
-func TestCreateUserNoNameBadRequest(t *testing.T) {
+func TestCreateUserNoNameUnprocessable(t *testing.T) {
 	// Setup
-	expectedBody := "User must have a name"
 	mockUsersServicer := mockUsersServicer{
 		mockCreateUser: func(user *models.User) (*models.User, error) {
-			return nil, errors.InvalidArgument{Message: expectedBody}
+			t.Errorf("Expected no user to be created")
+			return nil, nil
 		},
 	}
 
@@ -286,12 +342,490 @@ func TestCreateUserNoNameBadRequest(t *testing.T) {
 	// Execute
 	r.ServeHTTP(w, req)
 
+	// Assert
+	if w.Code != 422 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 422, w.Code)
+	}
+	problem := dtos.Problem{}
+	json.NewDecoder(w.Body).Decode(&problem)
+	if len(problem.Errors) != 1 || problem.Errors[0].Pointer != "/name" || problem.Errors[0].Detail != "is required" {
+		t.Errorf("Errors, expected: [{/name is required}], got: %v", problem.Errors)
+	}
+}
+
+func TestCreateUserCollectsValidationErrors(t *testing.T) {
+	// Setup
+	r := chi.NewRouter()
+	apis.RegisterUsersResource(r, &mockUsersServicer{})
+
+	body := `{"id":7,"name":" Bob","email":"bob at example.com","nickname":"Bobby","updatedAt":"2024-05-01T12:30:15Z"}`
+	req := httptest.NewRequest("POST", "http://localhost:8080/users", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 422 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 422, w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/problem+json" {
+		t.Errorf("Content-Type, expected: application/problem+json, got: %s", contentType)
+	}
+	problem := dtos.Problem{}
+	json.NewDecoder(w.Body).Decode(&problem)
+	expectedPointers := []string{"/nickname", "/id", "/name", "/email", "/updatedAt"}
+	if len(problem.Errors) != len(expectedPointers) {
+		t.Fatalf("Errors, expected: %d, got: %v", len(expectedPointers), problem.Errors)
+	}
+	for i, pointer := range expectedPointers {
+		if problem.Errors[i].Pointer != pointer {
+			t.Errorf("Error pointer, expected: %s, got: %s", pointer, problem.Errors[i].Pointer)
+		}
+	}
+}
+
+func TestCreateUserWrongType(t *testing.T) {
+	// Setup
+	r := chi.NewRouter()
+	apis.RegisterUsersResource(r, &mockUsersServicer{})
+
+	req := httptest.NewRequest("POST", "http://localhost:8080/users", strings.NewReader(`{"name":42}`))
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 422 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 422, w.Code)
+	}
+	problem := dtos.Problem{}
+	json.NewDecoder(w.Body).Decode(&problem)
+	if len(problem.Errors) != 1 || problem.Errors[0].Detail != "must be a string, not number" {
+		t.Errorf("Errors, expected: [{/name must be a string, not number}], got: %v", problem.Errors)
+	}
+}
+
+func TestCreateUserBodyTooLarge(t *testing.T) {
+	// Setup
+	r := chi.NewRouter()
+	apis.RegisterUsersResource(r, &mockUsersServicer{})
+
+	body := `{"name":"` + strings.Repeat("a", apis.MaxRequestBodySize) + `"}`
+	req := httptest.NewRequest("POST", "http://localhost:8080/users", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 413 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 413, w.Code)
+	}
+}
+
+func TestBatchGetUsers(t *testing.T) {
+	// Setup
+	var requestedIDs []int
+	mockUsersServicer := mockUsersServicer{
+		mockGetUsers: func(userIDs []int) ([]*models.User, error) {
+			requestedIDs = userIDs
+			return []*models.User{{ID: 3, Name: "Three"}, {ID: 1, Name: "One"}}, nil
+		},
+	}
+
+	r := chi.NewRouter()
+	apis.RegisterUsersResource(r, &mockUsersServicer)
+
+	bodyBytes, _ := json.Marshal(dtos.UserBatchRequest{IDs: []int{3, 2, 1, 2}})
+	req := httptest.NewRequest("POST", "http://localhost:8080/users:batchGet", bytes.NewReader(bodyBytes))
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 200 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 200, w.Code)
+	}
+	if len(requestedIDs) != 4 {
+		t.Errorf("Requested IDs, expected: %d, got: %d", 4, len(requestedIDs))
+	}
+	batch := dtos.UserBatch{}
+	json.NewDecoder(w.Body).Decode(&batch)
+	if len(batch.Users) != 2 || batch.Users[0].ID != 3 || batch.Users[1].ID != 1 {
+		t.Errorf("Users, expected IDs: 3, 1, got: %v", batch.Users)
+	}
+	if len(batch.MissingIDs) != 1 || batch.MissingIDs[0] != 2 {
+		t.Errorf("MissingIDs, expected: [2], got: %v", batch.MissingIDs)
+	}
+}
+
+func TestGetUsersByQuery(t *testing.T) {
+	// Setup
+	var requestedIDs []int
+	mockUsersServicer := mockUsersServicer{
+		mockGetUsers: func(userIDs []int) ([]*models.User, error) {
+			requestedIDs = userIDs
+			return []*models.User{}, nil
+		},
+	}
+
+	r := chi.NewRouter()
+	apis.RegisterUsersResource(r, &mockUsersServicer)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/users?ids=4,5", nil)
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 200 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 200, w.Code)
+	}
+	if len(requestedIDs) != 2 || requestedIDs[0] != 4 || requestedIDs[1] != 5 {
+		t.Errorf("Requested IDs, expected: [4 5], got: %v", requestedIDs)
+	}
+	batch := dtos.UserBatch{}
+	json.NewDecoder(w.Body).Decode(&batch)
+	if len(batch.MissingIDs) != 2 {
+		t.Errorf("MissingIDs, expected: [4 5], got: %v", batch.MissingIDs)
+	}
+}
+
+func TestGetUsersByQueryBadRequest(t *testing.T) {
+	// Setup
+	r := chi.NewRouter()
+	apis.RegisterUsersResource(r, &mockUsersServicer{})
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/users?ids=1,a", nil)
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
 	// Assert
 	if w.Code != 400 {
 		t.Errorf("HTTP status code, expected: %d, got: %d", 400, w.Code)
 	}
-	body := w.Body.String()
-	if body != expectedBody+"\n" {
-		t.Errorf("Response body, expected: %s, got: %s", expectedBody, body)
+}
+
+func TestBatchGetUsersTooMany(t *testing.T) {
+	// Setup
+	mockUsersServicer := mockUsersServicer{
+		mockGetUsers: func(userIDs []int) ([]*models.User, error) {
+			return nil, errors.InvalidArgument{Message: "Cannot get more than 100 users at once"}
+		},
+	}
+
+	r := chi.NewRouter()
+	apis.RegisterUsersResource(r, &mockUsersServicer)
+
+	bodyBytes, _ := json.Marshal(dtos.UserBatchRequest{IDs: make([]int, 101)})
+	req := httptest.NewRequest("POST", "http://localhost:8080/users:batchGet", bytes.NewReader(bodyBytes))
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 400 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 400, w.Code)
+	}
+}
+
+func TestGetUserXML(t *testing.T) {
+	// Setup
+	mockUsersServicer := mockUsersServicer{
+		mockGetUser: func(userID int) (*models.User, error) {
+			return &models.User{ID: userID, Name: "Bob"}, nil
+		},
+	}
+	r := chi.NewRouter()
+	apis.RegisterUsersResource(r, &mockUsersServicer)
+	req := httptest.NewRequest("GET", "http://localhost:8080/users/1", nil)
+	req.Header.Set("Accept", "application/xml")
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/xml" {
+		t.Errorf("Content-Type, expected: application/xml, got: %s", contentType)
+	}
+	if body := w.Body.String(); body != "<User><id>1</id><name>Bob</name></User>" {
+		t.Errorf("Response body, expected: <User><id>1</id><name>Bob</name></User>, got: %s", body)
+	}
+}
+
+func TestGetUserNotAcceptable(t *testing.T) {
+	// Setup
+	r := chi.NewRouter()
+	apis.RegisterUsersResource(r, &mockUsersServicer{})
+	req := httptest.NewRequest("GET", "http://localhost:8080/users/1", nil)
+	req.Header.Set("Accept", "text/html")
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 406 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 406, w.Code)
+	}
+}
+
+func TestCreateUserMessagePack(t *testing.T) {
+	// Setup
+	mockUsersServicer := mockUsersServicer{
+		mockCreateUser: func(user *models.User) (*models.User, error) {
+			return &models.User{ID: 5, Name: user.Name}, nil
+		},
+	}
+	r := chi.NewRouter()
+	apis.RegisterUsersResource(r, &mockUsersServicer)
+	var body bytes.Buffer
+	codecs.MessagePack{}.Encode(&body, &dtos.User{Name: "Bob"})
+	req := httptest.NewRequest("POST", "http://localhost:8080/users", &body)
+	req.Header.Set("Content-Type", "application/msgpack")
+	req.Header.Set("Accept", "application/cbor")
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 201 {
+		t.Fatalf("HTTP status code, expected: %d, got: %d", 201, w.Code)
+	}
+	var created dtos.User
+	if err := (codecs.CBOR{}).Decode(w.Body, &created); err != nil {
+		t.Fatalf("Failed to decode CBOR body. Error: %s", err.Error())
+	}
+	if created.ID != 5 || created.Name != "Bob" {
+		t.Errorf("Created, expected: {5 Bob}, got: %+v", created)
+	}
+}
+
+func TestCreateUserUnsupportedMediaType(t *testing.T) {
+	// Setup
+	r := chi.NewRouter()
+	apis.RegisterUsersResource(r, &mockUsersServicer{})
+	req := httptest.NewRequest("POST", "http://localhost:8080/users", strings.NewReader("name=Bob"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 415 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 415, w.Code)
+	}
+}
+
+func TestBatchGetUsersProtobuf(t *testing.T) {
+	// Setup
+	mockUsersServicer := mockUsersServicer{
+		mockGetUsers: func(userIDs []int) ([]*models.User, error) {
+			return []*models.User{{ID: 2, Name: "Alice"}}, nil
+		},
+	}
+	r := chi.NewRouter()
+	apis.RegisterUsersResource(r, &mockUsersServicer)
+	requestBody, _ := proto.Marshal(&userspb.UserBatchRequest{Ids: []int64{2, 3}})
+	req := httptest.NewRequest("POST", "http://localhost:8080/users:batchGet", bytes.NewReader(requestBody))
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Accept", "application/x-protobuf")
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	var batch userspb.UserBatch
+	if err := proto.Unmarshal(w.Body.Bytes(), &batch); err != nil {
+		t.Fatalf("Failed to decode protobuf body. Error: %s", err.Error())
+	}
+	if len(batch.Users) != 1 || batch.Users[0].Name != "Alice" || len(batch.MissingIds) != 1 || batch.MissingIds[0] != 3 {
+		t.Errorf("Batch, expected: Alice and missing 3, got: %v", &batch)
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	// Setup
+	var requestedOptions models.UserListOptions
+	mockUsersServicer := mockUsersServicer{
+		mockListUsers: func(options models.UserListOptions) ([]*models.User, error) {
+			requestedOptions = options
+			return []*models.User{{ID: 4, Name: "Alice"}, {ID: 7, Name: "Alan"}}, nil
+		},
+	}
+
+	r := chi.NewRouter()
+	apis.RegisterUsersResource(r, &mockUsersServicer)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/users?page_size=2&page_token=3&name_prefix=Al", nil)
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 200 {
+		t.Fatalf("HTTP status code, expected: %d, got: %d", 200, w.Code)
+	}
+	expectedOptions := models.UserListOptions{AfterID: 3, Limit: 2, NamePrefix: "Al"}
+	if requestedOptions != expectedOptions {
+		t.Errorf("Options, expected: %+v, got: %+v", expectedOptions, requestedOptions)
+	}
+	page := dtos.UserPage{}
+	json.NewDecoder(w.Body).Decode(&page)
+	if len(page.Users) != 2 || page.NextPageToken != "7" {
+		t.Errorf("Page, expected: 2 users and next page token 7, got: %+v", page)
+	}
+}
+
+func TestListUsersLastPage(t *testing.T) {
+	// Setup
+	mockUsersServicer := mockUsersServicer{
+		mockListUsers: func(options models.UserListOptions) ([]*models.User, error) {
+			return []*models.User{{ID: 4, Name: "Alice"}}, nil
+		},
+	}
+
+	r := chi.NewRouter()
+	apis.RegisterUsersResource(r, &mockUsersServicer)
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/users", nil)
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 200 {
+		t.Fatalf("HTTP status code, expected: %d, got: %d", 200, w.Code)
+	}
+	if strings.Contains(w.Body.String(), "nextPageToken") {
+		t.Errorf("Expected no next page token, got: %s", w.Body.String())
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	// Setup
+	var updatedUser *models.User
+	mockUsersServicer := mockUsersServicer{
+		mockUpdateUser: func(user *models.User) (*models.User, error) {
+			updatedUser = user
+			return user, nil
+		},
+	}
+
+	r := chi.NewRouter()
+	apis.RegisterUsersResource(r, &mockUsersServicer)
+
+	req := httptest.NewRequest("PUT", "http://localhost:8080/users/3", strings.NewReader(`{"name": "Bob"}`))
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 200 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 200, w.Code)
+	}
+	if updatedUser == nil || updatedUser.ID != 3 || updatedUser.Name != "Bob" {
+		t.Errorf("Updated user, expected: {ID:3 Name:Bob}, got: %+v", updatedUser)
+	}
+}
+
+func TestUpdateUserProtobufKeepsEmail(t *testing.T) {
+	// Setup
+	var updatedUser *models.User
+	mockUsersServicer := mockUsersServicer{
+		mockUpdateUser: func(user *models.User) (*models.User, error) {
+			updatedUser = user
+			return user, nil
+		},
+	}
+	r := chi.NewRouter()
+	apis.RegisterUsersResource(r, &mockUsersServicer)
+	requestBody, _ := proto.Marshal(&userspb.User{Name: "Bob", Email: "bob@example.com"})
+	req := httptest.NewRequest("PUT", "http://localhost:8080/users/3", bytes.NewReader(requestBody))
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Accept", "application/x-protobuf")
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 200 {
+		t.Fatalf("HTTP status code, expected: %d, got: %d (%s)", 200, w.Code, w.Body.String())
+	}
+	if updatedUser == nil || updatedUser.Email != "bob@example.com" {
+		t.Errorf("Updated user, expected: email bob@example.com, got: %+v", updatedUser)
+	}
+	var user userspb.User
+	if err := proto.Unmarshal(w.Body.Bytes(), &user); err != nil {
+		t.Fatalf("Failed to decode protobuf body. Error: %s", err.Error())
+	}
+	if user.GetEmail() != "bob@example.com" {
+		t.Errorf("Email, expected: bob@example.com, got: %s", user.GetEmail())
+	}
+}
+
+func TestUpdateUserNotFound(t *testing.T) {
+	// Setup
+	mockUsersServicer := mockUsersServicer{
+		mockUpdateUser: func(user *models.User) (*models.User, error) {
+			return nil, errors.NotFound{Message: "not found"}
+		},
+	}
+
+	r := chi.NewRouter()
+	apis.RegisterUsersResource(r, &mockUsersServicer)
+
+	req := httptest.NewRequest("PUT", "http://localhost:8080/users/3", strings.NewReader(`{"name": "Bob"}`))
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 404 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 404, w.Code)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	// Setup
+	deletedID := 0
+	mockUsersServicer := mockUsersServicer{
+		mockDeleteUser: func(userID int) error {
+			deletedID = userID
+			return nil
+		},
+	}
+
+	r := chi.NewRouter()
+	apis.RegisterUsersResource(r, &mockUsersServicer)
+
+	req := httptest.NewRequest("DELETE", "http://localhost:8080/users/3", nil)
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 204 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 204, w.Code)
+	}
+	if deletedID != 3 {
+		t.Errorf("Deleted ID, expected: 3, got: %d", deletedID)
 	}
 }
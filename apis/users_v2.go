@@ -0,0 +1,121 @@
+package apis
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/jordantipton/golang-restful-webservice/apis/codecs"
+	convertersv2 "github.com/jordantipton/golang-restful-webservice/apis/converters/v2"
+	dtosv2 "github.com/jordantipton/golang-restful-webservice/apis/dtos/v2"
+	"github.com/jordantipton/golang-restful-webservice/apis/openapi"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/services"
+)
+
+// UsersV2Resource defines the handlers of version 2 of the users routes. They share the
+// service of UsersResource and differ in their dtos. Representations are negotiated with
+// Codecs, or codecs.Default if it is nil.
+type UsersV2Resource struct {
+	Service services.UsersServicer
+	Codecs  *codecs.Registry
+}
+
+// batchGetUsersV2Operation documents POST /users:batchGet
+var batchGetUsersV2Operation = openapi.Operation{
+	ID:                "batchGetUsers",
+	Summary:           "Get users by the IDs in the body",
+	Tags:              []string{"users"},
+	Request:           &dtosv2.UserBatchRequest{},
+	RequestMediaTypes: codecs.Default.MediaTypes(),
+	Responses: append([]openapi.Response{
+		{Status: http.StatusOK, Body: &dtosv2.UserBatch{}, MediaTypes: codecs.Default.MediaTypesFor((*dtosv2.UserBatch)(nil))},
+		textResponse(http.StatusBadRequest, "The body is malformed, has an ID that is not a user ID or has too many IDs"),
+		notAcceptableResponse,
+	}, bindResponses...),
+}
+
+// RegisterUsersV2Resource sets up the routing of version 2 of the users routes. Lists
+// and batch lookups have separate routes, and imports stay in version 1.
+func RegisterUsersV2Resource(router chi.Router, service services.UsersServicer) {
+	r := &UsersV2Resource{Service: service}
+	RegisterResource(router, "/users", r.crud())
+	router.Method(http.MethodPost, "/users:batchGet", openapi.Handler(batchGetUsersV2Operation, r.BatchGetUsers))
+}
+
+// BatchGetUsers by the IDs in the request body
+func (r *UsersV2Resource) BatchGetUsers(res http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+	codec, ok := negotiate(res, req, r.codecs(), (*dtosv2.UserBatch)(nil))
+	if !ok {
+		return
+	}
+	var batch dtosv2.UserBatchRequest
+	if !bind(res, req, r.codecs(), &batch) {
+		return
+	}
+	userIDs := make([]int, len(batch.IDs))
+	for i, id := range batch.IDs {
+		userID, err := strconv.Atoi(id)
+		if err != nil {
+			http.Error(res, fmt.Sprintf("ID %q is not a user ID", id), http.StatusBadRequest)
+			return
+		}
+		userIDs[i] = userID
+	}
+	serviceUsers, err := r.Service.GetUsers(userIDs)
+	if err != nil {
+		if _, ok := err.(errors.InvalidArgument); ok {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	result := dtosv2.UserBatch{Users: []dtosv2.User{}, MissingIDs: []string{}}
+	found := map[int]bool{}
+	for _, serviceUser := range serviceUsers {
+		result.Users = append(result.Users, *convertersv2.ToUser(serviceUser))
+		found[serviceUser.ID] = true
+	}
+	for i, userID := range userIDs {
+		if !found[userID] {
+			result.MissingIDs = append(result.MissingIDs, batch.IDs[i])
+			found[userID] = true
+		}
+	}
+	render(res, codec, http.StatusOK, &result)
+}
+
+// crud returns the generic resource of the users routes of version 2
+func (r *UsersV2Resource) crud() *Resource[dtosv2.User, models.User, int] {
+	return &Resource[dtosv2.User, models.User, int]{
+		Service:  services.UsersCRUD{UsersServicer: r.Service},
+		Codecs:   r.Codecs,
+		Name:     "User",
+		IDParam:  "userID",
+		ParseID:  parseUserID,
+		FormatID: strconv.Itoa,
+		ID:       func(user *models.User) int { return user.ID },
+		SetID:    func(user *models.User, userID int) { user.ID = userID },
+		ToDTO:    convertersv2.ToUser,
+		FromDTO:  convertersv2.FromUser,
+		NewPage: func(users []dtosv2.User, nextPageToken string) interface{} {
+			return &dtosv2.UserPage{Users: users, NextPageToken: nextPageToken}
+		},
+		ModifiedAt:      func(user *models.User) time.Time { return user.UpdatedAt },
+		DefaultPageSize: services.DefaultListUsersLimit,
+		MaxPageSize:     services.MaxListUsersLimit,
+		PrefixParam:     "name_prefix",
+	}
+}
+
+func (r *UsersV2Resource) codecs() *codecs.Registry {
+	if r.Codecs == nil {
+		return codecs.Default
+	}
+	return r.Codecs
+}
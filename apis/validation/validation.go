@@ -0,0 +1,287 @@
+// Package validation checks request dtos against the rules in their validate struct
+// tags and reports every violation with the JSON pointer of its field.
+//
+// A validate tag is a comma separated list of rules:
+//
+//	required     the field must not be empty
+//	readonly     the field must be empty; it is set by the server
+//	min=N        strings need at least N characters, slices N items and numbers a value of N
+//	max=N        strings can have at most N characters, slices N items and numbers a value of N
+//	format=NAME  strings must be valid for the named entry of Formats
+//
+// N is an integer or the name of an entry of Limits, such as max=userName.
+//
+// Empty fields that are not required are not checked against the other rules.
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jordantipton/golang-restful-webservice/models"
+)
+
+type (
+	// Error is a violation of a rule by the body field at Pointer, or by the request
+	// Parameter. Errors of the request or response as a whole have neither.
+	Error struct {
+		Pointer   string
+		Parameter string
+		Detail    string
+	}
+
+	// Errors are all the violations found in a request body
+	Errors []Error
+
+	// Format is a named rule for the characters of a string
+	Format struct {
+		Valid  func(string) bool
+		Detail string
+	}
+)
+
+// Limits are the named sizes of the min and max rules, so that rules follow the limits
+// of the models
+var Limits = map[string]int{
+	"userName":         models.UserNameMaxLength,
+	"userEmail":        models.UserEmailMaxLength,
+	"groupName":        models.GroupNameMaxLength,
+	"groupDescription": models.GroupDescriptionMaxLength,
+}
+
+// Formats are the named formats of the format rule
+var Formats = map[string]Format{
+	"username": {Valid: models.ValidUserNameCharset, Detail: "can only contain letters, digits, spaces and . , ' -, and cannot start or end with a space"},
+	"email":    {Valid: models.ValidUserEmail, Detail: "must be an address such as ada@example.com"},
+	"role":     {Valid: models.ValidGroupRole, Detail: "must be owner or member"},
+}
+
+// Error lists the violations
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		switch {
+		case err.Pointer != "":
+			messages[i] = err.Pointer + " " + err.Detail
+		case err.Parameter != "":
+			messages[i] = err.Parameter + " " + err.Detail
+		default:
+			messages[i] = err.Detail
+		}
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Struct checks v, a pointer to a struct, and the structs it contains against their
+// validate tags. It panics if a tag has an unknown rule.
+func Struct(v interface{}) Errors {
+	var errs Errors
+	checkValue(&errs, "", reflect.ValueOf(v))
+	return errs
+}
+
+// UnknownFields returns an error for every member of document, a generic decoding of
+// a request body such as a map[string]interface{}, that the struct v points to has no
+// field for
+func UnknownFields(document interface{}, v interface{}) Errors {
+	var errs Errors
+	checkMembers(&errs, "", document, reflect.TypeOf(v))
+	return errs
+}
+
+// Pointer returns the JSON pointer of a path of member names, such as /ids/3 for ids and 3
+func Pointer(path ...string) string {
+	var pointer strings.Builder
+	for _, token := range path {
+		pointer.WriteString("/")
+		pointer.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(token))
+	}
+	return pointer.String()
+}
+
+func checkValue(errs *Errors, pointer string, value reflect.Value) {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			name, ok := FieldName(field)
+			if !ok {
+				continue
+			}
+			fieldPointer := pointer + Pointer(name)
+			if tag, ok := field.Tag.Lookup("validate"); ok {
+				if !checkRules(errs, fieldPointer, value.Field(i), tag) {
+					continue
+				}
+			}
+			checkValue(errs, fieldPointer, value.Field(i))
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			checkValue(errs, pointer+Pointer(strconv.Itoa(i)), value.Index(i))
+		}
+	}
+}
+
+// checkRules checks value against the rules of tag and returns false if it violates one
+func checkRules(errs *Errors, pointer string, value reflect.Value, tag string) bool {
+	for _, rule := range strings.Split(tag, ",") {
+		name, argument, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if detail := checkRule(value, name, argument); detail != "" {
+			*errs = append(*errs, Error{Pointer: pointer, Detail: detail})
+			return false
+		}
+	}
+	return true
+}
+
+// checkRule returns the detail of the violation of a rule by value, or an empty string
+func checkRule(value reflect.Value, name, argument string) string {
+	switch name {
+	case "required":
+		if value.IsZero() {
+			return "is required"
+		}
+		return ""
+	case "readonly":
+		if !value.IsZero() {
+			return "is read-only"
+		}
+		return ""
+	}
+	if value.IsZero() {
+		return ""
+	}
+	switch name {
+	case "min", "max":
+		limit, ok := Limit(argument)
+		if !ok {
+			panic(fmt.Sprintf("validation: %s needs an integer or a name of Limits, got: %s", name, argument))
+		}
+		size, unit := measure(value)
+		if name == "min" && size < limit {
+			return fmt.Sprintf("must be at least %d%s", limit, unit)
+		}
+		if name == "max" && size > limit {
+			return fmt.Sprintf("must be at most %d%s", limit, unit)
+		}
+	case "format":
+		format, ok := Formats[argument]
+		if !ok {
+			panic("validation: unknown format " + argument)
+		}
+		if value.Kind() == reflect.String && !format.Valid(value.String()) {
+			return format.Detail
+		}
+	default:
+		panic("validation: unknown rule " + name)
+	}
+	return ""
+}
+
+// Limit returns the size of the argument of a min or max rule, an integer or the name
+// of an entry of Limits
+func Limit(argument string) (int, bool) {
+	if limit, ok := Limits[argument]; ok {
+		return limit, true
+	}
+	limit, err := strconv.Atoi(argument)
+	return limit, err == nil
+}
+
+// measure returns the size of value compared by min and max and its unit
+func measure(value reflect.Value) (int, string) {
+	switch value.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(value.String()), " characters long"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return value.Len(), " items long"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(value.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(value.Uint()), ""
+	}
+	panic("validation: min and max cannot be applied to " + value.Kind().String())
+}
+
+func checkMembers(errs *Errors, pointer string, document interface{}, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		members, ok := documentMembers(document)
+		if !ok {
+			return
+		}
+		fields := map[string]reflect.Type{}
+		for i := 0; i < t.NumField(); i++ {
+			if name, ok := FieldName(t.Field(i)); ok {
+				fields[name] = t.Field(i).Type
+			}
+		}
+		names := make([]string, 0, len(members))
+		for name := range members {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fieldType, ok := fields[name]
+			if !ok {
+				*errs = append(*errs, Error{Pointer: pointer + Pointer(name), Detail: "is not a known field"})
+				continue
+			}
+			checkMembers(errs, pointer+Pointer(name), members[name], fieldType)
+		}
+	case reflect.Slice, reflect.Array:
+		items, ok := document.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range items {
+			checkMembers(errs, pointer+Pointer(strconv.Itoa(i)), item, t.Elem())
+		}
+	}
+}
+
+// documentMembers returns the members of a decoded object. CBOR decodes objects into
+// maps with interface{} keys.
+func documentMembers(document interface{}) (map[string]interface{}, bool) {
+	switch object := document.(type) {
+	case map[string]interface{}:
+		return object, true
+	case map[interface{}]interface{}:
+		members := make(map[string]interface{}, len(object))
+		for key, value := range object {
+			members[fmt.Sprint(key)] = value
+		}
+		return members, true
+	}
+	return nil, false
+}
+
+// FieldName returns the name of field in request bodies, which is its json name. It
+// returns false for fields that are not encoded.
+func FieldName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" {
+		return "", false
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = field.Name
+	}
+	return name, true
+}
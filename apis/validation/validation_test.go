@@ -0,0 +1,128 @@
+package validation_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jordantipton/golang-restful-webservice/apis/validation"
+	"github.com/jordantipton/golang-restful-webservice/models"
+)
+
+/*
+	Test objects
+*/
+
+type address struct {
+	City string `json:"city" validate:"required,max=5"`
+}
+
+type person struct {
+	ID        int       `json:"id" validate:"readonly"`
+	Name      string    `json:"name" validate:"required,min=2,format=username"`
+	Nickname  string    `json:"nickname,omitempty" validate:"min=3"`
+	Age       int       `json:"age" validate:"max=150"`
+	Tags      []string  `json:"tags" validate:"max=2"`
+	Addresses []address `json:"addresses"`
+	internal  string
+}
+
+type account struct {
+	Name string `json:"name" validate:"max=userName"`
+}
+
+func pointers(errs validation.Errors) string {
+	var pointers []string
+	for _, err := range errs {
+		pointers = append(pointers, err.Pointer)
+	}
+	return strings.Join(pointers, " ")
+}
+
+/*
+	Test functions
+*/
+
+func TestStructValid(t *testing.T) {
+	// Setup
+	v := &person{Name: "Bob", Age: 40, Tags: []string{"a"}, Addresses: []address{{City: "Paris"}}}
+
+	// Execute
+	errs := validation.Struct(v)
+
+	// Assert
+	if len(errs) != 0 {
+		t.Errorf("Errors, expected none, got: %v", errs)
+	}
+}
+
+func TestStructCollectsAllErrors(t *testing.T) {
+	// Setup
+	v := &person{ID: 1, Name: "B", Nickname: "Al", Age: 200, Tags: []string{"a", "b", "c"}, Addresses: []address{{City: "Paris"}, {City: ""}, {City: "London"}}}
+
+	// Execute
+	errs := validation.Struct(v)
+
+	// Assert
+	expected := "/id /name /nickname /age /tags /addresses/1/city /addresses/2/city"
+	if pointers(errs) != expected {
+		t.Errorf("Pointers, expected: %s, got: %s", expected, pointers(errs))
+	}
+	if errs[1].Detail != "must be at least 2 characters long" {
+		t.Errorf("Detail, expected: must be at least 2 characters long, got: %s", errs[1].Detail)
+	}
+}
+
+func TestStructFormat(t *testing.T) {
+	// Setup
+	names := map[string]bool{"Zoë O'Brien-Smith": true, "Bob, Jr.": true, " Bob": false, "Bob<script>": false, "Bob\tSmith": false}
+
+	for name, valid := range names {
+		// Execute
+		errs := validation.Struct(&person{Name: name})
+
+		// Assert
+		if (len(errs) == 0) != valid {
+			t.Errorf("Name %q valid, expected: %v, got errors: %v", name, valid, errs)
+		}
+	}
+}
+
+func TestStructNamedLimit(t *testing.T) {
+	// Setup
+	longest := &account{Name: strings.Repeat("a", models.UserNameMaxLength)}
+	tooLong := &account{Name: strings.Repeat("a", models.UserNameMaxLength+1)}
+
+	// Execute
+	longestErrs, tooLongErrs := validation.Struct(longest), validation.Struct(tooLong)
+
+	// Assert
+	if len(longestErrs) != 0 {
+		t.Errorf("Errors of the longest name, expected none, got: %v", longestErrs)
+	}
+	if pointers(tooLongErrs) != "/name" {
+		t.Errorf("Pointers of a name too long, expected: /name, got: %s", pointers(tooLongErrs))
+	}
+}
+
+func TestUnknownFields(t *testing.T) {
+	// Setup
+	document := map[string]interface{}{
+		"name":     "Bob",
+		"email":    "bob@example.com",
+		"a/b":      true,
+		"internal": "x",
+		"addresses": []interface{}{
+			map[string]interface{}{"city": "Paris"},
+			map[interface{}]interface{}{"city": "Rome", "zip": "00100"},
+		},
+	}
+
+	// Execute
+	errs := validation.UnknownFields(document, &person{})
+
+	// Assert
+	expected := "/a~1b /addresses/1/zip /email /internal"
+	if pointers(errs) != expected {
+		t.Errorf("Pointers, expected: %s, got: %s", expected, pointers(errs))
+	}
+}
@@ -0,0 +1,183 @@
+package apis
+
+import (
+	"expvar"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/jordantipton/golang-restful-webservice/apis/openapi"
+	"github.com/jordantipton/golang-restful-webservice/services"
+)
+
+// VersionHeader selects the version of a request to an unversioned route, such as
+// "API-Version: v2" for GET /users/1. Responses of versioned routes carry it too.
+const VersionHeader = "API-Version"
+
+// APIVersion is a version of the users routes
+type APIVersion struct {
+	// Name is the path segment of the version, such as v1. The unversioned routes at the
+	// root have no name.
+	Name string
+	// Deprecated is when the version was deprecated, or zero while it is supported
+	Deprecated time.Time
+	// Sunset is when the version will stop being served, if it is known
+	Sunset time.Time
+	// Successor is the path prefix of the version that replaces a deprecated version
+	Successor string
+}
+
+var (
+	// Unversioned are the users routes at the root, which predate versioning. They
+	// serve V1 and are deprecated in favor of it.
+	Unversioned = APIVersion{Deprecated: time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC), Successor: "/v1"}
+	// V1 is the first version of the users routes
+	V1 = APIVersion{Name: "v1"}
+	// V2 represents user IDs as strings and names as displayName
+	V2 = APIVersion{Name: "v2"}
+
+	// versionRequests counts the requests to the users routes by version, with
+	// "unversioned" for the routes at the root, across tenants. It is published at
+	// /debug/vars of the metrics server.
+	versionRequests = expvar.NewMap("apiVersionRequests")
+)
+
+// RegisterVersionedUsersResources mounts the users routes at the root, at /v1 and,
+// with the dtos of version 2, at /v2
+func RegisterVersionedUsersResources(router chi.Router, service services.UsersServicer) {
+	router.Group(func(r chi.Router) {
+		r.Use(Unversioned.Handler)
+		RegisterUsersResource(r, service)
+	})
+	router.Route("/"+V1.Name, func(r chi.Router) {
+		r.Use(V1.Handler)
+		RegisterUsersResource(r, service)
+	})
+	router.Route("/"+V2.Name, func(r chi.Router) {
+		r.Use(V2.Handler)
+		RegisterUsersV2Resource(r, service)
+	})
+}
+
+// RegisterMetricsResource serves the expvar variables, such as the requests per API
+// version, at /debug/vars. They describe the whole process, including its command line
+// and the traffic of every tenant, so register it on an internal router only.
+func RegisterMetricsResource(router chi.Router) {
+	router.Get("/debug/vars", expvar.Handler().ServeHTTP)
+}
+
+// Handler is middleware for the routes of v. It counts their requests and marks their
+// responses with the version and, once v is deprecated, with Deprecation, Sunset and
+// Link headers.
+func (v APIVersion) Handler(next http.Handler) http.Handler {
+	metric := v.Name
+	if metric == "" {
+		metric = "unversioned"
+	}
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		versionRequests.Add(metric, 1)
+		if v.Name != "" {
+			res.Header().Set(VersionHeader, v.Name)
+		}
+		if !v.Deprecated.IsZero() {
+			// RFC 9745 structured date
+			res.Header().Set("Deprecation", "@"+strconv.FormatInt(v.Deprecated.Unix(), 10))
+			if !v.Sunset.IsZero() {
+				res.Header().Set("Sunset", v.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if v.Successor != "" {
+				res.Header().Add("Link", "<"+v.Successor+req.URL.Path+">; rel=\"successor-version\"")
+			}
+		}
+		next.ServeHTTP(res, req)
+	})
+}
+
+// SelectAPIVersion returns middleware that routes requests to unversioned routes with a
+// VersionHeader of one of versions, such as "v2" or "2", to the route of that version.
+// Requests for a version that does not exist are rejected. Requests without the header,
+// or whose route has no version, are left unchanged, even when the versioned path
+// matches another route, as /v2/users/events matches /v2/users/{userID}.
+func SelectAPIVersion(versions ...APIVersion) func(http.Handler) http.Handler {
+	names := make([]string, len(versions))
+	for i, version := range versions {
+		names[i] = version.Name
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			if versionOf(req.URL.Path, names) != "" {
+				next.ServeHTTP(res, req)
+				return
+			}
+			// The header may select another representation of any unversioned route
+			res.Header().Add("Vary", VersionHeader)
+			requested := req.Header.Get(VersionHeader)
+			if requested == "" {
+				next.ServeHTTP(res, req)
+				return
+			}
+			name := strings.ToLower(requested)
+			if !strings.HasPrefix(name, "v") {
+				name = "v" + name
+			}
+			if versionOf("/"+name+"/", names) == "" {
+				http.Error(res, VersionHeader+" must be one of "+strings.Join(names, ", "), http.StatusBadRequest)
+				return
+			}
+			routeContext := chi.RouteContext(req.Context())
+			versioned := "/" + name + req.URL.Path
+			if routeContext != nil && sameRoute(routeContext.Routes, req.Method, req.URL.Path, versioned) {
+				req.URL.Path = versioned
+				req.URL.RawPath = ""
+			}
+			next.ServeHTTP(res, req)
+		})
+	}
+}
+
+// sameRoute tells whether the path versioned of a version matches the route of that
+// version that path matches without one
+func sameRoute(routes chi.Routes, method, path, versioned string) bool {
+	unversionedContext, versionedContext := chi.NewRouteContext(), chi.NewRouteContext()
+	if !routes.Match(versionedContext, method, versioned) {
+		return false
+	}
+	if !routes.Match(unversionedContext, method, path) {
+		return true
+	}
+	pattern := unversionedContext.RoutePattern()
+	return strings.HasSuffix(versionedContext.RoutePattern(), pattern)
+}
+
+// documentVersions returns operations with the IDs of the routes of V1 and V2 prefixed
+// by their version, such as v1GetUser, and, once Unversioned is deprecated, the
+// unversioned routes that its successor also serves marked deprecated
+func documentVersions(operations openapi.Operations) openapi.Operations {
+	names := []string{V1.Name, V2.Name}
+	versioned := openapi.Operations{}
+	for key, operation := range operations {
+		method, route, _ := strings.Cut(key, " ")
+		if name := versionOf(route, names); name != "" {
+			if operation.ID != "" {
+				operation.ID = name + strings.ToUpper(operation.ID[:1]) + operation.ID[1:]
+			}
+		} else if _, ok := operations[method+" "+Unversioned.Successor+route]; ok && !Unversioned.Deprecated.IsZero() {
+			operation.Deprecated = true
+		}
+		versioned[key] = operation
+	}
+	return versioned
+}
+
+// versionOf returns the name of the version in names that path starts with, or an
+// empty string
+func versionOf(path string, names []string) string {
+	for _, name := range names {
+		if strings.HasPrefix(path, "/"+name+"/") {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,170 @@
+package apis_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jordantipton/golang-restful-webservice/apis"
+	dtosv2 "github.com/jordantipton/golang-restful-webservice/apis/dtos/v2"
+	models "github.com/jordantipton/golang-restful-webservice/models"
+)
+
+/*
+	Test objects
+*/
+
+func versionedRouter(service *mockUsersServicer) *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(apis.SelectAPIVersion(apis.V1, apis.V2))
+	apis.RegisterVersionedUsersResources(r, service)
+	return r
+}
+
+func userOne() *mockUsersServicer {
+	return &mockUsersServicer{
+		mockGetUser: func(userID int) (*models.User, error) {
+			return &models.User{ID: userID, Name: "Name"}, nil
+		},
+	}
+}
+
+/*
+	Test functions
+*/
+
+func TestUnversionedRouteIsDeprecated(t *testing.T) {
+	// Setup
+	r := versionedRouter(userOne())
+	req := httptest.NewRequest("GET", "http://localhost:8080/users/1", nil)
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 200 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 200, w.Code)
+	}
+	if deprecation := w.Header().Get("Deprecation"); deprecation != "@1792368000" {
+		t.Errorf("Deprecation, expected: %s, got: %s", "@1792368000", deprecation)
+	}
+	if link := w.Header().Get("Link"); link != `</v1/users/1>; rel="successor-version"` {
+		t.Errorf("Link, expected: %s, got: %s", `</v1/users/1>; rel="successor-version"`, link)
+	}
+	if vary := w.Header().Get("Vary"); vary != apis.VersionHeader {
+		t.Errorf("Vary, expected: %s, got: %s", apis.VersionHeader, vary)
+	}
+}
+
+func TestRouteOperationsOfVersions(t *testing.T) {
+	// Setup
+	r := versionedRouter(userOne())
+
+	// Execute
+	operations, _, err := apis.RouteOperations(r)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("RouteOperations returned error: %s", err.Error())
+	}
+	unversioned, v1, v2 := operations["GET /users/{userID}"], operations["GET /v1/users/{userID}"], operations["GET /v2/users/{userID}"]
+	if unversioned.ID != "getUser" || !unversioned.Deprecated {
+		t.Errorf("GET /users/{userID}, expected: deprecated getUser, got: %s deprecated %t", unversioned.ID, unversioned.Deprecated)
+	}
+	if v1.ID != "v1GetUser" || v1.Deprecated {
+		t.Errorf("GET /v1/users/{userID}, expected: v1GetUser, got: %s deprecated %t", v1.ID, v1.Deprecated)
+	}
+	if v2.ID != "v2GetUser" || v2.Deprecated {
+		t.Errorf("GET /v2/users/{userID}, expected: v2GetUser, got: %s deprecated %t", v2.ID, v2.Deprecated)
+	}
+}
+
+func TestGetUserV2(t *testing.T) {
+	// Setup
+	r := versionedRouter(userOne())
+	req := httptest.NewRequest("GET", "http://localhost:8080/v2/users/1", nil)
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 200 {
+		t.Fatalf("HTTP status code, expected: %d, got: %d", 200, w.Code)
+	}
+	if version := w.Header().Get(apis.VersionHeader); version != "v2" {
+		t.Errorf("API-Version, expected: %s, got: %s", "v2", version)
+	}
+	if deprecation := w.Header().Get("Deprecation"); deprecation != "" {
+		t.Errorf("Deprecation, expected none, got: %s", deprecation)
+	}
+	actualUser := dtosv2.User{}
+	json.NewDecoder(w.Body).Decode(&actualUser)
+	if actualUser.ID != "1" || actualUser.DisplayName != "Name" {
+		t.Errorf("User, expected: {1 Name}, got: %+v", actualUser)
+	}
+}
+
+func TestSelectAPIVersionHeader(t *testing.T) {
+	// Setup
+	r := versionedRouter(userOne())
+	req := httptest.NewRequest("GET", "http://localhost:8080/users/1", nil)
+	req.Header.Set(apis.VersionHeader, "2")
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 200 {
+		t.Fatalf("HTTP status code, expected: %d, got: %d", 200, w.Code)
+	}
+	if version := w.Header().Get(apis.VersionHeader); version != "v2" {
+		t.Errorf("API-Version, expected: %s, got: %s", "v2", version)
+	}
+	actualUser := dtosv2.User{}
+	json.NewDecoder(w.Body).Decode(&actualUser)
+	if actualUser.ID != "1" {
+		t.Errorf("ID, expected: %s, got: %s", "1", actualUser.ID)
+	}
+}
+
+func TestSelectAPIVersionUnknown(t *testing.T) {
+	// Setup
+	r := versionedRouter(userOne())
+	req := httptest.NewRequest("GET", "http://localhost:8080/users/1", nil)
+	req.Header.Set(apis.VersionHeader, "v9")
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 400 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 400, w.Code)
+	}
+}
+
+func TestSelectAPIVersionKeepsUnversionedRoute(t *testing.T) {
+	// Setup
+	r := versionedRouter(userOne())
+	r.Get("/users/events", func(res http.ResponseWriter, req *http.Request) {})
+	req := httptest.NewRequest("GET", "http://localhost:8080/users/events", nil)
+	req.Header.Set(apis.VersionHeader, "2")
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 200 {
+		t.Fatalf("HTTP status code, expected: %d, got: %d (%s)", 200, w.Code, w.Body.String())
+	}
+	if version := w.Header().Get(apis.VersionHeader); version != "" {
+		t.Errorf("API-Version, expected: none since /users/events has no versions, got: %s", version)
+	}
+}
@@ -1,61 +1,368 @@
 package app
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"log"
+	"net"
 	"net/http"
+	"sort"
+	"sync"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql" // Register mysql driver
+	"github.com/go-sql-driver/mysql"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/cors"
 	"github.com/jordantipton/golang-restful-webservice/apis"
+	"github.com/jordantipton/golang-restful-webservice/apis/openapi"
+	"github.com/jordantipton/golang-restful-webservice/blobs"
+	"github.com/jordantipton/golang-restful-webservice/cache"
+	"github.com/jordantipton/golang-restful-webservice/graphqlapi"
+	"github.com/jordantipton/golang-restful-webservice/grpcapi"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/proto/userspb"
 	"github.com/jordantipton/golang-restful-webservice/repositories"
 	"github.com/jordantipton/golang-restful-webservice/services"
+	"github.com/jordantipton/golang-restful-webservice/services/interfaces"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
+// Default directories of the files of the app
+const (
+	// defaultJobsDir is where job inputs and artifacts are stored unless JobsDir is set
+	defaultJobsDir = "data/jobs"
+	// defaultBlobsDir is where avatars are stored unless BlobsDir or S3 is set
+	defaultBlobsDir = "data/blobs"
+	// defaultSearchIndexPath is where the user search index of the default tenant is
+	// saved unless SearchIndexPath is set
+	defaultSearchIndexPath = "data/search/users.idx"
+)
+
+// searchCatchUpInterval is how often the user search index applies the user changes
+const searchCatchUpInterval = time.Second
+
 // App struct
 type App struct {
-	Router *chi.Mux
+	// Router resolves the tenant of each request and passes it to the Router of the
+	// tenant
+	Router     http.Handler
+	HTTPServer *http.Server
+	GRPCServer *grpc.Server
+	// MetricsServer serves the process metrics at /debug/vars. Run it with RunMetrics
+	// on an address that only operators can reach: the metrics are not authenticated
+	// and cover every tenant.
+	MetricsServer *http.Server
+	// Tenants configures the served tenants and their rate limits. Only the default
+	// tenant is served, with the defaults, when it is nil. It must be set before Initialize.
+	Tenants *TenantsConfig
+	// JWTSecret verifies the bearer tokens naming the tenant of requests. When it or
+	// APIKeys is set, every request needs credentials and is served for their tenant.
+	// It must be set before Initialize.
+	JWTSecret []byte
+	// APIKeys maps the API keys accepted in place of bearer tokens to their tenant. It
+	// must be set before Initialize.
+	APIKeys map[string]string
+	// TenantDomain is the parent domain of the subdomains naming tenants, such as
+	// users.example.com. It must be set before Initialize.
+	TenantDomain string
+	// TrustTenantHeader serves requests without credentials for the tenant named by
+	// their X-Tenant-ID header or subdomain when neither JWTSecret nor APIKeys is set.
+	// Only set it when the service is reachable from trusted networks alone. It must
+	// be set before Initialize.
+	TrustTenantHeader bool
+	// JobsDir is the directory of job files. It must be set before Initialize.
+	JobsDir string
+	// RedisAddr is the address of the Redis server caching users. Users are cached in
+	// memory when it is empty, which is only correct for a single instance: the caches
+	// of the other instances would keep the users they changed until they expire. It
+	// must be set when several instances share the database, and before Initialize.
+	RedisAddr string
+	// BlobsDir is the directory of avatar images when S3 is nil. It must be set before
+	// Initialize.
+	BlobsDir string
+	// S3 stores avatar images in a bucket instead of BlobsDir. It must be set before
+	// Initialize.
+	S3 *blobs.S3
+	// SearchIndexPath is the file the user search index of the default tenant is saved
+	// to on shutdown; those of other tenants are saved below its directory. It must be
+	// set before Initialize.
+	SearchIndexPath string
+	// ResponseValidation selects whether responses are checked against the OpenAPI
+	// document. It must be set before Initialize.
+	ResponseValidation apis.ResponseValidation
+
+	db            *sql.DB
+	redisClient   *redis.Client
+	blobs         interfaces.BlobStore
+	authenticator *apis.Authenticator
+
+	// mu guards tenants, the started tenants by ID, which is nil once the app is shut
+	// down. starting starts each tenant once, without holding mu.
+	mu       sync.Mutex
+	tenants  map[string]*Tenant
+	starting singleflight.Group
 }
 
-// Initialize app and construct router
-func (a *App) Initialize(dsn string) {
+// Initialize app and construct router. It returns an error when the jobs or search
+// index of a configured tenant cannot be resumed, such as when the database is down.
+func (a *App) Initialize(dsn string) error {
+	// Scan DATETIME columns into time.Time
+	if config, err := mysql.ParseDSN(dsn); err == nil {
+		config.ParseTime = true
+		dsn = config.FormatDSN()
+	}
 	db, err := sql.Open("mysql", dsn)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	a.db = db
+	if a.RedisAddr != "" {
+		a.redisClient = redis.NewClient(&redis.Options{Addr: a.RedisAddr})
+	}
+	a.blobs = a.blobStore()
+	a.authenticator = &apis.Authenticator{JWTSecret: a.JWTSecret, APIKeys: a.APIKeys}
+	a.tenants = map[string]*Tenant{}
+	// The configured tenants resume their jobs now; others when they are first used
+	for _, tenantID := range a.startupTenants() {
+		if _, err := a.Tenant(tenantID); err != nil {
+			return fmt.Errorf("tenant %s: %w", tenantID, err)
+		}
+	}
+	resolver := &apis.TenantResolver{Authenticator: a.authenticator, TrustTenantHeader: a.TrustTenantHeader, Domain: a.TenantDomain}
+	if !a.tenantsConfig().RequireTenant {
+		resolver.Default = models.DefaultTenantID
+	}
+	limiter := &apis.RateLimiter{Limits: func(tenantID string) apis.RateLimit {
+		return a.tenantsConfig().For(tenantID).RateLimit
+	}}
+	a.Router = buildTenantRouter(resolver, limiter, a.serveTenant)
+	a.HTTPServer = &http.Server{Handler: a.Router}
+	a.MetricsServer = &http.Server{Handler: buildMetricsRouter()}
+	a.GRPCServer = buildGRPCServer(func(authorization, apiKey, tenantID, authority string) (services.UsersServicer, error) {
+		tenantID, err := resolver.Resolve(authorization, apiKey, tenantID, authority)
+		if err != nil {
+			return nil, err
+		}
+		if ok, _ := limiter.Allow(tenantID); !ok {
+			return nil, errors.ResourceExhausted{Message: "Rate limit exceeded"}
+		}
+		tenant, err := a.Tenant(tenantID)
+		if err != nil {
+			return nil, err
+		}
+		return tenant.Users, nil
+	})
+	return nil
+}
+
+// startupTenants returns the default tenant, unless tenants are required, and the
+// configured tenants
+func (a *App) startupTenants() []string {
+	var tenantIDs []string
+	for tenantID := range a.tenantsConfig().Tenants {
+		if tenantID != models.DefaultTenantID {
+			tenantIDs = append(tenantIDs, tenantID)
+		}
+	}
+	sort.Strings(tenantIDs)
+	if !a.tenantsConfig().RequireTenant {
+		tenantIDs = append([]string{models.DefaultTenantID}, tenantIDs...)
 	}
-	a.Router = buildRouter(db)
+	return tenantIDs
+}
+
+// serveTenant passes requests to the router of the tenant resolved by ResolveTenant
+func (a *App) serveTenant(res http.ResponseWriter, req *http.Request) {
+	tenant, err := a.Tenant(apis.TenantID(req.Context()))
+	if err != nil {
+		if _, ok := err.(errors.NotFound); ok {
+			http.Error(res, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(res, err.Error(), http.StatusServiceUnavailable)
+		}
+		return
+	}
+	tenant.Router.ServeHTTP(res, req)
+}
+
+// cacheStore returns a store of tenantID, such as the cache of its users: keys prefixed
+// with the tenant in Redis when RedisAddr is set, and an in-memory LRU of the tenant,
+// seen and invalidated by this instance only, otherwise
+func (a *App) cacheStore(tenantID string) cache.Store {
+	if a.redisClient == nil {
+		return &cache.LRU{}
+	}
+	return &cache.Redis{Client: a.redisClient, Prefix: "tenant:" + tenantID + ":"}
+}
+
+// blobStore returns the S3 store when S3 is set and a directory otherwise
+func (a *App) blobStore() interfaces.BlobStore {
+	if a.S3 != nil {
+		return a.S3
+	}
+	dir := a.BlobsDir
+	if dir == "" {
+		dir = defaultBlobsDir
+	}
+	return &blobs.Local{Dir: dir}
 }
 
 // Run app
 func (a *App) Run(addr string) {
-	http.ListenAndServe(addr, a.Router)
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		panic(err)
+	}
+	a.HTTPServer.Serve(lis)
 }
 
-func buildRouter(db *sql.DB) *chi.Mux {
-	r := chi.NewRouter()
+// RunMetrics serves the process metrics on addr
+func (a *App) RunMetrics(addr string) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		panic(err)
+	}
+	a.MetricsServer.Serve(lis)
+}
 
-	// Middleware stack
+// RunGRPC serves the gRPC API on addr
+func (a *App) RunGRPC(addr string) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		panic(err)
+	}
+	a.GRPCServer.Serve(lis)
+}
+
+// Shutdown stops the servers, waiting for in-flight requests, then stops the job
+// workers, saves the user search index and stops the event bus of every tenant.
+// Interrupted jobs resume when their tenant is next started.
+func (a *App) Shutdown(ctx context.Context) error {
+	err := a.HTTPServer.Shutdown(ctx)
+	a.GRPCServer.GracefulStop()
+	a.MetricsServer.Close()
+	a.mu.Lock()
+	tenants := a.tenants
+	a.tenants = nil
+	a.mu.Unlock()
+	for _, tenant := range tenants {
+		if tenantErr := tenant.stop(ctx); tenantErr != nil {
+			log.Printf("tenant %s: shutdown: %v", tenant.ID, tenantErr)
+			if err == nil {
+				err = tenantErr
+			}
+		}
+	}
+	return err
+}
+
+// buildTenantRouter returns the handler resolving the tenant of requests, limiting
+// their rate and passing them to serveTenant
+func buildTenantRouter(resolver *apis.TenantResolver, limiter *apis.RateLimiter, serveTenant http.HandlerFunc) http.Handler {
 	cors := cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", apis.VersionHeader, apis.TenantHeader, apis.APIKeyHeader},
+		ExposedHeaders:   []string{"Link", "Deprecation", "Sunset", "Retry-After", apis.VersionHeader},
 		AllowCredentials: true,
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	})
-	r.Use(cors.Handler)
-	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
+	return chi.Chain(
+		cors.Handler,
+		middleware.RequestID,
+		middleware.RealIP,
+		middleware.Logger,
+		middleware.Recoverer,
+		apis.ResolveTenant(resolver),
+		limiter.Handler,
+	).Handler(serveTenant)
+}
+
+// buildMetricsRouter returns the handler of the metrics server, which is not reachable
+// through the tenant routers
+func buildMetricsRouter() http.Handler {
+	r := chi.NewRouter()
+	apis.RegisterMetricsResource(r)
+	return r
+}
+
+// documentMiddleware adds the parameters and responses of the middleware of the
+// routers to their operations
+func documentMiddleware(operations openapi.Operations) openapi.Operations {
+	return apis.WithTenant(apis.WithIdempotency(operations, apis.IdempotentUserRoutes...))
+}
+
+// buildRouter returns the router of tenant
+func buildRouter(db *sql.DB, tenant *Tenant, avatarsService services.AvatarsServicer, userSearchService services.UserSearchServicer, authenticator *apis.Authenticator, responseValidation apis.ResponseValidation) *chi.Mux {
+	tenantID, usersService, scheduler := tenant.ID, tenant.Users, tenant.Jobs
+	r := chi.NewRouter()
+	openAPI := &apis.OpenAPIResource{Info: apis.APIInfo, Routes: r, Adjust: documentMiddleware}
+
+	// Middleware stack, below that of buildTenantRouter
+	r.Use(apis.Compress(apis.CompressOptions{}))
+	r.Use(apis.SelectAPIVersion(apis.V1, apis.V2))
+	r.Use(apis.ValidateOpenAPI(openAPI.Document, responseValidation))
+	r.Use(apis.Idempotency(tenant.Responses, apis.IdempotentUserRoutes...))
 
 	// Register Controllers
-	usersRepository := &repositories.UsersRepository{DB: db}
-	usersService := &services.UsersService{UsersPersister: usersRepository}
-	apis.RegisterUsersResource(r, usersService)
+	userEventsRepository := &repositories.UserEventsRepository{DB: db, TenantID: tenantID}
+	userEventsService := &services.UserEventsService{UserEventsPersister: userEventsRepository}
+	groupsService := &services.GroupsService{GroupsPersister: &repositories.GroupsRepository{DB: db, TenantID: tenantID}}
+	groupMembersService := &services.GroupMembersService{
+		GroupMembersPersister: &repositories.GroupMembersRepository{DB: db, TenantID: tenantID},
+		Groups:                groupsService,
+		Users:                 usersService,
+	}
+	userPolicy := apis.CachePolicy{MaxAge: 30 * time.Second, SharedMaxAge: time.Minute, StaleWhileRevalidate: 30 * time.Second, Vary: []string{"Accept"}}
+	usersPolicy := apis.CachePolicy{NoCache: true, Vary: []string{"Accept"}}
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(60 * time.Second))
+		r.Use(apis.CacheControl(apis.CachePolicies{
+			"/users/{userID}":    userPolicy,
+			"/users":             usersPolicy,
+			"/v1/users/{userID}": userPolicy,
+			"/v1/users":          usersPolicy,
+			"/v2/users/{userID}": userPolicy,
+			"/v2/users":          usersPolicy,
+			"/users/search":      usersPolicy,
+			"/jobs/{jobID}":      {NoStore: true},
+			// The avatar URLs of users change with every upload
+			"/users/{userID}/avatar": {MaxAge: time.Hour},
+		}))
+		apis.RegisterVersionedUsersResources(r, usersService)
+		apis.RegisterGroupsResource(r, groupsService)
+		apis.RegisterGroupMembersResource(r, groupMembersService)
+		apis.RegisterUserAvatarResource(r, avatarsService)
+		apis.RegisterUserSearchResource(r, userSearchService, scheduler)
+		graphqlapi.RegisterGraphQLResource(r, usersService)
+		apis.RegisterJobsResource(r, scheduler)
+	})
+
+	// Streaming endpoints are exempt from the request timeout
+	apis.RegisterUserEventsResource(r, userEventsService)
+	apis.RegisterUserChangesSocket(r, tenant.Events, authenticator)
+	apis.RegisterUserExportResource(r, usersService)
+	apis.RegisterUserJobsResource(r, scheduler)
+	apis.RegisterOpenAPIResource(r, openAPI)
 	return r
 }
+
+func buildGRPCServer(tenants grpcapi.TenantServices) *grpc.Server {
+	server := grpc.NewServer()
+	grpcapi.RegisterTenantUsersServer(server, tenants)
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(userspb.UserService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+	reflection.Register(server)
+	return server
+}
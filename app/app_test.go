@@ -26,7 +26,9 @@ func TestCreateGetUser(t *testing.T) {
 	port := "8080"
 	addr := "http://localhost:" + port
 	a := app.App{}
-	a.Initialize(os.Getenv("DSN"))
+	if err := a.Initialize(os.Getenv("DSN")); err != nil {
+		t.Fatalf("Initialize returned error: %s", err.Error())
+	}
 	go a.Run(":" + port)
 
 	userName := "TestUser" + strconv.Itoa(rand.Intn(999999))
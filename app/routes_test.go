@@ -0,0 +1,62 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jordantipton/golang-restful-webservice/apis"
+	"github.com/jordantipton/golang-restful-webservice/events"
+	"github.com/jordantipton/golang-restful-webservice/jobs"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/services"
+)
+
+/*
+	Test functions
+*/
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	// Setup
+	tenant := &Tenant{ID: models.DefaultTenantID, Users: &services.UsersService{}, Events: &events.Bus{}, Jobs: &jobs.Runner{}}
+	router := buildRouter(nil, tenant, &services.AvatarsService{}, &services.UserSearchService{}, &apis.Authenticator{}, apis.ResponseValidationOff)
+
+	// Execute
+	operations, undocumented, err := apis.RouteOperations(router)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("RouteOperations returned error: %s", err.Error())
+	}
+	for _, route := range undocumented {
+		t.Errorf("Route %s is missing from the OpenAPI document, register it with an openapi.Handler", route)
+	}
+	routes := map[string]string{}
+	for route, operation := range operations {
+		if other, ok := routes[operation.ID]; ok {
+			t.Errorf("Operation ID %q of %s is also the ID of %s", operation.ID, route, other)
+		}
+		routes[operation.ID] = route
+	}
+}
+
+func TestMetricsAreInternal(t *testing.T) {
+	// Setup
+	tenant := &Tenant{ID: models.DefaultTenantID, Users: &services.UsersService{}, Events: &events.Bus{}, Jobs: &jobs.Runner{}}
+	router := buildRouter(nil, tenant, &services.AvatarsService{}, &services.UserSearchService{}, &apis.Authenticator{}, apis.ResponseValidationOff)
+	w := httptest.NewRecorder()
+
+	// Execute
+	buildMetricsRouter().ServeHTTP(w, httptest.NewRequest("GET", "/debug/vars", nil))
+
+	// Assert
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"apiVersionRequests"`) {
+		t.Errorf("Metrics, expected: 200 with apiVersionRequests, got: %d %s", w.Code, w.Body.String())
+	}
+	if router.Match(chi.NewRouteContext(), http.MethodGet, "/debug/vars") {
+		t.Errorf("Expected the tenant router not to serve /debug/vars")
+	}
+}
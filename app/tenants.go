@@ -0,0 +1,266 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-chi/chi"
+	"gopkg.in/yaml.v3"
+
+	"github.com/jordantipton/golang-restful-webservice/apis"
+	"github.com/jordantipton/golang-restful-webservice/cache"
+	"github.com/jordantipton/golang-restful-webservice/events"
+	"github.com/jordantipton/golang-restful-webservice/jobs"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/repositories"
+	"github.com/jordantipton/golang-restful-webservice/search"
+	"github.com/jordantipton/golang-restful-webservice/services"
+)
+
+type (
+	// TenantConfig is the configuration of a tenant. Zero fields of the overrides of a
+	// tenant take the value of the default.
+	TenantConfig struct {
+		RateLimit apis.RateLimit `yaml:"rate-limit"`
+		// UserCacheTTL is how long users are cached, repositories.DefaultUserCacheTTL
+		// if it is 0
+		UserCacheTTL time.Duration `yaml:"user-cache-ttl"`
+	}
+
+	// TenantsConfig is the tenants file:
+	//
+	//	require-tenant: true
+	//	default:
+	//	  rate-limit:
+	//	    requests-per-second: 50
+	//	    burst: 100
+	//	tenants:
+	//	  acme:
+	//	    rate-limit:
+	//	      requests-per-second: 200
+	//	    user-cache-ttl: 1m
+	//	  globex: {}
+	//
+	// Only the listed tenants are served, along with the default tenant unless
+	// RequireTenant is set. Only the default tenant is served when none is listed, so
+	// that requests naming tenants cannot start any number of tenant stacks.
+	TenantsConfig struct {
+		// RequireTenant rejects requests that name no tenant instead of serving them
+		// as the default tenant
+		RequireTenant bool                    `yaml:"require-tenant"`
+		Default       TenantConfig            `yaml:"default"`
+		Tenants       map[string]TenantConfig `yaml:"tenants"`
+	}
+
+	// Tenant is the stack of services serving the users of a tenant. Its repositories
+	// only see the rows of the tenant.
+	Tenant struct {
+		ID     string
+		Router *chi.Mux
+		Users  services.UsersServicer
+		// Events carries the domain events published by the services of the tenant.
+		// Subscribe to it to react to mutations without changing the services.
+		Events *events.Bus
+		// Jobs runs the background jobs of the tenant, such as asynchronous imports
+		// and exports
+		Jobs *jobs.Runner
+		// Responses keeps the responses that are replayed to the requests repeating an
+		// Idempotency-Key
+		Responses cache.Store
+
+		// stopSearch stops the user search index from following the user changes, and
+		// searchDone is closed once it has saved the index
+		stopSearch context.CancelFunc
+		searchDone chan struct{}
+	}
+)
+
+// LoadTenantsConfig reads the tenants file at path
+func LoadTenantsConfig(path string) (*TenantsConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &TenantsConfig{}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	for tenantID := range c.Tenants {
+		if !models.ValidTenantID(tenantID) {
+			return nil, fmt.Errorf("reading %s: tenant ID %q must be a lower case DNS label", path, tenantID)
+		}
+	}
+	return c, nil
+}
+
+// For returns the configuration of tenantID, its overrides merged over the default
+func (c *TenantsConfig) For(tenantID string) TenantConfig {
+	config := c.Default
+	overrides := c.Tenants[tenantID]
+	if overrides.RateLimit.RequestsPerSecond != 0 {
+		config.RateLimit.RequestsPerSecond = overrides.RateLimit.RequestsPerSecond
+	}
+	if overrides.RateLimit.Burst != 0 {
+		config.RateLimit.Burst = overrides.RateLimit.Burst
+	}
+	if overrides.UserCacheTTL != 0 {
+		config.UserCacheTTL = overrides.UserCacheTTL
+	}
+	return config
+}
+
+// Serves reports whether tenantID is served
+func (c *TenantsConfig) Serves(tenantID string) bool {
+	if _, ok := c.Tenants[tenantID]; ok {
+		return true
+	}
+	return tenantID == models.DefaultTenantID && !c.RequireTenant
+}
+
+// Tenant returns the services of tenantID, starting them on its first use. Starting a
+// tenant resumes its interrupted jobs. It returns NotFound for tenants that are not
+// served.
+func (a *App) Tenant(tenantID string) (*Tenant, error) {
+	if tenant, err := a.startedTenant(tenantID); tenant != nil || err != nil {
+		return tenant, err
+	}
+	if !a.tenantsConfig().Serves(tenantID) {
+		return nil, errors.NotFound{Message: fmt.Sprintf("Tenant %s not found", tenantID)}
+	}
+	// The tenant is started without holding mu, so that a slow start does not stall the
+	// other tenants, and once however many requests wait for it
+	started, err, _ := a.starting.Do(tenantID, func() (interface{}, error) {
+		if tenant, err := a.startedTenant(tenantID); tenant != nil || err != nil {
+			return tenant, err
+		}
+		tenant, err := a.startTenant(tenantID)
+		if err != nil {
+			return nil, err
+		}
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if a.tenants == nil {
+			if err := tenant.stop(context.Background()); err != nil {
+				log.Printf("tenant %s: shutdown: %v", tenantID, err)
+			}
+			return nil, errShutDown
+		}
+		a.tenants[tenantID] = tenant
+		return tenant, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return started.(*Tenant), nil
+}
+
+// errShutDown is returned for the tenants used after Shutdown
+var errShutDown = fmt.Errorf("the app is shut down")
+
+// startedTenant returns tenantID if it is started, nil if it is not, and errShutDown
+// once the app is shut down
+func (a *App) startedTenant(tenantID string) (*Tenant, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.tenants == nil {
+		return nil, errShutDown
+	}
+	return a.tenants[tenantID], nil
+}
+
+// startTenant builds the services of tenantID and starts its job workers and user
+// search index
+func (a *App) startTenant(tenantID string) (*Tenant, error) {
+	config := a.tenantsConfig().For(tenantID)
+	tenant := &Tenant{ID: tenantID, Events: &events.Bus{}, Responses: a.cacheStore(tenantID)}
+	usersRepository := &repositories.CachedUsersRepository{
+		UsersPersister: &repositories.UsersRepository{DB: a.db, TenantID: tenantID},
+		Store:          a.cacheStore(tenantID),
+		TTL:            config.UserCacheTTL,
+	}
+	tenant.Events.Subscribe("users-cache", events.Sync, usersRepository.Invalidate)
+	usersService := &services.UsersService{UsersPersister: usersRepository, EventPublisher: tenant.Events}
+	tenant.Users = usersService
+	// Job IDs are unique across tenants, so their files share a directory
+	jobsDir := a.JobsDir
+	if jobsDir == "" {
+		jobsDir = defaultJobsDir
+	}
+	tenant.Jobs = &jobs.Runner{Persister: &repositories.JobsRepository{DB: a.db, TenantID: tenantID}, Dir: jobsDir}
+	apis.RegisterUserJobs(tenant.Jobs, usersService)
+	searchIndexPath := a.SearchIndexPath
+	if searchIndexPath == "" {
+		searchIndexPath = defaultSearchIndexPath
+	}
+	userSearchService := &services.UserSearchService{
+		Index:        &search.Index{Fields: services.UserSearchFields},
+		Users:        usersService,
+		UserEvents:   &services.UserEventsService{UserEventsPersister: &repositories.UserEventsRepository{DB: a.db, TenantID: tenantID}},
+		SnapshotPath: tenantPath(searchIndexPath, tenantID),
+	}
+	apis.RegisterUserSearchJobs(tenant.Jobs, userSearchService)
+	if err := tenant.Jobs.Start(); err != nil {
+		tenant.Events.Close()
+		return nil, err
+	}
+	// User IDs are unique across tenants, so avatars share the blob store
+	avatarsService := &services.AvatarsService{
+		Users:            usersService,
+		AvatarsPersister: &repositories.AvatarsRepository{DB: a.db, TenantID: tenantID},
+		BlobStore:        a.blobs,
+		EventPublisher:   tenant.Events,
+	}
+	tenant.Events.Subscribe("avatars", events.Async, avatarsService.DeleteUserAvatar)
+	tenant.followUserSearch(userSearchService)
+	tenant.Router = buildRouter(a.db, tenant, avatarsService, userSearchService, a.authenticator, a.ResponseValidation)
+	return tenant, nil
+}
+
+// followUserSearch keeps the user search index of the tenant up to date until stop
+func (tenant *Tenant) followUserSearch(userSearchService *services.UserSearchService) {
+	ctx, cancel := context.WithCancel(context.Background())
+	tenant.stopSearch, tenant.searchDone = cancel, make(chan struct{})
+	go func() {
+		defer close(tenant.searchDone)
+		userSearchService.Follow(ctx, searchCatchUpInterval)
+	}()
+}
+
+// stop stops the job workers, saves the user search index and stops the event bus of
+// the tenant
+func (tenant *Tenant) stop(ctx context.Context) error {
+	err := tenant.Jobs.Stop(ctx)
+	tenant.stopSearch()
+	select {
+	case <-tenant.searchDone:
+	case <-ctx.Done():
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+	tenant.Events.Close()
+	return err
+}
+
+// tenantsConfig returns Tenants, or the configuration serving every tenant with the
+// defaults if it is nil
+func (a *App) tenantsConfig() *TenantsConfig {
+	if a.Tenants == nil {
+		return &TenantsConfig{}
+	}
+	return a.Tenants
+}
+
+// tenantPath returns the path of the file at path for tenantID. The default tenant
+// keeps path, which predates tenants, and the others get one below its directory.
+func tenantPath(path, tenantID string) string {
+	if tenantID == models.DefaultTenantID {
+		return path
+	}
+	return filepath.Join(filepath.Dir(path), "tenants", tenantID, filepath.Base(path))
+}
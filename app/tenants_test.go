@@ -0,0 +1,169 @@
+package app
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/jordantipton/golang-restful-webservice/apis"
+)
+
+/*
+	Test objects
+*/
+
+const tenantsFile = `require-tenant: true
+default:
+  rate-limit:
+    requests-per-second: 50
+    burst: 100
+  user-cache-ttl: 5m
+tenants:
+  acme:
+    rate-limit:
+      requests-per-second: 200
+    user-cache-ttl: 1m
+  globex: {}
+`
+
+func writeTenantsFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "tenants.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile returned error: %v", err)
+	}
+	return path
+}
+
+/*
+	Test functions
+*/
+
+func TestLoadTenantsConfig(t *testing.T) {
+	// Setup
+	path := writeTenantsFile(t, tenantsFile)
+
+	// Execute
+	config, err := LoadTenantsConfig(path)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("LoadTenantsConfig returned error: %v", err)
+	}
+	if acme := config.For("acme"); acme.RateLimit != (apis.RateLimit{RequestsPerSecond: 200, Burst: 100}) || acme.UserCacheTTL != time.Minute {
+		t.Errorf("Config of acme, expected: its overrides over the default, got: %+v", acme)
+	}
+	if globex := config.For("globex"); globex != config.Default {
+		t.Errorf("Config of globex, expected: the default, got: %+v", globex)
+	}
+}
+
+func TestLoadTenantsConfigInvalidTenant(t *testing.T) {
+	// Setup
+	path := writeTenantsFile(t, "tenants:\n  Acme_Corp: {}\n")
+
+	// Execute
+	_, err := LoadTenantsConfig(path)
+
+	// Assert
+	if err == nil {
+		t.Errorf("Expected error to be returned but is nil")
+	}
+}
+
+func TestTenantsConfigServes(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   TenantsConfig
+		tenantID string
+		expected bool
+	}{
+		{name: "default tenant without a list", config: TenantsConfig{}, tenantID: "default", expected: true},
+		{name: "other tenant without a list", config: TenantsConfig{}, tenantID: "acme", expected: false},
+		{name: "listed tenant", config: TenantsConfig{Tenants: map[string]TenantConfig{"acme": {}}}, tenantID: "acme", expected: true},
+		{name: "unlisted tenant", config: TenantsConfig{Tenants: map[string]TenantConfig{"acme": {}}}, tenantID: "globex", expected: false},
+		{name: "default tenant", config: TenantsConfig{Tenants: map[string]TenantConfig{"acme": {}}}, tenantID: "default", expected: true},
+		{name: "default tenant when required", config: TenantsConfig{RequireTenant: true, Tenants: map[string]TenantConfig{"acme": {}}}, tenantID: "default", expected: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Execute
+			serves := test.config.Serves(test.tenantID)
+
+			// Assert
+			if serves != test.expected {
+				t.Errorf("Serves, expected: %v, got: %v", test.expected, serves)
+			}
+		})
+	}
+}
+
+func TestTenantPath(t *testing.T) {
+	// Execute
+	defaultPath, acmePath := tenantPath("data/search/users.idx", "default"), tenantPath("data/search/users.idx", "acme")
+
+	// Assert
+	if defaultPath != "data/search/users.idx" || acmePath != filepath.Join("data", "search", "tenants", "acme", "users.idx") {
+		t.Errorf("Paths, expected: the path of the default tenant kept, got: %s, %s", defaultPath, acmePath)
+	}
+}
+
+func TestTenantRouterRejectsUnservedTenant(t *testing.T) {
+	// Setup
+	a := &App{Tenants: &TenantsConfig{RequireTenant: true, Tenants: map[string]TenantConfig{"acme": {}}}, tenants: map[string]*Tenant{}}
+	router := buildTenantRouter(&apis.TenantResolver{TrustTenantHeader: true}, &apis.RateLimiter{}, a.serveTenant)
+	req := httptest.NewRequest("GET", "http://localhost:8080/users/1", nil)
+	req.Header.Set(apis.TenantHeader, "globex")
+	w := httptest.NewRecorder()
+
+	// Execute
+	router.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 404 {
+		t.Errorf("HTTP status code, expected: 404, got: %d (%s)", w.Code, w.Body.String())
+	}
+}
+
+func TestTenantStartsOutsideTheLock(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	// globex resumes its jobs once, from a slow database
+	mock.ExpectQuery("SELECT .* FROM job").WillDelayFor(300 * time.Millisecond).WillReturnError(fmt.Errorf("database unavailable"))
+	acme := &Tenant{ID: "acme"}
+	a := &App{db: db, Tenants: &TenantsConfig{Tenants: map[string]TenantConfig{"acme": {}, "globex": {}}}, tenants: map[string]*Tenant{"acme": acme}}
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := a.Tenant("globex")
+			errs <- err
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	// Execute
+	start := time.Now()
+	tenant, err := a.Tenant("acme")
+	elapsed := time.Since(start)
+
+	// Assert
+	if err != nil || tenant != acme || elapsed > 100*time.Millisecond {
+		t.Errorf("acme, expected: served while globex starts, got: %v, %v after %s", tenant, err, elapsed)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err == nil || err.Error() != "database unavailable" {
+			t.Errorf("Error of globex, expected: database unavailable from its one start, got: %v", err)
+		}
+	}
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+}
@@ -0,0 +1,104 @@
+// Package auth verifies the JSON Web Tokens of authenticated requests
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Leeway is the clock skew tolerated when checking the exp and nbf claims
+const Leeway = time.Minute
+
+type (
+	// Claims are the claims of a verified token by name
+	Claims map[string]interface{}
+
+	// header is the JOSE header of a token
+	header struct {
+		Alg string `json:"alg"`
+		Typ string `json:"typ"`
+	}
+)
+
+// VerifyHS256 checks that token is a JWT signed with secret using HS256 that is valid
+// at now, and returns its claims. Tokens without exp do not expire.
+func VerifyHS256(token string, secret []byte, now time.Time) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("token is not a JWT")
+	}
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, fmt.Errorf("invalid token header: %w", err)
+	}
+	// Only HS256 is accepted, so that "none" and key confusion attacks are not possible
+	if h.Alg != "HS256" {
+		return nil, fmt.Errorf("unsupported token algorithm %q", h.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token signature: %w", err)
+	}
+	if !hmac.Equal(signature, sign(parts[0]+"."+parts[1], secret)) {
+		return nil, fmt.Errorf("invalid token signature")
+	}
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %w", err)
+	}
+	if exp, ok := claims.time("exp"); ok && !now.Before(exp.Add(Leeway)) {
+		return nil, fmt.Errorf("token expired at %s", exp.UTC().Format(time.RFC3339))
+	}
+	if nbf, ok := claims.time("nbf"); ok && now.Add(Leeway).Before(nbf) {
+		return nil, fmt.Errorf("token is not valid before %s", nbf.UTC().Format(time.RFC3339))
+	}
+	return claims, nil
+}
+
+// SignHS256 returns a JWT of claims signed with secret using HS256
+func SignHS256(claims Claims, secret []byte) (string, error) {
+	h, err := json.Marshal(header{Alg: "HS256", Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign(signed, secret)), nil
+}
+
+// String returns the claim name if it is a string
+func (claims Claims) String(name string) (string, bool) {
+	value, ok := claims[name].(string)
+	return value, ok
+}
+
+// time returns the claim name if it is a NumericDate
+func (claims Claims) time(name string) (time.Time, bool) {
+	seconds, ok := claims[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+func sign(signed string, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,76 @@
+package auth_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jordantipton/golang-restful-webservice/auth"
+)
+
+/*
+	Test objects
+*/
+
+var (
+	secret = []byte("secret")
+	now    = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+)
+
+func signed(t *testing.T, claims auth.Claims) string {
+	token, err := auth.SignHS256(claims, secret)
+	if err != nil {
+		t.Fatalf("SignHS256 returned error: %v", err)
+	}
+	return token
+}
+
+/*
+	Test functions
+*/
+
+func TestVerifyHS256(t *testing.T) {
+	// Setup
+	token := signed(t, auth.Claims{"tenant": "acme", "exp": float64(now.Add(time.Hour).Unix())})
+
+	// Execute
+	claims, err := auth.VerifyHS256(token, secret, now)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("VerifyHS256 returned error: %v", err)
+	}
+	if tenant, ok := claims.String("tenant"); !ok || tenant != "acme" {
+		t.Errorf("Tenant claim, expected: acme, got: %v", claims["tenant"])
+	}
+}
+
+func TestVerifyHS256Invalid(t *testing.T) {
+	valid := signed(t, auth.Claims{"tenant": "acme"})
+	parts := strings.Split(valid, ".")
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "malformed", token: "not-a-token"},
+		{name: "wrong secret", token: func() string {
+			token, _ := auth.SignHS256(auth.Claims{"tenant": "acme"}, []byte("other"))
+			return token
+		}()},
+		{name: "tampered claims", token: parts[0] + "." + strings.Split(signed(t, auth.Claims{"tenant": "globex"}), ".")[1] + "." + parts[2]},
+		{name: "algorithm none", token: "eyJhbGciOiJub25lIn0." + parts[1] + "."},
+		{name: "expired", token: signed(t, auth.Claims{"exp": float64(now.Add(-time.Hour).Unix())})},
+		{name: "not yet valid", token: signed(t, auth.Claims{"nbf": float64(now.Add(time.Hour).Unix())})},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Execute
+			_, err := auth.VerifyHS256(test.token, secret, now)
+
+			// Assert
+			if err == nil {
+				t.Errorf("Expected error to be returned but is nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+// Package blobs implements interfaces.BlobStore on a local directory and on S3
+// compatible object storage
+package blobs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+)
+
+type (
+	// Local is a blob store of files in Dir, for single instance deployments and
+	// development. Keys are slash separated paths below Dir. Content types are not
+	// stored: Get sniffs them from the content.
+	Local struct {
+		Dir string
+	}
+)
+
+// Put stores blob under key, replacing it atomically
+func (s *Local) Put(key string, blob *models.Blob) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), ".blob-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(blob.Body); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// Get the blob under key
+func (s *Local) Get(key string) (*models.Blob, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	body, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, errors.NotFound{Message: fmt.Sprintf("Blob %s not found", key)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &models.Blob{Body: body, ContentType: http.DetectContentType(body), ETag: contentETag(body)}, nil
+}
+
+// Delete the blobs under keys
+func (s *Local) Delete(keys ...string) error {
+	for _, key := range keys {
+		path, err := s.path(key)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// path returns the file of key, rejecting keys that are not confined to Dir
+func (s *Local) path(key string) (string, error) {
+	if !fs.ValidPath(key) || key == "." {
+		return "", fmt.Errorf("blobs: invalid key %q", key)
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(key)), nil
+}
+
+// contentETag returns a strong ETag derived from body
+func contentETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
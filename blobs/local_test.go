@@ -0,0 +1,46 @@
+package blobs_test
+
+import (
+	"testing"
+
+	"github.com/jordantipton/golang-restful-webservice/blobs"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestLocalPutGetDelete(t *testing.T) {
+	// Setup
+	store := &blobs.Local{Dir: t.TempDir()}
+
+	// Execute
+	putErr := store.Put("avatars/1/original", &models.Blob{Body: pngHeader, ContentType: "image/png"})
+	blob, getErr := store.Get("avatars/1/original")
+	deleteErr := store.Delete("avatars/1/original", "avatars/1/missing")
+	_, deletedErr := store.Get("avatars/1/original")
+
+	// Assert
+	if putErr != nil || getErr != nil || deleteErr != nil {
+		t.Fatalf("Errors, expected: none, got: %v, %v, %v", putErr, getErr, deleteErr)
+	}
+	if string(blob.Body) != string(pngHeader) || blob.ContentType != "image/png" || blob.ETag == "" {
+		t.Errorf("Blob, expected: the PNG with an ETag, got: %q, %s, %s", blob.Body, blob.ContentType, blob.ETag)
+	}
+	if _, ok := deletedErr.(errors.NotFound); !ok {
+		t.Errorf("Error, expected: NotFound, got: %v", deletedErr)
+	}
+}
+
+func TestLocalRejectsKeysOutsideDir(t *testing.T) {
+	// Setup
+	store := &blobs.Local{Dir: t.TempDir()}
+
+	// Execute
+	err := store.Put("../escape", &models.Blob{Body: []byte("x")})
+
+	// Assert
+	if err == nil {
+		t.Errorf("Error, expected: invalid key, got: nil")
+	}
+}
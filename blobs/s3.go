@@ -0,0 +1,205 @@
+package blobs
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+)
+
+// maxS3ErrorBody bounds how much of an error response is quoted in errors
+const maxS3ErrorBody = 512
+
+type (
+	// S3 is a blob store of objects in a bucket of an S3 compatible service, such as
+	// AWS S3 or MinIO. Objects are addressed path-style, as Endpoint/Bucket/key, and
+	// requests are signed with AWS Signature Version 4.
+	S3 struct {
+		// Endpoint is the URL of the service, such as https://s3.eu-west-1.amazonaws.com
+		// or http://localhost:9000
+		Endpoint        string
+		Bucket          string
+		Region          string
+		AccessKeyID     string
+		SecretAccessKey string
+		// Client sends the requests, http.DefaultClient if nil
+		Client *http.Client
+	}
+)
+
+// Put stores blob under key
+func (s *S3) Put(key string, blob *models.Blob) error {
+	req, err := s.request(http.MethodPut, key, blob.Body)
+	if err != nil {
+		return err
+	}
+	if blob.ContentType != "" {
+		req.Header.Set("Content-Type", blob.ContentType)
+	}
+	res, err := s.do(req, blob.Body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return s.responseError(req, res)
+	}
+	return nil
+}
+
+// Get the blob under key
+func (s *S3) Get(key string) (*models.Blob, error) {
+	req, err := s.request(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.do(req, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, errors.NotFound{Message: fmt.Sprintf("Blob %s not found", key)}
+	default:
+		return nil, s.responseError(req, res)
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	etag := res.Header.Get("ETag")
+	if etag == "" {
+		etag = contentETag(body)
+	}
+	return &models.Blob{Body: body, ContentType: res.Header.Get("Content-Type"), ETag: etag}, nil
+}
+
+// Delete the blobs under keys
+func (s *S3) Delete(keys ...string) error {
+	for _, key := range keys {
+		req, err := s.request(http.MethodDelete, key, nil)
+		if err != nil {
+			return err
+		}
+		res, err := s.do(req, nil)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNotFound {
+			return s.responseError(req, res)
+		}
+	}
+	return nil
+}
+
+// request returns an unsigned request for the object of key
+func (s *S3) request(method, key string, body []byte) (*http.Request, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(s.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	objectURL := *endpoint
+	objectURL.Path = endpoint.Path + "/" + s.Bucket + "/" + key
+	objectURL.RawPath = endpoint.EscapedPath() + "/" + uriEncode(s.Bucket) + "/" + uriEncode(key)
+	return http.NewRequest(method, objectURL.String(), bytes.NewReader(body))
+}
+
+// do signs req, whose body is body, and sends it
+func (s *S3) do(req *http.Request, body []byte) (*http.Response, error) {
+	s.sign(req, body, time.Now())
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// sign adds the AWS Signature Version 4 headers of req, whose body is body, at now
+func (s *S3) sign(req *http.Request, body []byte, now time.Time) {
+	now = now.UTC()
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "content-type" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + now.Format("20060102T150405Z") + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	for _, part := range []string{s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKeyID+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// responseError describes an unexpected response to req
+func (s *S3) responseError(req *http.Request, res *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(res.Body, maxS3ErrorBody))
+	return fmt.Errorf("blobs: %s %s: %s: %s", req.Method, req.URL.Path, res.Status, bytes.TrimSpace(body))
+}
+
+// uriEncode percent-encodes a path as Signature Version 4 requires: every byte but the
+// unreserved characters and slashes
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '.', c == '_', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
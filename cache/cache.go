@@ -0,0 +1,19 @@
+package cache
+
+import "time"
+
+type (
+	// Store is a key value cache with per entry expiry. Implementations must be safe for
+	// concurrent use.
+	Store interface {
+		// Get returns the value of key and whether it was found
+		Get(key string) ([]byte, bool, error)
+		// Set stores value under key for ttl
+		Set(key string, value []byte, ttl time.Duration) error
+		// SetNX atomically stores value under key for ttl unless key is already stored.
+		// It returns whether value was stored.
+		SetNX(key string, value []byte, ttl time.Duration) (bool, error)
+		// Delete removes keys. Missing keys are ignored.
+		Delete(keys ...string) error
+	}
+)
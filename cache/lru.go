@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DefaultLRUCapacity is the capacity of an LRU whose Capacity is not set
+const DefaultLRUCapacity = 10000
+
+type (
+	// LRU is an in-memory Store holding at most Capacity entries. The least recently
+	// used entry is evicted to make room, and expired entries are dropped when they are
+	// read. The zero value is ready to use. Entries live in one process, so it only
+	// suits caches that a single instance reads and invalidates.
+	LRU struct {
+		Capacity int
+
+		mu      sync.Mutex
+		entries *list.List
+		index   map[string]*list.Element
+	}
+
+	lruEntry struct {
+		key     string
+		value   []byte
+		expires time.Time
+	}
+)
+
+// Get value of key
+func (c *LRU) Get(key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.index[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := element.Value.(*lruEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(element)
+		return nil, false, nil
+	}
+	c.entries.MoveToFront(element)
+	return entry.value, true, nil
+}
+
+// Set value of key, evicting the least recently used entry if the LRU is full
+func (c *LRU) Set(key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(key, value, ttl)
+	return nil
+}
+
+// SetNX sets value of key unless key holds an entry that has not expired
+func (c *LRU) SetNX(key string, value []byte, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.index[key]; ok && !time.Now().After(element.Value.(*lruEntry).expires) {
+		return false, nil
+	}
+	c.set(key, value, ttl)
+	return true, nil
+}
+
+// set value of key. c.mu must be held.
+func (c *LRU) set(key string, value []byte, ttl time.Duration) {
+	if c.index == nil {
+		c.entries, c.index = list.New(), map[string]*list.Element{}
+	}
+	expires := time.Now().Add(ttl)
+	if element, ok := c.index[key]; ok {
+		entry := element.Value.(*lruEntry)
+		entry.value, entry.expires = value, expires
+		c.entries.MoveToFront(element)
+		return
+	}
+	capacity := c.Capacity
+	if capacity <= 0 {
+		capacity = DefaultLRUCapacity
+	}
+	for c.entries.Len() >= capacity {
+		c.remove(c.entries.Back())
+	}
+	c.index[key] = c.entries.PushFront(&lruEntry{key: key, value: value, expires: expires})
+}
+
+// Delete keys
+func (c *LRU) Delete(keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if element, ok := c.index[key]; ok {
+			c.remove(element)
+		}
+	}
+	return nil
+}
+
+// Len returns the number of entries, including expired entries not yet dropped
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		return 0
+	}
+	return c.entries.Len()
+}
+
+func (c *LRU) remove(element *list.Element) {
+	c.entries.Remove(element)
+	delete(c.index, element.Value.(*lruEntry).key)
+}
@@ -0,0 +1,89 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jordantipton/golang-restful-webservice/cache"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	// Setup
+	lru := cache.LRU{Capacity: 2}
+	lru.Set("a", []byte("1"), time.Minute)
+	lru.Set("b", []byte("2"), time.Minute)
+	lru.Get("a")
+
+	// Execute
+	lru.Set("c", []byte("3"), time.Minute)
+
+	// Assert
+	if _, ok, _ := lru.Get("b"); ok {
+		t.Errorf("Expected b to be evicted")
+	}
+	if value, ok, _ := lru.Get("a"); !ok || string(value) != "1" {
+		t.Errorf("a, expected: 1, got: %s (found: %t)", value, ok)
+	}
+	if lru.Len() != 2 {
+		t.Errorf("Len, expected: %d, got: %d", 2, lru.Len())
+	}
+}
+
+func TestLRUExpiresEntries(t *testing.T) {
+	// Setup
+	lru := cache.LRU{}
+	lru.Set("short", []byte("1"), time.Millisecond)
+	lru.Set("long", []byte("2"), time.Minute)
+
+	// Execute
+	time.Sleep(5 * time.Millisecond)
+
+	// Assert
+	if _, ok, _ := lru.Get("short"); ok {
+		t.Errorf("Expected short to have expired")
+	}
+	if _, ok, _ := lru.Get("long"); !ok {
+		t.Errorf("Expected long to be cached")
+	}
+	if lru.Len() != 1 {
+		t.Errorf("Len, expected: %d, got: %d", 1, lru.Len())
+	}
+}
+
+func TestLRUDelete(t *testing.T) {
+	// Setup
+	lru := cache.LRU{}
+	lru.Set("a", []byte("1"), time.Minute)
+
+	// Execute
+	lru.Delete("a", "missing")
+
+	// Assert
+	if _, ok, _ := lru.Get("a"); ok {
+		t.Errorf("Expected a to be deleted")
+	}
+}
+
+func TestLRUSetNX(t *testing.T) {
+	// Setup
+	lru := cache.LRU{}
+	lru.Set("taken", []byte("1"), time.Minute)
+	lru.Set("expired", []byte("1"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	// Execute
+	takenSet, _ := lru.SetNX("taken", []byte("2"), time.Minute)
+	expiredSet, _ := lru.SetNX("expired", []byte("2"), time.Minute)
+	freeSet, _ := lru.SetNX("free", []byte("2"), time.Minute)
+
+	// Assert
+	if takenSet || !expiredSet || !freeSet {
+		t.Errorf("Set, expected: taken false, expired true, free true, got: %t, %t, %t", takenSet, expiredSet, freeSet)
+	}
+	if value, _, _ := lru.Get("taken"); string(value) != "1" {
+		t.Errorf("taken, expected: 1, got: %s", value)
+	}
+	if value, _, _ := lru.Get("expired"); string(value) != "2" {
+		t.Errorf("expired, expected: 2, got: %s", value)
+	}
+}
@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type (
+	// Redis is a Store backed by a Redis server, shared by every instance of the service
+	Redis struct {
+		Client redis.UniversalClient
+		// Prefix is prepended to every key
+		Prefix string
+	}
+)
+
+// Get value of key
+func (c *Redis) Get(key string) ([]byte, bool, error) {
+	value, err := c.Client.Get(context.Background(), c.Prefix+key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Set value of key
+func (c *Redis) Set(key string, value []byte, ttl time.Duration) error {
+	return c.Client.Set(context.Background(), c.Prefix+key, value, ttl).Err()
+}
+
+// SetNX sets value of key with SET NX PX unless key exists
+func (c *Redis) SetNX(key string, value []byte, ttl time.Duration) (bool, error) {
+	return c.Client.SetNX(context.Background(), c.Prefix+key, value, ttl).Result()
+}
+
+// Delete keys
+func (c *Redis) Delete(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = c.Prefix + key
+	}
+	return c.Client.Del(context.Background(), prefixed...).Err()
+}
@@ -0,0 +1,110 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/jordantipton/golang-restful-webservice/cache"
+)
+
+func newRedis(t *testing.T) (*cache.Redis, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return &cache.Redis{Client: client, Prefix: "test:"}, server
+}
+
+func TestRedisSetGet(t *testing.T) {
+	// Setup
+	store, server := newRedis(t)
+
+	// Execute
+	err := store.Set("user:1", []byte(`{"ID":1}`), time.Minute)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Set returned error: %s", err.Error())
+	}
+	if value, ok, err := store.Get("user:1"); err != nil || !ok || string(value) != `{"ID":1}` {
+		t.Errorf("Get, expected: {\"ID\":1}, got: %s (found: %t, error: %v)", value, ok, err)
+	}
+	if !server.Exists("test:user:1") {
+		t.Errorf("Expected the key to be prefixed")
+	}
+}
+
+func TestRedisExpiry(t *testing.T) {
+	// Setup
+	store, server := newRedis(t)
+	store.Set("user:1", []byte{}, time.Second)
+
+	// Execute
+	server.FastForward(2 * time.Second)
+
+	// Assert
+	if _, ok, err := store.Get("user:1"); ok || err != nil {
+		t.Errorf("Expected user:1 to have expired, found: %t, error: %v", ok, err)
+	}
+}
+
+func TestRedisDelete(t *testing.T) {
+	// Setup
+	store, _ := newRedis(t)
+	store.Set("user:1", []byte("1"), time.Minute)
+	store.Set("user:2", []byte("2"), time.Minute)
+
+	// Execute
+	err := store.Delete("user:1", "user:3")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Delete returned error: %s", err.Error())
+	}
+	if _, ok, _ := store.Get("user:1"); ok {
+		t.Errorf("Expected user:1 to be deleted")
+	}
+	if _, ok, _ := store.Get("user:2"); !ok {
+		t.Errorf("Expected user:2 to be kept")
+	}
+}
+
+func TestRedisSetNX(t *testing.T) {
+	// Setup
+	store, server := newRedis(t)
+	store.Set("taken", []byte("1"), time.Minute)
+
+	// Execute
+	takenSet, takenErr := store.SetNX("taken", []byte("2"), time.Minute)
+	freeSet, freeErr := store.SetNX("free", []byte("2"), time.Minute)
+
+	// Assert
+	if takenErr != nil || freeErr != nil {
+		t.Fatalf("SetNX returned errors: %v, %v", takenErr, freeErr)
+	}
+	if takenSet || !freeSet {
+		t.Errorf("Set, expected: taken false, free true, got: %t, %t", takenSet, freeSet)
+	}
+	if value, _, _ := store.Get("taken"); string(value) != "1" {
+		t.Errorf("taken, expected: 1, got: %s", value)
+	}
+	if ttl := server.TTL("test:free"); ttl != time.Minute {
+		t.Errorf("TTL of free, expected: %s, got: %s", time.Minute, ttl)
+	}
+}
+
+func TestRedisUnavailable(t *testing.T) {
+	// Setup
+	store, server := newRedis(t)
+	server.Close()
+
+	// Execute
+	_, ok, err := store.Get("user:1")
+
+	// Assert
+	if ok || err == nil {
+		t.Errorf("Expected an error from an unavailable server, found: %t, error: %v", ok, err)
+	}
+}
@@ -0,0 +1,506 @@
+// Package client is a Go client of the users REST API. It exchanges the dtos of the
+// apis package as JSON and returns the error types of models/errors, so callers handle
+// errors like the services do.
+package client
+
+import (
+	"bytes"
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+)
+
+// Retry defaults
+const (
+	DefaultMaxAttempts = 4
+	DefaultMinBackoff  = 100 * time.Millisecond
+	DefaultMaxBackoff  = 5 * time.Second
+)
+
+// maxErrorBodySize bounds how much of an error response is read
+const maxErrorBodySize = 64 << 10
+
+// tenantHeader names the tenant of requests, apis.TenantHeader
+const tenantHeader = "X-Tenant-ID"
+
+type (
+	// Authenticator adds credentials to every request, including retries
+	Authenticator interface {
+		Authenticate(req *http.Request) error
+	}
+
+	// AuthenticatorFunc adapts a function to an Authenticator
+	AuthenticatorFunc func(req *http.Request) error
+
+	// BearerToken authenticates with an Authorization: Bearer header
+	BearerToken string
+
+	// RetryPolicy retries requests that fail with a network error, 429 Too Many
+	// Requests or a 5xx status. Waits double from MinBackoff up to MaxBackoff, with
+	// jitter, unless the response has a Retry-After header. Zero fields take the
+	// defaults; set MaxAttempts to 1 to disable retries.
+	RetryPolicy struct {
+		MaxAttempts int
+		MinBackoff  time.Duration
+		MaxBackoff  time.Duration
+	}
+
+	// Client of the users API. BaseURL is the URL the routes are relative to, such as
+	// "https://users.example.com". HTTPClient defaults to http.DefaultClient and Auth to
+	// no credentials. Tenant, if set, is sent as the X-Tenant-ID header; otherwise the
+	// server tells the tenant from the token or the host.
+	//
+	// Requests that create users carry an Idempotency-Key header that stays the same
+	// across retries. The server applies them once per key and replays the response to
+	// the retries, so a create whose response was lost is not applied twice.
+	Client struct {
+		BaseURL    string
+		HTTPClient *http.Client
+		Auth       Authenticator
+		Retry      RetryPolicy
+		Tenant     string
+	}
+
+	// Error is an error response without a models/errors equivalent. Problem is set
+	// when the body was a problem+json document.
+	Error struct {
+		StatusCode int
+		Message    string
+		Problem    *dtos.Problem
+	}
+
+	// ListOptions select the users of ListUsers. PageSize defaults to the server
+	// default.
+	ListOptions struct {
+		PageSize   int
+		NamePrefix string
+	}
+
+	// UserPages iterates over the pages of users ordered by ID. Call Next before each
+	// page and check Err once Next returns false:
+	//
+	//	pages := c.ListUsers(ListOptions{})
+	//	for pages.Next(ctx) {
+	//		for _, user := range pages.Users() { ... }
+	//	}
+	//	if err := pages.Err(); err != nil { ... }
+	UserPages struct {
+		client    *Client
+		options   ListOptions
+		users     []dtos.User
+		pageToken string
+		done      bool
+		err       error
+	}
+
+	// ImportOptions of ImportUsers. OnConflict is fail, skip or update and defaults to
+	// fail.
+	ImportOptions struct {
+		OnConflict string
+		DryRun     bool
+	}
+
+	// request is an API call. body is kept encoded so that it can be sent again; a
+	// stream can only be sent once.
+	request struct {
+		method         string
+		path           string
+		query          url.Values
+		body           []byte
+		stream         io.Reader
+		contentType    string
+		idempotencyKey string
+	}
+)
+
+// Authenticate calls f
+func (f AuthenticatorFunc) Authenticate(req *http.Request) error { return f(req) }
+
+// Authenticate sets the Authorization header
+func (t BearerToken) Authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+string(t))
+	return nil
+}
+
+// Error method for Error
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// GetUser by ID
+func (c *Client) GetUser(ctx context.Context, userID int) (*dtos.User, error) {
+	var user dtos.User
+	if err := c.do(ctx, &request{method: http.MethodGet, path: "/users/" + strconv.Itoa(userID)}, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CreateUser and return the created user
+func (c *Client) CreateUser(ctx context.Context, user *dtos.User) (*dtos.User, error) {
+	body, err := json.Marshal(user)
+	if err != nil {
+		return nil, err
+	}
+	key, err := newIdempotencyKey()
+	if err != nil {
+		return nil, err
+	}
+	var created dtos.User
+	if err := c.do(ctx, &request{method: http.MethodPost, path: "/users", body: body, idempotencyKey: key}, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// UpdateUser renames the user with the ID of user and returns the result
+func (c *Client) UpdateUser(ctx context.Context, user *dtos.User) (*dtos.User, error) {
+	body, err := json.Marshal(&dtos.User{Name: user.Name})
+	if err != nil {
+		return nil, err
+	}
+	var updated dtos.User
+	if err := c.do(ctx, &request{method: http.MethodPut, path: "/users/" + strconv.Itoa(user.ID), body: body}, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteUser by ID
+func (c *Client) DeleteUser(ctx context.Context, userID int) error {
+	return c.do(ctx, &request{method: http.MethodDelete, path: "/users/" + strconv.Itoa(userID)}, nil)
+}
+
+// ImportUsers creates users from a text/csv or application/x-ndjson body and returns
+// the outcome of every row. An import that stopped early returns its report along with
+// errors.AlreadyExists, after a conflict in on_conflict=fail mode, or
+// errors.InvalidArgument, after a malformed row. Imports are not retried because body
+// is streamed.
+func (c *Client) ImportUsers(ctx context.Context, body io.Reader, mediaType string, options ImportOptions) (*dtos.ImportReport, error) {
+	query := url.Values{}
+	if options.OnConflict != "" {
+		query.Set("on_conflict", options.OnConflict)
+	}
+	if options.DryRun {
+		query.Set("dry_run", "true")
+	}
+	key, err := newIdempotencyKey()
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.roundTrip(ctx, &request{method: http.MethodPost, path: "/users:import", query: query, stream: body, contentType: mediaType, idempotencyKey: key})
+	if err != nil {
+		return nil, err
+	}
+	stopped := res.StatusCode == http.StatusBadRequest || res.StatusCode == http.StatusConflict
+	if mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type")); res.StatusCode >= 300 && (!stopped || mediaType != "application/json") {
+		return nil, decodeError(res)
+	}
+	defer res.Body.Close()
+	var report dtos.ImportReport
+	if err := json.NewDecoder(res.Body).Decode(&report); err != nil {
+		return nil, err
+	}
+	switch res.StatusCode {
+	case http.StatusConflict:
+		return &report, errors.AlreadyExists{Message: report.Error}
+	case http.StatusBadRequest:
+		return &report, errors.InvalidArgument{Message: report.Error}
+	}
+	return &report, nil
+}
+
+// ExportUsers writes all users, or those whose name starts with namePrefix, to w in
+// format csv, ndjson or parquet
+func (c *Client) ExportUsers(ctx context.Context, format, namePrefix string, w io.Writer) error {
+	query := url.Values{"format": {format}}
+	if namePrefix != "" {
+		query.Set("name_prefix", namePrefix)
+	}
+	res, err := c.roundTrip(ctx, &request{method: http.MethodGet, path: "/users:export", query: query})
+	if err != nil {
+		return err
+	}
+	if res.StatusCode >= 300 {
+		return decodeError(res)
+	}
+	defer res.Body.Close()
+	_, err = io.Copy(w, res.Body)
+	return err
+}
+
+// SearchUsers returns the users best matching query, best first. A limit of 0 takes the
+// server default.
+func (c *Client) SearchUsers(ctx context.Context, query string, limit int) ([]dtos.UserSearchResult, error) {
+	params := url.Values{"q": {query}}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+	var results dtos.UserSearchResults
+	if err := c.do(ctx, &request{method: http.MethodGet, path: "/users/search", query: params}, &results); err != nil {
+		return nil, err
+	}
+	return results.Results, nil
+}
+
+// RebuildSearchIndex starts a rebuild of the user search index and returns its job.
+// Rebuilds are retried since running one twice is harmless.
+func (c *Client) RebuildSearchIndex(ctx context.Context) (*dtos.Job, error) {
+	var job dtos.Job
+	if err := c.do(ctx, &request{method: http.MethodPost, path: "/users/search:rebuild"}, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetJob by ID
+func (c *Client) GetJob(ctx context.Context, jobID int) (*dtos.Job, error) {
+	var job dtos.Job
+	if err := c.do(ctx, &request{method: http.MethodGet, path: "/jobs/" + strconv.Itoa(jobID)}, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListUsers returns an iterator over the pages of users selected by options. No request
+// is made until Next is called.
+func (c *Client) ListUsers(options ListOptions) *UserPages {
+	return &UserPages{client: c, options: options}
+}
+
+// Next fetches the next page. It returns false after the last page or on an error.
+func (p *UserPages) Next(ctx context.Context) bool {
+	if p.done || p.err != nil {
+		return false
+	}
+	query := url.Values{}
+	if p.options.PageSize > 0 {
+		query.Set("page_size", strconv.Itoa(p.options.PageSize))
+	}
+	if p.options.NamePrefix != "" {
+		query.Set("name_prefix", p.options.NamePrefix)
+	}
+	if p.pageToken != "" {
+		query.Set("page_token", p.pageToken)
+	}
+	var page dtos.UserPage
+	if p.err = p.client.do(ctx, &request{method: http.MethodGet, path: "/users", query: query}, &page); p.err != nil {
+		p.users = nil
+		return false
+	}
+	p.users, p.pageToken = page.Users, page.NextPageToken
+	p.done = page.NextPageToken == ""
+	// An empty last page has nothing to iterate over
+	return len(p.users) > 0 || !p.done
+}
+
+// Users of the current page
+func (p *UserPages) Users() []dtos.User {
+	return p.users
+}
+
+// Err returns the error that stopped the iteration, if any
+func (p *UserPages) Err() error {
+	return p.err
+}
+
+// do sends r and decodes a successful JSON response into v unless v is nil
+func (c *Client) do(ctx context.Context, r *request, v interface{}) error {
+	res, err := c.roundTrip(ctx, r)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode >= 300 {
+		return decodeError(res)
+	}
+	defer res.Body.Close()
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// roundTrip sends r, retrying according to the retry policy, and returns the last
+// response. Requests with a streamed body are sent once.
+func (c *Client) roundTrip(ctx context.Context, r *request) (*http.Response, error) {
+	policy := c.retryPolicy()
+	if r.stream != nil {
+		policy.MaxAttempts = 1
+	}
+	for attempt := 1; ; attempt++ {
+		res, err := c.send(ctx, r)
+		retryable := err != nil || res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+		if !retryable || ctx.Err() != nil || attempt >= policy.MaxAttempts {
+			return res, err
+		}
+		var wait time.Duration
+		if err == nil {
+			wait = retryAfter(res.Header.Get("Retry-After"))
+			res.Body.Close()
+		}
+		if wait == 0 {
+			wait = policy.backoff(attempt)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// send makes one attempt at r
+func (c *Client) send(ctx context.Context, r *request) (*http.Response, error) {
+	target := strings.TrimSuffix(c.BaseURL, "/") + r.path
+	if len(r.query) > 0 {
+		target += "?" + r.query.Encode()
+	}
+	body := r.stream
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if r.contentType != "" {
+		req.Header.Set("Content-Type", r.contentType)
+	} else if r.body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if r.idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", r.idempotencyKey)
+	}
+	if c.Tenant != "" {
+		req.Header.Set(tenantHeader, c.Tenant)
+	}
+	if c.Auth != nil {
+		if err := c.Auth.Authenticate(req); err != nil {
+			return nil, err
+		}
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return httpClient.Do(req)
+}
+
+func (c *Client) retryPolicy() RetryPolicy {
+	policy := c.Retry
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = DefaultMaxAttempts
+	}
+	if policy.MinBackoff <= 0 {
+		policy.MinBackoff = DefaultMinBackoff
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = DefaultMaxBackoff
+	}
+	return policy
+}
+
+// backoff returns a random wait of up to MinBackoff doubled for every failed attempt,
+// capped at MaxBackoff
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	limit := p.MinBackoff << (attempt - 1)
+	if limit <= 0 || limit > p.MaxBackoff {
+		limit = p.MaxBackoff
+	}
+	return limit/2 + rand.N(limit/2+1)
+}
+
+// decodeError closes res and returns its error. Problems and plain text messages of
+// 400, 401, 403, 404, 409 and 422 responses map to the models/errors types.
+func decodeError(res *http.Response) error {
+	defer res.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBodySize))
+	message := strings.TrimSpace(string(data))
+	var problem *dtos.Problem
+	if mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type")); mediaType == "application/problem+json" {
+		problem = &dtos.Problem{}
+		if err := json.Unmarshal(data, problem); err == nil {
+			message = problemMessage(problem)
+		} else {
+			problem = nil
+		}
+	}
+	if message == "" {
+		message = http.StatusText(res.StatusCode)
+	}
+	switch res.StatusCode {
+	case http.StatusNotFound:
+		return errors.NotFound{Message: message}
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return errors.InvalidArgument{Message: message}
+	case http.StatusConflict:
+		return errors.AlreadyExists{Message: message}
+	case http.StatusUnauthorized:
+		return errors.Unauthenticated{Message: message}
+	case http.StatusForbidden:
+		return errors.PermissionDenied{Message: message}
+	}
+	return &Error{StatusCode: res.StatusCode, Message: message, Problem: problem}
+}
+
+// problemMessage formats the title, detail and errors of problem on one line
+func problemMessage(problem *dtos.Problem) string {
+	message := problem.Title
+	if problem.Detail != "" {
+		message += ": " + problem.Detail
+	}
+	var violations []string
+	for _, violation := range problem.Errors {
+		switch {
+		case violation.Pointer != "":
+			violations = append(violations, violation.Pointer+" "+violation.Detail)
+		case violation.Parameter != "":
+			violations = append(violations, violation.Parameter+" "+violation.Detail)
+		default:
+			violations = append(violations, violation.Detail)
+		}
+	}
+	if len(violations) > 0 {
+		message += " (" + strings.Join(violations, "; ") + ")"
+	}
+	return message
+}
+
+// retryAfter parses a Retry-After header in seconds or as an HTTP date, returning zero
+// if it is missing or invalid
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && time.Until(date) > 0 {
+		return time.Until(date)
+	}
+	return 0
+}
+
+func newIdempotencyKey() (string, error) {
+	key := make([]byte, 16)
+	if _, err := crand.Read(key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
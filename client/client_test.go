@@ -0,0 +1,358 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jordantipton/golang-restful-webservice/apis"
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+	"github.com/jordantipton/golang-restful-webservice/cache"
+	"github.com/jordantipton/golang-restful-webservice/client"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+)
+
+/*
+	Test objects
+*/
+
+type mockUsersServicer struct {
+	mockGetUser    func(userID int) (*models.User, error)
+	mockListUsers  func(options models.UserListOptions) ([]*models.User, error)
+	mockCreateUser func(user *models.User) (*models.User, error)
+	mockDeleteUser func(userID int) error
+}
+
+func (m *mockUsersServicer) GetUser(userID int) (*models.User, error) {
+	if m.mockGetUser != nil {
+		return m.mockGetUser(userID)
+	}
+	return nil, nil
+}
+
+func (m *mockUsersServicer) GetUsers(userIDs []int) ([]*models.User, error) {
+	return nil, nil
+}
+
+func (m *mockUsersServicer) ListUsers(options models.UserListOptions) ([]*models.User, error) {
+	if m.mockListUsers != nil {
+		return m.mockListUsers(options)
+	}
+	return nil, nil
+}
+
+func (m *mockUsersServicer) CreateUser(user *models.User) (*models.User, error) {
+	if m.mockCreateUser != nil {
+		return m.mockCreateUser(user)
+	}
+	return nil, nil
+}
+
+func (m *mockUsersServicer) UpdateUser(user *models.User) (*models.User, error) {
+	return user, nil
+}
+
+func (m *mockUsersServicer) RenameUser(userID int, name string) (*models.User, error) {
+	return &models.User{ID: userID, Name: name}, nil
+}
+
+func (m *mockUsersServicer) DeleteUser(userID int) error {
+	if m.mockDeleteUser != nil {
+		return m.mockDeleteUser(userID)
+	}
+	return nil
+}
+
+func (m *mockUsersServicer) ImportUsers(users []*models.User, options models.ImportOptions) ([]models.ImportResult, error) {
+	return nil, nil
+}
+
+func (m *mockUsersServicer) ExportUsers(filter models.UserFilter, fn func(user *models.User) error) error {
+	return nil
+}
+
+// usersServer serves the users resource of service
+func usersServer(service *mockUsersServicer) *httptest.Server {
+	r := chi.NewRouter()
+	apis.RegisterUsersResource(r, service)
+	return httptest.NewServer(r)
+}
+
+/*
+	Test functions
+*/
+
+func TestGetUser(t *testing.T) {
+	// Setup
+	server := usersServer(&mockUsersServicer{
+		mockGetUser: func(userID int) (*models.User, error) {
+			return &models.User{ID: userID, Name: "Alice"}, nil
+		},
+	})
+	defer server.Close()
+	c := &client.Client{BaseURL: server.URL}
+
+	// Execute
+	user, err := c.GetUser(context.Background(), 3)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("GetUser returned error: %s", err.Error())
+	}
+	if user.ID != 3 || user.Name != "Alice" {
+		t.Errorf("User, expected: {ID:3 Name:Alice}, got: %+v", user)
+	}
+}
+
+func TestGetUserNotFound(t *testing.T) {
+	// Setup
+	server := usersServer(&mockUsersServicer{
+		mockGetUser: func(userID int) (*models.User, error) {
+			return nil, errors.NotFound{Message: "not found"}
+		},
+	})
+	defer server.Close()
+	c := &client.Client{BaseURL: server.URL}
+
+	// Execute
+	_, err := c.GetUser(context.Background(), 3)
+
+	// Assert
+	notFound, ok := err.(errors.NotFound)
+	if !ok {
+		t.Fatalf("Error, expected: errors.NotFound, got: %T %v", err, err)
+	}
+	if notFound.Message != "User with ID 3 not found" {
+		t.Errorf("Message, expected: User with ID 3 not found, got: %s", notFound.Message)
+	}
+}
+
+func TestCreateUserInvalid(t *testing.T) {
+	// Setup
+	server := usersServer(&mockUsersServicer{})
+	defer server.Close()
+	c := &client.Client{BaseURL: server.URL}
+
+	// Execute
+	_, err := c.CreateUser(context.Background(), &dtos.User{})
+
+	// Assert
+	invalid, ok := err.(errors.InvalidArgument)
+	if !ok {
+		t.Fatalf("Error, expected: errors.InvalidArgument, got: %T %v", err, err)
+	}
+	if !strings.Contains(invalid.Message, "/name is required") {
+		t.Errorf("Message, expected to contain: /name is required, got: %s", invalid.Message)
+	}
+}
+
+func TestCreateUserRetries(t *testing.T) {
+	// Setup
+	var keys, authorizations []string
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		keys = append(keys, req.Header.Get("Idempotency-Key"))
+		authorizations = append(authorizations, req.Header.Get("Authorization"))
+		switch len(keys) {
+		case 1:
+			http.Error(res, "unavailable", http.StatusServiceUnavailable)
+		case 2:
+			res.Header().Set("Retry-After", "0")
+			http.Error(res, "slow down", http.StatusTooManyRequests)
+		default:
+			res.Header().Set("Content-Type", "application/json")
+			res.WriteHeader(http.StatusCreated)
+			res.Write([]byte(`{"id": 1, "name": "Bob"}`))
+		}
+	}))
+	defer server.Close()
+	c := &client.Client{
+		BaseURL: server.URL,
+		Auth:    client.BearerToken("secret"),
+		Retry:   client.RetryPolicy{MinBackoff: time.Millisecond},
+	}
+
+	// Execute
+	user, err := c.CreateUser(context.Background(), &dtos.User{Name: "Bob"})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("CreateUser returned error: %s", err.Error())
+	}
+	if user.ID != 1 {
+		t.Errorf("ID, expected: 1, got: %d", user.ID)
+	}
+	if len(keys) != 3 || keys[0] == "" || keys[1] != keys[0] || keys[2] != keys[0] {
+		t.Errorf("Idempotency keys, expected the same key 3 times, got: %v", keys)
+	}
+	for _, authorization := range authorizations {
+		if authorization != "Bearer secret" {
+			t.Errorf("Authorization, expected: Bearer secret, got: %s", authorization)
+		}
+	}
+}
+
+func TestCreateUserRetryAfterLostResponse(t *testing.T) {
+	// Setup
+	created := 0
+	r := chi.NewRouter()
+	r.Use(apis.Idempotency(&cache.LRU{}, apis.IdempotentUserRoutes...))
+	apis.RegisterUsersResource(r, &mockUsersServicer{
+		mockCreateUser: func(user *models.User) (*models.User, error) {
+			created++
+			user.ID = created
+			return user, nil
+		},
+	})
+	attempts := 0
+	// The proxy loses the response to the first attempt after the user was created
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		attempts++
+		if attempts == 1 {
+			r.ServeHTTP(httptest.NewRecorder(), req)
+			http.Error(res, "bad gateway", http.StatusBadGateway)
+			return
+		}
+		r.ServeHTTP(res, req)
+	}))
+	defer server.Close()
+	c := &client.Client{BaseURL: server.URL, Retry: client.RetryPolicy{MinBackoff: time.Millisecond}}
+
+	// Execute
+	user, err := c.CreateUser(context.Background(), &dtos.User{Name: "Bob"})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("CreateUser returned error: %s", err.Error())
+	}
+	if attempts != 2 || created != 1 {
+		t.Errorf("Attempts and users created, expected: 2 and 1, got: %d and %d", attempts, created)
+	}
+	if user.ID != 1 || user.Name != "Bob" {
+		t.Errorf("User, expected: {ID:1 Name:Bob}, got: %+v", user)
+	}
+}
+
+func TestCreateUserGivesUp(t *testing.T) {
+	// Setup
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		attempts++
+		http.Error(res, "broken", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	c := &client.Client{BaseURL: server.URL, Retry: client.RetryPolicy{MaxAttempts: 2, MinBackoff: time.Millisecond}}
+
+	// Execute
+	_, err := c.CreateUser(context.Background(), &dtos.User{Name: "Bob"})
+
+	// Assert
+	apiErr, ok := err.(*client.Error)
+	if !ok || apiErr.StatusCode != http.StatusInternalServerError || apiErr.Message != "broken" {
+		t.Errorf("Error, expected: 500 broken, got: %T %v", err, err)
+	}
+	if attempts != 2 {
+		t.Errorf("Attempts, expected: 2, got: %d", attempts)
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	// Setup
+	users := []*models.User{{ID: 1, Name: "Alice"}, {ID: 2, Name: "Bob"}, {ID: 5, Name: "Carol"}}
+	server := usersServer(&mockUsersServicer{
+		mockListUsers: func(options models.UserListOptions) ([]*models.User, error) {
+			var page []*models.User
+			for _, user := range users {
+				if user.ID > options.AfterID && len(page) < options.Limit {
+					page = append(page, user)
+				}
+			}
+			return page, nil
+		},
+	})
+	defer server.Close()
+	c := &client.Client{BaseURL: server.URL}
+
+	// Execute
+	var pageSizes []int
+	var names []string
+	pages := c.ListUsers(client.ListOptions{PageSize: 2})
+	for pages.Next(context.Background()) {
+		pageSizes = append(pageSizes, len(pages.Users()))
+		for _, user := range pages.Users() {
+			names = append(names, user.Name)
+		}
+	}
+
+	// Assert
+	if err := pages.Err(); err != nil {
+		t.Fatalf("ListUsers returned error: %s", err.Error())
+	}
+	if len(pageSizes) != 2 || pageSizes[0] != 2 || pageSizes[1] != 1 {
+		t.Errorf("Page sizes, expected: [2 1], got: %v", pageSizes)
+	}
+	if strings.Join(names, ",") != "Alice,Bob,Carol" {
+		t.Errorf("Names, expected: Alice,Bob,Carol, got: %v", names)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	// Setup
+	deletedID := 0
+	server := usersServer(&mockUsersServicer{
+		mockDeleteUser: func(userID int) error {
+			deletedID = userID
+			return nil
+		},
+	})
+	defer server.Close()
+	c := &client.Client{BaseURL: server.URL}
+
+	// Execute
+	err := c.DeleteUser(context.Background(), 4)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("DeleteUser returned error: %s", err.Error())
+	}
+	if deletedID != 4 {
+		t.Errorf("Deleted ID, expected: 4, got: %d", deletedID)
+	}
+}
+
+func TestClientSendsTenant(t *testing.T) {
+	// Setup
+	var tenantID string
+	r := chi.NewRouter()
+	r.Use(apis.ResolveTenant(&apis.TenantResolver{TrustTenantHeader: true}))
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			tenantID = apis.TenantID(req.Context())
+			next.ServeHTTP(res, req)
+		})
+	})
+	apis.RegisterUsersResource(r, &mockUsersServicer{
+		mockGetUser: func(userID int) (*models.User, error) {
+			return &models.User{ID: userID, Name: "Alice"}, nil
+		},
+	})
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	// Execute
+	_, err := (&client.Client{BaseURL: server.URL, Tenant: "acme"}).GetUser(context.Background(), 3)
+	_, missingErr := (&client.Client{BaseURL: server.URL}).GetUser(context.Background(), 3)
+
+	// Assert
+	if err != nil || tenantID != "acme" {
+		t.Errorf("Tenant, expected: acme, got: %s, %v", tenantID, err)
+	}
+	if _, ok := missingErr.(errors.InvalidArgument); !ok {
+		t.Errorf("Error without a tenant, expected: errors.InvalidArgument, got: %T %v", missingErr, missingErr)
+	}
+}
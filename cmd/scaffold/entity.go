@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// Entity is the definition of a resource to scaffold, read from a YAML file:
+	//
+	//	name: Product
+	//	plural: Products     # optional, Name + "s" by default
+	//	table: product       # optional, the snake case Name by default
+	//	timestamps: true     # optional, adds an updated_at column and Last-Modified
+	//	fields:
+	//	  - name: Title
+	//	    type: string     # string, int, int64, bool, float64 or time
+	//	    required: true   # cannot be the zero value
+	//	    max: 120         # maximum length of a string, 255 by default
+	//	    prefix: true     # lists can be filtered by a prefix of this string field
+	//	  - name: Price
+	//	    type: int
+	//
+	// Every entity has an integer ID generated by the database.
+	Entity struct {
+		Name       string  `yaml:"name"`
+		Plural     string  `yaml:"plural"`
+		Table      string  `yaml:"table"`
+		Timestamps bool    `yaml:"timestamps"`
+		Fields     []Field `yaml:"fields"`
+
+		// Module is the import path of the repository
+		Module string `yaml:"-"`
+		// Migration is the file name of the migration, such as 0005_create_product.sql
+		Migration string `yaml:"-"`
+	}
+
+	// Field is a field of an Entity
+	Field struct {
+		Name     string `yaml:"name"`
+		Type     string `yaml:"type"`
+		Required bool   `yaml:"required"`
+		Max      int    `yaml:"max"`
+		Prefix   bool   `yaml:"prefix"`
+	}
+)
+
+var (
+	identifierRe = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+	tableRe      = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+	// reservedVars are the variables of the templates besides the entity variables
+	reservedVars = map[string]bool{"r": true, "res": true, "req": true, "err": true, "tx": true, "w": true, "t": true, "db": true, "mock": true, "rows": true, "body": true, "tests": true, "test": true, "router": true}
+
+	// fieldTypes maps field types to their Go and SQL types
+	fieldTypes = map[string]struct{ goType, sqlType string }{
+		"string":  {"string", "VARCHAR(%d)"},
+		"int":     {"int", "INT"},
+		"int64":   {"int64", "BIGINT"},
+		"bool":    {"bool", "BOOLEAN"},
+		"float64": {"float64", "DOUBLE"},
+		"time":    {"time.Time", "DATETIME(6)"},
+	}
+)
+
+// readEntity reads and checks the entity definition at path
+func readEntity(path string) (*Entity, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entity Entity
+	decoder := yaml.NewDecoder(bytes.NewReader(body))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&entity); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := entity.check(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &entity, nil
+}
+
+// check validates the definition and fills in its defaults
+func (e *Entity) check() error {
+	if !identifierRe.MatchString(e.Name) {
+		return fmt.Errorf("name %q must be an exported Go identifier such as Product", e.Name)
+	}
+	if e.Plural == "" {
+		e.Plural = e.Name + "s"
+	}
+	if !identifierRe.MatchString(e.Plural) || e.Plural == e.Name {
+		return fmt.Errorf("plural %q must be an exported Go identifier other than the name", e.Plural)
+	}
+	if token.IsKeyword(e.Var()) || token.IsKeyword(e.PluralVar()) || reservedVars[e.Var()] || reservedVars[e.PluralVar()] {
+		return fmt.Errorf("name %q clashes with a keyword or variable of the generated code once lower cased", e.Name)
+	}
+	if e.Table == "" {
+		e.Table = snake(e.Name)
+	}
+	if !tableRe.MatchString(e.Table) {
+		return fmt.Errorf("table %q must be a lower case SQL identifier", e.Table)
+	}
+	if len(e.Fields) == 0 {
+		return fmt.Errorf("%s needs at least one field", e.Name)
+	}
+	seen := map[string]bool{"ID": true, "UpdatedAt": e.Timestamps}
+	prefixes := 0
+	for i := range e.Fields {
+		field := &e.Fields[i]
+		if !identifierRe.MatchString(field.Name) {
+			return fmt.Errorf("field name %q must be an exported Go identifier", field.Name)
+		}
+		if seen[field.Name] {
+			return fmt.Errorf("field %s is defined more than once or is reserved", field.Name)
+		}
+		seen[field.Name] = true
+		if _, ok := fieldTypes[field.Type]; !ok {
+			return fmt.Errorf("field %s has unknown type %q", field.Name, field.Type)
+		}
+		if field.Type == "string" && field.Max == 0 {
+			field.Max = 255
+		}
+		if field.Max < 0 || (field.Max > 0 && field.Type != "string") {
+			return fmt.Errorf("field %s: max only applies to strings and must be positive", field.Name)
+		}
+		if field.Prefix {
+			if field.Type != "string" {
+				return fmt.Errorf("field %s: prefix only applies to strings", field.Name)
+			}
+			prefixes++
+		}
+	}
+	if prefixes > 1 {
+		return fmt.Errorf("only one field can be filtered by prefix")
+	}
+	return nil
+}
+
+// Var is the name of a variable holding an entity, such as product
+func (e *Entity) Var() string { return lowerFirst(e.Name) }
+
+// PluralVar is the name of a variable holding entities, such as products
+func (e *Entity) PluralVar() string { return lowerFirst(e.Plural) }
+
+// Label is the entity in prose, such as "order line"
+func (e *Entity) Label() string { return strings.ReplaceAll(snake(e.Name), "_", " ") }
+
+// A is the Label with its indefinite article, such as "an order line"
+func (e *Entity) A() string {
+	if strings.ContainsRune("aeiou", rune(e.Label()[0])) {
+		return "an " + e.Label()
+	}
+	return "a " + e.Label()
+}
+
+// PluralLabel is the entities in prose, such as "order lines"
+func (e *Entity) PluralLabel() string { return strings.ReplaceAll(snake(e.Plural), "_", " ") }
+
+// File is the base name of files about one entity, such as order_line
+func (e *Entity) File() string { return snake(e.Name) }
+
+// PluralFile is the base name of files about entities, such as order_lines
+func (e *Entity) PluralFile() string { return snake(e.Plural) }
+
+// Path is the route of the entities, such as /order-lines
+func (e *Entity) Path() string { return "/" + strings.ReplaceAll(snake(e.Plural), "_", "-") }
+
+// Columns are the columns read into the model, separated by commas
+func (e *Entity) Columns() string {
+	columns := []string{"id"}
+	for _, field := range e.Fields {
+		columns = append(columns, field.Column())
+	}
+	if e.Timestamps {
+		columns = append(columns, "updated_at")
+	}
+	return strings.Join(columns, ", ")
+}
+
+// PrefixField is the field that lists are filtered by, or nil
+func (e *Entity) PrefixField() *Field {
+	for i := range e.Fields {
+		if e.Fields[i].Prefix {
+			return &e.Fields[i]
+		}
+	}
+	return nil
+}
+
+// Uses reports whether any field has the type
+func (e *Entity) Uses(fieldType string) bool {
+	for _, field := range e.Fields {
+		if field.Type == fieldType {
+			return true
+		}
+	}
+	return false
+}
+
+// UsesTime reports whether the model has a time.Time field
+func (e *Entity) UsesTime() bool { return e.Timestamps || e.Uses("time") }
+
+// Limited reports whether any field has a maximum length
+func (e *Entity) Limited() bool {
+	for _, field := range e.Fields {
+		if field.Max > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// GoType is the type of the field in models and dtos
+func (f Field) GoType() string { return fieldTypes[f.Type].goType }
+
+// SQLType is the type of the column of the field
+func (f Field) SQLType() string {
+	if f.Type == "string" {
+		return fmt.Sprintf(fieldTypes[f.Type].sqlType, f.Max)
+	}
+	return fieldTypes[f.Type].sqlType
+}
+
+// JSON is the name of the field in representations, such as unitPrice
+func (f Field) JSON() string { return lowerFirst(f.Name) }
+
+// Column is the column of the field, such as unit_price
+func (f Field) Column() string { return snake(f.Name) }
+
+// Label is the field in prose, such as "unit price"
+func (f Field) Label() string { return strings.ReplaceAll(snake(f.Name), "_", " ") }
+
+// Validate is the validate tag of the field in dtos
+func (f Field) Validate() string {
+	var rules []string
+	if f.Required {
+		rules = append(rules, "required")
+	}
+	if f.Max > 0 {
+		rules = append(rules, fmt.Sprintf("max=%d", f.Max))
+	}
+	return strings.Join(rules, ",")
+}
+
+// Sample is a Go expression of a valid value of the field for tests
+func (f Field) Sample() string {
+	switch f.Type {
+	case "string":
+		return `"Sample"`
+	case "int64":
+		return "int64(1)"
+	case "bool":
+		return "true"
+	case "float64":
+		return "1.5"
+	case "time":
+		return "time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)"
+	}
+	return "1"
+}
+
+// snake converts a Go identifier to snake case, such as OrderLineID to order_line_id
+func snake(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			startsWord := i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1])))
+			if startsWord {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// lowerFirst lower cases the leading word of a Go identifier, such as URLPath to urlPath
+func lowerFirst(name string) string {
+	runes := []rune(name)
+	for i := range runes {
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		if !unicode.IsUpper(runes[i]) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
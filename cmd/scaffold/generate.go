@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+var (
+	templates = template.Must(template.New("").ParseFS(templateFiles, "templates/*.tmpl"))
+
+	migrationRe = regexp.MustCompile(`^(\d+)_.*\.sql$`)
+)
+
+// file is a file generated for an entity
+type file struct {
+	// Path is relative to the root of the repository
+	Path     string
+	Template string
+}
+
+// files returns the files generated for e, following the layout of the users stack
+func files(e *Entity) []file {
+	return []file{
+		{"models/" + e.File() + ".go", "model.go.tmpl"},
+		{"apis/dtos/" + e.File() + ".go", "dto.go.tmpl"},
+		{"apis/converters/" + e.File() + "_converter.go", "converter.go.tmpl"},
+		{"apis/" + e.PluralFile() + ".go", "resource.go.tmpl"},
+		{"apis/" + e.PluralFile() + "_test.go", "resource_test.go.tmpl"},
+		{"services/interfaces/" + e.PluralFile() + ".go", "persister.go.tmpl"},
+		{"services/" + e.PluralFile() + ".go", "service.go.tmpl"},
+		{"services/" + e.PluralFile() + "_test.go", "service_test.go.tmpl"},
+		{"repositories/" + e.PluralFile() + ".go", "repository.go.tmpl"},
+		{"repositories/" + e.PluralFile() + "_test.go", "repository_test.go.tmpl"},
+		{"migrations/" + e.Migration, "migration.sql.tmpl"},
+	}
+}
+
+// generate renders the files of e into root. Existing files are only replaced if force
+// is set. It returns the paths of the files it wrote, or would write if dryRun is set.
+func generate(e *Entity, root string, force, dryRun bool) ([]string, error) {
+	migration, err := nextMigration(filepath.Join(root, "migrations"), e.Table)
+	if err != nil {
+		return nil, err
+	}
+	e.Migration = migration
+	rendered := map[string][]byte{}
+	var paths []string
+	for _, f := range files(e) {
+		target := filepath.Join(root, filepath.FromSlash(f.Path))
+		if _, err := os.Stat(target); err == nil && !force {
+			return nil, fmt.Errorf("%s already exists, use -force to replace it", f.Path)
+		}
+		var body bytes.Buffer
+		if err := templates.ExecuteTemplate(&body, f.Template, e); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Path, err)
+		}
+		source := body.Bytes()
+		if strings.HasSuffix(f.Path, ".go") {
+			if source, err = format.Source(source); err != nil {
+				return nil, fmt.Errorf("%s does not compile: %w", f.Path, err)
+			}
+		}
+		rendered[target] = source
+		paths = append(paths, f.Path)
+	}
+	if dryRun {
+		return paths, nil
+	}
+	for target, source := range rendered {
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(target, source, 0o644); err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+// nextMigration returns the file name of the migration creating table, numbered after
+// the migrations in dir. The number is kept if a migration already creates table.
+func nextMigration(dir, table string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	name := "_create_" + table + ".sql"
+	var numbers []int
+	for _, entry := range entries {
+		match := migrationRe.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		if strings.HasSuffix(entry.Name(), name) {
+			return entry.Name(), nil
+		}
+		number, _ := strconv.Atoi(match[1])
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+	next := 1
+	if len(numbers) > 0 {
+		next = numbers[len(numbers)-1] + 1
+	}
+	return fmt.Sprintf("%04d%s", next, name), nil
+}
@@ -0,0 +1,76 @@
+// Command scaffold generates a resource end to end from an entity definition file: its
+// model, dto, converter, persister interface, service, SQL repository, migration, REST
+// handlers, which document their own OpenAPI operations, and tests. The files follow the layout of the
+// users stack and are built on the generic Resource, Service and Repository.
+//
+// Usage:
+//
+//	go run ./cmd/scaffold [-root dir] [-force] [-dry-run] entity.yaml
+//
+// or from a go:generate directive next to the definition:
+//
+//	//go:generate go run ./cmd/scaffold product.yaml
+//
+// See Entity for the format of the definition. Existing files are left alone unless
+// -force is set. The generated resource still has to be registered in app.buildRouter;
+// scaffold prints how.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// defaultModule is the import path of this repository
+const defaultModule = "github.com/jordantipton/golang-restful-webservice"
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "scaffold:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("scaffold", flag.ContinueOnError)
+	root := flags.String("root", ".", "root directory of the repository")
+	module := flags.String("module", defaultModule, "import path of the repository")
+	force := flags.Bool("force", false, "replace existing files")
+	dryRun := flags.Bool("dry-run", false, "list the files without writing them")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: scaffold [flags] entity.yaml")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected one entity definition file")
+	}
+	entity, err := readEntity(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	entity.Module = *module
+	paths, err := generate(entity, *root, *force, *dryRun)
+	if err != nil {
+		return err
+	}
+	verb := "wrote"
+	if *dryRun {
+		verb = "would write"
+	}
+	for _, path := range paths {
+		fmt.Fprintln(out, verb, path)
+	}
+	fmt.Fprintf(out, `
+Next steps:
+  1. Apply migrations/%s to the database.
+  2. Register the resource in app.buildRouter:
+       apis.Register%sResource(r, &services.%sService{%sPersister: &repositories.%sRepository{DB: db, TenantID: tenantID}})
+`, entity.Migration, entity.Plural, entity.Plural, entity.Plural, entity.Plural)
+	return nil
+}
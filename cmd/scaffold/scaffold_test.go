@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+/*
+	Test objects
+*/
+
+const orderLineDefinition = `name: OrderLine
+timestamps: true
+fields:
+  - name: Title
+    type: string
+    required: true
+    max: 120
+    prefix: true
+  - name: UnitPrice
+    type: int64
+  - name: ShippedAt
+    type: time
+`
+
+// writeFile writes body to name in dir and returns its path
+func writeFile(t *testing.T, dir, name, body string) string {
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+/*
+	Test functions
+*/
+
+func TestRunWritesResourceFiles(t *testing.T) {
+	// Setup
+	root := t.TempDir()
+	writeFile(t, root, "migrations/0004_add_user_updated_at.sql", "")
+	definition := writeFile(t, t.TempDir(), "order_line.yaml", orderLineDefinition)
+	var out bytes.Buffer
+
+	// Execute
+	err := run([]string{"-root", root, definition}, &out)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("run returned error: %s", err.Error())
+	}
+	expected := []string{
+		"models/order_line.go",
+		"apis/dtos/order_line.go",
+		"apis/converters/order_line_converter.go",
+		"apis/order_lines.go",
+		"apis/order_lines_test.go",
+		"services/interfaces/order_lines.go",
+		"services/order_lines.go",
+		"services/order_lines_test.go",
+		"repositories/order_lines.go",
+		"repositories/order_lines_test.go",
+		"migrations/0005_create_order_line.sql",
+	}
+	for _, path := range expected {
+		if _, err := os.Stat(filepath.Join(root, path)); err != nil {
+			t.Errorf("File %s, expected: written, got: %s", path, err)
+		}
+		if !strings.Contains(out.String(), "wrote "+path+"\n") {
+			t.Errorf("Output, expected: wrote %s, got: %s", path, out.String())
+		}
+	}
+	migration, _ := os.ReadFile(filepath.Join(root, "migrations/0005_create_order_line.sql"))
+	if !strings.Contains(string(migration), "title VARCHAR(120) NOT NULL") || !strings.Contains(string(migration), "tenant_id VARCHAR(63) NOT NULL") {
+		t.Errorf("Migration, expected: title and tenant_id columns, got: %s", migration)
+	}
+	resource, _ := os.ReadFile(filepath.Join(root, "apis/order_lines.go"))
+	if !strings.Contains(string(resource), `RegisterResource(router, "/order-lines", r.crud())`) || !strings.Contains(string(resource), `Plural:   "OrderLines"`) {
+		t.Errorf("Resource, expected: /order-lines routes of OrderLines, got: %s", resource)
+	}
+}
+
+func TestRunKeepsExistingFiles(t *testing.T) {
+	// Setup
+	root := t.TempDir()
+	definition := writeFile(t, t.TempDir(), "order_line.yaml", orderLineDefinition)
+	existing := writeFile(t, root, "models/order_line.go", "package models\n")
+
+	// Execute
+	err := run([]string{"-root", root, definition}, &bytes.Buffer{})
+	dryRunErr := run([]string{"-root", root, "-force", "-dry-run", definition}, &bytes.Buffer{})
+	body, _ := os.ReadFile(existing)
+	forceErr := run([]string{"-root", root, "-force", definition}, &bytes.Buffer{})
+	forced, _ := os.ReadFile(existing)
+
+	// Assert
+	if err == nil || !strings.Contains(err.Error(), "models/order_line.go already exists") {
+		t.Errorf("Error, expected: models/order_line.go already exists, got: %v", err)
+	}
+	if dryRunErr != nil || string(body) != "package models\n" {
+		t.Errorf("Dry run, expected: no changes, got: %v, %s", dryRunErr, body)
+	}
+	if forceErr != nil || !strings.Contains(string(forced), "type OrderLine struct") {
+		t.Errorf("Force, expected: replaced model, got: %v, %s", forceErr, forced)
+	}
+	if _, err := os.Stat(filepath.Join(root, "migrations/0001_create_order_line.sql")); err != nil {
+		t.Errorf("Migration, expected: 0001_create_order_line.sql, got: %s", err)
+	}
+}
+
+func TestEntityCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition string
+		err        string
+	}{
+		{"unknown type", "name: Product\nfields:\n  - name: Price\n    type: money\n", `field Price has unknown type "money"`},
+		{"two prefixes", "name: Product\nfields:\n  - name: A\n    type: string\n    prefix: true\n  - name: B\n    type: string\n    prefix: true\n", "only one field can be filtered by prefix"},
+		{"reserved field", "name: Product\nfields:\n  - name: ID\n    type: int\n", "field ID is defined more than once or is reserved"},
+		{"unknown key", "name: Product\ncolumns: []\n", "field columns not found"},
+		{"keyword name", "name: Type\nfields:\n  - name: Label\n    type: string\n", "clashes with a keyword"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Setup
+			definition := writeFile(t, t.TempDir(), "entity.yaml", test.definition)
+
+			// Execute
+			_, err := readEntity(definition)
+
+			// Assert
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Error, expected: %s, got: %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/jordantipton/golang-restful-webservice/apis/converters"
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+	"github.com/jordantipton/golang-restful-webservice/client"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/repositories"
+	"github.com/jordantipton/golang-restful-webservice/services"
+)
+
+type (
+	// backend carries out the commands, either through the API or on the database
+	backend interface {
+		GetUser(ctx context.Context, userID int) (*dtos.User, error)
+		CreateUser(ctx context.Context, user *dtos.User) (*dtos.User, error)
+		UpdateUser(ctx context.Context, user *dtos.User) (*dtos.User, error)
+		DeleteUser(ctx context.Context, userID int) error
+		// ListUsers calls fn with the users selected by options, ordered by ID, until fn
+		// returns false
+		ListUsers(ctx context.Context, options client.ListOptions, fn func(user dtos.User) bool) error
+		ImportUsers(ctx context.Context, body io.Reader, mediaType string, options client.ImportOptions) (*dtos.ImportReport, error)
+		ExportUsers(ctx context.Context, format, namePrefix string, w io.Writer) error
+		SearchUsers(ctx context.Context, query string, limit int) ([]dtos.UserSearchResult, error)
+		RebuildSearchIndex(ctx context.Context) (*dtos.Job, error)
+		GetJob(ctx context.Context, jobID int) (*dtos.Job, error)
+		Close() error
+	}
+
+	// apiBackend talks to the running service
+	apiBackend struct {
+		*client.Client
+	}
+
+	// dbBackend works on the database through the services and repositories, for when
+	// the service is down. It bypasses the caches of the service, which serve the old
+	// users until their entries expire, and does not publish events.
+	dbBackend struct {
+		db      *sql.DB
+		service services.UsersServicer
+	}
+)
+
+// errNeedsAPI is returned by the commands that only the service implements
+var errNeedsAPI = fmt.Errorf("this command needs the service: set --url or a profile with a url")
+
+// newDBBackend opens the database at dsn to operate on the users of tenantID, or of
+// the default tenant if it is empty
+func newDBBackend(dsn, tenantID string) (*dbBackend, error) {
+	if tenantID == "" {
+		tenantID = models.DefaultTenantID
+	}
+	// Scan DATETIME columns into time.Time, like the service does
+	if config, err := mysql.ParseDSN(dsn); err == nil {
+		config.ParseTime = true
+		dsn = config.FormatDSN()
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+	service := &services.UsersService{UsersPersister: &repositories.UsersRepository{DB: db, TenantID: tenantID}}
+	return &dbBackend{db: db, service: service}, nil
+}
+
+// ListUsers pages through the users with the client
+func (b *apiBackend) ListUsers(ctx context.Context, options client.ListOptions, fn func(user dtos.User) bool) error {
+	pages := b.Client.ListUsers(options)
+	for pages.Next(ctx) {
+		for _, user := range pages.Users() {
+			if !fn(user) {
+				return nil
+			}
+		}
+	}
+	return pages.Err()
+}
+
+// Close does nothing; the client holds no resources
+func (b *apiBackend) Close() error {
+	return nil
+}
+
+// GetUser by ID
+func (b *dbBackend) GetUser(ctx context.Context, userID int) (*dtos.User, error) {
+	user, err := b.service.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	return converters.ToUser(user), nil
+}
+
+// CreateUser with the service rules
+func (b *dbBackend) CreateUser(ctx context.Context, user *dtos.User) (*dtos.User, error) {
+	created, err := b.service.CreateUser(converters.FromUser(user))
+	if err != nil {
+		return nil, err
+	}
+	return converters.ToUser(created), nil
+}
+
+// UpdateUser with the service rules
+func (b *dbBackend) UpdateUser(ctx context.Context, user *dtos.User) (*dtos.User, error) {
+	serviceUser := converters.FromUser(user)
+	serviceUser.ID = user.ID
+	updated, err := b.service.UpdateUser(serviceUser)
+	if err != nil {
+		return nil, err
+	}
+	return converters.ToUser(updated), nil
+}
+
+// DeleteUser by ID
+func (b *dbBackend) DeleteUser(ctx context.Context, userID int) error {
+	return b.service.DeleteUser(userID)
+}
+
+// ListUsers pages through the users with the service
+func (b *dbBackend) ListUsers(ctx context.Context, options client.ListOptions, fn func(user dtos.User) bool) error {
+	listOptions := models.UserListOptions{Limit: options.PageSize, NamePrefix: options.NamePrefix}
+	if listOptions.Limit <= 0 {
+		listOptions.Limit = services.DefaultListUsersLimit
+	}
+	if listOptions.Limit > services.MaxListUsersLimit {
+		listOptions.Limit = services.MaxListUsersLimit
+	}
+	for {
+		users, err := b.service.ListUsers(listOptions)
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			if !fn(*converters.ToUser(user)) {
+				return nil
+			}
+		}
+		// A short page means there is nothing left to fetch
+		if len(users) < listOptions.Limit {
+			return nil
+		}
+		listOptions.AfterID = users[len(users)-1].ID
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// ImportUsers needs the row readers of the service
+func (b *dbBackend) ImportUsers(ctx context.Context, body io.Reader, mediaType string, options client.ImportOptions) (*dtos.ImportReport, error) {
+	return nil, errNeedsAPI
+}
+
+// ExportUsers needs the export writers of the service
+func (b *dbBackend) ExportUsers(ctx context.Context, format, namePrefix string, w io.Writer) error {
+	return errNeedsAPI
+}
+
+// SearchUsers needs the search index of the service
+func (b *dbBackend) SearchUsers(ctx context.Context, query string, limit int) ([]dtos.UserSearchResult, error) {
+	return nil, errNeedsAPI
+}
+
+// RebuildSearchIndex needs the search index of the service
+func (b *dbBackend) RebuildSearchIndex(ctx context.Context) (*dtos.Job, error) {
+	return nil, errNeedsAPI
+}
+
+// GetJob needs the job runner of the service
+func (b *dbBackend) GetJob(ctx context.Context, jobID int) (*dtos.Job, error) {
+	return nil, errNeedsAPI
+}
+
+// Close the database
+func (b *dbBackend) Close() error {
+	return b.db.Close()
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+	"github.com/jordantipton/golang-restful-webservice/client"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/repositories"
+)
+
+// defaultListLimit is the number of users list prints unless --limit is set
+const defaultListLimit = 100
+
+// jobPollInterval is how often reindex --wait checks on the job
+const jobPollInterval = time.Second
+
+// globalOptions are the flags of every command
+type globalOptions struct {
+	configPath string
+	profile    string
+	url        string
+	token      string
+	tenant     string
+	dsn        string
+	output     string
+	timeout    time.Duration
+}
+
+// newRootCommand builds the usersctl command tree
+func newRootCommand() *cobra.Command {
+	options := &globalOptions{}
+	root := &cobra.Command{
+		Use:          "usersctl",
+		Short:        "Operate the users service",
+		Long:         fmt.Sprintf("usersctl reads and changes users through the REST API of the service, or through its database with --dsn when the service is down. Changes made on the database bypass the caches of the service, which keep serving the old users for up to %s, and publish no events.", repositories.DefaultUserCacheTTL),
+		SilenceUsage: true,
+	}
+	flags := root.PersistentFlags()
+	flags.StringVar(&options.configPath, "config", defaultConfigPath(), "profiles file")
+	flags.StringVarP(&options.profile, "profile", "p", "", "profile to use instead of the current profile")
+	flags.StringVar(&options.url, "url", "", "base URL of the service, overriding the profile")
+	flags.StringVar(&options.token, "token", "", "bearer token for the service, overriding the profile")
+	flags.StringVar(&options.tenant, "tenant", "", "tenant to operate on, overriding the profile")
+	flags.StringVar(&options.dsn, "dsn", "", "MySQL DSN of the database, overriding the profile")
+	flags.StringVarP(&options.output, "output", "o", outputTable, "output format: table, json or yaml")
+	flags.DurationVar(&options.timeout, "timeout", time.Minute, "time limit of the command")
+	root.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(outputFormats, cobra.ShellCompDirectiveNoFileComp))
+	root.RegisterFlagCompletionFunc("profile", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		c, err := loadConfig(options.configPath)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		return c.profileNames(), cobra.ShellCompDirectiveNoFileComp
+	})
+
+	root.AddCommand(
+		newGetCommand(options),
+		newCreateCommand(options),
+		newListCommand(options),
+		newUpdateCommand(options),
+		newDeleteCommand(options),
+		newImportCommand(options),
+		newExportCommand(options),
+		newSearchCommand(options),
+		newReindexCommand(options),
+		newProfilesCommand(options),
+	)
+	return root
+}
+
+// run calls fn with the backend of the selected profile and a context bounded by the
+// timeout
+func (o *globalOptions) run(cmd *cobra.Command, fn func(ctx context.Context, b backend) error) error {
+	c, err := loadConfig(o.configPath)
+	if err != nil {
+		return err
+	}
+	p, err := c.profile(o.profile)
+	if err != nil {
+		return err
+	}
+	// Either flag replaces the target of the profile
+	if o.url != "" || o.dsn != "" {
+		p.URL, p.DSN = o.url, o.dsn
+	}
+	if o.token != "" {
+		p.Token = o.token
+	}
+	if o.tenant != "" {
+		p.Tenant = o.tenant
+	}
+	var b backend
+	switch {
+	case p.URL != "":
+		apiClient := &client.Client{BaseURL: p.URL, Tenant: p.Tenant}
+		if p.Token != "" {
+			apiClient.Auth = client.BearerToken(p.Token)
+		}
+		b = &apiBackend{Client: apiClient}
+	case p.DSN != "":
+		if b, err = newDBBackend(p.DSN, p.Tenant); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("no service to talk to: set --url or --dsn, or define a profile in %s", o.configPath)
+	}
+	defer b.Close()
+	ctx, cancel := context.WithTimeout(cmd.Context(), o.timeout)
+	defer cancel()
+	return fn(ctx, b)
+}
+
+func newGetCommand(options *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get USER_ID...",
+		Short: "Print users by ID",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			userIDs, err := parseUserIDs(args)
+			if err != nil {
+				return err
+			}
+			return options.run(cmd, func(ctx context.Context, b backend) error {
+				var users []dtos.User
+				for _, userID := range userIDs {
+					user, err := b.GetUser(ctx, userID)
+					if err != nil {
+						return err
+					}
+					users = append(users, *user)
+				}
+				if len(users) == 1 {
+					return printUser(cmd.OutOrStdout(), options.output, &users[0])
+				}
+				return printUsers(cmd.OutOrStdout(), options.output, users)
+			})
+		},
+	}
+}
+
+func newCreateCommand(options *globalOptions) *cobra.Command {
+	var email string
+	cmd := &cobra.Command{
+		Use:   "create NAME",
+		Short: "Create a user",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return options.run(cmd, func(ctx context.Context, b backend) error {
+				user, err := b.CreateUser(ctx, &dtos.User{Name: args[0], Email: email})
+				if err != nil {
+					return err
+				}
+				return printUser(cmd.OutOrStdout(), options.output, user)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&email, "email", "", "email of the user")
+	return cmd
+}
+
+func newListCommand(options *globalOptions) *cobra.Command {
+	var listOptions client.ListOptions
+	limit := defaultListLimit
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "Print users ordered by ID",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return options.run(cmd, func(ctx context.Context, b backend) error {
+				users := []dtos.User{}
+				err := b.ListUsers(ctx, listOptions, func(user dtos.User) bool {
+					users = append(users, user)
+					return limit <= 0 || len(users) < limit
+				})
+				if err != nil {
+					return err
+				}
+				return printUsers(cmd.OutOrStdout(), options.output, users)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&listOptions.NamePrefix, "name-prefix", "", "only print users whose name starts with it")
+	cmd.Flags().IntVar(&listOptions.PageSize, "page-size", 0, "users fetched per request")
+	cmd.Flags().IntVar(&limit, "limit", limit, "maximum number of users to print, or 0 for all")
+	return cmd
+}
+
+func newUpdateCommand(options *globalOptions) *cobra.Command {
+	var name, email string
+	cmd := &cobra.Command{
+		Use:   "update USER_ID --name NAME [--email EMAIL]",
+		Short: "Rename a user",
+		Long:  "Rename a user. The user keeps their email unless --email is set; --email \"\" removes it.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			userIDs, err := parseUserIDs(args)
+			if err != nil {
+				return err
+			}
+			return options.run(cmd, func(ctx context.Context, b backend) error {
+				if !cmd.Flags().Changed("email") {
+					current, err := b.GetUser(ctx, userIDs[0])
+					if err != nil {
+						return err
+					}
+					email = current.Email
+				}
+				user, err := b.UpdateUser(ctx, &dtos.User{ID: userIDs[0], Name: name, Email: email})
+				if err != nil {
+					return err
+				}
+				return printUser(cmd.OutOrStdout(), options.output, user)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "new name of the user")
+	cmd.Flags().StringVar(&email, "email", "", "new email of the user")
+	cmd.MarkFlagRequired("name")
+	return cmd
+}
+
+func newDeleteCommand(options *globalOptions) *cobra.Command {
+	var yes bool
+	cmd := &cobra.Command{
+		Use:   "delete USER_ID...",
+		Short: "Delete users by ID",
+		Long:  "Delete users by ID. Each user is printed and confirmed before it is deleted, unless --yes is set.",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			userIDs, err := parseUserIDs(args)
+			if err != nil {
+				return err
+			}
+			answers := bufio.NewReader(cmd.InOrStdin())
+			return options.run(cmd, func(ctx context.Context, b backend) error {
+				for _, userID := range userIDs {
+					if !yes {
+						user, err := b.GetUser(ctx, userID)
+						if err != nil {
+							return err
+						}
+						fmt.Fprintf(cmd.OutOrStdout(), "Delete user %d (%s)? [y/N] ", user.ID, user.Name)
+						answer, _ := answers.ReadString('\n')
+						if !strings.EqualFold(strings.TrimSpace(answer), "y") {
+							fmt.Fprintln(cmd.OutOrStdout(), "Skipped")
+							continue
+						}
+					}
+					if err := b.DeleteUser(ctx, userID); err != nil {
+						return err
+					}
+					fmt.Fprintf(cmd.OutOrStdout(), "Deleted user %d\n", userID)
+				}
+				return nil
+			})
+		},
+	}
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "delete without asking")
+	return cmd
+}
+
+func newImportCommand(options *globalOptions) *cobra.Command {
+	var importOptions client.ImportOptions
+	var format string
+	cmd := &cobra.Command{
+		Use:   "import FILE",
+		Short: "Import users from a CSV or NDJSON file, or - for stdin",
+		Long:  "Import users from a CSV file with a name column and optional id and email columns, or from an NDJSON file of users. The format is taken from the file extension unless --format is set. Imports run on the service.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format == "" {
+				format = strings.TrimPrefix(filepath.Ext(args[0]), ".")
+			}
+			mediaType, ok := map[string]string{"csv": "text/csv", "ndjson": "application/x-ndjson"}[format]
+			if !ok {
+				return fmt.Errorf("format must be csv or ndjson, got: %q", format)
+			}
+			body := cmd.InOrStdin()
+			if args[0] != "-" {
+				file, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				body = file
+			}
+			return options.run(cmd, func(ctx context.Context, b backend) error {
+				report, err := b.ImportUsers(ctx, body, mediaType, importOptions)
+				if report != nil {
+					if printErr := printImportReport(cmd.OutOrStdout(), options.output, report); printErr != nil {
+						return printErr
+					}
+				}
+				return err
+			})
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "", "csv or ndjson")
+	cmd.Flags().StringVar(&importOptions.OnConflict, "on-conflict", "", "what to do with rows whose ID exists: fail, skip or update")
+	cmd.Flags().BoolVar(&importOptions.DryRun, "dry-run", false, "validate the rows without writing them")
+	cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"csv", "ndjson"}, cobra.ShellCompDirectiveNoFileComp))
+	cmd.RegisterFlagCompletionFunc("on-conflict", cobra.FixedCompletions([]string{"fail", "skip", "update"}, cobra.ShellCompDirectiveNoFileComp))
+	return cmd
+}
+
+func newExportCommand(options *globalOptions) *cobra.Command {
+	var format, namePrefix, file string
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export users as CSV, NDJSON or Parquet",
+		Long:  "Export all users, or those whose name starts with --name-prefix, to stdout or --file. Exports run on the service.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return options.run(cmd, func(ctx context.Context, b backend) error {
+				var w io.Writer = cmd.OutOrStdout()
+				if file != "" {
+					f, err := os.Create(file)
+					if err != nil {
+						return err
+					}
+					defer f.Close()
+					w = f
+				}
+				return b.ExportUsers(ctx, format, namePrefix, w)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "csv", "csv, ndjson or parquet")
+	cmd.Flags().StringVar(&namePrefix, "name-prefix", "", "only export users whose name starts with it")
+	cmd.Flags().StringVarP(&file, "file", "f", "", "file to write instead of stdout")
+	cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"csv", "ndjson", "parquet"}, cobra.ShellCompDirectiveNoFileComp))
+	return cmd
+}
+
+func newSearchCommand(options *globalOptions) *cobra.Command {
+	var limit int
+	cmd := &cobra.Command{
+		Use:   "search QUERY...",
+		Short: "Search users by name and email",
+		Long:  "Print the users best matching the words of the query, best first. Matching ignores case and accents, completes the last letters of words and tolerates typos. Searches run on the service.",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return options.run(cmd, func(ctx context.Context, b backend) error {
+				results, err := b.SearchUsers(ctx, strings.Join(args, " "), limit)
+				if err != nil {
+					return err
+				}
+				return printSearchResults(cmd.OutOrStdout(), options.output, results)
+			})
+		},
+	}
+	cmd.Flags().IntVar(&limit, "limit", 0, "maximum number of users to print, 20 by default")
+	return cmd
+}
+
+func newReindexCommand(options *globalOptions) *cobra.Command {
+	var wait bool
+	cmd := &cobra.Command{
+		Use:   "reindex",
+		Short: "Rebuild the user search index",
+		Long:  "Start a rebuild of the user search index on the service and print its job. With --wait, print the job once it has finished, within --timeout.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return options.run(cmd, func(ctx context.Context, b backend) error {
+				job, err := b.RebuildSearchIndex(ctx)
+				if err != nil {
+					return err
+				}
+				for wait && !(&models.Job{Status: job.Status}).Finished() {
+					select {
+					case <-ctx.Done():
+						return ctx.Err()
+					case <-time.After(jobPollInterval):
+					}
+					if job, err = b.GetJob(ctx, job.ID); err != nil {
+						return err
+					}
+				}
+				if err := printJob(cmd.OutOrStdout(), options.output, job); err != nil {
+					return err
+				}
+				if job.Status == models.JobFailed {
+					return fmt.Errorf("reindex failed: %s", job.Error)
+				}
+				return nil
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&wait, "wait", false, "wait for the rebuild to finish")
+	return cmd
+}
+
+func newProfilesCommand(options *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "profiles",
+		Short: "List the profiles of the config file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := loadConfig(options.configPath)
+			if err != nil {
+				return err
+			}
+			for _, name := range c.profileNames() {
+				marker := " "
+				if name == c.CurrentProfile {
+					marker = "*"
+				}
+				p := c.Profiles[name]
+				target := p.URL
+				if target == "" {
+					target = "database"
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%s %s\t%s\n", marker, name, target)
+			}
+			return nil
+		},
+	}
+}
+
+// parseUserIDs parses command arguments as user IDs
+func parseUserIDs(args []string) ([]int, error) {
+	userIDs := make([]int, len(args))
+	for i, arg := range args {
+		userID, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("user ID must be an integer, got: %q", arg)
+		}
+		userIDs[i] = userID
+	}
+	return userIDs, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// config is the profiles file, by default $XDG_CONFIG_HOME/usersctl/config.yaml:
+	//
+	//	current-profile: staging
+	//	profiles:
+	//	  staging:
+	//	    url: https://users.staging.example.com
+	//	    token-env: USERS_STAGING_TOKEN
+	//	    tenant: acme
+	//	  local-db:
+	//	    dsn: root:secret@tcp(localhost:3306)/users
+	config struct {
+		CurrentProfile string             `yaml:"current-profile"`
+		Profiles       map[string]profile `yaml:"profiles"`
+	}
+
+	// profile is an environment to operate on. URL talks to the service and DSN to its
+	// database; URL wins if both are set. TokenEnv names an environment variable that
+	// holds the token, to keep it out of the file. Tenant selects the tenant whose users
+	// are operated on; the service may tell it from the token instead.
+	profile struct {
+		URL      string `yaml:"url,omitempty"`
+		Token    string `yaml:"token,omitempty"`
+		TokenEnv string `yaml:"token-env,omitempty"`
+		DSN      string `yaml:"dsn,omitempty"`
+		Tenant   string `yaml:"tenant,omitempty"`
+	}
+)
+
+// defaultConfigPath returns $USERSCTL_CONFIG or config.yaml in the usersctl directory of
+// the user configuration directory
+func defaultConfigPath() string {
+	if path := os.Getenv("USERSCTL_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "usersctl", "config.yaml")
+}
+
+// loadConfig reads the config at path. A missing file is an empty config.
+func loadConfig(path string) (*config, error) {
+	c := &config{}
+	if path == "" {
+		return c, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// profile returns the profile called name, or the current profile if name is empty.
+// Without either, it returns an empty profile.
+func (c *config) profile(name string) (profile, error) {
+	if name == "" {
+		name = c.CurrentProfile
+	}
+	if name == "" {
+		return profile{}, nil
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		return profile{}, fmt.Errorf("profile %q is not defined", name)
+	}
+	if p.Token == "" && p.TokenEnv != "" {
+		p.Token = os.Getenv(p.TokenEnv)
+	}
+	return p, nil
+}
+
+// profileNames returns the names of the profiles in order
+func (c *config) profileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,16 @@
+// Command usersctl operates the users service from the command line. It talks to the
+// service through its REST API or, when the service is down, straight to its database.
+//
+// Environments are kept as profiles in a config file; see config. Run
+// "usersctl completion --help" to set up shell completion.
+package main
+
+import (
+	"os"
+)
+
+func main() {
+	if err := newRootCommand().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
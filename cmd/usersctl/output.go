@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+	"github.com/jordantipton/golang-restful-webservice/models"
+)
+
+// Output formats
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+var outputFormats = []string{outputTable, outputJSON, outputYAML}
+
+// printValue writes v in format. Tables are written by table; JSON and YAML use the
+// json names of the dtos, so they match the API.
+func printValue(w io.Writer, format string, v interface{}, table func(w *tabwriter.Writer)) error {
+	switch format {
+	case outputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case outputYAML:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		var document interface{}
+		if err := json.Unmarshal(data, &document); err != nil {
+			return err
+		}
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(document); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case outputTable:
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		table(tw)
+		return tw.Flush()
+	}
+	return fmt.Errorf("output must be one of table, json or yaml, got: %s", format)
+}
+
+// printUsers writes users as a list
+func printUsers(w io.Writer, format string, users []dtos.User) error {
+	return printValue(w, format, users, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, "ID\tNAME\tEMAIL\tUPDATED")
+		for _, user := range users {
+			updatedAt := ""
+			if user.UpdatedAt != nil {
+				updatedAt = user.UpdatedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", user.ID, user.Name, user.Email, updatedAt)
+		}
+	})
+}
+
+// printUser writes a single user, as an object rather than a list in JSON and YAML
+func printUser(w io.Writer, format string, user *dtos.User) error {
+	if format == outputTable {
+		return printUsers(w, format, []dtos.User{*user})
+	}
+	return printValue(w, format, user, nil)
+}
+
+// printImportReport writes the totals of report and the rows that were not created
+func printImportReport(w io.Writer, format string, report *dtos.ImportReport) error {
+	return printValue(w, format, report, func(tw *tabwriter.Writer) {
+		fmt.Fprintf(tw, "created: %d, updated: %d, skipped: %d, failed: %d", report.Created, report.Updated, report.Skipped, report.Failed)
+		if report.DryRun {
+			fmt.Fprint(tw, " (dry run)")
+		}
+		fmt.Fprintln(tw)
+		header := false
+		for _, row := range report.Rows {
+			if row.Status == models.ImportCreated {
+				continue
+			}
+			if !header {
+				fmt.Fprintln(tw, "\nROW\tID\tSTATUS\tERROR")
+				header = true
+			}
+			id := ""
+			if row.ID != 0 {
+				id = strconv.Itoa(row.ID)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", row.Row, id, row.Status, row.Error)
+		}
+		if report.Error != "" {
+			fmt.Fprintln(tw, "\n"+report.Error)
+		}
+	})
+}
+
+// printSearchResults writes the users of results with their scores
+func printSearchResults(w io.Writer, format string, results []dtos.UserSearchResult) error {
+	return printValue(w, format, results, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, "ID\tNAME\tSCORE")
+		for _, result := range results {
+			fmt.Fprintf(tw, "%d\t%s\t%.2f\n", result.User.ID, result.User.Name, result.Score)
+		}
+	})
+}
+
+// printJob writes the status of a background job
+func printJob(w io.Writer, format string, job *dtos.Job) error {
+	return printValue(w, format, job, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, "ID\tTYPE\tSTATUS\tPROGRESS\tERROR")
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%s\n", job.ID, job.Type, job.Status, job.Progress, job.Error)
+	})
+}
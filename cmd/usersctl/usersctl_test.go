@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jordantipton/golang-restful-webservice/apis/dtos"
+)
+
+/*
+	Test objects
+*/
+
+// usersServer serves users and records the requests it receives as "METHOD path"
+func usersServer(users []dtos.User, requests *[]string, authorizations *[]string) *httptest.Server {
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			*requests = append(*requests, req.Method+" "+req.URL.RequestURI())
+			if authorizations != nil {
+				*authorizations = append(*authorizations, req.Header.Get("Authorization"))
+			}
+			next.ServeHTTP(res, req)
+		})
+	})
+	r.Get("/users/{userID}", func(res http.ResponseWriter, req *http.Request) {
+		userID, _ := strconv.Atoi(chi.URLParam(req, "userID"))
+		for _, user := range users {
+			if user.ID == userID {
+				json.NewEncoder(res).Encode(user)
+				return
+			}
+		}
+		http.Error(res, "User not found", http.StatusNotFound)
+	})
+	r.Get("/users", func(res http.ResponseWriter, req *http.Request) {
+		pageSize, _ := strconv.Atoi(req.URL.Query().Get("page_size"))
+		afterID, _ := strconv.Atoi(req.URL.Query().Get("page_token"))
+		page := dtos.UserPage{Users: []dtos.User{}}
+		for _, user := range users {
+			if user.ID > afterID && len(page.Users) < pageSize {
+				page.Users = append(page.Users, user)
+			}
+		}
+		if len(page.Users) == pageSize {
+			page.NextPageToken = strconv.Itoa(page.Users[pageSize-1].ID)
+		}
+		json.NewEncoder(res).Encode(page)
+	})
+	r.Delete("/users/{userID}", func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusNoContent)
+	})
+	r.Get("/users/search", func(res http.ResponseWriter, req *http.Request) {
+		results := dtos.UserSearchResults{Results: []dtos.UserSearchResult{}}
+		for _, user := range users {
+			if strings.Contains(strings.ToLower(user.Name), req.URL.Query().Get("q")) {
+				results.Results = append(results.Results, dtos.UserSearchResult{User: user, Score: 1.5})
+			}
+		}
+		json.NewEncoder(res).Encode(results)
+	})
+	r.Post("/users/search:rebuild", func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(res).Encode(dtos.Job{ID: 3, Type: "users.search.rebuild", Status: "queued"})
+	})
+	r.Get("/jobs/{jobID}", func(res http.ResponseWriter, req *http.Request) {
+		json.NewEncoder(res).Encode(dtos.Job{ID: 3, Type: "users.search.rebuild", Status: "succeeded", Progress: int64(len(users))})
+	})
+	return httptest.NewServer(r)
+}
+
+// execute runs usersctl with args and stdin and returns its output
+func execute(t *testing.T, stdin string, args ...string) (string, error) {
+	cmd := newRootCommand()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetIn(strings.NewReader(stdin))
+	cmd.SetArgs(append([]string{"--config", filepath.Join(t.TempDir(), "missing.yaml")}, args...))
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+var testUsers = []dtos.User{{ID: 1, Name: "Alice"}, {ID: 2, Name: "Bob"}, {ID: 5, Name: "Carol"}, {ID: 8, Name: "Dave"}}
+
+/*
+	Test functions
+*/
+
+func TestGetTable(t *testing.T) {
+	// Setup
+	var requests []string
+	server := usersServer(testUsers, &requests, nil)
+	defer server.Close()
+
+	// Execute
+	out, err := execute(t, "", "--url", server.URL, "get", "2")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("get returned error: %s", err.Error())
+	}
+	expected := "ID  NAME  EMAIL  UPDATED\n2   Bob          \n"
+	if out != expected {
+		t.Errorf("Output, expected: %q, got: %q", expected, out)
+	}
+}
+
+func TestGetNotFound(t *testing.T) {
+	// Setup
+	var requests []string
+	server := usersServer(testUsers, &requests, nil)
+	defer server.Close()
+
+	// Execute
+	_, err := execute(t, "", "--url", server.URL, "get", "3")
+
+	// Assert
+	if err == nil || err.Error() != "User not found" {
+		t.Errorf("Error, expected: User not found, got: %v", err)
+	}
+}
+
+func TestListYAMLWithLimit(t *testing.T) {
+	// Setup
+	var requests []string
+	server := usersServer(testUsers, &requests, nil)
+	defer server.Close()
+
+	// Execute
+	out, err := execute(t, "", "--url", server.URL, "-o", "yaml", "list", "--page-size", "2", "--limit", "3")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("list returned error: %s", err.Error())
+	}
+	expected := "- id: 1\n  name: Alice\n- id: 2\n  name: Bob\n- id: 5\n  name: Carol\n"
+	if out != expected {
+		t.Errorf("Output, expected: %q, got: %q", expected, out)
+	}
+	if len(requests) != 2 {
+		t.Errorf("Requests, expected 2 pages, got: %v", requests)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	// Setup
+	var requests, authorizations []string
+	server := usersServer(testUsers, &requests, &authorizations)
+	defer server.Close()
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	config := "current-profile: local\nprofiles:\n  local:\n    dsn: root@tcp(localhost:1)/users\n  staging:\n    url: " + server.URL + "\n    token-env: USERSCTL_TEST_TOKEN\n"
+	os.WriteFile(configPath, []byte(config), 0600)
+	t.Setenv("USERSCTL_TEST_TOKEN", "secret")
+
+	// Execute
+	out, err := execute(t, "", "--config", configPath, "--profile", "staging", "-o", "json", "get", "1")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("get returned error: %s", err.Error())
+	}
+	if !strings.Contains(out, `"name": "Alice"`) {
+		t.Errorf("Output, expected Alice as JSON, got: %s", out)
+	}
+	if len(authorizations) != 1 || authorizations[0] != "Bearer secret" {
+		t.Errorf("Authorization, expected: Bearer secret, got: %v", authorizations)
+	}
+}
+
+func TestTenantFlag(t *testing.T) {
+	// Setup
+	var requests, tenants []string
+	server := usersServer(testUsers, &requests, nil)
+	defer server.Close()
+	handler := server.Config.Handler
+	server.Config.Handler = http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		tenants = append(tenants, req.Header.Get("X-Tenant-ID"))
+		handler.ServeHTTP(res, req)
+	})
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	config := "current-profile: staging\nprofiles:\n  staging:\n    url: " + server.URL + "\n    tenant: globex\n"
+	os.WriteFile(configPath, []byte(config), 0600)
+
+	// Execute
+	_, profileErr := execute(t, "", "--config", configPath, "get", "1")
+	_, flagErr := execute(t, "", "--config", configPath, "--tenant", "acme", "get", "1")
+
+	// Assert
+	if profileErr != nil || flagErr != nil {
+		t.Fatalf("get returned error: %v, %v", profileErr, flagErr)
+	}
+	if len(tenants) != 2 || tenants[0] != "globex" || tenants[1] != "acme" {
+		t.Errorf("Tenants, expected: globex from the profile then acme from the flag, got: %v", tenants)
+	}
+}
+
+func TestDeleteAsks(t *testing.T) {
+	// Setup
+	var requests []string
+	server := usersServer(testUsers, &requests, nil)
+	defer server.Close()
+
+	// Execute
+	out, err := execute(t, "n\ny\n", "--url", server.URL, "delete", "1", "2")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("delete returned error: %s", err.Error())
+	}
+	expected := "Delete user 1 (Alice)? [y/N] Skipped\nDelete user 2 (Bob)? [y/N] Deleted user 2\n"
+	if out != expected {
+		t.Errorf("Output, expected: %q, got: %q", expected, out)
+	}
+	expectedRequests := "GET /users/1,GET /users/2,DELETE /users/2"
+	if strings.Join(requests, ",") != expectedRequests {
+		t.Errorf("Requests, expected: %s, got: %v", expectedRequests, requests)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	// Setup
+	var requests []string
+	server := usersServer(testUsers, &requests, nil)
+	defer server.Close()
+
+	// Execute
+	out, err := execute(t, "", "--url", server.URL, "search", "ca", "--limit", "5")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("search returned error: %s", err.Error())
+	}
+	expected := "ID  NAME   SCORE\n5   Carol  1.50\n"
+	if out != expected {
+		t.Errorf("Output, expected: %q, got: %q", expected, out)
+	}
+	if len(requests) != 1 || requests[0] != "GET /users/search?limit=5&q=ca" {
+		t.Errorf("Requests, expected: the search, got: %v", requests)
+	}
+}
+
+func TestReindexWaits(t *testing.T) {
+	// Setup
+	var requests []string
+	server := usersServer(testUsers, &requests, nil)
+	defer server.Close()
+
+	// Execute
+	out, err := execute(t, "", "--url", server.URL, "reindex", "--wait", "-o", "json")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("reindex returned error: %s", err.Error())
+	}
+	var job dtos.Job
+	json.Unmarshal([]byte(out), &job)
+	if job.Status != "succeeded" || job.Progress != 4 {
+		t.Errorf("Job, expected: succeeded with 4 users, got: %+v", job)
+	}
+	expectedRequests := []string{"POST /users/search:rebuild", "GET /jobs/3"}
+	if strings.Join(requests, ",") != strings.Join(expectedRequests, ",") {
+		t.Errorf("Requests, expected: %v, got: %v", expectedRequests, requests)
+	}
+}
+
+func TestSearchNeedsAPI(t *testing.T) {
+	// Execute
+	_, err := execute(t, "", "--dsn", "root@tcp(127.0.0.1:1)/users", "search", "ada")
+
+	// Assert
+	if err != errNeedsAPI {
+		t.Errorf("Error, expected: %v, got: %v", errNeedsAPI, err)
+	}
+}
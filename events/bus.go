@@ -0,0 +1,228 @@
+package events
+
+import (
+	"hash/fnv"
+	"log"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jordantipton/golang-restful-webservice/models"
+)
+
+// Subscriber delivery modes
+const (
+	// Sync subscribers run on the publishing goroutine before Publish returns
+	Sync Mode = iota
+	// Async subscribers run on background workers. Events with the same aggregate
+	// ID are handled in publish order; different aggregates are handled concurrently.
+	Async
+)
+
+const (
+	defaultAsyncWorkers      = 4
+	defaultAsyncQueueSize    = 256
+	defaultAsyncQueueTimeout = time.Second
+)
+
+type (
+	// Mode selects how a subscriber receives events
+	Mode int
+
+	// Handler handles a published event
+	Handler func(event models.Event)
+
+	// Bus is an in-process domain event bus. The zero value is ready to use.
+	Bus struct {
+		// AsyncWorkers is the number of workers per async subscriber
+		AsyncWorkers int
+		// AsyncQueueSize is the buffered queue length of each async worker
+		AsyncQueueSize int
+		// AsyncQueueTimeout is how long Publish waits while the queue for an event's
+		// aggregate is full. The event is then dropped for that subscriber, counted in
+		// Dropped and reported through OnDrop, so that a stalled subscriber cannot stall
+		// the publishers. It defaults to a second.
+		AsyncQueueTimeout time.Duration
+		// OnPanic is called when a handler panics. It defaults to logging the panic.
+		OnPanic func(subscriber string, event models.Event, recovered interface{})
+		// OnDrop is called when an event is dropped for an async subscriber. It defaults
+		// to logging the event.
+		OnDrop func(subscriber string, event models.Event)
+
+		mu          sync.RWMutex
+		subscribers []*subscriber
+		closed      bool
+		dropped     atomic.Uint64
+	}
+
+	subscriber struct {
+		name    string
+		mode    Mode
+		handler Handler
+		queues  []chan models.Event
+		done    sync.WaitGroup
+
+		// mu guards stopped, which is set once the queues are closed. Enqueuers hold
+		// it for reading so the queues are not closed under them.
+		mu      sync.RWMutex
+		stopped bool
+	}
+)
+
+// Subscribe registers handler under name and returns a function that removes it.
+// Removing an async subscriber waits for its queued events to be handled.
+func (b *Bus) Subscribe(name string, mode Mode, handler Handler) func() {
+	s := &subscriber{name: name, mode: mode, handler: handler}
+	if mode == Async {
+		workers, queueSize := b.AsyncWorkers, b.AsyncQueueSize
+		if workers <= 0 {
+			workers = defaultAsyncWorkers
+		}
+		if queueSize <= 0 {
+			queueSize = defaultAsyncQueueSize
+		}
+		s.queues = make([]chan models.Event, workers)
+		for i := range s.queues {
+			s.queues[i] = make(chan models.Event, queueSize)
+			s.done.Add(1)
+			go b.work(s, s.queues[i])
+		}
+	}
+	b.mu.Lock()
+	b.subscribers = append(b.subscribers, s)
+	b.mu.Unlock()
+	return func() { b.unsubscribe(s) }
+}
+
+// Publish delivers event to every subscriber. Panics in handlers are recovered and
+// reported through OnPanic, so one failing subscriber cannot affect the publisher
+// or other subscribers. Events published after Close are dropped. The bus is not
+// locked while handlers run or events are queued, so handlers may publish, subscribe
+// and close the bus themselves.
+func (b *Bus) Publish(event models.Event) {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return
+	}
+	subscribers := b.subscribers
+	b.mu.RUnlock()
+	for _, s := range subscribers {
+		if s.mode == Async {
+			b.enqueue(s, event)
+		} else {
+			b.handle(s, event)
+		}
+	}
+}
+
+// Dropped returns the number of events dropped because the queue of an async
+// subscriber stayed full for AsyncQueueTimeout
+func (b *Bus) Dropped() uint64 {
+	return b.dropped.Load()
+}
+
+// Close stops accepting events and waits for async subscribers to drain their queues
+func (b *Bus) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	subscribers := b.subscribers
+	b.subscribers = nil
+	b.mu.Unlock()
+	for _, s := range subscribers {
+		s.stop()
+	}
+}
+
+func (b *Bus) unsubscribe(s *subscriber) {
+	b.mu.Lock()
+	found := false
+	for i, existing := range b.subscribers {
+		if existing == s {
+			// Publishers may hold the old slice, so it is never modified in place
+			b.subscribers = append(b.subscribers[:i:i], b.subscribers[i+1:]...)
+			found = true
+			break
+		}
+	}
+	b.mu.Unlock()
+	if found {
+		s.stop()
+	}
+}
+
+// enqueue queues event for the async subscriber s, waiting at most AsyncQueueTimeout
+// for room. Events for a stopped subscriber are ignored.
+func (b *Bus) enqueue(s *subscriber, event models.Event) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.stopped {
+		return
+	}
+	queue := s.queues[shard(event.AggregateID(), len(s.queues))]
+	select {
+	case queue <- event:
+		return
+	default:
+	}
+	timeout := b.AsyncQueueTimeout
+	if timeout <= 0 {
+		timeout = defaultAsyncQueueTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case queue <- event:
+	case <-timer.C:
+		b.dropped.Add(1)
+		if b.OnDrop != nil {
+			b.OnDrop(s.name, event)
+		} else {
+			log.Printf("events: dropped %s for subscriber %q, its queue is full", event.EventType(), s.name)
+		}
+	}
+}
+
+func (b *Bus) work(s *subscriber, queue chan models.Event) {
+	defer s.done.Done()
+	for event := range queue {
+		b.handle(s, event)
+	}
+}
+
+func (b *Bus) handle(s *subscriber, event models.Event) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			if b.OnPanic != nil {
+				b.OnPanic(s.name, event, recovered)
+			} else {
+				log.Printf("events: subscriber %q panicked handling %s: %v\n%s", s.name, event.EventType(), recovered, debug.Stack())
+			}
+		}
+	}()
+	s.handler(event)
+}
+
+// stop closes the subscriber's queues and waits for its workers. Callers must have
+// removed s from the bus so no further events are queued, except by publishers that
+// took the subscribers before; those find s stopped.
+func (s *subscriber) stop() {
+	s.mu.Lock()
+	s.stopped = true
+	for _, queue := range s.queues {
+		close(queue)
+	}
+	s.mu.Unlock()
+	s.done.Wait()
+}
+
+func shard(aggregateID string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(aggregateID))
+	return int(h.Sum32() % uint32(n))
+}
@@ -0,0 +1,186 @@
+package events_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jordantipton/golang-restful-webservice/events"
+	"github.com/jordantipton/golang-restful-webservice/models"
+)
+
+func TestPublishSync(t *testing.T) {
+	// Setup
+	bus := events.Bus{}
+	var received []models.Event
+	bus.Subscribe("recorder", events.Sync, func(event models.Event) {
+		received = append(received, event)
+	})
+
+	// Execute
+	bus.Publish(models.UserCreated{User: models.User{ID: 1, Name: "Name"}})
+
+	// Assert
+	if len(received) != 1 {
+		t.Fatalf("Received events, expected: %d, got: %d", 1, len(received))
+	}
+	if received[0].EventType() != models.EventUserCreated {
+		t.Errorf("Event type, expected: %s, got: %s", models.EventUserCreated, received[0].EventType())
+	}
+}
+
+func TestPublishAsyncOrdersPerAggregate(t *testing.T) {
+	// Setup
+	bus := events.Bus{AsyncWorkers: 3, AsyncQueueSize: 1}
+	var mu sync.Mutex
+	names := map[int][]string{}
+	bus.Subscribe("recorder", events.Async, func(event models.Event) {
+		renamed := event.(models.UserRenamed)
+		mu.Lock()
+		names[renamed.UserID] = append(names[renamed.UserID], renamed.NewName)
+		mu.Unlock()
+	})
+
+	// Execute
+	for i := 0; i < 50; i++ {
+		for userID := 1; userID <= 4; userID++ {
+			bus.Publish(models.UserRenamed{UserID: userID, NewName: string(rune('a' + i%26))})
+		}
+	}
+	bus.Close()
+
+	// Assert
+	for userID := 1; userID <= 4; userID++ {
+		if len(names[userID]) != 50 {
+			t.Fatalf("Events for user %d, expected: %d, got: %d", userID, 50, len(names[userID]))
+		}
+		for i, name := range names[userID] {
+			if expected := string(rune('a' + i%26)); name != expected {
+				t.Fatalf("Event %d for user %d, expected: %s, got: %s", i, userID, expected, name)
+			}
+		}
+	}
+}
+
+func TestPublishIsolatesPanics(t *testing.T) {
+	// Setup
+	var panicked []string
+	bus := events.Bus{
+		OnPanic: func(subscriber string, event models.Event, recovered interface{}) {
+			panicked = append(panicked, subscriber)
+		},
+	}
+	bus.Subscribe("broken", events.Sync, func(event models.Event) {
+		panic("boom")
+	})
+	delivered := 0
+	bus.Subscribe("healthy", events.Sync, func(event models.Event) {
+		delivered++
+	})
+
+	// Execute
+	bus.Publish(models.UserDeleted{UserID: 1})
+
+	// Assert
+	if len(panicked) != 1 || panicked[0] != "broken" {
+		t.Errorf("Panicked subscribers, expected: [broken], got: %v", panicked)
+	}
+	if delivered != 1 {
+		t.Errorf("Delivered to healthy subscriber, expected: %d, got: %d", 1, delivered)
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	// Setup
+	bus := events.Bus{}
+	delivered := 0
+	unsubscribe := bus.Subscribe("counter", events.Async, func(event models.Event) {
+		delivered++
+	})
+	bus.Publish(models.UserDeleted{UserID: 1})
+
+	// Execute
+	unsubscribe()
+	bus.Publish(models.UserDeleted{UserID: 2})
+
+	// Assert
+	if delivered != 1 {
+		t.Errorf("Delivered events, expected: %d, got: %d", 1, delivered)
+	}
+}
+
+func TestPublishDropsWhenQueueFull(t *testing.T) {
+	// Setup
+	var dropped []models.Event
+	bus := events.Bus{
+		AsyncWorkers:      1,
+		AsyncQueueSize:    1,
+		AsyncQueueTimeout: 10 * time.Millisecond,
+		OnDrop: func(subscriber string, event models.Event) {
+			dropped = append(dropped, event)
+		},
+	}
+	started, release := make(chan struct{}), make(chan struct{})
+	bus.Subscribe("stalled", events.Async, func(event models.Event) {
+		if event.(models.UserDeleted).UserID == 1 {
+			close(started)
+		}
+		<-release
+	})
+	bus.Publish(models.UserDeleted{UserID: 1})
+	<-started
+	bus.Publish(models.UserDeleted{UserID: 2})
+
+	// Execute
+	bus.Publish(models.UserDeleted{UserID: 3})
+
+	// Assert
+	close(release)
+	bus.Close()
+	if bus.Dropped() != 1 {
+		t.Errorf("Dropped events, expected: %d, got: %d", 1, bus.Dropped())
+	}
+	if len(dropped) != 1 || dropped[0].(models.UserDeleted).UserID != 3 {
+		t.Errorf("Dropped events, expected: [user 3], got: %v", dropped)
+	}
+}
+
+func TestSubscribeWhilePublishWaitsForFullQueue(t *testing.T) {
+	// Setup
+	bus := events.Bus{AsyncWorkers: 1, AsyncQueueSize: 1, AsyncQueueTimeout: 10 * time.Second}
+	started, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	bus.Subscribe("stalled", events.Async, func(event models.Event) {
+		once.Do(func() { close(started) })
+		<-release
+	})
+	bus.Publish(models.UserDeleted{UserID: 1})
+	<-started
+	bus.Publish(models.UserDeleted{UserID: 2})
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		bus.Publish(models.UserDeleted{UserID: 3})
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// Execute
+	subscribed := make(chan struct{})
+	go func() {
+		defer close(subscribed)
+		bus.Subscribe("late", events.Sync, func(event models.Event) {})
+	}()
+
+	// Assert
+	select {
+	case <-subscribed:
+	case <-time.After(time.Second):
+		t.Fatalf("Subscribe blocked while Publish waited for a full queue")
+	}
+	close(release)
+	<-published
+	bus.Close()
+	if bus.Dropped() != 0 {
+		t.Errorf("Dropped events, expected: %d, got: %d", 0, bus.Dropped())
+	}
+}
@@ -0,0 +1,109 @@
+package graphqlapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/jordantipton/golang-restful-webservice/apis/openapi"
+	"github.com/jordantipton/golang-restful-webservice/services"
+)
+
+type (
+	// GraphQLResource serves GraphQL queries and mutations over the users domain
+	GraphQLResource struct {
+		Schema        graphql.Schema
+		Service       services.UsersServicer
+		MaxDepth      int
+		MaxComplexity int
+	}
+
+	graphQLRequest struct {
+		Query         string                 `json:"query"`
+		Variables     map[string]interface{} `json:"variables"`
+		OperationName string                 `json:"operationName"`
+	}
+)
+
+// RegisterGraphQLResource sets up the routing of the GraphQL endpoint
+func RegisterGraphQLResource(router chi.Router, service services.UsersServicer) {
+	schema, err := NewSchema(service)
+	if err != nil {
+		panic(err)
+	}
+	r := &GraphQLResource{
+		Schema:        schema,
+		Service:       service,
+		MaxDepth:      DefaultMaxDepth,
+		MaxComplexity: DefaultMaxComplexity,
+	}
+	router.Method(http.MethodGet, "/graphql", openapi.Handler(queryGraphQLOperation, r.Query))
+	router.Method(http.MethodPost, "/graphql", openapi.Handler(executeGraphQLOperation, r.Query))
+}
+
+// Query executes a GraphQL request. GET requests may only run queries.
+func (r *GraphQLResource) Query(res http.ResponseWriter, req *http.Request) {
+	var body graphQLRequest
+	if req.Method == http.MethodGet {
+		query := req.URL.Query()
+		body.Query = query.Get("query")
+		body.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &body.Variables); err != nil {
+				http.Error(res, "variables must be a JSON object", http.StatusBadRequest)
+				return
+			}
+		}
+	} else {
+		defer req.Body.Close()
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(body.Query)})})
+	if err != nil {
+		writeResult(res, http.StatusBadRequest, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+	if req.Method == http.MethodGet && hasMutation(doc) {
+		writeResult(res, http.StatusMethodNotAllowed, &graphql.Result{Errors: gqlerrors.FormatErrors(errMutationOverGet)})
+		return
+	}
+	if err := checkLimits(doc, body.Variables, r.MaxDepth, r.MaxComplexity); err != nil {
+		writeResult(res, http.StatusBadRequest, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         r.Schema,
+		RequestString:  body.Query,
+		VariableValues: body.Variables,
+		OperationName:  body.OperationName,
+		Context:        withUserLoader(req.Context(), newUserLoader(r.Service)),
+	})
+	writeResult(res, http.StatusOK, result)
+}
+
+var errMutationOverGet = gqlerrors.NewFormattedError("Mutations must be sent with POST")
+
+func hasMutation(doc *ast.Document) bool {
+	for _, definition := range doc.Definitions {
+		if operation, ok := definition.(*ast.OperationDefinition); ok && operation.Operation == ast.OperationTypeMutation {
+			return true
+		}
+	}
+	return false
+}
+
+func writeResult(res http.ResponseWriter, status int, result *graphql.Result) {
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	json.NewEncoder(res).Encode(result)
+}
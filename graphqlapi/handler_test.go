@@ -0,0 +1,308 @@
+package graphqlapi_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	"github.com/jordantipton/golang-restful-webservice/graphqlapi"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+)
+
+/*
+	Test objects
+*/
+
+type mockUsersServicer struct {
+	mockGetUser     func(userID int) (*models.User, error)
+	mockGetUsers    func(userIDs []int) ([]*models.User, error)
+	mockListUsers   func(options models.UserListOptions) ([]*models.User, error)
+	mockCreateUser  func(user *models.User) (*models.User, error)
+	mockUpdateUser  func(user *models.User) (*models.User, error)
+	mockRenameUser  func(userID int, name string) (*models.User, error)
+	mockDeleteUser  func(userID int) error
+	mockImportUsers func(users []*models.User, options models.ImportOptions) ([]models.ImportResult, error)
+	mockExportUsers func(filter models.UserFilter, fn func(user *models.User) error) error
+}
+
+func (m *mockUsersServicer) GetUser(userID int) (*models.User, error) {
+	if m.mockGetUser != nil {
+		return m.mockGetUser(userID)
+	}
+	return nil, nil
+}
+
+func (m *mockUsersServicer) GetUsers(userIDs []int) ([]*models.User, error) {
+	if m.mockGetUsers != nil {
+		return m.mockGetUsers(userIDs)
+	}
+	return nil, nil
+}
+
+func (m *mockUsersServicer) ListUsers(options models.UserListOptions) ([]*models.User, error) {
+	if m.mockListUsers != nil {
+		return m.mockListUsers(options)
+	}
+	return nil, nil
+}
+
+func (m *mockUsersServicer) CreateUser(user *models.User) (*models.User, error) {
+	if m.mockCreateUser != nil {
+		return m.mockCreateUser(user)
+	}
+	return nil, nil
+}
+
+func (m *mockUsersServicer) UpdateUser(user *models.User) (*models.User, error) {
+	if m.mockUpdateUser != nil {
+		return m.mockUpdateUser(user)
+	}
+	return nil, nil
+}
+
+func (m *mockUsersServicer) RenameUser(userID int, name string) (*models.User, error) {
+	if m.mockRenameUser != nil {
+		return m.mockRenameUser(userID, name)
+	}
+	return nil, nil
+}
+
+func (m *mockUsersServicer) DeleteUser(userID int) error {
+	if m.mockDeleteUser != nil {
+		return m.mockDeleteUser(userID)
+	}
+	return nil
+}
+
+func (m *mockUsersServicer) ImportUsers(users []*models.User, options models.ImportOptions) ([]models.ImportResult, error) {
+	if m.mockImportUsers != nil {
+		return m.mockImportUsers(users, options)
+	}
+	return make([]models.ImportResult, len(users)), nil
+}
+
+func (m *mockUsersServicer) ExportUsers(filter models.UserFilter, fn func(user *models.User) error) error {
+	if m.mockExportUsers != nil {
+		return m.mockExportUsers(filter, fn)
+	}
+	return nil
+}
+
+type graphQLResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func post(service *mockUsersServicer, query string, variables map[string]interface{}) (int, graphQLResponse) {
+	r := chi.NewRouter()
+	graphqlapi.RegisterGraphQLResource(r, service)
+
+	bodyBytes, _ := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	req := httptest.NewRequest("POST", "http://localhost:8080/graphql", bytes.NewReader(bodyBytes))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var response graphQLResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	return w.Code, response
+}
+
+/*
+	Test functions
+*/
+
+func TestUserQueriesAreBatched(t *testing.T) {
+	// Setup
+	var batches [][]int
+	service := &mockUsersServicer{
+		mockGetUsers: func(userIDs []int) ([]*models.User, error) {
+			batches = append(batches, userIDs)
+			return []*models.User{{ID: 1, Name: "One"}, {ID: 3, Name: "Three"}}, nil
+		},
+	}
+
+	// Execute
+	code, response := post(service, `{ a: user(id: 1) { name } b: user(id: 2) { name } c: user(id: 3) { id name } }`, nil)
+
+	// Assert
+	if code != 200 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 200, code)
+	}
+	// graphql-go visits sibling fields in map order, so only the batch contents are fixed
+	if len(batches) == 1 {
+		sort.Ints(batches[0])
+	}
+	if len(batches) != 1 || !reflect.DeepEqual(batches[0], []int{1, 2, 3}) {
+		t.Errorf("GetUsers batches, expected: [[1 2 3]], got: %v", batches)
+	}
+	if response.Data["a"].(map[string]interface{})["name"] != "One" {
+		t.Errorf("a.name, expected: One, got: %v", response.Data["a"])
+	}
+	if response.Data["b"] != nil {
+		t.Errorf("b, expected: null, got: %v", response.Data["b"])
+	}
+	if response.Data["c"].(map[string]interface{})["name"] != "Three" {
+		t.Errorf("c.name, expected: Three, got: %v", response.Data["c"])
+	}
+}
+
+func TestUsersConnection(t *testing.T) {
+	// Setup
+	var requested models.UserListOptions
+	service := &mockUsersServicer{
+		mockListUsers: func(options models.UserListOptions) ([]*models.User, error) {
+			requested = options
+			return []*models.User{{ID: 4, Name: "Bob"}, {ID: 5, Name: "Bobby"}, {ID: 6, Name: "Bobbie"}}, nil
+		},
+	}
+	query := `query($after: String) { users(filter: {namePrefix: "Bo"}, first: 2, after: $after) {
+		edges { cursor node { id } } pageInfo { hasNextPage endCursor } } }`
+
+	// Execute
+	_, first := post(service, query, nil)
+	endCursor := first.Data["users"].(map[string]interface{})["pageInfo"].(map[string]interface{})["endCursor"].(string)
+	post(service, query, map[string]interface{}{"after": endCursor})
+
+	// Assert
+	users := first.Data["users"].(map[string]interface{})
+	if edges := users["edges"].([]interface{}); len(edges) != 2 {
+		t.Errorf("Edges, expected: %d, got: %d", 2, len(edges))
+	}
+	if users["pageInfo"].(map[string]interface{})["hasNextPage"] != true {
+		t.Errorf("hasNextPage, expected: true, got: %v", users["pageInfo"])
+	}
+	expected := models.UserListOptions{AfterID: 5, Limit: 3, NamePrefix: "Bo"}
+	if requested != expected {
+		t.Errorf("Options for second page, expected: %v, got: %v", expected, requested)
+	}
+}
+
+func TestCreateUserErrorCode(t *testing.T) {
+	// Setup
+	service := &mockUsersServicer{
+		mockCreateUser: func(user *models.User) (*models.User, error) {
+			return nil, errors.InvalidArgument{Message: "User name cannot be empty"}
+		},
+	}
+
+	// Execute
+	_, response := post(service, `mutation { createUser(name: "") { id } }`, nil)
+
+	// Assert
+	if len(response.Errors) != 1 {
+		t.Fatalf("Errors, expected: %d, got: %d", 1, len(response.Errors))
+	}
+	if code := response.Errors[0].Extensions["code"]; code != "INVALID_ARGUMENT" {
+		t.Errorf("Error code, expected: INVALID_ARGUMENT, got: %v", code)
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	// Setup
+	service := &mockUsersServicer{
+		mockRenameUser: func(userID int, name string) (*models.User, error) {
+			return &models.User{ID: userID, Name: name, Email: "ada@example.com"}, nil
+		},
+		mockUpdateUser: func(user *models.User) (*models.User, error) {
+			t.Errorf("UpdateUser called, expected only the name to be written")
+			return user, nil
+		},
+	}
+
+	// Execute
+	_, response := post(service, `mutation { updateUser(id: 1, name: "New") { id name email } }`, nil)
+
+	// Assert
+	user := response.Data["updateUser"].(map[string]interface{})
+	if user["name"] != "New" {
+		t.Errorf("Name, expected: New, got: %v", user["name"])
+	}
+	if user["email"] != "ada@example.com" {
+		t.Errorf("Email, expected: ada@example.com, got: %v", user["email"])
+	}
+}
+
+func TestUpdateUserEmail(t *testing.T) {
+	// Setup
+	service := &mockUsersServicer{
+		mockUpdateUser: func(user *models.User) (*models.User, error) {
+			return user, nil
+		},
+	}
+
+	// Execute
+	_, response := post(service, `mutation { updateUser(id: 1, name: "New", email: "") { id email } }`, nil)
+
+	// Assert
+	if email := response.Data["updateUser"].(map[string]interface{})["email"]; email != nil {
+		t.Errorf("Email, expected: null, got: %v", email)
+	}
+}
+
+func TestQueryDepthLimit(t *testing.T) {
+	// Setup
+	query := `{ users { edges { node { id } } } }`
+	r := chi.NewRouter()
+	resource := &graphqlapi.GraphQLResource{Service: &mockUsersServicer{}, MaxDepth: 3, MaxComplexity: graphqlapi.DefaultMaxComplexity}
+	resource.Schema, _ = graphqlapi.NewSchema(resource.Service)
+	r.Post("/graphql", resource.Query)
+
+	bodyBytes, _ := json.Marshal(map[string]interface{}{"query": query})
+	req := httptest.NewRequest("POST", "http://localhost:8080/graphql", bytes.NewReader(bodyBytes))
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 400 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 400, w.Code)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "depth 4 exceeds") {
+		t.Errorf("Response body, expected depth error, got: %s", body)
+	}
+}
+
+func TestQueryComplexityLimit(t *testing.T) {
+	// Setup
+	query := `{ users(first: 100) { edges { node { id name } cursor } } a: users(first: 100) { edges { node { id name } cursor } } }`
+
+	// Execute
+	code, response := post(&mockUsersServicer{}, query, nil)
+
+	// Assert
+	if code != 400 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 400, code)
+	}
+	if len(response.Errors) != 1 || !strings.Contains(response.Errors[0].Message, "complexity") {
+		t.Errorf("Errors, expected complexity error, got: %v", response.Errors)
+	}
+}
+
+func TestMutationOverGetRejected(t *testing.T) {
+	// Setup
+	r := chi.NewRouter()
+	graphqlapi.RegisterGraphQLResource(r, &mockUsersServicer{})
+
+	req := httptest.NewRequest("GET", "http://localhost:8080/graphql?query="+url.QueryEscape(`mutation { createUser(name: "x") { id } }`), nil)
+	w := httptest.NewRecorder()
+
+	// Execute
+	r.ServeHTTP(w, req)
+
+	// Assert
+	if w.Code != 405 {
+		t.Errorf("HTTP status code, expected: %d, got: %d", 405, w.Code)
+	}
+}
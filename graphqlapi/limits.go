@@ -0,0 +1,99 @@
+package graphqlapi
+
+import (
+	"fmt"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// Query limits
+const (
+	DefaultMaxDepth      = 8
+	DefaultMaxComplexity = 1000
+)
+
+// queryCost measures the depth and complexity of the operations in a parsed document.
+// Every field costs one plus the cost of its selections; selections below a field with
+// a first argument are multiplied by it, since they are resolved once per node.
+type queryCost struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+// checkLimits returns an error if any operation in doc exceeds maxDepth or maxComplexity
+func checkLimits(doc *ast.Document, variables map[string]interface{}, maxDepth, maxComplexity int) error {
+	cost := queryCost{fragments: map[string]*ast.FragmentDefinition{}, variables: variables}
+	for _, definition := range doc.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			cost.fragments[fragment.Name.Value] = fragment
+		}
+	}
+	for _, definition := range doc.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		depth, complexity := cost.measure(operation.SelectionSet, map[string]bool{})
+		if depth > maxDepth {
+			return fmt.Errorf("Query depth %d exceeds the maximum of %d", depth, maxDepth)
+		}
+		if complexity > maxComplexity {
+			return fmt.Errorf("Query complexity %d exceeds the maximum of %d", complexity, maxComplexity)
+		}
+	}
+	return nil
+}
+
+// measure returns the depth and complexity of set. visiting holds the fragments on the
+// current path so that cyclic spreads, which validation rejects later, terminate here.
+func (c queryCost) measure(set *ast.SelectionSet, visiting map[string]bool) (int, int) {
+	if set == nil {
+		return 0, 0
+	}
+	maxDepth, complexity := 0, 0
+	for _, selection := range set.Selections {
+		var depth, cost int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			childDepth, childCost := c.measure(selection.SelectionSet, visiting)
+			depth, cost = childDepth+1, 1+childCost*c.multiplier(selection)
+		case *ast.InlineFragment:
+			depth, cost = c.measure(selection.SelectionSet, visiting)
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment, ok := c.fragments[name]
+			if !ok || visiting[name] {
+				continue
+			}
+			visiting[name] = true
+			depth, cost = c.measure(fragment.SelectionSet, visiting)
+			delete(visiting, name)
+		}
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+		complexity += cost
+	}
+	return maxDepth, complexity
+}
+
+// multiplier returns the page size requested by field, or 1 for non-paginated fields
+func (c queryCost) multiplier(field *ast.Field) int {
+	for _, argument := range field.Arguments {
+		if argument.Name.Value != "first" {
+			continue
+		}
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			var n int
+			fmt.Sscan(value.Value, &n)
+			return clampFirst(n)
+		case *ast.Variable:
+			if n, ok := c.variables[value.Name.Value].(float64); ok {
+				return clampFirst(int(n))
+			}
+		}
+		return defaultFirst
+	}
+	return 1
+}
@@ -0,0 +1,84 @@
+package graphqlapi
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/services"
+)
+
+type loaderKey struct{}
+
+type (
+	// userLoader batches the GetUser lookups of a single request into GetUsers calls.
+	// Resolvers call load, which returns a thunk; graphql-go resolves thunks after all
+	// sibling fields have been visited, so the first thunk fetches every queued ID.
+	userLoader struct {
+		service services.UsersServicer
+
+		mu      sync.Mutex
+		pending []int
+		results map[int]*loadResult
+	}
+
+	loadResult struct {
+		user *models.User
+		err  error
+	}
+)
+
+func newUserLoader(service services.UsersServicer) *userLoader {
+	return &userLoader{service: service, results: map[int]*loadResult{}}
+}
+
+func withUserLoader(ctx context.Context, loader *userLoader) context.Context {
+	return context.WithValue(ctx, loaderKey{}, loader)
+}
+
+func userLoaderFrom(ctx context.Context) *userLoader {
+	return ctx.Value(loaderKey{}).(*userLoader)
+}
+
+// load queues userID and returns a thunk resolving to the user, or nil if it does not exist
+func (l *userLoader) load(userID int) func() (interface{}, error) {
+	l.mu.Lock()
+	if _, ok := l.results[userID]; !ok {
+		l.results[userID] = nil
+		l.pending = append(l.pending, userID)
+	}
+	l.mu.Unlock()
+	return func() (interface{}, error) {
+		l.dispatch()
+		l.mu.Lock()
+		result := l.results[userID]
+		l.mu.Unlock()
+		if result.err != nil {
+			return nil, resolverError(result.err)
+		}
+		if result.user == nil {
+			return nil, nil
+		}
+		return result.user, nil
+	}
+}
+
+// dispatch fetches all pending IDs in batches of at most services.MaxGetUsersBatch
+func (l *userLoader) dispatch() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for len(l.pending) > 0 {
+		batch := l.pending
+		if len(batch) > services.MaxGetUsersBatch {
+			batch = batch[:services.MaxGetUsersBatch]
+		}
+		l.pending = l.pending[len(batch):]
+		users, err := l.service.GetUsers(batch)
+		for _, userID := range batch {
+			l.results[userID] = &loadResult{err: err}
+		}
+		for _, user := range users {
+			l.results[user.ID] = &loadResult{user: user}
+		}
+	}
+}
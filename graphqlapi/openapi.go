@@ -0,0 +1,38 @@
+package graphqlapi
+
+import (
+	"net/http"
+
+	"github.com/jordantipton/golang-restful-webservice/apis/openapi"
+)
+
+// The operations document the GraphQL endpoint. The GraphQL schema itself is available
+// through introspection.
+var (
+	// queryGraphQLOperation documents GET /graphql
+	queryGraphQLOperation = openapi.Operation{
+		ID:      "queryGraphQL",
+		Summary: "Run a GraphQL query",
+		Tags:    []string{"graphql"},
+		Parameters: []openapi.Parameter{
+			{Name: "query", In: "query", Required: true, Schema: &openapi.Schema{Type: "string"}},
+			{Name: "operationName", In: "query", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "variables", In: "query", Description: "JSON object of the query variables", Schema: &openapi.Schema{Type: "string"}},
+		},
+		Responses: graphQLResponses,
+	}
+
+	// executeGraphQLOperation documents POST /graphql
+	executeGraphQLOperation = openapi.Operation{
+		ID:        "executeGraphQL",
+		Summary:   "Run a GraphQL query or mutation",
+		Tags:      []string{"graphql"},
+		Request:   &graphQLRequest{},
+		Responses: graphQLResponses,
+	}
+)
+
+var graphQLResponses = []openapi.Response{
+	{Status: http.StatusOK, Description: "The result of the request, with any errors", Body: map[string]interface{}{}},
+	{Status: http.StatusBadRequest, Description: "The query is invalid or too deep or complex. Malformed requests get a plain text error.", Body: map[string]interface{}{}},
+}
@@ -0,0 +1,264 @@
+package graphqlapi
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/services"
+)
+
+// Connection page sizes
+const (
+	defaultFirst = 20
+	maxFirst     = 100
+)
+
+const cursorPrefix = "user:"
+
+type (
+	// codedError carries a machine readable code in the GraphQL error extensions
+	codedError struct {
+		error
+		code string
+	}
+
+	userConnection struct {
+		users       []*models.User
+		hasNextPage bool
+	}
+)
+
+// Extensions of codedError
+func (e codedError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.code}
+}
+
+// NewSchema builds the GraphQL schema over service
+func NewSchema(service services.UsersServicer) (graphql.Schema, error) {
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Int),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*models.User).ID, nil
+				},
+			},
+			"name": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*models.User).Name, nil
+				},
+			},
+			"email": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if email := p.Source.(*models.User).Email; email != "" {
+						return email, nil
+					}
+					return nil, nil
+				},
+			},
+		},
+	})
+
+	userEdgeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "UserEdge",
+		Fields: graphql.Fields{
+			"cursor": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return encodeCursor(p.Source.(*models.User).ID), nil
+				},
+			},
+			"node": &graphql.Field{
+				Type: graphql.NewNonNull(userType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source, nil
+				},
+			},
+		},
+	})
+
+	pageInfoType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PageInfo",
+		Fields: graphql.Fields{
+			"hasNextPage": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*userConnection).hasNextPage, nil
+				},
+			},
+			"endCursor": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					users := p.Source.(*userConnection).users
+					if len(users) == 0 {
+						return nil, nil
+					}
+					return encodeCursor(users[len(users)-1].ID), nil
+				},
+			},
+		},
+	})
+
+	userConnectionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "UserConnection",
+		Fields: graphql.Fields{
+			"edges": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(userEdgeType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*userConnection).users, nil
+				},
+			},
+			"pageInfo": &graphql.Field{
+				Type: graphql.NewNonNull(pageInfoType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source, nil
+				},
+			},
+		},
+	})
+
+	userFilterType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "UserFilter",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"namePrefix": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"user": &graphql.Field{
+				Type: userType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return userLoaderFrom(p.Context).load(p.Args["id"].(int)), nil
+				},
+			},
+			"users": &graphql.Field{
+				Type: graphql.NewNonNull(userConnectionType),
+				Args: graphql.FieldConfigArgument{
+					"filter": &graphql.ArgumentConfig{Type: userFilterType},
+					"first":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultFirst},
+					"after":  &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					first := p.Args["first"].(int)
+					if first <= 0 || first > maxFirst {
+						return nil, resolverError(errors.InvalidArgument{Message: "first must be between 1 and " + strconv.Itoa(maxFirst)})
+					}
+					options := models.UserListOptions{Limit: first + 1}
+					if after, ok := p.Args["after"].(string); ok {
+						afterID, err := decodeCursor(after)
+						if err != nil {
+							return nil, resolverError(err)
+						}
+						options.AfterID = afterID
+					}
+					if filter, ok := p.Args["filter"].(map[string]interface{}); ok {
+						options.NamePrefix, _ = filter["namePrefix"].(string)
+					}
+					users, err := service.ListUsers(options)
+					if err != nil {
+						return nil, resolverError(err)
+					}
+					connection := &userConnection{users: users}
+					if len(users) > first {
+						connection.users, connection.hasNextPage = users[:first], true
+					}
+					return connection, nil
+				},
+			},
+		},
+	})
+
+	mutationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createUser": &graphql.Field{
+				Type: graphql.NewNonNull(userType),
+				Args: graphql.FieldConfigArgument{
+					"name":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"email": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					email, _ := p.Args["email"].(string)
+					user, err := service.CreateUser(&models.User{Name: p.Args["name"].(string), Email: email})
+					if err != nil {
+						return nil, resolverError(err)
+					}
+					return user, nil
+				},
+			},
+			"updateUser": &graphql.Field{
+				Type: graphql.NewNonNull(userType),
+				// Without an email argument the user keeps their email
+				Args: graphql.FieldConfigArgument{
+					"id":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+					"name":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"email": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id, name := p.Args["id"].(int), p.Args["name"].(string)
+					var user *models.User
+					var err error
+					if email, ok := p.Args["email"].(string); ok {
+						user, err = service.UpdateUser(&models.User{ID: id, Name: name, Email: email})
+					} else {
+						user, err = service.RenameUser(id, name)
+					}
+					if err != nil {
+						return nil, resolverError(err)
+					}
+					return user, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType, Mutation: mutationType})
+}
+
+// resolverError tags domain errors with a code so clients can tell them apart
+func resolverError(err error) error {
+	switch err.(type) {
+	case errors.NotFound:
+		return codedError{err, "NOT_FOUND"}
+	case errors.InvalidArgument:
+		return codedError{err, "INVALID_ARGUMENT"}
+	default:
+		return codedError{err, "INTERNAL"}
+	}
+}
+
+func encodeCursor(userID int) string {
+	return base64.StdEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(userID)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	invalid := errors.InvalidArgument{Message: "Invalid cursor"}
+	raw, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, invalid
+	}
+	userID, err := strconv.Atoi(strings.TrimPrefix(string(raw), cursorPrefix))
+	if err != nil {
+		return 0, invalid
+	}
+	return userID, nil
+}
+
+func clampFirst(n int) int {
+	if n <= 0 || n > maxFirst {
+		return maxFirst
+	}
+	return n
+}
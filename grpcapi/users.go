@@ -0,0 +1,178 @@
+package grpcapi
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/jordantipton/golang-restful-webservice/apis/converters"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/proto/userspb"
+	"github.com/jordantipton/golang-restful-webservice/services"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TenantMetadataKey is the metadata key naming the tenant of a call, like the
+// X-Tenant-ID header of the REST API
+const TenantMetadataKey = "x-tenant-id"
+
+// APIKeyMetadataKey is the metadata key carrying the API key of a call, like the
+// X-API-Key header of the REST API
+const APIKeyMetadataKey = "x-api-key"
+
+type (
+	// UsersServer implements the UserService gRPC API
+	UsersServer struct {
+		userspb.UnimplementedUserServiceServer
+		Service services.UsersServicer
+		// Tenants, if set, serves each call with the service of its tenant instead of
+		// Service
+		Tenants TenantServices
+	}
+
+	// TenantServices returns the users service of the tenant named by the
+	// authorization, APIKeyMetadataKey and TenantMetadataKey metadata and the authority
+	// of a call
+	TenantServices func(authorization, apiKey, tenantID, authority string) (services.UsersServicer, error)
+)
+
+// RegisterUsersServer registers the UserService implementation with server
+func RegisterUsersServer(server *grpc.Server, service services.UsersServicer) {
+	userspb.RegisterUserServiceServer(server, &UsersServer{Service: service})
+}
+
+// RegisterTenantUsersServer registers the UserService implementation serving the
+// users of the tenant of each call with server
+func RegisterTenantUsersServer(server *grpc.Server, tenants TenantServices) {
+	userspb.RegisterUserServiceServer(server, &UsersServer{Tenants: tenants})
+}
+
+// GetUser by ID
+func (s *UsersServer) GetUser(ctx context.Context, req *userspb.GetUserRequest) (*userspb.User, error) {
+	service, err := s.service(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	user, err := service.GetUser(int(req.GetId()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toUser(user), nil
+}
+
+// CreateUser and return result
+func (s *UsersServer) CreateUser(ctx context.Context, req *userspb.CreateUserRequest) (*userspb.User, error) {
+	service, err := s.service(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	user, err := service.CreateUser(&models.User{Name: req.GetName()})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toUser(user), nil
+}
+
+// ListUsers returns a page of users ordered by ID
+func (s *UsersServer) ListUsers(ctx context.Context, req *userspb.ListUsersRequest) (*userspb.ListUsersResponse, error) {
+	limit := int(req.GetPageSize())
+	if limit <= 0 {
+		limit = services.DefaultListUsersLimit
+	}
+	if limit > services.MaxListUsersLimit {
+		limit = services.MaxListUsersLimit
+	}
+	options := models.UserListOptions{Limit: limit, NamePrefix: req.GetNamePrefix()}
+	if req.GetPageToken() != "" {
+		afterID, err := strconv.Atoi(req.GetPageToken())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "Invalid page token")
+		}
+		options.AfterID = afterID
+	}
+	service, err := s.service(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	users, err := service.ListUsers(options)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	res := &userspb.ListUsersResponse{}
+	for _, user := range users {
+		res.Users = append(res.Users, toUser(user))
+	}
+	// A short page means there is nothing left to fetch
+	if len(users) == limit {
+		res.NextPageToken = strconv.Itoa(users[len(users)-1].ID)
+	}
+	return res, nil
+}
+
+// UpdateUser renames a user and returns the result. Requests have no email, so only the
+// name is written and the user keeps theirs.
+func (s *UsersServer) UpdateUser(ctx context.Context, req *userspb.UpdateUserRequest) (*userspb.User, error) {
+	service, err := s.service(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	user, err := service.RenameUser(int(req.GetId()), req.GetName())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toUser(user), nil
+}
+
+// DeleteUser by ID
+func (s *UsersServer) DeleteUser(ctx context.Context, req *userspb.DeleteUserRequest) (*userspb.DeleteUserResponse, error) {
+	service, err := s.service(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if err := service.DeleteUser(int(req.GetId())); err != nil {
+		return nil, toStatus(err)
+	}
+	return &userspb.DeleteUserResponse{}, nil
+}
+
+// service returns the users service of the tenant of the call
+func (s *UsersServer) service(ctx context.Context) (services.UsersServicer, error) {
+	if s.Tenants == nil {
+		return s.Service, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	return s.Tenants(firstValue(md, "authorization"), firstValue(md, APIKeyMetadataKey), firstValue(md, TenantMetadataKey), firstValue(md, ":authority"))
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// toUser returns the protobuf user that the REST API serves for user
+func toUser(user *models.User) *userspb.User {
+	return converters.ToUser(user).ToProto().(*userspb.User)
+}
+
+// toStatus maps domain errors to gRPC status codes
+func toStatus(err error) error {
+	switch err.(type) {
+	case errors.NotFound:
+		return status.Error(codes.NotFound, err.Error())
+	case errors.InvalidArgument:
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Unauthenticated:
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.PermissionDenied:
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.ResourceExhausted:
+		return status.Error(codes.ResourceExhausted, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
@@ -0,0 +1,325 @@
+package grpcapi_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jordantipton/golang-restful-webservice/grpcapi"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/proto/userspb"
+	"github.com/jordantipton/golang-restful-webservice/services"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+/*
+	Test objects
+*/
+
+type mockUsersServicer struct {
+	mockGetUser     func(userID int) (*models.User, error)
+	mockGetUsers    func(userIDs []int) ([]*models.User, error)
+	mockListUsers   func(options models.UserListOptions) ([]*models.User, error)
+	mockCreateUser  func(user *models.User) (*models.User, error)
+	mockUpdateUser  func(user *models.User) (*models.User, error)
+	mockRenameUser  func(userID int, name string) (*models.User, error)
+	mockDeleteUser  func(userID int) error
+	mockImportUsers func(users []*models.User, options models.ImportOptions) ([]models.ImportResult, error)
+	mockExportUsers func(filter models.UserFilter, fn func(user *models.User) error) error
+}
+
+func (m *mockUsersServicer) GetUser(userID int) (*models.User, error) {
+	if m.mockGetUser != nil {
+		return m.mockGetUser(userID)
+	}
+	return nil, nil
+}
+
+func (m *mockUsersServicer) GetUsers(userIDs []int) ([]*models.User, error) {
+	if m.mockGetUsers != nil {
+		return m.mockGetUsers(userIDs)
+	}
+	return nil, nil
+}
+
+func (m *mockUsersServicer) ListUsers(options models.UserListOptions) ([]*models.User, error) {
+	if m.mockListUsers != nil {
+		return m.mockListUsers(options)
+	}
+	return nil, nil
+}
+
+func (m *mockUsersServicer) CreateUser(user *models.User) (*models.User, error) {
+	if m.mockCreateUser != nil {
+		return m.mockCreateUser(user)
+	}
+	return nil, nil
+}
+
+func (m *mockUsersServicer) UpdateUser(user *models.User) (*models.User, error) {
+	if m.mockUpdateUser != nil {
+		return m.mockUpdateUser(user)
+	}
+	return nil, nil
+}
+
+func (m *mockUsersServicer) RenameUser(userID int, name string) (*models.User, error) {
+	if m.mockRenameUser != nil {
+		return m.mockRenameUser(userID, name)
+	}
+	return nil, nil
+}
+
+func (m *mockUsersServicer) DeleteUser(userID int) error {
+	if m.mockDeleteUser != nil {
+		return m.mockDeleteUser(userID)
+	}
+	return nil
+}
+
+func (m *mockUsersServicer) ImportUsers(users []*models.User, options models.ImportOptions) ([]models.ImportResult, error) {
+	if m.mockImportUsers != nil {
+		return m.mockImportUsers(users, options)
+	}
+	return make([]models.ImportResult, len(users)), nil
+}
+
+func (m *mockUsersServicer) ExportUsers(filter models.UserFilter, fn func(user *models.User) error) error {
+	if m.mockExportUsers != nil {
+		return m.mockExportUsers(filter, fn)
+	}
+	return nil
+}
+
+// newClient serves service over an in-memory listener and returns a connected client
+func newClient(t *testing.T, service *mockUsersServicer) userspb.UserServiceClient {
+	server := grpc.NewServer()
+	grpcapi.RegisterUsersServer(server, service)
+	return serve(t, server)
+}
+
+// newTenantClient serves the services of tenants over an in-memory listener and
+// returns a connected client
+func newTenantClient(t *testing.T, tenants grpcapi.TenantServices) userspb.UserServiceClient {
+	server := grpc.NewServer()
+	grpcapi.RegisterTenantUsersServer(server, tenants)
+	return serve(t, server)
+}
+
+func serve(t *testing.T, server *grpc.Server) userspb.UserServiceClient {
+	lis := bufconn.Listen(1024 * 1024)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("NewClient returned error: %s", err.Error())
+	}
+	t.Cleanup(func() { conn.Close() })
+	return userspb.NewUserServiceClient(conn)
+}
+
+/*
+	Test functions
+*/
+
+func TestGetUser(t *testing.T) {
+	// Setup
+	updatedAt := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	client := newClient(t, &mockUsersServicer{
+		mockGetUser: func(userID int) (*models.User, error) {
+			return &models.User{ID: userID, Name: "Name", Email: "name@example.com", Avatar: "abc", UpdatedAt: updatedAt}, nil
+		},
+	})
+
+	// Execute
+	user, err := client.GetUser(context.Background(), &userspb.GetUserRequest{Id: 1})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("GetUser returned error: %s", err.Error())
+	}
+	if user.GetId() != 1 || user.GetName() != "Name" || user.GetEmail() != "name@example.com" {
+		t.Errorf("User, expected: 1 Name name@example.com, got: %d %s %s", user.GetId(), user.GetName(), user.GetEmail())
+	}
+	if user.GetAvatarUrl() != "/users/1/avatar?v=abc" || !user.GetUpdatedAt().AsTime().Equal(updatedAt) {
+		t.Errorf("Read-only fields, expected: the avatar URL and %s, got: %s %v", updatedAt, user.GetAvatarUrl(), user.GetUpdatedAt())
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	tests := []struct {
+		err  error
+		code codes.Code
+	}{
+		{errors.NotFound{Message: "User with ID 1 not found"}, codes.NotFound},
+		{errors.InvalidArgument{Message: "User name cannot be empty"}, codes.InvalidArgument},
+		{errors.Unauthenticated{Message: "token expired"}, codes.Unauthenticated},
+		{errors.PermissionDenied{Message: "The token is not valid for tenant globex"}, codes.PermissionDenied},
+		{errors.ResourceExhausted{Message: "Rate limit exceeded"}, codes.ResourceExhausted},
+		{mockError("some error"), codes.Internal},
+	}
+	for _, test := range tests {
+		// Setup
+		client := newClient(t, &mockUsersServicer{
+			mockGetUser: func(userID int) (*models.User, error) {
+				return nil, test.err
+			},
+		})
+
+		// Execute
+		_, err := client.GetUser(context.Background(), &userspb.GetUserRequest{Id: 1})
+
+		// Assert
+		if code := status.Code(err); code != test.code {
+			t.Errorf("Code for %T, expected: %s, got: %s", test.err, test.code, code)
+		}
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	// Setup
+	client := newClient(t, &mockUsersServicer{
+		mockCreateUser: func(user *models.User) (*models.User, error) {
+			return &models.User{ID: 7, Name: user.Name}, nil
+		},
+	})
+
+	// Execute
+	user, err := client.CreateUser(context.Background(), &userspb.CreateUserRequest{Name: "Name"})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("CreateUser returned error: %s", err.Error())
+	}
+	if user.GetId() != 7 || user.GetName() != "Name" {
+		t.Errorf("User, expected: 7 Name, got: %d %s", user.GetId(), user.GetName())
+	}
+}
+
+func TestListUsersPaging(t *testing.T) {
+	// Setup
+	var requested models.UserListOptions
+	client := newClient(t, &mockUsersServicer{
+		mockListUsers: func(options models.UserListOptions) ([]*models.User, error) {
+			requested = options
+			return []*models.User{{ID: 11, Name: "Bob"}, {ID: 12, Name: "Bobby"}}, nil
+		},
+	})
+
+	// Execute
+	res, err := client.ListUsers(context.Background(), &userspb.ListUsersRequest{PageSize: 2, PageToken: "10", NamePrefix: "Bo"})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("ListUsers returned error: %s", err.Error())
+	}
+	expected := models.UserListOptions{AfterID: 10, Limit: 2, NamePrefix: "Bo"}
+	if requested != expected {
+		t.Errorf("Options, expected: %v, got: %v", expected, requested)
+	}
+	if len(res.GetUsers()) != 2 {
+		t.Errorf("Users, expected: %d, got: %d", 2, len(res.GetUsers()))
+	}
+	if res.GetNextPageToken() != "12" {
+		t.Errorf("NextPageToken, expected: 12, got: %s", res.GetNextPageToken())
+	}
+}
+
+func TestListUsersInvalidPageToken(t *testing.T) {
+	// Setup
+	client := newClient(t, &mockUsersServicer{})
+
+	// Execute
+	_, err := client.ListUsers(context.Background(), &userspb.ListUsersRequest{PageToken: "abc"})
+
+	// Assert
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Errorf("Code, expected: %s, got: %s", codes.InvalidArgument, code)
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	// Setup
+	var renamedID int
+	client := newClient(t, &mockUsersServicer{
+		mockRenameUser: func(userID int, name string) (*models.User, error) {
+			renamedID = userID
+			return &models.User{ID: userID, Name: name, Email: "ada@example.com"}, nil
+		},
+		mockUpdateUser: func(user *models.User) (*models.User, error) {
+			t.Errorf("UpdateUser called, expected only the name to be written")
+			return user, nil
+		},
+	})
+
+	// Execute
+	user, err := client.UpdateUser(context.Background(), &userspb.UpdateUserRequest{Id: 1, Name: "New"})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("UpdateUser returned error: %s", err.Error())
+	}
+	if renamedID != 1 || user.GetName() != "New" || user.GetEmail() != "ada@example.com" {
+		t.Errorf("User, expected: 1 New ada@example.com, got: %d %s %s", renamedID, user.GetName(), user.GetEmail())
+	}
+}
+
+func TestDeleteUserNotFound(t *testing.T) {
+	// Setup
+	client := newClient(t, &mockUsersServicer{
+		mockDeleteUser: func(userID int) error {
+			return errors.NotFound{Message: "User with ID 1 not found"}
+		},
+	})
+
+	// Execute
+	_, err := client.DeleteUser(context.Background(), &userspb.DeleteUserRequest{Id: 1})
+
+	// Assert
+	if code := status.Code(err); code != codes.NotFound {
+		t.Errorf("Code, expected: %s, got: %s", codes.NotFound, code)
+	}
+}
+
+type mockError string
+
+func (e mockError) Error() string { return string(e) }
+
+func TestTenantUsersServer(t *testing.T) {
+	// Setup
+	var tenantID string
+	client := newTenantClient(t, func(authorization, apiKey, tenant, authority string) (services.UsersServicer, error) {
+		if tenant == "" {
+			return nil, errors.InvalidArgument{Message: "A tenant is required"}
+		}
+		tenantID = tenant
+		return &mockUsersServicer{
+			mockGetUser: func(userID int) (*models.User, error) {
+				return &models.User{ID: userID, TenantID: tenant, Name: "Name"}, nil
+			},
+		}, nil
+	})
+	ctx := metadata.AppendToOutgoingContext(context.Background(), grpcapi.TenantMetadataKey, "acme")
+
+	// Execute
+	_, err := client.GetUser(ctx, &userspb.GetUserRequest{Id: 1})
+	_, missingErr := client.GetUser(context.Background(), &userspb.GetUserRequest{Id: 1})
+
+	// Assert
+	if err != nil || tenantID != "acme" {
+		t.Errorf("GetUser, expected: served by acme, got: %s, %v", tenantID, err)
+	}
+	if code := status.Code(missingErr); code != codes.InvalidArgument {
+		t.Errorf("Code without a tenant, expected: %s, got: %s", codes.InvalidArgument, code)
+	}
+}
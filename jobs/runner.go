@@ -0,0 +1,399 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"sync"
+
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/services/interfaces"
+)
+
+const defaultWorkers = 2
+
+// Files kept in the directory of each job
+const (
+	inputFile    = "input"
+	artifactFile = "artifact"
+)
+
+type (
+	// Handler runs a job of one type. It should return ctx.Err() soon after ctx is done:
+	// ctx is cancelled when the job is cancelled and when the runner stops, in which
+	// case the job is queued again and resumed by the next runner.
+	Handler func(ctx context.Context, run *Run) error
+
+	// Scheduler submits and tracks jobs
+	Scheduler interface {
+		Submit(jobType string, params interface{}, input io.Reader) (*models.Job, error)
+		GetJob(jobID int) (*models.Job, error)
+		CancelJob(jobID int) (*models.Job, error)
+		OpenArtifact(jobID int) (*models.Job, *os.File, error)
+	}
+
+	// Runner executes jobs on a bounded pool of workers. Job state is stored through
+	// Persister and job files are kept in a directory per job under Dir.
+	Runner struct {
+		Persister interfaces.JobsPersister
+		Dir       string
+		// Workers is the number of jobs run concurrently
+		Workers int
+
+		mu       sync.Mutex
+		handlers map[string]Handler
+		pending  []int
+		running  map[int]*execution
+		wake     chan struct{}
+		stop     chan struct{}
+		started  bool
+		stopped  bool
+		done     sync.WaitGroup
+	}
+
+	execution struct {
+		cancel    context.CancelFunc
+		cancelled bool
+	}
+
+	// Run gives a Handler access to its job
+	Run struct {
+		Job    *models.Job
+		runner *Runner
+	}
+)
+
+// Register sets the handler of jobType. Handlers must be registered before Start.
+func (r *Runner) Register(jobType string, handler Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.handlers == nil {
+		r.handlers = map[string]Handler{}
+	}
+	r.handlers[jobType] = handler
+}
+
+// Start resumes the jobs left queued or running by a previous runner and starts the workers
+func (r *Runner) Start() error {
+	unfinished, err := r.Persister.ListJobsByStatus([]string{models.JobQueued, models.JobRunning})
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started {
+		return fmt.Errorf("jobs: runner already started")
+	}
+	r.started = true
+	r.running = map[int]*execution{}
+	r.wake = make(chan struct{}, 1)
+	r.stop = make(chan struct{})
+	for _, job := range unfinished {
+		r.pending = append(r.pending, job.ID)
+	}
+	workers := r.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	for i := 0; i < workers; i++ {
+		r.done.Add(1)
+		go r.work()
+	}
+	r.signal()
+	return nil
+}
+
+// Stop cancels the running jobs and waits for the workers to exit or for ctx to be
+// done. Interrupted jobs are queued again so that the next runner resumes them.
+func (r *Runner) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	if !r.started || r.stopped {
+		r.mu.Unlock()
+		return nil
+	}
+	r.stopped = true
+	close(r.stop)
+	for _, exec := range r.running {
+		exec.cancel()
+	}
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.done.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Submit queues a job of jobType. params is stored as JSON and input, if not nil, is
+// copied to the job directory for the handler to read.
+func (r *Runner) Submit(jobType string, params interface{}, input io.Reader) (*models.Job, error) {
+	r.mu.Lock()
+	_, ok := r.handlers[jobType]
+	r.mu.Unlock()
+	if !ok {
+		return nil, errors.InvalidArgument{Message: fmt.Sprintf("Unknown job type %q", jobType)}
+	}
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	job, err := r.Persister.CreateJob(&models.Job{Type: jobType, Status: models.JobQueued, Params: string(encoded)})
+	if err != nil {
+		return nil, err
+	}
+	if input != nil {
+		if err := r.saveInput(job.ID, input); err != nil {
+			job.Status, job.Error = models.JobFailed, "Failed to store job input: "+err.Error()
+			r.Persister.UpdateJob(job)
+			return nil, err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.started || r.stopped {
+		// The job stays queued and is picked up when a runner starts
+		return job, nil
+	}
+	r.pending = append(r.pending, job.ID)
+	r.signal()
+	return job, nil
+}
+
+// GetJob by ID
+func (r *Runner) GetJob(jobID int) (*models.Job, error) {
+	return r.Persister.GetJob(jobID)
+}
+
+// CancelJob cancels a queued or running job. A running job is cancelled
+// asynchronously, so the returned job may still be running. Cancelling a finished job
+// has no effect.
+func (r *Runner) CancelJob(jobID int) (*models.Job, error) {
+	r.mu.Lock()
+	if exec, ok := r.running[jobID]; ok {
+		exec.cancelled = true
+		exec.cancel()
+		r.mu.Unlock()
+		return r.Persister.GetJob(jobID)
+	}
+	for i, pendingID := range r.pending {
+		if pendingID == jobID {
+			r.pending = append(r.pending[:i:i], r.pending[i+1:]...)
+			break
+		}
+	}
+	r.mu.Unlock()
+
+	job, err := r.Persister.GetJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status == models.JobQueued {
+		job.Status = models.JobCancelled
+		if err := r.Persister.UpdateJob(job); err != nil {
+			return nil, err
+		}
+	}
+	return job, nil
+}
+
+// OpenArtifact opens the result file of a succeeded job
+func (r *Runner) OpenArtifact(jobID int) (*models.Job, *os.File, error) {
+	job, err := r.Persister.GetJob(jobID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if job.Status != models.JobSucceeded || job.ArtifactName == "" {
+		return nil, nil, errors.NotFound{Message: fmt.Sprintf("Job with ID %d has no artifact", jobID)}
+	}
+	file, err := os.Open(r.path(jobID, artifactFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	return job, file, nil
+}
+
+// signal wakes a waiting worker. Callers must hold r.mu.
+func (r *Runner) signal() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (r *Runner) work() {
+	defer r.done.Done()
+	for {
+		jobID, ctx, ok := r.next()
+		if !ok {
+			return
+		}
+		r.execute(ctx, jobID)
+	}
+}
+
+// next blocks until a job is pending and marks it as running, or returns false once
+// the runner is stopped
+func (r *Runner) next() (int, context.Context, bool) {
+	for {
+		r.mu.Lock()
+		if r.stopped {
+			r.mu.Unlock()
+			return 0, nil, false
+		}
+		if len(r.pending) > 0 {
+			jobID := r.pending[0]
+			r.pending = r.pending[1:]
+			ctx, cancel := context.WithCancel(context.Background())
+			r.running[jobID] = &execution{cancel: cancel}
+			if len(r.pending) > 0 {
+				r.signal()
+			}
+			r.mu.Unlock()
+			return jobID, ctx, true
+		}
+		r.mu.Unlock()
+		select {
+		case <-r.wake:
+		case <-r.stop:
+		}
+	}
+}
+
+func (r *Runner) execute(ctx context.Context, jobID int) {
+	job, err := r.Persister.GetJob(jobID)
+	if err == nil && !job.Finished() {
+		err = r.run(ctx, job)
+	}
+
+	r.mu.Lock()
+	exec := r.running[jobID]
+	delete(r.running, jobID)
+	stopped := r.stopped
+	r.mu.Unlock()
+	exec.cancel()
+
+	if job == nil {
+		log.Printf("jobs: failed to load job %d: %v", jobID, err)
+		return
+	}
+	if job.Finished() {
+		return
+	}
+	switch {
+	case err == nil:
+		job.Status, job.Error = models.JobSucceeded, ""
+	case exec.cancelled:
+		job.Status, job.Error = models.JobCancelled, ""
+	case stopped && ctx.Err() != nil:
+		job.Status = models.JobQueued
+	default:
+		job.Status, job.Error = models.JobFailed, err.Error()
+	}
+	if err := r.Persister.UpdateJob(job); err != nil {
+		log.Printf("jobs: failed to store job %d: %v", jobID, err)
+	}
+}
+
+func (r *Runner) run(ctx context.Context, job *models.Job) (err error) {
+	r.mu.Lock()
+	handler, ok := r.handlers[job.Type]
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("No handler for job type %q", job.Type)
+	}
+	job.Status = models.JobRunning
+	if err := r.Persister.UpdateJob(job); err != nil {
+		return err
+	}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Printf("jobs: job %d panicked: %v\n%s", job.ID, recovered, debug.Stack())
+			err = fmt.Errorf("Job panicked: %v", recovered)
+		}
+	}()
+	return handler(ctx, &Run{Job: job, runner: r})
+}
+
+func (r *Runner) saveInput(jobID int, input io.Reader) error {
+	if err := os.MkdirAll(r.path(jobID, ""), 0o755); err != nil {
+		return err
+	}
+	file, err := os.Create(r.path(jobID, inputFile))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, input); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (r *Runner) path(jobID int, name string) string {
+	return filepath.Join(r.Dir, strconv.Itoa(jobID), name)
+}
+
+// Params decodes the job parameters into v
+func (run *Run) Params(v interface{}) error {
+	return json.Unmarshal([]byte(run.Job.Params), v)
+}
+
+// Resumed reports whether the job made progress in a previous runner
+func (run *Run) Resumed() bool {
+	return run.Job.Progress > 0
+}
+
+// Input opens the input submitted with the job
+func (run *Run) Input() (*os.File, error) {
+	return os.Open(run.runner.path(run.Job.ID, inputFile))
+}
+
+// Path returns the path of a working file of the job, which is kept across restarts
+func (run *Run) Path(name string) string {
+	return run.runner.path(run.Job.ID, "work-"+name)
+}
+
+// SetProgress records that done of total units of work are complete. Handlers that
+// can resume should report progress only for work that will not be repeated.
+func (run *Run) SetProgress(done, total int64) error {
+	run.Job.Progress, run.Job.Total = done, total
+	return run.runner.Persister.UpdateJob(run.Job)
+}
+
+// CreateArtifact creates, or truncates, the result file of the job. name and
+// contentType are used when the artifact is downloaded.
+func (run *Run) CreateArtifact(name, contentType string) (*os.File, error) {
+	return run.openArtifact(name, contentType, os.O_TRUNC)
+}
+
+// AppendArtifact opens the result file of the job for appending, creating it if needed
+func (run *Run) AppendArtifact(name, contentType string) (*os.File, error) {
+	return run.openArtifact(name, contentType, os.O_APPEND)
+}
+
+func (run *Run) openArtifact(name, contentType string, flag int) (*os.File, error) {
+	if err := os.MkdirAll(run.runner.path(run.Job.ID, ""), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(run.runner.path(run.Job.ID, artifactFile), os.O_CREATE|os.O_WRONLY|flag, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	run.Job.ArtifactName, run.Job.ArtifactType = name, contentType
+	return file, nil
+}
@@ -0,0 +1,299 @@
+package jobs_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jordantipton/golang-restful-webservice/jobs"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+)
+
+/*
+	Test objects
+*/
+
+// memoryJobsPersister stores jobs in memory
+type memoryJobsPersister struct {
+	mu   sync.Mutex
+	jobs []models.Job
+}
+
+func (m *memoryJobsPersister) CreateJob(job *models.Job) (*models.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	created := *job
+	created.ID = len(m.jobs) + 1
+	m.jobs = append(m.jobs, created)
+	return &created, nil
+}
+
+func (m *memoryJobsPersister) GetJob(jobID int) (*models.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if jobID < 1 || jobID > len(m.jobs) {
+		return nil, errors.NotFound{Message: "not found"}
+	}
+	job := m.jobs[jobID-1]
+	return &job, nil
+}
+
+func (m *memoryJobsPersister) UpdateJob(job *models.Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[job.ID-1] = *job
+	return nil
+}
+
+func (m *memoryJobsPersister) ListJobsByStatus(statuses []string) ([]*models.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := []*models.Job{}
+	for i := range m.jobs {
+		for _, status := range statuses {
+			if m.jobs[i].Status == status {
+				job := m.jobs[i]
+				jobs = append(jobs, &job)
+			}
+		}
+	}
+	return jobs, nil
+}
+
+func waitForStatus(t *testing.T, runner *jobs.Runner, jobID int, status string) *models.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := runner.GetJob(jobID)
+		if err != nil {
+			t.Fatalf("GetJob returned error: %s", err.Error())
+		}
+		if job.Status == status {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Job status, expected: %s, got: %s", status, job.Status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+/*
+	Test functions
+*/
+
+func TestSubmitRunsJob(t *testing.T) {
+	// Setup
+	runner := &jobs.Runner{Persister: &memoryJobsPersister{}, Dir: t.TempDir(), Workers: 1}
+	runner.Register("upper", func(ctx context.Context, run *jobs.Run) error {
+		var params struct{ Suffix string }
+		if err := run.Params(&params); err != nil {
+			return err
+		}
+		input, err := run.Input()
+		if err != nil {
+			return err
+		}
+		defer input.Close()
+		body, _ := io.ReadAll(input)
+		artifact, err := run.CreateArtifact("out.txt", "text/plain")
+		if err != nil {
+			return err
+		}
+		defer artifact.Close()
+		fmt.Fprint(artifact, strings.ToUpper(string(body))+params.Suffix)
+		return run.SetProgress(1, 1)
+	})
+	runner.Start()
+	defer runner.Stop(context.Background())
+
+	// Execute
+	job, err := runner.Submit("upper", map[string]string{"Suffix": "!"}, strings.NewReader("hello"))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Submit returned error: %s", err.Error())
+	}
+	finished := waitForStatus(t, runner, job.ID, models.JobSucceeded)
+	if finished.Progress != 1 || finished.Total != 1 {
+		t.Errorf("Progress, expected: 1/1, got: %d/%d", finished.Progress, finished.Total)
+	}
+	_, file, err := runner.OpenArtifact(job.ID)
+	if err != nil {
+		t.Fatalf("OpenArtifact returned error: %s", err.Error())
+	}
+	defer file.Close()
+	artifact, _ := io.ReadAll(file)
+	if string(artifact) != "HELLO!" {
+		t.Errorf("Artifact, expected: HELLO!, got: %s", artifact)
+	}
+}
+
+func TestSubmitUnknownType(t *testing.T) {
+	// Setup
+	runner := &jobs.Runner{Persister: &memoryJobsPersister{}, Dir: t.TempDir()}
+
+	// Execute
+	_, err := runner.Submit("missing", nil, nil)
+
+	// Assert
+	if _, ok := err.(errors.InvalidArgument); !ok {
+		t.Errorf("Expected InvalidArgument error, got: %v", err)
+	}
+}
+
+func TestFailedAndPanickingJobs(t *testing.T) {
+	// Setup
+	runner := &jobs.Runner{Persister: &memoryJobsPersister{}, Dir: t.TempDir()}
+	runner.Register("fail", func(ctx context.Context, run *jobs.Run) error {
+		return fmt.Errorf("broken input")
+	})
+	runner.Register("panic", func(ctx context.Context, run *jobs.Run) error {
+		panic("boom")
+	})
+	runner.Start()
+	defer runner.Stop(context.Background())
+
+	// Execute
+	failing, _ := runner.Submit("fail", nil, nil)
+	panicking, _ := runner.Submit("panic", nil, nil)
+
+	// Assert
+	if job := waitForStatus(t, runner, failing.ID, models.JobFailed); job.Error != "broken input" {
+		t.Errorf("Error, expected: broken input, got: %s", job.Error)
+	}
+	if job := waitForStatus(t, runner, panicking.ID, models.JobFailed); !strings.Contains(job.Error, "boom") {
+		t.Errorf("Error, expected to contain boom, got: %s", job.Error)
+	}
+	if _, _, err := runner.OpenArtifact(failing.ID); err == nil {
+		t.Errorf("Expected OpenArtifact of a failed job to return an error")
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	// Setup
+	runner := &jobs.Runner{Persister: &memoryJobsPersister{}, Dir: t.TempDir(), Workers: 1}
+	started := make(chan struct{})
+	runner.Register("wait", func(ctx context.Context, run *jobs.Run) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	runner.Start()
+	defer runner.Stop(context.Background())
+	running, _ := runner.Submit("wait", nil, nil)
+	queued, _ := runner.Submit("wait", nil, nil)
+	<-started
+
+	// Execute
+	cancelledQueued, err := runner.CancelJob(queued.ID)
+	if err != nil {
+		t.Fatalf("CancelJob returned error: %s", err.Error())
+	}
+	runner.CancelJob(running.ID)
+
+	// Assert
+	if cancelledQueued.Status != models.JobCancelled {
+		t.Errorf("Queued job status, expected: %s, got: %s", models.JobCancelled, cancelledQueued.Status)
+	}
+	waitForStatus(t, runner, running.ID, models.JobCancelled)
+}
+
+func TestStopRequeuesAndStartResumes(t *testing.T) {
+	// Setup
+	persister := &memoryJobsPersister{}
+	dir := t.TempDir()
+	first := &jobs.Runner{Persister: persister, Dir: dir}
+	started := make(chan struct{})
+	first.Register("resumable", func(ctx context.Context, run *jobs.Run) error {
+		run.SetProgress(5, 10)
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	first.Start()
+	job, _ := first.Submit("resumable", nil, nil)
+	<-started
+
+	// Execute
+	if err := first.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop returned error: %s", err.Error())
+	}
+	interrupted, _ := persister.GetJob(job.ID)
+	second := &jobs.Runner{Persister: persister, Dir: dir}
+	var resumedFrom int64
+	second.Register("resumable", func(ctx context.Context, run *jobs.Run) error {
+		resumedFrom = run.Job.Progress
+		return run.SetProgress(10, 10)
+	})
+	second.Start()
+	defer second.Stop(context.Background())
+
+	// Assert
+	if interrupted.Status != models.JobQueued {
+		t.Errorf("Interrupted job status, expected: %s, got: %s", models.JobQueued, interrupted.Status)
+	}
+	waitForStatus(t, second, job.ID, models.JobSucceeded)
+	if resumedFrom != 5 {
+		t.Errorf("Resumed progress, expected: %d, got: %d", 5, resumedFrom)
+	}
+}
+
+func TestStopTimesOut(t *testing.T) {
+	// Setup
+	runner := &jobs.Runner{Persister: &memoryJobsPersister{}, Dir: t.TempDir()}
+	started, release := make(chan struct{}), make(chan struct{})
+	runner.Register("stubborn", func(ctx context.Context, run *jobs.Run) error {
+		close(started)
+		<-release
+		return nil
+	})
+	runner.Start()
+	runner.Submit("stubborn", nil, nil)
+	<-started
+	defer close(release)
+
+	// Execute
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := runner.Stop(ctx)
+
+	// Assert
+	if err != context.DeadlineExceeded {
+		t.Errorf("Stop error, expected: %v, got: %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestArtifactsAreKeptPerJob(t *testing.T) {
+	// Setup
+	dir := t.TempDir()
+	runner := &jobs.Runner{Persister: &memoryJobsPersister{}, Dir: dir}
+	runner.Register("write", func(ctx context.Context, run *jobs.Run) error {
+		artifact, err := run.CreateArtifact("out.txt", "text/plain")
+		if err != nil {
+			return err
+		}
+		return artifact.Close()
+	})
+	runner.Start()
+	defer runner.Stop(context.Background())
+
+	// Execute
+	job, _ := runner.Submit("write", nil, strings.NewReader("input"))
+	waitForStatus(t, runner, job.ID, models.JobSucceeded)
+
+	// Assert
+	entries, err := os.ReadDir(dir + "/1")
+	if err != nil {
+		t.Fatalf("ReadDir returned error: %s", err.Error())
+	}
+	if len(entries) != 2 {
+		t.Errorf("Job files, expected: %d, got: %d", 2, len(entries))
+	}
+}
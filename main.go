@@ -1,13 +1,72 @@
 package main
 
 import (
+	"context"
+	"log"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
+	"github.com/jordantipton/golang-restful-webservice/apis"
 	"github.com/jordantipton/golang-restful-webservice/app"
+	"github.com/jordantipton/golang-restful-webservice/blobs"
 )
 
+// shutdownTimeout bounds how long in-flight requests and jobs get to finish
+const shutdownTimeout = 30 * time.Second
+
+// defaultMetricsAddr only accepts connections from the host unless METRICS_ADDR is set
+const defaultMetricsAddr = "127.0.0.1:8081"
+
 func main() {
-	a := app.App{}
-	a.Initialize(os.Getenv("DSN"))
-	a.Run(":8080")
+	responseValidation, err := apis.ParseResponseValidation(os.Getenv("RESPONSE_VALIDATION"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	a := app.App{JobsDir: os.Getenv("JOBS_DIR"), RedisAddr: os.Getenv("REDIS_ADDR"), BlobsDir: os.Getenv("BLOBS_DIR"), SearchIndexPath: os.Getenv("SEARCH_INDEX_PATH"), ResponseValidation: responseValidation}
+	if path := os.Getenv("TENANTS_CONFIG"); path != "" {
+		if a.Tenants, err = app.LoadTenantsConfig(path); err != nil {
+			log.Fatal(err)
+		}
+	}
+	a.JWTSecret = []byte(os.Getenv("JWT_SECRET"))
+	if a.APIKeys, err = apis.ParseAPIKeys(os.Getenv("API_KEYS")); err != nil {
+		log.Fatal(err)
+	}
+	a.TenantDomain = os.Getenv("TENANT_DOMAIN")
+	if value := os.Getenv("TRUST_TENANT_HEADER"); value != "" {
+		if a.TrustTenantHeader, err = strconv.ParseBool(value); err != nil {
+			log.Fatalf("TRUST_TENANT_HEADER must be true or false, got: %s", value)
+		}
+	}
+	if bucket := os.Getenv("S3_BUCKET"); bucket != "" {
+		a.S3 = &blobs.S3{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Bucket:          bucket,
+			Region:          os.Getenv("S3_REGION"),
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		}
+	}
+	if err := a.Initialize(os.Getenv("DSN")); err != nil {
+		log.Fatal(err)
+	}
+	metricsAddr := os.Getenv("METRICS_ADDR")
+	if metricsAddr == "" {
+		metricsAddr = defaultMetricsAddr
+	}
+	go a.RunGRPC(":9090")
+	go a.Run(":8080")
+	go a.RunMetrics(metricsAddr)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := a.Shutdown(ctx); err != nil {
+		log.Printf("shutdown: %v", err)
+	}
 }
@@ -0,0 +1,8 @@
+-- Change log of user mutations. seq is the Last-Event-ID used by GET /users/events.
+CREATE TABLE IF NOT EXISTS user_event (
+	seq BIGINT NOT NULL AUTO_INCREMENT,
+	type VARCHAR(64) NOT NULL,
+	user_id INT NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	PRIMARY KEY (seq)
+);
@@ -0,0 +1,14 @@
+-- Background jobs. Queued and running jobs are resumed when the service starts.
+CREATE TABLE IF NOT EXISTS job (
+	id INT NOT NULL AUTO_INCREMENT,
+	type VARCHAR(64) NOT NULL,
+	status VARCHAR(16) NOT NULL,
+	params TEXT NOT NULL,
+	progress BIGINT NOT NULL DEFAULT 0,
+	total BIGINT NOT NULL DEFAULT 0,
+	error TEXT NOT NULL,
+	artifact_name VARCHAR(255) NOT NULL DEFAULT '',
+	artifact_type VARCHAR(255) NOT NULL DEFAULT '',
+	PRIMARY KEY (id),
+	INDEX job_status (status)
+);
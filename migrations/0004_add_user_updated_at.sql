@@ -0,0 +1,4 @@
+-- Last modification time of a user, used for Last-Modified. The database sets it on
+-- every change, including renames by bulk imports.
+ALTER TABLE user
+	ADD COLUMN updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6);
@@ -0,0 +1,8 @@
+-- Memberships of users in groups. Rows are deleted along with their user or group.
+CREATE TABLE IF NOT EXISTS group_member (
+	group_id INT NOT NULL,
+	user_id INT NOT NULL,
+	role VARCHAR(16) NOT NULL,
+	PRIMARY KEY (group_id, user_id),
+	INDEX group_member_user (user_id, group_id)
+);
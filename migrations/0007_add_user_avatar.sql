@@ -0,0 +1,4 @@
+-- Version of the avatar of a user, empty if they have none. Avatar URLs include it so
+-- clients fetch a new avatar once it changes. The images are kept in the blob store.
+ALTER TABLE user
+	ADD COLUMN avatar VARCHAR(64) NOT NULL DEFAULT '';
@@ -0,0 +1,14 @@
+-- Tenant of users, groups, user events and jobs. Rows of one tenant are invisible to
+-- the others. Existing rows belong to the default tenant.
+ALTER TABLE user
+	ADD COLUMN tenant_id VARCHAR(63) NOT NULL DEFAULT 'default',
+	ADD INDEX user_tenant_name (tenant_id, name);
+ALTER TABLE user_group
+	ADD COLUMN tenant_id VARCHAR(63) NOT NULL DEFAULT 'default',
+	ADD INDEX user_group_tenant_name (tenant_id, name);
+ALTER TABLE user_event
+	ADD COLUMN tenant_id VARCHAR(63) NOT NULL DEFAULT 'default',
+	ADD INDEX user_event_tenant_seq (tenant_id, seq);
+ALTER TABLE job
+	ADD COLUMN tenant_id VARCHAR(63) NOT NULL DEFAULT 'default',
+	ADD INDEX job_tenant_status (tenant_id, status);
@@ -0,0 +1,5 @@
+-- Time an event was recorded. Readers of the change feed skip events younger than
+-- repositories.UserEventSettleTime, so that an event whose transaction commits after
+-- one with a higher seq is not passed over for good.
+ALTER TABLE user_event
+	ADD COLUMN created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6);
@@ -0,0 +1,6 @@
+-- Optional email of a user, matched by user search. Events carry the email of the user
+-- after the change, so that readers of the change feed can follow it.
+ALTER TABLE user
+	ADD COLUMN email VARCHAR(254) NOT NULL DEFAULT '';
+ALTER TABLE user_event
+	ADD COLUMN email VARCHAR(254) NOT NULL DEFAULT '';
@@ -0,0 +1,24 @@
+package models
+
+// Limits of uploaded avatars
+const (
+	// AvatarMaxBytes is the maximum size of an uploaded avatar
+	AvatarMaxBytes = 5 << 20
+	// AvatarMaxDimension is the maximum width and height of an uploaded avatar in pixels
+	AvatarMaxDimension = 4096
+)
+
+// AvatarSizes are the widths in pixels of the square thumbnails generated for every
+// avatar, in increasing order
+var AvatarSizes = []int{64, 128, 256}
+
+// AvatarContentTypes are the media types of the images accepted as avatars
+var AvatarContentTypes = []string{"image/png", "image/jpeg", "image/gif"}
+
+// Blob represents a stored binary object. ETag identifies its content, as a quoted
+// string for the ETag header.
+type Blob struct {
+	Body        []byte
+	ContentType string
+	ETag        string
+}
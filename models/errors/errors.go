@@ -15,3 +15,35 @@ func (e NotFound) Error() string { return e.Message }
 
 // Error method for InvalidArgument
 func (e InvalidArgument) Error() string { return e.Message }
+
+// AlreadyExists error type
+type AlreadyExists struct {
+	Message string
+}
+
+// Error method for AlreadyExists
+func (e AlreadyExists) Error() string { return e.Message }
+
+// Unauthenticated error type, for requests without valid credentials
+type Unauthenticated struct {
+	Message string
+}
+
+// Error method for Unauthenticated
+func (e Unauthenticated) Error() string { return e.Message }
+
+// PermissionDenied error type, for credentials that do not allow a request
+type PermissionDenied struct {
+	Message string
+}
+
+// Error method for PermissionDenied
+func (e PermissionDenied) Error() string { return e.Message }
+
+// ResourceExhausted error type, for requests over a quota such as a rate limit
+type ResourceExhausted struct {
+	Message string
+}
+
+// Error method for ResourceExhausted
+func (e ResourceExhausted) Error() string { return e.Message }
@@ -0,0 +1,89 @@
+package models
+
+import "strconv"
+
+// Domain event types
+const (
+	EventUserCreated       = "UserCreated"
+	EventUserRenamed       = "UserRenamed"
+	EventUserDeleted       = "UserDeleted"
+	EventUserAvatarChanged = "UserAvatarChanged"
+	EventUserEmailChanged  = "UserEmailChanged"
+)
+
+type (
+	// Event is a domain event published after a successful mutation
+	Event interface {
+		EventType() string
+		// AggregateID identifies the entity the event belongs to. Events with the
+		// same aggregate ID are delivered to each subscriber in publish order.
+		AggregateID() string
+	}
+
+	// UserCreated is published after a user is created
+	UserCreated struct {
+		User User
+	}
+
+	// UserRenamed is published after a user's name changes
+	UserRenamed struct {
+		UserID  int
+		OldName string
+		NewName string
+	}
+
+	// UserDeleted is published after a user is deleted. Name is the name the user had.
+	UserDeleted struct {
+		UserID int
+		Name   string
+	}
+
+	// UserAvatarChanged is published after a user uploads a new avatar
+	UserAvatarChanged struct {
+		UserID int
+		Avatar string
+	}
+
+	// UserEmailChanged is published after a user's email changes. Name is the current
+	// name of the user.
+	UserEmailChanged struct {
+		UserID   int
+		Name     string
+		OldEmail string
+		NewEmail string
+	}
+)
+
+// EventType of UserCreated
+func (e UserCreated) EventType() string { return EventUserCreated }
+
+// AggregateID of UserCreated
+func (e UserCreated) AggregateID() string { return userAggregateID(e.User.ID) }
+
+// EventType of UserRenamed
+func (e UserRenamed) EventType() string { return EventUserRenamed }
+
+// AggregateID of UserRenamed
+func (e UserRenamed) AggregateID() string { return userAggregateID(e.UserID) }
+
+// EventType of UserDeleted
+func (e UserDeleted) EventType() string { return EventUserDeleted }
+
+// AggregateID of UserDeleted
+func (e UserDeleted) AggregateID() string { return userAggregateID(e.UserID) }
+
+// EventType of UserAvatarChanged
+func (e UserAvatarChanged) EventType() string { return EventUserAvatarChanged }
+
+// AggregateID of UserAvatarChanged
+func (e UserAvatarChanged) AggregateID() string { return userAggregateID(e.UserID) }
+
+// EventType of UserEmailChanged
+func (e UserEmailChanged) EventType() string { return EventUserEmailChanged }
+
+// AggregateID of UserEmailChanged
+func (e UserEmailChanged) AggregateID() string { return userAggregateID(e.UserID) }
+
+func userAggregateID(userID int) string {
+	return "user:" + strconv.Itoa(userID)
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Maximum lengths of the text of a group in characters
+const (
+	GroupNameMaxLength        = 255
+	GroupDescriptionMaxLength = 1000
+)
+
+// Group represents a group service object. UpdatedAt is maintained by the database.
+type Group struct {
+	ID          int
+	Name        string
+	Description string
+	UpdatedAt   time.Time
+}
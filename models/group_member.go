@@ -0,0 +1,19 @@
+package models
+
+// Group membership roles
+const (
+	GroupRoleOwner  = "owner"
+	GroupRoleMember = "member"
+)
+
+// GroupMember represents the membership of a user in a group service object
+type GroupMember struct {
+	GroupID int
+	UserID  int
+	Role    string
+}
+
+// ValidGroupRole reports whether role is one of the group membership roles
+func ValidGroupRole(role string) bool {
+	return role == GroupRoleOwner || role == GroupRoleMember
+}
@@ -0,0 +1,29 @@
+package models
+
+// Job statuses
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// Job model. Params holds the JSON encoded parameters of the job type. Progress and
+// Total count units of work as reported by the job, and Total is 0 while unknown.
+type Job struct {
+	ID           int
+	Type         string
+	Status       string
+	Params       string
+	Progress     int64
+	Total        int64
+	Error        string
+	ArtifactName string
+	ArtifactType string
+}
+
+// Finished reports whether the job has reached a terminal status
+func (job *Job) Finished() bool {
+	return job.Status == JobSucceeded || job.Status == JobFailed || job.Status == JobCancelled
+}
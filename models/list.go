@@ -0,0 +1,11 @@
+package models
+
+// ListOptions selects a page of entities ordered by ID
+type ListOptions[ID comparable] struct {
+	// AfterID returns entities with an ID greater than AfterID
+	AfterID ID
+	// Limit is the maximum number of entities to return
+	Limit int
+	// Prefix, if set, only returns entities whose name starts with it
+	Prefix string
+}
@@ -0,0 +1,23 @@
+package models
+
+// DefaultTenantID is the tenant of the users created before tenants were introduced,
+// and of requests that name no tenant when one is not required
+const DefaultTenantID = "default"
+
+// TenantIDMaxLength is the maximum length of a tenant ID, that of a DNS label so that
+// tenants can be told by subdomain
+const TenantIDMaxLength = 63
+
+// ValidTenantID reports whether id is a lower case DNS label: letters, digits and
+// hyphens, neither starting nor ending with a hyphen
+func ValidTenantID(id string) bool {
+	if id == "" || len(id) > TenantIDMaxLength || id[0] == '-' || id[len(id)-1] == '-' {
+		return false
+	}
+	for _, r := range id {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return false
+		}
+	}
+	return true
+}
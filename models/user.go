@@ -1,7 +1,65 @@
 package models
 
-// User represents a user service object
+import (
+	"net/mail"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// UserNameMaxLength is the maximum length of a user name in characters
+const UserNameMaxLength = 255
+
+// UserEmailMaxLength is the maximum length of an email address in characters, the
+// longest path RFC 5321 allows
+const UserEmailMaxLength = 254
+
+// User represents a user service object. UpdatedAt is maintained by the database.
+// Avatar is the version of the avatar of the user, or empty if they have none.
+// TenantID is the tenant the user belongs to, set by the repository of that tenant.
+// Email is optional.
 type User struct {
-	ID   int
-	Name string
+	ID        int
+	TenantID  string
+	Name      string
+	Email     string
+	Avatar    string
+	UpdatedAt time.Time
+}
+
+// UserListOptions selects a page of users ordered by ID
+type UserListOptions struct {
+	// AfterID returns users with an ID greater than AfterID
+	AfterID int
+	// Limit is the maximum number of users to return
+	Limit int
+	// NamePrefix, if set, only returns users whose name starts with it
+	NamePrefix string
+}
+
+// UserFilter selects users for an export
+type UserFilter struct {
+	// NamePrefix, if set, only selects users whose name starts with it
+	NamePrefix string
+}
+
+// ValidUserNameCharset reports whether name only contains letters, combining marks,
+// digits, spaces and the punctuation . , ' ’ -, and neither starts nor ends with a space
+func ValidUserNameCharset(name string) bool {
+	if strings.HasPrefix(name, " ") || strings.HasSuffix(name, " ") {
+		return false
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsMark(r) && !unicode.IsDigit(r) && !strings.ContainsRune(" .,'’-", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidUserEmail reports whether email is a bare address such as ada@example.com,
+// without a display name or angle brackets
+func ValidUserEmail(email string) bool {
+	address, err := mail.ParseAddress(email)
+	return err == nil && address.Address == email
 }
@@ -0,0 +1,19 @@
+package models
+
+// User event types
+const (
+	UserEventCreated      = "user.created"
+	UserEventRenamed      = "user.renamed"
+	UserEventEmailChanged = "user.email_changed"
+	UserEventDeleted      = "user.deleted"
+)
+
+// UserEvent represents a stored user change event. Name and Email are those of the
+// user after the change.
+type UserEvent struct {
+	Seq    int64
+	Type   string
+	UserID int
+	Name   string
+	Email  string
+}
@@ -0,0 +1,50 @@
+package models
+
+// Import conflict modes, applied to rows whose ID already exists
+const (
+	ImportConflictFail   = "fail"
+	ImportConflictSkip   = "skip"
+	ImportConflictUpdate = "update"
+)
+
+// Import row statuses
+const (
+	ImportCreated  = "created"
+	ImportUpdated  = "updated"
+	ImportSkipped  = "skipped"
+	ImportInvalid  = "invalid"
+	ImportConflict = "conflict"
+	// ImportAborted rows were valid but rolled back because another row in the
+	// same batch conflicted in fail mode
+	ImportAborted = "aborted"
+)
+
+type (
+	// ImportOptions controls a bulk user import
+	ImportOptions struct {
+		OnConflict string
+		// DryRun validates rows and detects conflicts without writing anything
+		DryRun bool
+		// Checkpoint, when set, is recorded in the transaction writing the batch, so
+		// that a resumed job neither repeats nor skips the batch
+		Checkpoint *ImportCheckpoint `json:"-"`
+	}
+
+	// ImportCheckpoint is the progress of the import job JobID once a batch is written
+	ImportCheckpoint struct {
+		JobID    int
+		Progress int64
+	}
+
+	// ImportResult is the outcome of importing a single row
+	ImportResult struct {
+		// ID of the created or existing user. It is 0 for rows that were not
+		// written and for new rows without an ID in a dry run.
+		ID     int
+		Status string
+		Error  string
+		// PreviousName and PreviousEmail are set for updated rows
+		PreviousName  string
+		PreviousEmail string
+	}
+)
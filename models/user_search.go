@@ -0,0 +1,9 @@
+package models
+
+// UserSearchHit is a user matching a search query. Highlights holds the searched fields
+// of the user that matched, by name, as HTML with the matched words in mark elements.
+type UserSearchHit struct {
+	User       *User
+	Score      float64
+	Highlights map[string]string
+}
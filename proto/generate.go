@@ -0,0 +1,6 @@
+// Package proto holds the protobuf definitions of the gRPC API and of the protobuf
+// representations served by the REST API. Run go generate
+// with buf, protoc-gen-go and protoc-gen-go-grpc on the PATH to rebuild userspb.
+package proto
+
+//go:generate buf generate
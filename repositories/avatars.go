@@ -0,0 +1,36 @@
+package repositories
+
+import (
+	"database/sql"
+)
+
+type (
+	// AvatarsRepository represents a repository for the avatar versions of the users of
+	// the tenant TenantID, stored in the avatar column of the user table
+	AvatarsRepository struct {
+		DB       *sql.DB
+		TenantID string
+	}
+)
+
+// SetUserAvatar sets the avatar version of the user with userID. The database bumps
+// updated_at, so representations of the user are modified too.
+func (repository *AvatarsRepository) SetUserAvatar(userID int, avatar string) error {
+	tx, err := repository.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := lockExclusive(tx, "user", "User", repository.TenantID, userID); err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare("UPDATE user SET avatar=? WHERE id=?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	if _, err := stmt.Exec(avatar, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,62 @@
+package repositories_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/repositories"
+)
+
+func TestSetUserAvatar(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectPrepare("SELECT id FROM user WHERE id=\\? AND tenant_id=\\? FOR UPDATE").
+		ExpectQuery().WithArgs(1, "acme").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectPrepare("UPDATE user SET avatar=\\? WHERE id=\\?").
+		ExpectExec().WithArgs("0123456789abcdef", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	repository := repositories.AvatarsRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	err = repository.SetUserAvatar(1, "0123456789abcdef")
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if err != nil {
+		t.Errorf("SetUserAvatar returned error: %v", err)
+	}
+}
+
+func TestSetUserAvatarUserNotFound(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectPrepare("SELECT id FROM user WHERE id=\\? AND tenant_id=\\? FOR UPDATE").
+		ExpectQuery().WithArgs(2, "acme").WillReturnError(fmt.Errorf("sql: no rows in result set"))
+	mock.ExpectRollback()
+	repository := repositories.AvatarsRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	err = repository.SetUserAvatar(2, "0123456789abcdef")
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if _, ok := err.(errors.NotFound); !ok || err.Error() != "User with ID 2 not found" {
+		t.Errorf("Error, expected: NotFound User with ID 2 not found, got: %v", err)
+	}
+}
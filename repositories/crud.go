@@ -0,0 +1,326 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+)
+
+type (
+	// Repository stores entities of type M with IDs of type ID in a table. It implements
+	// interfaces.Persister. Writes run in a transaction that the On hooks may add to,
+	// such as to record events.
+	Repository[M any, ID comparable] struct {
+		DB *sql.DB
+		// Table is the name of the table
+		Table string
+		// Entity names the entity in errors, such as "User"
+		Entity string
+		// Columns are the columns read into an entity, starting with the ID column
+		Columns []string
+		// Fields returns pointers to the fields of m in the order of Columns
+		Fields func(m *M) []interface{}
+		// Writable are the columns written by Create and Update. The ID column is
+		// generated on insert.
+		Writable []string
+		// Values returns the values of m in the order of Writable
+		Values func(m *M) []interface{}
+		// ID returns the ID of m
+		ID func(m *M) ID
+		// PrefixColumn is the column matched by the Prefix of ListOptions, or empty if
+		// entities cannot be filtered
+		PrefixColumn string
+		// ScopeColumn, if set, confines the repository to the rows whose ScopeColumn is
+		// Scope, such as those of a tenant. Other rows are not found, and Create sets
+		// ScopeColumn of the rows it inserts.
+		ScopeColumn string
+		Scope       interface{}
+
+		// OnCreate is called with the generated ID and the inserted entity before the
+		// insert is committed
+		OnCreate func(tx *sql.Tx, id int64, created *M) error
+		// OnUpdate is called with the locked entity and the entity read back after the
+		// update before it is committed
+		OnUpdate func(tx *sql.Tx, old, updated *M) error
+		// OnDelete is called with the locked entity before its delete is committed
+		OnDelete func(tx *sql.Tx, deleted *M) error
+	}
+)
+
+// Get by ID
+func (repository *Repository[M, ID]) Get(id ID) (*M, error) {
+	stmt, err := repository.DB.Prepare("SELECT " + repository.columns() + " FROM " + repository.Table + " WHERE " + repository.Columns[0] + "=?" + repository.scope())
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	return repository.scanRow(stmt.QueryRow(repository.scoped(id)...), id)
+}
+
+// GetMany by ID in a single query. Missing entities are omitted and the order of the
+// result is unspecified.
+func (repository *Repository[M, ID]) GetMany(ids []ID) ([]*M, error) {
+	if len(ids) == 0 {
+		return []*M{}, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := repository.DB.Query("SELECT "+repository.columns()+" FROM "+repository.Table+" WHERE "+repository.Columns[0]+" IN ("+placeholderRows("?", len(ids))+")"+repository.scope(), repository.scoped(args...)...)
+	if err != nil {
+		return nil, err
+	}
+	return repository.scanRows(rows)
+}
+
+// List returns a page of entities ordered by ID
+func (repository *Repository[M, ID]) List(options models.ListOptions[ID]) ([]*M, error) {
+	query := "SELECT " + repository.columns() + " FROM " + repository.Table + " WHERE " + repository.Columns[0] + " > ?"
+	args := []interface{}{options.AfterID}
+	if repository.PrefixColumn != "" {
+		query += " AND " + repository.PrefixColumn + " LIKE ?"
+		args = append(args, escapeLike(options.Prefix)+"%")
+	}
+	query += repository.scope()
+	args = repository.scoped(args...)
+	stmt, err := repository.DB.Prepare(query + " ORDER BY " + repository.Columns[0] + " LIMIT ?")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	rows, err := stmt.Query(append(args, options.Limit)...)
+	if err != nil {
+		return nil, err
+	}
+	return repository.scanRows(rows)
+}
+
+// Create inserts m and returns the stored entity
+func (repository *Repository[M, ID]) Create(m *M) (*M, error) {
+	tx, err := repository.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	columns, values := repository.Writable, repository.Values(m)
+	if repository.ScopeColumn != "" {
+		columns = append(append([]string{}, columns...), repository.ScopeColumn)
+		values = append(values, repository.Scope)
+	}
+	stmtInsert, err := tx.Prepare("INSERT INTO " + repository.Table + " (" + strings.Join(columns, ", ") + ") values(" + placeholderRows("?", len(columns)) + ")")
+	if err != nil {
+		return nil, err
+	}
+	defer stmtInsert.Close()
+	result, err := stmtInsert.Exec(values...)
+	if err != nil {
+		return nil, err
+	}
+	lastInsertedID, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	if repository.OnCreate != nil {
+		if err := repository.OnCreate(tx, lastInsertedID, m); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	stmtSelect, err := repository.DB.Prepare("SELECT " + repository.columns() + " FROM " + repository.Table + " WHERE " + repository.Columns[0] + "=?" + repository.scope())
+	if err != nil {
+		return nil, err
+	}
+	defer stmtSelect.Close()
+	return repository.scanRow(stmtSelect.QueryRow(repository.scoped(lastInsertedID)...), lastInsertedID)
+}
+
+// Update writes the Writable columns of the entity with the ID of m. It returns the
+// entity as it was locked before the write along with the stored entity, which is read
+// back in the same transaction so that columns set by the database are included.
+func (repository *Repository[M, ID]) Update(m *M) (old, updated *M, err error) {
+	return repository.UpdateColumns(m, repository.Writable...)
+}
+
+// UpdateColumns is Update writing only columns, a subset of Writable. The other columns
+// keep their stored values.
+func (repository *Repository[M, ID]) UpdateColumns(m *M, columns ...string) (old, updated *M, err error) {
+	tx, err := repository.DB.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+	id := repository.ID(m)
+	old, err = repository.lock(tx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	writable := repository.Values(m)
+	assignments := make([]string, len(columns))
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		j := indexOf(repository.Writable, column)
+		if j < 0 {
+			return nil, nil, fmt.Errorf("%s is not a writable column of %s", column, repository.Table)
+		}
+		assignments[i], values[i] = column+"=?", writable[j]
+	}
+	stmtUpdate, err := tx.Prepare("UPDATE " + repository.Table + " SET " + strings.Join(assignments, ", ") + " WHERE " + repository.Columns[0] + "=?" + repository.scope())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer stmtUpdate.Close()
+	if _, err := stmtUpdate.Exec(repository.scoped(append(values, id)...)...); err != nil {
+		return nil, nil, err
+	}
+	stmtSelect, err := tx.Prepare("SELECT " + repository.columns() + " FROM " + repository.Table + " WHERE " + repository.Columns[0] + "=?" + repository.scope())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer stmtSelect.Close()
+	updated, err = repository.scanRow(stmtSelect.QueryRow(repository.scoped(id)...), id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if repository.OnUpdate != nil {
+		if err := repository.OnUpdate(tx, old, updated); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return old, updated, nil
+}
+
+// Delete by ID and return the entity as it was locked before the delete
+func (repository *Repository[M, ID]) Delete(id ID) (deleted *M, err error) {
+	tx, err := repository.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	old, err := repository.lock(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	stmtDelete, err := tx.Prepare("DELETE FROM " + repository.Table + " WHERE " + repository.Columns[0] + "=?" + repository.scope())
+	if err != nil {
+		return nil, err
+	}
+	defer stmtDelete.Close()
+	if _, err := stmtDelete.Exec(repository.scoped(id)...); err != nil {
+		return nil, err
+	}
+	if repository.OnDelete != nil {
+		if err := repository.OnDelete(tx, old); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return old, nil
+}
+
+// lock locks the row of id for the rest of tx and returns its current entity
+func (repository *Repository[M, ID]) lock(tx *sql.Tx, id ID) (*M, error) {
+	stmt, err := tx.Prepare("SELECT " + repository.columns() + " FROM " + repository.Table + " WHERE " + repository.Columns[0] + "=?" + repository.scope() + " FOR UPDATE")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	return repository.scanRow(stmt.QueryRow(repository.scoped(id)...), id)
+}
+
+// scanRow reads the entity with id from row
+func (repository *Repository[M, ID]) scanRow(row *sql.Row, id interface{}) (*M, error) {
+	var m M
+	if err := row.Scan(repository.Fields(&m)...); err != nil {
+		if err.Error() == sqlNotFound {
+			return nil, errors.NotFound{Message: fmt.Sprintf("%s with ID %v not found", repository.Entity, id)}
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+// scanRows reads the entities of rows and closes rows
+func (repository *Repository[M, ID]) scanRows(rows *sql.Rows) ([]*M, error) {
+	defer rows.Close()
+	entities := []*M{}
+	for rows.Next() {
+		var m M
+		if err := rows.Scan(repository.Fields(&m)...); err != nil {
+			return nil, err
+		}
+		entities = append(entities, &m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return entities, nil
+}
+
+func (repository *Repository[M, ID]) columns() string {
+	return strings.Join(repository.Columns, ", ")
+}
+
+// scope returns the condition on ScopeColumn to add to a WHERE clause, if any
+func (repository *Repository[M, ID]) scope() string {
+	if repository.ScopeColumn == "" {
+		return ""
+	}
+	return " AND " + repository.ScopeColumn + "=?"
+}
+
+// scoped returns args followed by the argument of the condition of scope, if any
+func (repository *Repository[M, ID]) scoped(args ...interface{}) []interface{} {
+	if repository.ScopeColumn == "" {
+		return args
+	}
+	return append(args, repository.Scope)
+}
+
+// indexOf returns the index of s in values, or -1
+func indexOf(values []string, s string) int {
+	for i, value := range values {
+		if value == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// lockShared locks the row of id of tenantID in table against writes for the rest of
+// tx. It returns NotFound naming the entity if there is no such row.
+func lockShared(tx *sql.Tx, table, entity, tenantID string, id int) error {
+	return lockRow(tx, table, entity, tenantID, id, "LOCK IN SHARE MODE")
+}
+
+// lockExclusive locks the row of id of tenantID in table for the rest of tx. It
+// returns NotFound naming the entity if there is no such row.
+func lockExclusive(tx *sql.Tx, table, entity, tenantID string, id int) error {
+	return lockRow(tx, table, entity, tenantID, id, "FOR UPDATE")
+}
+
+// lockRow locks the row of id of tenantID in table with lock
+func lockRow(tx *sql.Tx, table, entity, tenantID string, id int, lock string) error {
+	stmt, err := tx.Prepare("SELECT id FROM " + table + " WHERE id=? AND tenant_id=? " + lock)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	if err := stmt.QueryRow(id, tenantID).Scan(&id); err != nil {
+		if err.Error() == sqlNotFound {
+			return errors.NotFound{Message: fmt.Sprintf("%s with ID %d not found", entity, id)}
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package repositories_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/repositories"
+)
+
+/*
+	Test objects
+*/
+
+type color struct {
+	Code string
+	Name string
+	Hex  string
+}
+
+func colorRepository(db *sql.DB) *repositories.Repository[color, string] {
+	return &repositories.Repository[color, string]{
+		DB:       db,
+		Table:    "color",
+		Entity:   "Color",
+		Columns:  []string{"code", "name", "hex"},
+		Fields:   func(c *color) []interface{} { return []interface{}{&c.Code, &c.Name, &c.Hex} },
+		Writable: []string{"name", "hex"},
+		Values:   func(c *color) []interface{} { return []interface{}{c.Name, c.Hex} },
+		ID:       func(c *color) string { return c.Code },
+	}
+}
+
+/*
+	Test functions
+*/
+
+func TestRepositoryListWithoutPrefixColumn(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"code", "name", "hex"}).AddRow("red", "Red", "#f00")
+	mock.ExpectPrepare("SELECT code, name, hex FROM color WHERE code > \\? ORDER BY code LIMIT \\?").
+		ExpectQuery().WithArgs("blue", 5).WillReturnRows(rows)
+
+	// Execute
+	colors, err := colorRepository(db).List(models.ListOptions[string]{AfterID: "blue", Limit: 5, Prefix: "ignored"})
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if err != nil {
+		t.Fatalf("List returned error: %s", err.Error())
+	}
+	if len(colors) != 1 || colors[0].Hex != "#f00" {
+		t.Errorf("Colors, expected: [red], got: %v", colors)
+	}
+}
+
+func TestRepositoryUpdateCallsOnUpdate(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare("SELECT code, name, hex FROM color WHERE code=\\? FOR UPDATE").
+		ExpectQuery().WithArgs("red").WillReturnRows(sqlmock.NewRows([]string{"code", "name", "hex"}).AddRow("red", "Red", "#f00"))
+	mock.ExpectPrepare("UPDATE color SET name=\\?, hex=\\? WHERE code=\\?").
+		ExpectExec().WithArgs("Crimson", "#dc143c", "red").WillReturnResult(&mockResult{})
+	mock.ExpectPrepare("SELECT code, name, hex FROM color WHERE code=\\?$").
+		ExpectQuery().WithArgs("red").WillReturnRows(sqlmock.NewRows([]string{"code", "name", "hex"}).AddRow("red", "Crimson", "#DC143C"))
+	mock.ExpectCommit()
+
+	repository := colorRepository(db)
+	var oldName, updatedHex string
+	repository.OnUpdate = func(tx *sql.Tx, old, updated *color) error {
+		oldName, updatedHex = old.Name, updated.Hex
+		return nil
+	}
+
+	// Execute
+	old, updated, err := repository.Update(&color{Code: "red", Name: "Crimson", Hex: "#dc143c"})
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if err != nil {
+		t.Fatalf("Update returned error: %s", err.Error())
+	}
+	if oldName != "Red" || old.Name != "Red" {
+		t.Errorf("Old name, expected: %s, got: %s and %s", "Red", oldName, old.Name)
+	}
+	if updatedHex != "#DC143C" || updated.Hex != "#DC143C" {
+		t.Errorf("Updated hex, expected: the stored %s, got: %s and %s", "#DC143C", updatedHex, updated.Hex)
+	}
+}
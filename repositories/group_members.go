@@ -0,0 +1,127 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+)
+
+// groupMemberColumns are the columns read into a models.GroupMember
+const groupMemberColumns = "group_id, user_id, role"
+
+type (
+	// GroupMembersRepository represents a repository for the memberships of users in
+	// groups of the tenant TenantID, stored in the group_member join table. Memberships
+	// have no tenant of their own: they are added only between a group and a user of the
+	// tenant.
+	GroupMembersRepository struct {
+		DB       *sql.DB
+		TenantID string
+	}
+)
+
+// AddGroupMember adds the user to the group, or changes the role of an existing
+// member. The group and the user are locked until the membership is committed, so
+// they cannot be deleted concurrently.
+func (repository *GroupMembersRepository) AddGroupMember(member *models.GroupMember) (*models.GroupMember, error) {
+	tx, err := repository.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if err := lockShared(tx, "user_group", "Group", repository.TenantID, member.GroupID); err != nil {
+		return nil, err
+	}
+	if err := lockShared(tx, "user", "User", repository.TenantID, member.UserID); err != nil {
+		return nil, err
+	}
+	stmt, err := tx.Prepare("INSERT INTO group_member (" + groupMemberColumns + ") values(?, ?, ?) ON DUPLICATE KEY UPDATE role=VALUES(role)")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	if _, err := stmt.Exec(member.GroupID, member.UserID, member.Role); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// RemoveGroupMember removes the user from the group
+func (repository *GroupMembersRepository) RemoveGroupMember(groupID, userID int) error {
+	stmt, err := repository.DB.Prepare("DELETE group_member FROM group_member JOIN user_group ON user_group.id=group_member.group_id WHERE group_id=? AND user_id=? AND user_group.tenant_id=?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	result, err := stmt.Exec(groupID, userID, repository.TenantID)
+	if err != nil {
+		return err
+	}
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return errors.NotFound{Message: fmt.Sprintf("User with ID %d is not a member of group %d", userID, groupID)}
+	}
+	return nil
+}
+
+// ListGroupMembers returns a page of the members of a group of the tenant ordered by
+// user ID
+func (repository *GroupMembersRepository) ListGroupMembers(groupID int, options models.ListOptions[int]) ([]*models.GroupMember, error) {
+	return repository.list("group_id", "user_id", groupID, options)
+}
+
+// ListUserGroups returns a page of the memberships of a user of the tenant ordered by
+// group ID
+func (repository *GroupMembersRepository) ListUserGroups(userID int, options models.ListOptions[int]) ([]*models.GroupMember, error) {
+	return repository.list("user_id", "group_id", userID, options)
+}
+
+// list returns the memberships whose column is id, paged by orderColumn. Only
+// memberships between a group and a user of the tenant are returned.
+func (repository *GroupMembersRepository) list(column, orderColumn string, id int, options models.ListOptions[int]) ([]*models.GroupMember, error) {
+	stmt, err := repository.DB.Prepare("SELECT group_member.group_id, group_member.user_id, group_member.role FROM group_member" +
+		" JOIN user_group ON user_group.id=group_member.group_id AND user_group.tenant_id=?" +
+		" JOIN user ON user.id=group_member.user_id AND user.tenant_id=?" +
+		" WHERE group_member." + column + "=? AND group_member." + orderColumn + " > ? ORDER BY group_member." + orderColumn + " LIMIT ?")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	rows, err := stmt.Query(repository.TenantID, repository.TenantID, id, options.AfterID, options.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	members := []*models.GroupMember{}
+	for rows.Next() {
+		member := models.GroupMember{}
+		if err := rows.Scan(&member.GroupID, &member.UserID, &member.Role); err != nil {
+			return nil, err
+		}
+		members = append(members, &member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// deleteGroupMembers deletes the memberships whose column is id as part of tx, such
+// as those of a deleted user
+func deleteGroupMembers(tx *sql.Tx, column string, id int) error {
+	stmt, err := tx.Prepare("DELETE FROM group_member WHERE " + column + "=?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(id)
+	return err
+}
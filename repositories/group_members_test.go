@@ -0,0 +1,151 @@
+package repositories_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/repositories"
+)
+
+func TestAddGroupMember(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectPrepare("SELECT id FROM user_group WHERE id=\\? AND tenant_id=\\? LOCK IN SHARE MODE").
+		ExpectQuery().WithArgs(1, "acme").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectPrepare("SELECT id FROM user WHERE id=\\? AND tenant_id=\\? LOCK IN SHARE MODE").
+		ExpectQuery().WithArgs(2, "acme").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectPrepare("INSERT INTO group_member \\(group_id, user_id, role\\) values\\(\\?, \\?, \\?\\) ON DUPLICATE KEY UPDATE role=VALUES\\(role\\)").
+		ExpectExec().WithArgs(1, 2, models.GroupRoleOwner).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	repository := repositories.GroupMembersRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	member, err := repository.AddGroupMember(&models.GroupMember{GroupID: 1, UserID: 2, Role: models.GroupRoleOwner})
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if err != nil || member.Role != models.GroupRoleOwner {
+		t.Errorf("AddGroupMember, expected: owner, got: %v, %v", member, err)
+	}
+}
+
+func TestAddGroupMemberUserNotFound(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectPrepare("SELECT id FROM user_group WHERE id=\\? AND tenant_id=\\? LOCK IN SHARE MODE").
+		ExpectQuery().WithArgs(1, "acme").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectPrepare("SELECT id FROM user WHERE id=\\? AND tenant_id=\\? LOCK IN SHARE MODE").
+		ExpectQuery().WithArgs(2, "acme").WillReturnError(fmt.Errorf("sql: no rows in result set"))
+	mock.ExpectRollback()
+	repository := repositories.GroupMembersRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	_, err = repository.AddGroupMember(&models.GroupMember{GroupID: 1, UserID: 2, Role: models.GroupRoleMember})
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if _, ok := err.(errors.NotFound); !ok || err.Error() != "User with ID 2 not found" {
+		t.Errorf("Error, expected: NotFound User with ID 2 not found, got: %v", err)
+	}
+}
+
+func TestRemoveGroupMemberNotAMember(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	mock.ExpectPrepare("DELETE group_member FROM group_member JOIN user_group ON user_group.id=group_member.group_id WHERE group_id=\\? AND user_id=\\? AND user_group.tenant_id=\\?").
+		ExpectExec().WithArgs(1, 2, "acme").WillReturnResult(sqlmock.NewResult(0, 0))
+	repository := repositories.GroupMembersRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	err = repository.RemoveGroupMember(1, 2)
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if _, ok := err.(errors.NotFound); !ok {
+		t.Errorf("Error, expected: NotFound, got: %v", err)
+	}
+}
+
+func TestListUserGroups(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	rows := sqlmock.NewRows([]string{"group_id", "user_id", "role"}).
+		AddRow(3, 2, models.GroupRoleMember).
+		AddRow(5, 2, models.GroupRoleOwner)
+	mock.ExpectPrepare("SELECT group_member.group_id, group_member.user_id, group_member.role FROM group_member"+
+		" JOIN user_group ON user_group.id=group_member.group_id AND user_group.tenant_id=\\?"+
+		" JOIN user ON user.id=group_member.user_id AND user.tenant_id=\\?"+
+		" WHERE group_member.user_id=\\? AND group_member.group_id > \\? ORDER BY group_member.group_id LIMIT \\?").
+		ExpectQuery().WithArgs("acme", "acme", 2, 1, 10).WillReturnRows(rows)
+	repository := repositories.GroupMembersRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	members, err := repository.ListUserGroups(2, models.ListOptions[int]{AfterID: 1, Limit: 10})
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if err != nil {
+		t.Fatalf("ListUserGroups returned error: %s", err.Error())
+	}
+	if len(members) != 2 || members[0].GroupID != 3 || members[1].Role != models.GroupRoleOwner {
+		t.Errorf("Memberships, expected: groups 3 and 5, got: %v", members)
+	}
+}
+
+func TestListGroupMembersOfAnotherTenant(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	mock.ExpectPrepare("SELECT group_member.group_id, group_member.user_id, group_member.role FROM group_member"+
+		" JOIN user_group ON user_group.id=group_member.group_id AND user_group.tenant_id=\\?"+
+		" JOIN user ON user.id=group_member.user_id AND user.tenant_id=\\?"+
+		" WHERE group_member.group_id=\\? AND group_member.user_id > \\? ORDER BY group_member.user_id LIMIT \\?").
+		ExpectQuery().WithArgs("globex", "globex", 1, 0, 10).WillReturnRows(sqlmock.NewRows([]string{"group_id", "user_id", "role"}))
+	repository := repositories.GroupMembersRepository{DB: db, TenantID: "globex"}
+
+	// Execute
+	members, err := repository.ListGroupMembers(1, models.ListOptions[int]{Limit: 10})
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if err != nil {
+		t.Fatalf("ListGroupMembers returned error: %s", err.Error())
+	}
+	if len(members) != 0 {
+		t.Errorf("Members, expected: none of group 1 of acme, got: %v", members)
+	}
+}
@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/jordantipton/golang-restful-webservice/models"
+)
+
+// groupColumns are the columns read into a models.Group
+const groupColumns = "id, name, description, updated_at"
+
+type (
+	// GroupsRepository represents a repository for the groups of the tenant TenantID.
+	// Groups of other tenants are not found.
+	GroupsRepository struct {
+		DB       *sql.DB
+		TenantID string
+	}
+)
+
+// Get by ID
+func (repository *GroupsRepository) Get(groupID int) (*models.Group, error) {
+	return repository.table().Get(groupID)
+}
+
+// GetMany by ID in a single query. Missing groups are omitted and the order of
+// the result is unspecified.
+func (repository *GroupsRepository) GetMany(groupIDs []int) ([]*models.Group, error) {
+	return repository.table().GetMany(groupIDs)
+}
+
+// List returns a page of groups ordered by ID
+func (repository *GroupsRepository) List(options models.ListOptions[int]) ([]*models.Group, error) {
+	return repository.table().List(options)
+}
+
+// Create in repository and return the stored group
+func (repository *GroupsRepository) Create(group *models.Group) (*models.Group, error) {
+	return repository.table().Create(group)
+}
+
+// Update the group with the ID of group and return the group as it was before
+func (repository *GroupsRepository) Update(group *models.Group) (old, updated *models.Group, err error) {
+	return repository.table().Update(group)
+}
+
+// Delete by ID along with the memberships of the group and return the deleted group
+func (repository *GroupsRepository) Delete(groupID int) (deleted *models.Group, err error) {
+	return repository.table().Delete(groupID)
+}
+
+// table returns the generic repository of the user_group table
+func (repository *GroupsRepository) table() *Repository[models.Group, int] {
+	return &Repository[models.Group, int]{
+		DB:      repository.DB,
+		Table:   "user_group",
+		Entity:  "Group",
+		Columns: strings.Split(groupColumns, ", "),
+		Fields: func(group *models.Group) []interface{} {
+			return []interface{}{&group.ID, &group.Name, &group.Description, &group.UpdatedAt}
+		},
+		Writable: []string{"name", "description"},
+		Values: func(group *models.Group) []interface{} {
+			return []interface{}{group.Name, group.Description}
+		},
+		ID:           func(group *models.Group) int { return group.ID },
+		PrefixColumn: "name",
+		ScopeColumn:  "tenant_id",
+		Scope:        repository.TenantID,
+		OnDelete: func(tx *sql.Tx, deleted *models.Group) error {
+			return deleteGroupMembers(tx, "group_id", deleted.ID)
+		},
+	}
+}
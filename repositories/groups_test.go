@@ -0,0 +1,117 @@
+package repositories_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/repositories"
+)
+
+func TestGroupsRepositoryGet(t *testing.T) {
+	tests := []struct {
+		name        string
+		rows        *sqlmock.Rows
+		err         error
+		expectFound bool
+	}{
+		{name: "found", rows: sqlmock.NewRows([]string{"id", "name", "description", "updated_at"}).
+			AddRow(1, "Sample", "Sample", time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)), expectFound: true},
+		{name: "not found", err: fmt.Errorf("sql: no rows in result set")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Setup
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+			query := mock.ExpectPrepare("SELECT id, name, description, updated_at FROM user_group WHERE id=\\? AND tenant_id=\\?").ExpectQuery().WithArgs(1, "acme")
+			if test.rows != nil {
+				query.WillReturnRows(test.rows)
+			} else {
+				query.WillReturnError(test.err)
+			}
+			repository := repositories.GroupsRepository{DB: db, TenantID: "acme"}
+
+			// Execute
+			group, err := repository.Get(1)
+
+			// Assert
+			if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+				t.Errorf("there were unfulfilled expectations: %s", mockErr)
+			}
+			if test.expectFound && (err != nil || group.ID != 1) {
+				t.Errorf("Get, expected: group 1, got: %v, %v", group, err)
+			}
+			if _, ok := err.(errors.NotFound); !test.expectFound && !ok {
+				t.Errorf("Error, expected: NotFound, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestGroupsRepositoryCreate(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectPrepare("INSERT INTO user_group").ExpectExec().WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectPrepare("SELECT id, name, description, updated_at FROM user_group WHERE id=\\? AND tenant_id=\\?").ExpectQuery().WithArgs(1, "acme").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description", "updated_at"}).
+			AddRow(1, "Sample", "Sample", time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)))
+	repository := repositories.GroupsRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	group, err := repository.Create(&models.Group{
+		Name:        "Sample",
+		Description: "Sample",
+	})
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if err != nil || group.ID != 1 {
+		t.Errorf("Create, expected: group 1, got: %v, %v", group, err)
+	}
+}
+
+func TestGroupsRepositoryDeleteRemovesMembers(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectPrepare("SELECT id, name, description, updated_at FROM user_group WHERE id=\\? AND tenant_id=\\? FOR UPDATE").ExpectQuery().WithArgs(1, "acme").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description", "updated_at"}).
+			AddRow(1, "Sample", "Sample", time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)))
+	mock.ExpectPrepare("DELETE FROM user_group WHERE id=\\? AND tenant_id=\\?").ExpectExec().WithArgs(1, "acme").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectPrepare("DELETE FROM group_member WHERE group_id=\\?").ExpectExec().WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+	repository := repositories.GroupsRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	deleted, err := repository.Delete(1)
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if err != nil {
+		t.Errorf("Delete returned error: %s", err.Error())
+	}
+	if deleted == nil || deleted.ID != 1 {
+		t.Errorf("Delete returned %+v, expected the deleted group", deleted)
+	}
+}
@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+)
+
+const jobColumns = "id, type, status, params, progress, total, error, artifact_name, artifact_type"
+
+type (
+	// JobsRepository represents a repository for the background jobs of the tenant
+	// TenantID. Jobs of other tenants are not found.
+	JobsRepository struct {
+		DB       *sql.DB
+		TenantID string
+	}
+)
+
+// CreateJob inserts job and returns it with its assigned ID
+func (repository *JobsRepository) CreateJob(job *models.Job) (*models.Job, error) {
+	stmt, err := repository.DB.Prepare("INSERT INTO job (type, status, params, progress, total, error, artifact_name, artifact_type, tenant_id) values(?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	res, err := stmt.Exec(job.Type, job.Status, job.Params, job.Progress, job.Total, job.Error, job.ArtifactName, job.ArtifactType, repository.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	jobID, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	created := *job
+	created.ID = int(jobID)
+	return &created, nil
+}
+
+// GetJob by ID
+func (repository *JobsRepository) GetJob(jobID int) (*models.Job, error) {
+	rows, err := repository.DB.Query("SELECT "+jobColumns+" FROM job WHERE id=? AND tenant_id=?", jobID, repository.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := scanJobs(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, errors.NotFound{Message: fmt.Sprintf("Job with ID %d not found", jobID)}
+	}
+	return jobs[0], nil
+}
+
+// UpdateJob stores the status, progress and results of job
+func (repository *JobsRepository) UpdateJob(job *models.Job) error {
+	stmt, err := repository.DB.Prepare("UPDATE job SET status=?, progress=?, total=?, error=?, artifact_name=?, artifact_type=? WHERE id=? AND tenant_id=?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(job.Status, job.Progress, job.Total, job.Error, job.ArtifactName, job.ArtifactType, job.ID, repository.TenantID)
+	return err
+}
+
+// ListJobsByStatus returns the jobs in any of statuses ordered by ID
+func (repository *JobsRepository) ListJobsByStatus(statuses []string) ([]*models.Job, error) {
+	if len(statuses) == 0 {
+		return []*models.Job{}, nil
+	}
+	args := make([]interface{}, len(statuses))
+	for i, status := range statuses {
+		args[i] = status
+	}
+	rows, err := repository.DB.Query("SELECT "+jobColumns+" FROM job WHERE status IN ("+placeholderRows("?", len(statuses))+") AND tenant_id=? ORDER BY id", append(args, repository.TenantID)...)
+	if err != nil {
+		return nil, err
+	}
+	return scanJobs(rows)
+}
+
+func scanJobs(rows *sql.Rows) ([]*models.Job, error) {
+	defer rows.Close()
+	jobs := []*models.Job{}
+	for rows.Next() {
+		job := models.Job{}
+		err := rows.Scan(&job.ID, &job.Type, &job.Status, &job.Params, &job.Progress, &job.Total, &job.Error, &job.ArtifactName, &job.ArtifactType)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, &job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
@@ -0,0 +1,92 @@
+package repositories_test
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/repositories"
+)
+
+var jobColumns = []string{"id", "type", "status", "params", "progress", "total", "error", "artifact_name", "artifact_type"}
+
+func TestCreateJob(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	expectedPrepare := mock.ExpectPrepare("INSERT INTO job")
+	expectedPrepare.ExpectExec().WithArgs("users.export", models.JobQueued, "{}", 0, 0, "", "", "", "acme").WillReturnResult(sqlmock.NewResult(7, 1))
+
+	repository := repositories.JobsRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	job, err := repository.CreateJob(&models.Job{Type: "users.export", Status: models.JobQueued, Params: "{}"})
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if err != nil {
+		t.Fatalf("CreateJob returned error: %s", err.Error())
+	}
+	if job.ID != 7 {
+		t.Errorf("Job ID, expected: %d, got: %d", 7, job.ID)
+	}
+}
+
+func TestGetJobNotFound(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT (.+) FROM job WHERE id=\\? AND tenant_id=\\?").WithArgs(3, "acme").WillReturnRows(sqlmock.NewRows(jobColumns))
+
+	repository := repositories.JobsRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	_, err = repository.GetJob(3)
+
+	// Assert
+	if _, ok := err.(errors.NotFound); !ok {
+		t.Errorf("Expected NotFound error, got: %v", err)
+	}
+}
+
+func TestListJobsByStatus(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows(jobColumns).
+		AddRow(1, "users.import", models.JobRunning, "{}", 500, 0, "", "", "").
+		AddRow(4, "users.export", models.JobQueued, "{}", 0, 0, "", "", "")
+	mock.ExpectQuery("SELECT (.+) FROM job WHERE status IN \\(\\?, \\?\\) AND tenant_id=\\? ORDER BY id").
+		WithArgs(models.JobQueued, models.JobRunning, "acme").WillReturnRows(rows)
+
+	repository := repositories.JobsRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	jobs, err := repository.ListJobsByStatus([]string{models.JobQueued, models.JobRunning})
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if err != nil {
+		t.Fatalf("ListJobsByStatus returned error: %s", err.Error())
+	}
+	if len(jobs) != 2 || jobs[0].Progress != 500 || jobs[1].Type != "users.export" {
+		t.Errorf("Jobs, expected: jobs 1 and 4, got: %v", jobs)
+	}
+}
@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jordantipton/golang-restful-webservice/models"
+)
+
+// UserEventSettleTime is how old an event must be before it is read from the change
+// feed. Sequence numbers are taken when an event is inserted but become visible when
+// its transaction commits, so a reader that resumed after a higher sequence number
+// would never see an event committed later. The age is measured from created_at, the
+// time of the insert, not of the commit: an event whose transaction commits more than
+// UserEventSettleTime after inserting it, behind a higher sequence number that has
+// already been read, is skipped for good by the readers of the feed. Transactions
+// recording events must therefore commit within this time of recording them, which is
+// why they record their events last.
+const UserEventSettleTime = 2 * time.Second
+
+type (
+	// UserEventsRepository represents a repository for the user change events of the
+	// tenant TenantID. Sequence numbers are shared by all tenants, so those of a tenant
+	// have gaps.
+	UserEventsRepository struct {
+		DB       *sql.DB
+		TenantID string
+	}
+)
+
+// ListUserEvents returns up to limit events with a sequence number greater than afterSeq
+// that are at least UserEventSettleTime old
+func (repository *UserEventsRepository) ListUserEvents(afterSeq int64, limit int) ([]*models.UserEvent, error) {
+	stmt, err := repository.DB.Prepare("SELECT seq, type, user_id, name, email FROM user_event WHERE seq > ? AND tenant_id=? AND created_at <= NOW(6) - INTERVAL ? MICROSECOND ORDER BY seq LIMIT ?")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	rows, err := stmt.Query(afterSeq, repository.TenantID, UserEventSettleTime.Microseconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events := []*models.UserEvent{}
+	for rows.Next() {
+		event := models.UserEvent{}
+		if err := rows.Scan(&event.Seq, &event.Type, &event.UserID, &event.Name, &event.Email); err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// GetLatestUserEventSeq returns the highest sequence number of the events that are at
+// least UserEventSettleTime old, or 0 if there are none
+func (repository *UserEventsRepository) GetLatestUserEventSeq() (int64, error) {
+	var seq int64
+	err := repository.DB.QueryRow("SELECT COALESCE(MAX(seq), 0) FROM user_event WHERE tenant_id=? AND created_at <= NOW(6) - INTERVAL ? MICROSECOND", repository.TenantID, UserEventSettleTime.Microseconds()).Scan(&seq)
+	if err != nil {
+		return 0, err
+	}
+	return seq, nil
+}
+
+// insertUserEvent records a change event of user, as it is after the change, as part
+// of tx
+func insertUserEvent(tx *sql.Tx, tenantID, eventType string, user *models.User) error {
+	stmt, err := tx.Prepare("INSERT INTO user_event (type, user_id, name, email, tenant_id) values(?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(eventType, user.ID, user.Name, user.Email, tenantID)
+	return err
+}
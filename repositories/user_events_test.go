@@ -0,0 +1,100 @@
+package repositories_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/repositories"
+)
+
+func TestListUserEvents(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"seq", "type", "user_id", "name", "email"}).
+		AddRow(6, models.UserEventCreated, 1, "Bob", "").
+		AddRow(7, models.UserEventCreated, 2, "Alice", "alice@example.com")
+	expectedPrepare := mock.ExpectPrepare("SELECT seq, type, user_id, name, email FROM user_event WHERE seq > \\? AND tenant_id=\\? AND created_at <= NOW\\(6\\) - INTERVAL \\? MICROSECOND ORDER BY seq LIMIT \\?")
+	expectedPrepare.ExpectQuery().WithArgs(5, "acme", repositories.UserEventSettleTime.Microseconds(), 10).WillReturnRows(rows)
+
+	repository := repositories.UserEventsRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	events, err := repository.ListUserEvents(5, 10)
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if err != nil {
+		t.Fatalf("ListUserEvents returned error: %s", err.Error())
+	}
+	if len(events) != 2 {
+		t.Fatalf("Events, expected: %d, got: %d", 2, len(events))
+	}
+	if events[0].Seq != 6 || events[1].Seq != 7 {
+		t.Errorf("Seq, expected: 6, 7, got: %d, %d", events[0].Seq, events[1].Seq)
+	}
+	if events[1].UserID != 2 || events[1].Name != "Alice" {
+		t.Errorf("Event, expected: 2 Alice, got: %d %s", events[1].UserID, events[1].Name)
+	}
+}
+
+func TestListUserEventsError(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	expectedPrepare := mock.ExpectPrepare("SELECT seq, type, user_id, name, email FROM user_event")
+	expectedPrepare.ExpectQuery().WillReturnError(fmt.Errorf("some error"))
+
+	repository := repositories.UserEventsRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	_, err = repository.ListUserEvents(0, 10)
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if err == nil {
+		t.Errorf("Expected error to be returned but is nil")
+	}
+}
+
+func TestGetLatestUserEventSeq(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"seq"}).AddRow(42)
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(seq\\), 0\\) FROM user_event WHERE tenant_id=\\? AND created_at <= NOW\\(6\\) - INTERVAL \\? MICROSECOND").WithArgs("acme", repositories.UserEventSettleTime.Microseconds()).WillReturnRows(rows)
+
+	repository := repositories.UserEventsRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	seq, err := repository.GetLatestUserEventSeq()
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if err != nil {
+		t.Errorf("GetLatestUserEventSeq returned error: %s", err.Error())
+	}
+	if seq != 42 {
+		t.Errorf("Seq, expected: %d, got: %d", 42, seq)
+	}
+}
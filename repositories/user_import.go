@@ -0,0 +1,171 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+)
+
+// ImportUsers writes users in a single transaction using multi-row INSERTs, except
+// for users without an ID, which are inserted one by one to read the ID generated for
+// each. Users with an ID that already exists are handled according to options.OnConflict. In
+// fail mode a conflict rolls back the whole batch and returns AlreadyExists along
+// with the per-row results. Results are in the order of users. IDs of users of
+// other tenants are conflicts in every mode, and are never written. The progress of
+// options.Checkpoint is recorded in the same transaction.
+func (repository *UsersRepository) ImportUsers(users []*models.User, options models.ImportOptions) ([]models.ImportResult, error) {
+	results := make([]models.ImportResult, len(users))
+	if len(users) == 0 {
+		return results, nil
+	}
+	tx, err := repository.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	existing, foreign, err := lockExistingUsers(tx, repository.TenantID, users)
+	if err != nil {
+		return nil, err
+	}
+
+	var created, explicit, fresh, updated []int
+	conflicts := 0
+	for i, user := range users {
+		previous, exists := existing[user.ID]
+		switch {
+		case foreign[user.ID]:
+			results[i] = models.ImportResult{ID: user.ID, Status: models.ImportConflict, Error: fmt.Sprintf("User ID %d is not available", user.ID)}
+			if options.OnConflict != models.ImportConflictSkip && options.OnConflict != models.ImportConflictUpdate {
+				conflicts++
+			}
+		case !exists && user.ID != 0:
+			explicit = append(explicit, i)
+			created = append(created, i)
+			results[i] = models.ImportResult{ID: user.ID, Status: models.ImportCreated}
+		case !exists:
+			fresh = append(fresh, i)
+			created = append(created, i)
+			results[i] = models.ImportResult{Status: models.ImportCreated}
+		case options.OnConflict == models.ImportConflictSkip:
+			results[i] = models.ImportResult{ID: user.ID, Status: models.ImportSkipped}
+		case options.OnConflict == models.ImportConflictUpdate:
+			updated = append(updated, i)
+			results[i] = models.ImportResult{ID: user.ID, Status: models.ImportUpdated, PreviousName: previous.Name, PreviousEmail: previous.Email}
+		default:
+			conflicts++
+			results[i] = models.ImportResult{ID: user.ID, Status: models.ImportConflict, Error: fmt.Sprintf("User with ID %d already exists", user.ID)}
+		}
+	}
+	if conflicts > 0 {
+		for i := range results {
+			if results[i].Status != models.ImportConflict {
+				results[i] = models.ImportResult{Status: models.ImportAborted}
+			}
+		}
+		return results, errors.AlreadyExists{Message: fmt.Sprintf("%d users already exist", conflicts)}
+	}
+	if options.DryRun {
+		return results, nil
+	}
+
+	if len(explicit) > 0 {
+		args := make([]interface{}, 0, 4*len(explicit))
+		for _, i := range explicit {
+			args = append(args, users[i].ID, users[i].Name, users[i].Email, repository.TenantID)
+		}
+		if _, err := tx.Exec("INSERT INTO user (id, name, email, tenant_id) VALUES "+placeholderRows("(?, ?, ?, ?)", len(explicit)), args...); err != nil {
+			return nil, err
+		}
+	}
+	if len(fresh) > 0 {
+		for _, i := range fresh {
+			result, err := tx.Exec("INSERT INTO user (name, email, tenant_id) VALUES (?, ?, ?)", users[i].Name, users[i].Email, repository.TenantID)
+			if err != nil {
+				return nil, err
+			}
+			userID, err := result.LastInsertId()
+			if err != nil {
+				return nil, err
+			}
+			results[i].ID = int(userID)
+		}
+	}
+	if len(updated) > 0 {
+		args := make([]interface{}, 0, 3*len(updated))
+		for _, i := range updated {
+			args = append(args, users[i].ID, users[i].Name, users[i].Email)
+		}
+		// The rows were locked above and are all of the tenant
+		query := "INSERT INTO user (id, name, email) VALUES " + placeholderRows("(?, ?, ?)", len(updated)) + " ON DUPLICATE KEY UPDATE name=VALUES(name), email=VALUES(email)"
+		if _, err := tx.Exec(query, args...); err != nil {
+			return nil, err
+		}
+	}
+
+	var eventArgs []interface{}
+	for _, i := range created {
+		eventArgs = append(eventArgs, models.UserEventCreated, results[i].ID, users[i].Name, users[i].Email, repository.TenantID)
+	}
+	for _, i := range updated {
+		if results[i].PreviousName != users[i].Name {
+			eventArgs = append(eventArgs, models.UserEventRenamed, users[i].ID, users[i].Name, users[i].Email, repository.TenantID)
+		}
+		if results[i].PreviousEmail != users[i].Email {
+			eventArgs = append(eventArgs, models.UserEventEmailChanged, users[i].ID, users[i].Name, users[i].Email, repository.TenantID)
+		}
+	}
+	if len(eventArgs) > 0 {
+		if _, err := tx.Exec("INSERT INTO user_event (type, user_id, name, email, tenant_id) VALUES "+placeholderRows("(?, ?, ?, ?, ?)", len(eventArgs)/5), eventArgs...); err != nil {
+			return nil, err
+		}
+	}
+	if checkpoint := options.Checkpoint; checkpoint != nil {
+		if _, err := tx.Exec("UPDATE job SET progress=? WHERE id=? AND tenant_id=?", checkpoint.Progress, checkpoint.JobID, repository.TenantID); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// lockExistingUsers locks the rows of users that already exist and returns the name and
+// email of those of tenantID by ID, and the IDs of those of other tenants
+func lockExistingUsers(tx *sql.Tx, tenantID string, users []*models.User) (map[int]*models.User, map[int]bool, error) {
+	var args []interface{}
+	for _, user := range users {
+		if user.ID != 0 {
+			args = append(args, user.ID)
+		}
+	}
+	existing, foreign := map[int]*models.User{}, map[int]bool{}
+	if len(args) == 0 {
+		return existing, foreign, nil
+	}
+	rows, err := tx.Query("SELECT id, name, email, tenant_id FROM user WHERE id IN ("+placeholderRows("?", len(args))+") FOR UPDATE", args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		user := models.User{}
+		if err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.TenantID); err != nil {
+			return nil, nil, err
+		}
+		if user.TenantID == tenantID {
+			existing[user.ID] = &user
+		} else {
+			foreign[user.ID] = true
+		}
+	}
+	return existing, foreign, rows.Err()
+}
+
+// placeholderRows repeats row n times separated by commas
+func placeholderRows(row string, n int) string {
+	return strings.TrimSuffix(strings.Repeat(row+", ", n), ", ")
+}
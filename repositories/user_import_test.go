@@ -0,0 +1,194 @@
+package repositories_test
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/repositories"
+)
+
+func TestImportUsersUpdate(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, name, email, tenant_id FROM user WHERE id IN \\(\\?, \\?\\) FOR UPDATE").
+		WithArgs(5, 9).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "tenant_id"}).AddRow(9, "Old", "old@example.com", "acme"))
+	mock.ExpectExec("INSERT INTO user \\(id, name, email, tenant_id\\) VALUES \\(\\?, \\?, \\?, \\?\\)$").
+		WithArgs(5, "Five", "", "acme").WillReturnResult(sqlmock.NewResult(5, 1))
+	// Generated IDs need not be consecutive, e.g. with innodb_autoinc_lock_mode=2
+	mock.ExpectExec("INSERT INTO user \\(name, email, tenant_id\\) VALUES \\(\\?, \\?, \\?\\)$").
+		WithArgs("Alice", "alice@example.com", "acme").WillReturnResult(sqlmock.NewResult(20, 1))
+	mock.ExpectExec("INSERT INTO user \\(name, email, tenant_id\\) VALUES \\(\\?, \\?, \\?\\)$").
+		WithArgs("Bob", "", "acme").WillReturnResult(sqlmock.NewResult(35, 1))
+	mock.ExpectExec("INSERT INTO user \\(id, name, email\\) VALUES \\(\\?, \\?, \\?\\) ON DUPLICATE KEY UPDATE name=VALUES\\(name\\), email=VALUES\\(email\\)").
+		WithArgs(9, "Nine", "nine@example.com").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO user_event \\(type, user_id, name, email, tenant_id\\) VALUES (\\(\\?, \\?, \\?, \\?, \\?\\)(, )?){5}$").
+		WithArgs(
+			models.UserEventCreated, 20, "Alice", "alice@example.com", "acme",
+			models.UserEventCreated, 5, "Five", "", "acme",
+			models.UserEventCreated, 35, "Bob", "", "acme",
+			models.UserEventRenamed, 9, "Nine", "nine@example.com", "acme",
+			models.UserEventEmailChanged, 9, "Nine", "nine@example.com", "acme",
+		).
+		WillReturnResult(sqlmock.NewResult(0, 5))
+	mock.ExpectCommit()
+
+	repository := repositories.UsersRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	users := []*models.User{{Name: "Alice", Email: "alice@example.com"}, {ID: 5, Name: "Five"}, {ID: 9, Name: "Nine", Email: "nine@example.com"}, {Name: "Bob"}}
+	results, err := repository.ImportUsers(users, models.ImportOptions{OnConflict: models.ImportConflictUpdate})
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if err != nil {
+		t.Fatalf("ImportUsers returned error: %s", err.Error())
+	}
+	expected := []models.ImportResult{
+		{ID: 20, Status: models.ImportCreated},
+		{ID: 5, Status: models.ImportCreated},
+		{ID: 9, Status: models.ImportUpdated, PreviousName: "Old", PreviousEmail: "old@example.com"},
+		{ID: 35, Status: models.ImportCreated},
+	}
+	for i := range expected {
+		if results[i] != expected[i] {
+			t.Errorf("Result %d, expected: %+v, got: %+v", i, expected[i], results[i])
+		}
+	}
+}
+
+func TestImportUsersConflictRollsBack(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, name, email, tenant_id FROM user WHERE id IN \\(\\?\\) FOR UPDATE").
+		WithArgs(9).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "tenant_id"}).AddRow(9, "Old", "", "acme"))
+	mock.ExpectRollback()
+
+	repository := repositories.UsersRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	users := []*models.User{{Name: "Alice"}, {ID: 9, Name: "Nine"}}
+	results, err := repository.ImportUsers(users, models.ImportOptions{OnConflict: models.ImportConflictFail})
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if _, ok := err.(errors.AlreadyExists); !ok {
+		t.Errorf("Error, expected: AlreadyExists, got: %v", err)
+	}
+	if results[0].Status != models.ImportAborted || results[1].Status != models.ImportConflict {
+		t.Errorf("Statuses, expected: aborted, conflict, got: %s, %s", results[0].Status, results[1].Status)
+	}
+}
+
+func TestImportUsersDryRunWritesNothing(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, name, email, tenant_id FROM user WHERE id IN \\(\\?\\) FOR UPDATE").
+		WithArgs(9).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "tenant_id"}).AddRow(9, "Old", "", "acme"))
+	mock.ExpectRollback()
+
+	repository := repositories.UsersRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	users := []*models.User{{Name: "Alice"}, {ID: 9, Name: "Nine"}}
+	results, err := repository.ImportUsers(users, models.ImportOptions{OnConflict: models.ImportConflictSkip, DryRun: true})
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if err != nil {
+		t.Errorf("ImportUsers returned error: %s", err.Error())
+	}
+	if results[0].Status != models.ImportCreated || results[1].Status != models.ImportSkipped {
+		t.Errorf("Statuses, expected: created, skipped, got: %s, %s", results[0].Status, results[1].Status)
+	}
+}
+
+func TestImportUsersForeignIDConflicts(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, name, email, tenant_id FROM user WHERE id IN \\(\\?\\) FOR UPDATE").
+		WithArgs(9).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "tenant_id"}).AddRow(9, "Other", "", "globex"))
+	mock.ExpectExec("INSERT INTO user \\(name, email, tenant_id\\) VALUES \\(\\?, \\?, \\?\\)").
+		WithArgs("Alice", "", "acme").WillReturnResult(sqlmock.NewResult(20, 1))
+	mock.ExpectExec("INSERT INTO user_event").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	repository := repositories.UsersRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	users := []*models.User{{Name: "Alice"}, {ID: 9, Name: "Nine"}}
+	results, err := repository.ImportUsers(users, models.ImportOptions{OnConflict: models.ImportConflictUpdate})
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if err != nil {
+		t.Fatalf("ImportUsers returned error: %s", err.Error())
+	}
+	if results[0].Status != models.ImportCreated || results[1].Status != models.ImportConflict {
+		t.Errorf("Statuses, expected: created, conflict, got: %s, %s", results[0].Status, results[1].Status)
+	}
+}
+
+func TestImportUsersRecordsCheckpointInTransaction(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO user \\(name, email, tenant_id\\) VALUES \\(\\?, \\?, \\?\\)").
+		WithArgs("Alice", "", "acme").WillReturnResult(sqlmock.NewResult(20, 1))
+	mock.ExpectExec("INSERT INTO user_event").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE job SET progress=\\? WHERE id=\\? AND tenant_id=\\?").
+		WithArgs(500, 7, "acme").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	repository := repositories.UsersRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	options := models.ImportOptions{OnConflict: models.ImportConflictFail, Checkpoint: &models.ImportCheckpoint{JobID: 7, Progress: 500}}
+	_, err = repository.ImportUsers([]*models.User{{Name: "Alice"}}, options)
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if err != nil {
+		t.Fatalf("ImportUsers returned error: %s", err.Error())
+	}
+}
@@ -2,63 +2,129 @@ package repositories
 
 import (
 	"database/sql"
-	"fmt"
+	"strings"
 
 	"github.com/jordantipton/golang-restful-webservice/models"
-	"github.com/jordantipton/golang-restful-webservice/models/errors"
 )
 
 const sqlNotFound = "sql: no rows in result set"
 
+// userColumns are the columns read into a models.User
+const userColumns = "id, name, email, avatar, updated_at"
+
 type (
-	// UsersRepository represents a repository for user information
+	// UsersRepository represents a repository for the users of the tenant TenantID.
+	// Users of other tenants are not found.
 	UsersRepository struct {
-		DB *sql.DB
+		DB       *sql.DB
+		TenantID string
 	}
 )
 
 // GetUser by ID
 func (repository *UsersRepository) GetUser(userID int) (*models.User, error) {
-	user := models.User{}
-	stmt, err := repository.DB.Prepare("SELECT id, name FROM user WHERE id=?")
+	return repository.table().Get(userID)
+}
+
+// GetUsers by ID in a single query. Missing users are omitted and the order of the
+// result is unspecified.
+func (repository *UsersRepository) GetUsers(userIDs []int) ([]*models.User, error) {
+	return repository.table().GetMany(userIDs)
+}
+
+// ListUsers returns a page of users ordered by ID
+func (repository *UsersRepository) ListUsers(options models.UserListOptions) ([]*models.User, error) {
+	return repository.table().List(models.ListOptions[int]{AfterID: options.AfterID, Limit: options.Limit, Prefix: options.NamePrefix})
+}
+
+// ExportUsers calls fn for every user matching filter in ID order. Rows are read from
+// a single cursor, so the result set is never held in memory.
+func (repository *UsersRepository) ExportUsers(filter models.UserFilter, fn func(user *models.User) error) error {
+	rows, err := repository.DB.Query("SELECT "+userColumns+" FROM user WHERE name LIKE ? AND tenant_id=? ORDER BY id", escapeLike(filter.NamePrefix)+"%", repository.TenantID)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer stmt.Close()
-	err = stmt.QueryRow(userID).Scan(&user.ID, &user.Name)
-	if err != nil {
-		if err.Error() == sqlNotFound {
-			return nil, errors.NotFound{Message: fmt.Sprintf("User with ID %d not found", userID)}
+	defer rows.Close()
+	for rows.Next() {
+		user := models.User{TenantID: repository.TenantID}
+		if err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Avatar, &user.UpdatedAt); err != nil {
+			return err
+		}
+		if err := fn(&user); err != nil {
+			return err
 		}
-		return nil, err
 	}
-	return &user, nil
+	return rows.Err()
 }
 
-// CreateUser in repository and return repository
+// CreateUser in repository and return repository. A user.created event is
+// recorded in the same transaction.
 func (repository *UsersRepository) CreateUser(user *models.User) (*models.User, error) {
-	resultUser := models.User{}
-	stmtInsert, err := repository.DB.Prepare("INSERT INTO user (name) values(?)")
-	if err != nil {
-		return nil, err
-	}
-	defer stmtInsert.Close()
-	result, err := stmtInsert.Exec(user.Name)
-	if err != nil {
-		return nil, err
-	}
-	lastInsertedID, err := result.LastInsertId()
-	if err != nil {
-		return nil, err
-	}
-	stmtSelect, err := repository.DB.Prepare("SELECT id, name FROM user WHERE id=?")
-	if err != nil {
-		return nil, err
-	}
-	defer stmtSelect.Close()
-	err = stmtSelect.QueryRow(lastInsertedID).Scan(&resultUser.ID, &resultUser.Name)
-	if err != nil {
-		return nil, err
+	return repository.table().Create(user)
+}
+
+// UpdateUser writes the name and email of the user with user.ID and returns the user as
+// it was before along with the stored user. A user.renamed event is recorded in the same transaction when the name
+// changes, and a user.email_changed event when the email does.
+func (repository *UsersRepository) UpdateUser(user *models.User) (old, updated *models.User, err error) {
+	return repository.table().Update(&models.User{ID: user.ID, Name: user.Name, Email: user.Email})
+}
+
+// RenameUser writes only the name of the user with userID, so that a concurrent change
+// of the email is kept. It returns the user as it was before along with the stored user.
+// A user.renamed event is recorded in the same transaction when the name changes.
+func (repository *UsersRepository) RenameUser(userID int, name string) (old, updated *models.User, err error) {
+	return repository.table().UpdateColumns(&models.User{ID: userID, Name: name}, "name")
+}
+
+// DeleteUser by ID and return the deleted user. Its group memberships are deleted and
+// a user.deleted event is recorded in the same transaction.
+func (repository *UsersRepository) DeleteUser(userID int) (deleted *models.User, err error) {
+	return repository.table().Delete(userID)
+}
+
+// table returns the generic repository of the user table
+func (repository *UsersRepository) table() *Repository[models.User, int] {
+	return &Repository[models.User, int]{
+		DB:      repository.DB,
+		Table:   "user",
+		Entity:  "User",
+		Columns: strings.Split(userColumns, ", "),
+		Fields: func(user *models.User) []interface{} {
+			// Only users of the tenant are read
+			user.TenantID = repository.TenantID
+			return []interface{}{&user.ID, &user.Name, &user.Email, &user.Avatar, &user.UpdatedAt}
+		},
+		Writable:     []string{"name", "email"},
+		Values:       func(user *models.User) []interface{} { return []interface{}{user.Name, user.Email} },
+		ID:           func(user *models.User) int { return user.ID },
+		PrefixColumn: "name",
+		ScopeColumn:  "tenant_id",
+		Scope:        repository.TenantID,
+		OnCreate: func(tx *sql.Tx, id int64, created *models.User) error {
+			return insertUserEvent(tx, repository.TenantID, models.UserEventCreated, &models.User{ID: int(id), Name: created.Name, Email: created.Email})
+		},
+		OnUpdate: func(tx *sql.Tx, old, updated *models.User) error {
+			if old.Name != updated.Name {
+				if err := insertUserEvent(tx, repository.TenantID, models.UserEventRenamed, updated); err != nil {
+					return err
+				}
+			}
+			if old.Email != updated.Email {
+				return insertUserEvent(tx, repository.TenantID, models.UserEventEmailChanged, updated)
+			}
+			return nil
+		},
+		OnDelete: func(tx *sql.Tx, deleted *models.User) error {
+			if err := deleteGroupMembers(tx, "user_id", deleted.ID); err != nil {
+				return err
+			}
+			return insertUserEvent(tx, repository.TenantID, models.UserEventDeleted, deleted)
+		},
 	}
-	return &resultUser, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s
+func escapeLike(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(s)
 }
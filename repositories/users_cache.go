@@ -0,0 +1,166 @@
+package repositories
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/jordantipton/golang-restful-webservice/cache"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/services/interfaces"
+	"golang.org/x/sync/singleflight"
+)
+
+// Default lifetimes of cached users
+const (
+	DefaultUserCacheTTL    = 5 * time.Minute
+	DefaultUserNotFoundTTL = 10 * time.Second
+)
+
+type (
+	// CachedUsersRepository is a read-through cache in front of a UsersPersister.
+	// GetUser and GetUsers are served from Store; concurrent GetUser misses for the same
+	// user share one lookup, and users that do not exist are remembered for NotFoundTTL.
+	// Entries are removed by Invalidate, which should be subscribed to the user domain
+	// events, and otherwise expire after TTL. A lookup that races with a mutation can
+	// store the previous value, so TTL bounds how stale a user can be. Invalidate only
+	// hears the events of this process: an in-memory Store such as cache.LRU is only
+	// coherent when a single instance serves the users, and instances sharing a database
+	// must share a Store such as cache.Redis, or serve users up to TTL stale.
+	CachedUsersRepository struct {
+		interfaces.UsersPersister
+		Store       cache.Store
+		TTL         time.Duration
+		NotFoundTTL time.Duration
+
+		group singleflight.Group
+	}
+)
+
+// GetUser by ID from the cache, loading it on a miss
+func (repository *CachedUsersRepository) GetUser(userID int) (*models.User, error) {
+	key := userCacheKey(userID)
+	if user, hit := repository.lookup(key); hit {
+		if user == nil {
+			return nil, errors.NotFound{Message: fmt.Sprintf("User with ID %d not found", userID)}
+		}
+		return user, nil
+	}
+	loaded, err, _ := repository.group.Do(key, func() (interface{}, error) {
+		user, err := repository.UsersPersister.GetUser(userID)
+		switch err.(type) {
+		case nil:
+			repository.store(key, user)
+		case errors.NotFound:
+			repository.store(key, nil)
+		}
+		return user, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Callers that shared the lookup must not share the result
+	user := *loaded.(*models.User)
+	return &user, nil
+}
+
+// GetUsers by ID from the cache, loading the misses in a single GetUsers call
+func (repository *CachedUsersRepository) GetUsers(userIDs []int) ([]*models.User, error) {
+	users := []*models.User{}
+	var misses []int
+	for _, userID := range userIDs {
+		user, hit := repository.lookup(userCacheKey(userID))
+		switch {
+		case !hit:
+			misses = append(misses, userID)
+		case user != nil:
+			users = append(users, user)
+		}
+	}
+	if len(misses) == 0 {
+		return users, nil
+	}
+	loaded, err := repository.UsersPersister.GetUsers(misses)
+	if err != nil {
+		return nil, err
+	}
+	found := map[int]bool{}
+	for _, user := range loaded {
+		found[user.ID] = true
+		repository.store(userCacheKey(user.ID), user)
+	}
+	for _, userID := range misses {
+		if !found[userID] {
+			repository.store(userCacheKey(userID), nil)
+		}
+	}
+	return append(users, loaded...), nil
+}
+
+// Invalidate removes the user changed by event from the cache
+func (repository *CachedUsersRepository) Invalidate(event models.Event) {
+	var userID int
+	switch e := event.(type) {
+	case models.UserCreated:
+		userID = e.User.ID
+	case models.UserRenamed:
+		userID = e.UserID
+	case models.UserDeleted:
+		userID = e.UserID
+	case models.UserAvatarChanged:
+		userID = e.UserID
+	case models.UserEmailChanged:
+		userID = e.UserID
+	default:
+		return
+	}
+	if err := repository.Store.Delete(userCacheKey(userID)); err != nil {
+		log.Printf("users cache: failed to invalidate user %d: %v", userID, err)
+	}
+}
+
+// lookup returns the cached user under key and whether there was an entry. A hit with
+// a nil user is a cached NotFound. Cache errors are treated as misses.
+func (repository *CachedUsersRepository) lookup(key string) (*models.User, bool) {
+	value, ok, err := repository.Store.Get(key)
+	if err != nil {
+		log.Printf("users cache: failed to read %s: %v", key, err)
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+	if len(value) == 0 {
+		return nil, true
+	}
+	user := models.User{}
+	if err := json.Unmarshal(value, &user); err != nil {
+		return nil, false
+	}
+	return &user, true
+}
+
+// store caches user under key, or a NotFound entry if user is nil
+func (repository *CachedUsersRepository) store(key string, user *models.User) {
+	var value []byte
+	ttl := repository.NotFoundTTL
+	if ttl <= 0 {
+		ttl = DefaultUserNotFoundTTL
+	}
+	if user != nil {
+		value, _ = json.Marshal(user)
+		if ttl = repository.TTL; ttl <= 0 {
+			ttl = DefaultUserCacheTTL
+		}
+	}
+	if err := repository.Store.Set(key, value, ttl); err != nil {
+		log.Printf("users cache: failed to write %s: %v", key, err)
+	}
+}
+
+func userCacheKey(userID int) string {
+	return "user:" + strconv.Itoa(userID)
+}
@@ -0,0 +1,156 @@
+package repositories_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jordantipton/golang-restful-webservice/cache"
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/repositories"
+)
+
+func TestCachedGetUser(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows(userRowColumns).AddRow(1, "Bob", "", "", updatedAt)
+	mock.ExpectPrepare("SELECT id, name, email, avatar, updated_at FROM user WHERE id=\\? AND tenant_id=\\?").ExpectQuery().WithArgs(1, "acme").WillReturnRows(rows)
+
+	repository := &repositories.CachedUsersRepository{UsersPersister: &repositories.UsersRepository{DB: db, TenantID: "acme"}, Store: &cache.LRU{}}
+
+	// Execute
+	repository.GetUser(1)
+	user, err := repository.GetUser(1)
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if err != nil {
+		t.Fatalf("GetUser returned error: %s", err.Error())
+	}
+	if user.Name != "Bob" {
+		t.Errorf("Name, expected: Bob, got: %s", user.Name)
+	}
+}
+
+func TestCachedGetUserCachesNotFound(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectPrepare("SELECT id, name, email, avatar, updated_at FROM user WHERE id=\\? AND tenant_id=\\?").ExpectQuery().WithArgs(5, "acme").WillReturnRows(sqlmock.NewRows(userRowColumns))
+
+	repository := &repositories.CachedUsersRepository{UsersPersister: &repositories.UsersRepository{DB: db, TenantID: "acme"}, Store: &cache.LRU{}}
+
+	// Execute
+	repository.GetUser(5)
+	_, err = repository.GetUser(5)
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if _, ok := err.(errors.NotFound); !ok {
+		t.Errorf("Expected NotFound error, got: %v", err)
+	}
+}
+
+func TestCachedGetUserCollapsesConcurrentMisses(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows(userRowColumns).AddRow(1, "Bob", "", "", updatedAt)
+	mock.ExpectPrepare("SELECT id, name, email, avatar, updated_at FROM user WHERE id=\\? AND tenant_id=\\?").ExpectQuery().WithArgs(1, "acme").WillDelayFor(50 * time.Millisecond).WillReturnRows(rows)
+
+	repository := &repositories.CachedUsersRepository{UsersPersister: &repositories.UsersRepository{DB: db, TenantID: "acme"}, Store: &cache.LRU{}}
+
+	// Execute
+	var wg sync.WaitGroup
+	results := make([]*models.User, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = repository.GetUser(1)
+		}(i)
+	}
+	wg.Wait()
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	for i, user := range results {
+		if user == nil || user.Name != "Bob" {
+			t.Fatalf("Result %d, expected: Bob, got: %v", i, user)
+		}
+	}
+	if results[0] == results[1] {
+		t.Errorf("Expected every caller to get its own copy of the user")
+	}
+}
+
+func TestCachedGetUsersLoadsOnlyMisses(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows(userRowColumns).AddRow(2, "Alice", "", "", updatedAt)
+	mock.ExpectQuery("SELECT id, name, email, avatar, updated_at FROM user WHERE id IN \\(\\?, \\?\\) AND tenant_id=\\?").WithArgs(2, 3, "acme").WillReturnRows(rows)
+
+	store := &cache.LRU{}
+	store.Set("user:1", []byte(`{"ID":1,"Name":"Bob"}`), time.Minute)
+	repository := &repositories.CachedUsersRepository{UsersPersister: &repositories.UsersRepository{DB: db, TenantID: "acme"}, Store: store}
+
+	// Execute
+	users, err := repository.GetUsers([]int{1, 2, 3})
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if err != nil {
+		t.Fatalf("GetUsers returned error: %s", err.Error())
+	}
+	if len(users) != 2 {
+		t.Errorf("Users, expected: %d, got: %d", 2, len(users))
+	}
+	if value, ok, _ := store.Get("user:3"); !ok || len(value) != 0 {
+		t.Errorf("Expected user 3 to be cached as not found")
+	}
+}
+
+func TestCachedUsersInvalidate(t *testing.T) {
+	// Setup
+	store := &cache.LRU{}
+	store.Set("user:1", []byte(`{"ID":1,"Name":"Bob"}`), time.Minute)
+	store.Set("user:2", []byte{}, time.Minute)
+	repository := &repositories.CachedUsersRepository{Store: store}
+
+	// Execute
+	repository.Invalidate(models.UserRenamed{UserID: 1, OldName: "Bob", NewName: "Robert"})
+	repository.Invalidate(models.UserCreated{User: models.User{ID: 2, Name: "Alice"}})
+
+	// Assert
+	if store.Len() != 0 {
+		t.Errorf("Cached entries, expected: %d, got: %d", 0, store.Len())
+	}
+}
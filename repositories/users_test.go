@@ -3,6 +3,7 @@ package repositories_test
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/jordantipton/golang-restful-webservice/models"
@@ -10,6 +11,11 @@ import (
 	"github.com/jordantipton/golang-restful-webservice/repositories"
 )
 
+var (
+	userRowColumns = []string{"id", "name", "email", "avatar", "updated_at"}
+	updatedAt      = time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+)
+
 //GetUser tests
 
 func TestGetUserByID(t *testing.T) {
@@ -21,11 +27,11 @@ func TestGetUserByID(t *testing.T) {
 	}
 	defer db.Close()
 
-	rows := sqlmock.NewRows([]string{"id", "name"}).AddRow(userID, userName)
-	expectedPrepare := mock.ExpectPrepare("SELECT id, name FROM user WHERE id=?")
+	rows := sqlmock.NewRows(userRowColumns).AddRow(userID, userName, "", "", updatedAt)
+	expectedPrepare := mock.ExpectPrepare("SELECT id, name, email, avatar, updated_at FROM user WHERE id=\\? AND tenant_id=\\?")
 	expectedPrepare.ExpectQuery().WillReturnRows(rows)
 
-	repository := repositories.UsersRepository{DB: db}
+	repository := repositories.UsersRepository{DB: db, TenantID: "acme"}
 
 	// Execute
 	var user *models.User
@@ -47,6 +53,9 @@ func TestGetUserByID(t *testing.T) {
 	if user.Name != userName {
 		t.Errorf("ID, expected: %s, got: %s", userName, user.Name)
 	}
+	if user.TenantID != "acme" {
+		t.Errorf("TenantID, expected: %s, got: %s", "acme", user.TenantID)
+	}
 }
 
 func TestGetUserByIDNotFound(t *testing.T) {
@@ -58,10 +67,10 @@ func TestGetUserByIDNotFound(t *testing.T) {
 	}
 	defer db.Close()
 
-	expectedPrepare := mock.ExpectPrepare("SELECT id, name FROM user WHERE id=?")
+	expectedPrepare := mock.ExpectPrepare("SELECT id, name, email, avatar, updated_at FROM user WHERE id=\\? AND tenant_id=\\?")
 	expectedPrepare.ExpectQuery().WillReturnError(fmt.Errorf("sql: no rows in result set"))
 
-	repository := repositories.UsersRepository{DB: db}
+	repository := repositories.UsersRepository{DB: db, TenantID: "acme"}
 
 	// Execute
 	_, err = repository.GetUser(userID)
@@ -84,10 +93,10 @@ func TestGetUserByIDError(t *testing.T) {
 	}
 	defer db.Close()
 
-	expectedPrepare := mock.ExpectPrepare("SELECT id, name FROM user WHERE id=?")
+	expectedPrepare := mock.ExpectPrepare("SELECT id, name, email, avatar, updated_at FROM user WHERE id=\\? AND tenant_id=\\?")
 	expectedPrepare.ExpectQuery().WillReturnError(fmt.Errorf("some error"))
 
-	repository := repositories.UsersRepository{DB: db}
+	repository := repositories.UsersRepository{DB: db, TenantID: "acme"}
 
 	// Execute
 	_, err = repository.GetUser(userID)
@@ -112,13 +121,17 @@ func TestCreateUser(t *testing.T) {
 	}
 	defer db.Close()
 
-	rows := sqlmock.NewRows([]string{"id", "name"}).AddRow(userID, userName)
-	expectedPrepareInsert := mock.ExpectPrepare("INSERT INTO user \\(name\\) values\\(\\?\\)")
-	expectedPrepareInsert.ExpectExec().WillReturnResult(&mockResult{})
-	expectedPrepareSelect := mock.ExpectPrepare("SELECT id, name FROM user WHERE id=?")
+	rows := sqlmock.NewRows(userRowColumns).AddRow(userID, userName, "", "", updatedAt)
+	mock.ExpectBegin()
+	expectedPrepareInsert := mock.ExpectPrepare("INSERT INTO user \\(name, email, tenant_id\\) values\\(\\?, \\?, \\?\\)")
+	expectedPrepareInsert.ExpectExec().WithArgs(userName, "", "acme").WillReturnResult(&mockResult{})
+	expectedPrepareEvent := mock.ExpectPrepare("INSERT INTO user_event")
+	expectedPrepareEvent.ExpectExec().WithArgs(models.UserEventCreated, 1, userName, "", "acme").WillReturnResult(&mockResult{})
+	mock.ExpectCommit()
+	expectedPrepareSelect := mock.ExpectPrepare("SELECT id, name, email, avatar, updated_at FROM user WHERE id=\\? AND tenant_id=\\?")
 	expectedPrepareSelect.ExpectQuery().WillReturnRows(rows)
 
-	repository := repositories.UsersRepository{DB: db}
+	repository := repositories.UsersRepository{DB: db, TenantID: "acme"}
 
 	// Execute
 	requestUser := models.User{
@@ -153,12 +166,16 @@ func TestCreateUserErrorSelect(t *testing.T) {
 	}
 	defer db.Close()
 
-	expectedPrepareInsert := mock.ExpectPrepare("INSERT INTO user \\(name\\) values\\(\\?\\)")
-	expectedPrepareInsert.ExpectExec().WillReturnResult(&mockResult{})
-	expectedPrepareSelect := mock.ExpectPrepare("SELECT id, name FROM user WHERE id=?")
+	mock.ExpectBegin()
+	expectedPrepareInsert := mock.ExpectPrepare("INSERT INTO user \\(name, email, tenant_id\\) values\\(\\?, \\?, \\?\\)")
+	expectedPrepareInsert.ExpectExec().WithArgs(userName, "", "acme").WillReturnResult(&mockResult{})
+	expectedPrepareEvent := mock.ExpectPrepare("INSERT INTO user_event")
+	expectedPrepareEvent.ExpectExec().WithArgs(models.UserEventCreated, 1, userName, "", "acme").WillReturnResult(&mockResult{})
+	mock.ExpectCommit()
+	expectedPrepareSelect := mock.ExpectPrepare("SELECT id, name, email, avatar, updated_at FROM user WHERE id=\\? AND tenant_id=\\?")
 	expectedPrepareSelect.ExpectQuery().WillReturnError(fmt.Errorf("sql: no rows in result set"))
 
-	repository := repositories.UsersRepository{DB: db}
+	repository := repositories.UsersRepository{DB: db, TenantID: "acme"}
 
 	// Execute
 	requestUser := models.User{
@@ -184,12 +201,16 @@ func TestCreateUserErrorInsert(t *testing.T) {
 	}
 	defer db.Close()
 
-	expectedPrepareInsert := mock.ExpectPrepare("INSERT INTO user \\(name\\) values\\(\\?\\)")
-	expectedPrepareInsert.ExpectExec().WillReturnResult(&mockResult{})
-	expectedPrepareSelect := mock.ExpectPrepare("SELECT id, name FROM user WHERE id=?")
+	mock.ExpectBegin()
+	expectedPrepareInsert := mock.ExpectPrepare("INSERT INTO user \\(name, email, tenant_id\\) values\\(\\?, \\?, \\?\\)")
+	expectedPrepareInsert.ExpectExec().WithArgs(userName, "", "acme").WillReturnResult(&mockResult{})
+	expectedPrepareEvent := mock.ExpectPrepare("INSERT INTO user_event")
+	expectedPrepareEvent.ExpectExec().WithArgs(models.UserEventCreated, 1, userName, "", "acme").WillReturnResult(&mockResult{})
+	mock.ExpectCommit()
+	expectedPrepareSelect := mock.ExpectPrepare("SELECT id, name, email, avatar, updated_at FROM user WHERE id=\\? AND tenant_id=\\?")
 	expectedPrepareSelect.ExpectQuery().WillReturnError(fmt.Errorf("some error"))
 
-	repository := repositories.UsersRepository{DB: db}
+	repository := repositories.UsersRepository{DB: db, TenantID: "acme"}
 
 	// Execute
 	requestUser := models.User{
@@ -206,6 +227,270 @@ func TestCreateUserErrorInsert(t *testing.T) {
 	}
 }
 
+func TestCreateUserErrorEventRollsBack(t *testing.T) {
+	// Setup
+	userName := "Bob"
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	expectedPrepareInsert := mock.ExpectPrepare("INSERT INTO user \\(name, email, tenant_id\\) values\\(\\?, \\?, \\?\\)")
+	expectedPrepareInsert.ExpectExec().WithArgs(userName, "", "acme").WillReturnResult(&mockResult{})
+	expectedPrepareEvent := mock.ExpectPrepare("INSERT INTO user_event")
+	expectedPrepareEvent.ExpectExec().WillReturnError(fmt.Errorf("some error"))
+	mock.ExpectRollback()
+
+	repository := repositories.UsersRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	requestUser := models.User{
+		Name: userName,
+	}
+	_, err = repository.CreateUser(&requestUser)
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if err == nil {
+		t.Errorf("Expected error to be returned but is nil")
+	}
+}
+
+// GetUsers tests
+
+func TestGetUsers(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows(userRowColumns).AddRow(1, "Bob", "", "", updatedAt).AddRow(3, "Alice", "", "", updatedAt)
+	mock.ExpectQuery("SELECT id, name, email, avatar, updated_at FROM user WHERE id IN \\(\\?, \\?, \\?\\) AND tenant_id=\\?").WithArgs(1, 2, 3, "acme").WillReturnRows(rows)
+
+	repository := repositories.UsersRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	users, err := repository.GetUsers([]int{1, 2, 3})
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if err != nil {
+		t.Fatalf("GetUsers returned error: %s", err.Error())
+	}
+	if len(users) != 2 {
+		t.Errorf("Users, expected: %d, got: %d", 2, len(users))
+	}
+}
+
+// ListUsers tests
+
+func TestListUsers(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows(userRowColumns).AddRow(3, "Bob_1", "", "", updatedAt).AddRow(4, "Bob_2", "", "", updatedAt)
+	expectedPrepare := mock.ExpectPrepare("SELECT id, name, email, avatar, updated_at FROM user WHERE id > \\? AND name LIKE \\? AND tenant_id=\\? ORDER BY id LIMIT \\?")
+	expectedPrepare.ExpectQuery().WithArgs(2, "Bob\\_%", "acme", 10).WillReturnRows(rows)
+
+	repository := repositories.UsersRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	users, err := repository.ListUsers(models.UserListOptions{AfterID: 2, Limit: 10, NamePrefix: "Bob_"})
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if err != nil {
+		t.Fatalf("ListUsers returned error: %s", err.Error())
+	}
+	if len(users) != 2 || users[0].ID != 3 || users[1].ID != 4 {
+		t.Errorf("Users, expected IDs: 3, 4, got: %v", users)
+	}
+}
+
+// UpdateUser tests
+
+func TestUpdateUser(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare("SELECT id, name, email, avatar, updated_at FROM user WHERE id=\\? AND tenant_id=\\? FOR UPDATE").
+		ExpectQuery().WithArgs(1, "acme").WillReturnRows(sqlmock.NewRows(userRowColumns).AddRow(1, "Old", "", "", updatedAt))
+	mock.ExpectPrepare("UPDATE user SET name=\\?, email=\\? WHERE id=\\? AND tenant_id=\\?").
+		ExpectExec().WithArgs("New", "", 1, "acme").WillReturnResult(&mockResult{})
+	storedAt := updatedAt.Add(time.Minute)
+	mock.ExpectPrepare("SELECT id, name, email, avatar, updated_at FROM user WHERE id=\\? AND tenant_id=\\?$").
+		ExpectQuery().WithArgs(1, "acme").WillReturnRows(sqlmock.NewRows(userRowColumns).AddRow(1, "New", "", "1/avatar.png", storedAt))
+	mock.ExpectPrepare("INSERT INTO user_event").
+		ExpectExec().WithArgs(models.UserEventRenamed, 1, "New", "", "acme").WillReturnResult(&mockResult{})
+	mock.ExpectCommit()
+
+	repository := repositories.UsersRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	old, user, err := repository.UpdateUser(&models.User{ID: 1, Name: "New"})
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if err != nil {
+		t.Fatalf("UpdateUser returned error: %s", err.Error())
+	}
+	if old.ID != 1 || old.Name != "Old" {
+		t.Errorf("Old user, expected: 1 Old, got: %d %s", old.ID, old.Name)
+	}
+	if user.ID != 1 || user.Name != "New" || user.Avatar != "1/avatar.png" || !user.UpdatedAt.Equal(storedAt) {
+		t.Errorf("User, expected: the stored user, got: %+v", user)
+	}
+}
+
+func TestRenameUserWritesOnlyTheName(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare("SELECT id, name, email, avatar, updated_at FROM user WHERE id=\\? AND tenant_id=\\? FOR UPDATE").
+		ExpectQuery().WithArgs(1, "acme").WillReturnRows(sqlmock.NewRows(userRowColumns).AddRow(1, "Old", "ada@example.com", "", updatedAt))
+	mock.ExpectPrepare("UPDATE user SET name=\\? WHERE id=\\? AND tenant_id=\\?").
+		ExpectExec().WithArgs("New", 1, "acme").WillReturnResult(&mockResult{})
+	mock.ExpectPrepare("SELECT id, name, email, avatar, updated_at FROM user WHERE id=\\? AND tenant_id=\\?$").
+		ExpectQuery().WithArgs(1, "acme").WillReturnRows(sqlmock.NewRows(userRowColumns).AddRow(1, "New", "ada@example.com", "", updatedAt))
+	mock.ExpectPrepare("INSERT INTO user_event").
+		ExpectExec().WithArgs(models.UserEventRenamed, 1, "New", "ada@example.com", "acme").WillReturnResult(&mockResult{})
+	mock.ExpectCommit()
+
+	repository := repositories.UsersRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	old, user, err := repository.RenameUser(1, "New")
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if err != nil {
+		t.Fatalf("RenameUser returned error: %s", err.Error())
+	}
+	if old.Name != "Old" || user.Name != "New" || user.Email != "ada@example.com" {
+		t.Errorf("Users, expected: Old then New ada@example.com, got: %+v then %+v", old, user)
+	}
+}
+
+func TestUpdateUserNotFound(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare("SELECT id, name, email, avatar, updated_at FROM user WHERE id=\\? AND tenant_id=\\? FOR UPDATE").
+		ExpectQuery().WillReturnError(fmt.Errorf("sql: no rows in result set"))
+	mock.ExpectRollback()
+
+	repository := repositories.UsersRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	_, _, err = repository.UpdateUser(&models.User{ID: 1, Name: "New"})
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if _, ok := err.(errors.NotFound); !ok {
+		t.Errorf("Error, expected: NotFound, got: %v", err)
+	}
+}
+
+// DeleteUser tests
+
+func TestDeleteUser(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare("SELECT id, name, email, avatar, updated_at FROM user WHERE id=\\? AND tenant_id=\\? FOR UPDATE").
+		ExpectQuery().WithArgs(1, "acme").WillReturnRows(sqlmock.NewRows(userRowColumns).AddRow(1, "Bob", "", "", updatedAt))
+	mock.ExpectPrepare("DELETE FROM user WHERE id=\\? AND tenant_id=\\?").
+		ExpectExec().WithArgs(1, "acme").WillReturnResult(&mockResult{})
+	mock.ExpectPrepare("DELETE FROM group_member WHERE user_id=\\?").
+		ExpectExec().WithArgs(1).WillReturnResult(&mockResult{})
+	mock.ExpectPrepare("INSERT INTO user_event").
+		ExpectExec().WithArgs(models.UserEventDeleted, 1, "Bob", "", "acme").WillReturnResult(&mockResult{})
+	mock.ExpectCommit()
+
+	repository := repositories.UsersRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	deleted, err := repository.DeleteUser(1)
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if err != nil {
+		t.Errorf("DeleteUser returned error: %s", err.Error())
+	}
+	if deleted == nil || deleted.Name != "Bob" {
+		t.Errorf("DeleteUser returned %+v, expected the deleted user Bob", deleted)
+	}
+}
+
+func TestDeleteUserNotFound(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare("SELECT id, name, email, avatar, updated_at FROM user WHERE id=\\? AND tenant_id=\\? FOR UPDATE").
+		ExpectQuery().WillReturnError(fmt.Errorf("sql: no rows in result set"))
+	mock.ExpectRollback()
+
+	repository := repositories.UsersRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	_, err = repository.DeleteUser(1)
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if _, ok := err.(errors.NotFound); !ok {
+		t.Errorf("Error, expected: NotFound, got: %v", err)
+	}
+}
+
 type mockResult struct{}
 
 func (result *mockResult) LastInsertId() (int64, error) {
@@ -214,3 +499,66 @@ func (result *mockResult) LastInsertId() (int64, error) {
 func (result *mockResult) RowsAffected() (int64, error) {
 	return 1, nil
 }
+
+// ExportUsers tests
+
+func TestExportUsers(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows(userRowColumns).AddRow(1, "Bob", "", "", updatedAt).AddRow(2, "Bobby", "", "", updatedAt)
+	mock.ExpectQuery("SELECT id, name, email, avatar, updated_at FROM user WHERE name LIKE \\? AND tenant_id=\\? ORDER BY id").WithArgs("Bo%", "acme").WillReturnRows(rows)
+
+	repository := repositories.UsersRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	var exported []string
+	err = repository.ExportUsers(models.UserFilter{NamePrefix: "Bo"}, func(user *models.User) error {
+		exported = append(exported, user.Name)
+		return nil
+	})
+
+	// Assert
+	if mockErr := mock.ExpectationsWereMet(); mockErr != nil {
+		t.Errorf("there were unfulfilled expectations: %s", mockErr)
+	}
+	if err != nil {
+		t.Errorf("ExportUsers returned error: %s", err.Error())
+	}
+	if len(exported) != 2 || exported[1] != "Bobby" {
+		t.Errorf("Exported, expected: [Bob Bobby], got: %v", exported)
+	}
+}
+
+func TestExportUsersStopsOnCallbackError(t *testing.T) {
+	// Setup
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows(userRowColumns).AddRow(1, "Bob", "", "", updatedAt).AddRow(2, "Bobby", "", "", updatedAt)
+	mock.ExpectQuery("SELECT id, name, email, avatar, updated_at FROM user").WillReturnRows(rows)
+
+	repository := repositories.UsersRepository{DB: db, TenantID: "acme"}
+
+	// Execute
+	calls := 0
+	err = repository.ExportUsers(models.UserFilter{}, func(user *models.User) error {
+		calls++
+		return fmt.Errorf("client went away")
+	})
+
+	// Assert
+	if err == nil {
+		t.Errorf("Expected error to be returned but is nil")
+	}
+	if calls != 1 {
+		t.Errorf("Callback calls, expected: %d, got: %d", 1, calls)
+	}
+}
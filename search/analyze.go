@@ -0,0 +1,69 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+type (
+	// Token is a word of a text. Term is the word folded to lower case without
+	// diacritics; Start and End are its byte offsets in the text.
+	Token struct {
+		Term       string
+		Start, End int
+	}
+)
+
+// foldings spell the letters that do not decompose into a base letter and diacritics
+var foldings = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'ł': "l", 'đ': "d", 'ð': "d", 'þ': "th", 'ı': "i",
+}
+
+// Tokenize splits text into words of letters and digits. Everything else, such as
+// spaces, punctuation and the @ of an email address, separates words.
+func Tokenize(text string) []Token {
+	var tokens []Token
+	start := -1
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = appendToken(tokens, text, start, i)
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = appendToken(tokens, text, start, len(text))
+	}
+	return tokens
+}
+
+func appendToken(tokens []Token, text string, start, end int) []Token {
+	if term := Fold(text[start:end]); term != "" {
+		tokens = append(tokens, Token{Term: term, Start: start, End: end})
+	}
+	return tokens
+}
+
+// Fold returns word in lower case without diacritics, so that "Zoë" and "zoe" match
+func Fold(word string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(word) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		r = unicode.ToLower(r)
+		if folded, ok := foldings[r]; ok {
+			b.WriteString(folded)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,398 @@
+// Package search implements an embedded full-text index of documents with case and
+// accent insensitive, prefix and fuzzy matching, relevance ranking and highlighting
+package search
+
+import (
+	"encoding/gob"
+	"html"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Matching limits and the relative scores of the kinds of matches. An exact match of a
+// term scores 1.
+const (
+	// maxPrefixExpansions bounds the number of terms a query word matches as a prefix
+	maxPrefixExpansions = 100
+	// oneTypoLength is the length of the shortest query word matched with one typo,
+	// and twoTyposLength that of the shortest matched with two
+	oneTypoLength  = 4
+	twoTyposLength = 8
+
+	prefixScore = 0.5
+	fuzzyScore  = 0.4
+)
+
+type (
+	// Field is a field of the documents of an index. Matches in fields of higher Weight
+	// rank higher.
+	Field struct {
+		Name   string
+		Weight float64
+	}
+
+	// Document is a text to index, as the values of its fields by name
+	Document struct {
+		ID     int
+		Fields map[string]string
+	}
+
+	// Hit is a document matching a query. Highlights holds the fields with matches,
+	// as HTML with the matched words in mark elements.
+	Hit struct {
+		ID         int
+		Score      float64
+		Highlights map[string]string
+	}
+
+	// Index is an in-memory inverted index of the Fields of documents. Every word of a
+	// query must match a word of a document, exactly, as a prefix or, for longer words,
+	// with one or two typos. Hits are ranked by the rarity of the words they match,
+	// how closely and in which fields. Fields must be set before the first Put; the
+	// index is safe for concurrent use.
+	Index struct {
+		Fields []Field
+
+		mu       sync.RWMutex
+		seq      int64
+		docs     map[int]Document
+		postings map[string]map[int]uint64 // term -> document ID -> bit set of Fields
+		terms    []string                  // the keys of postings, sorted unless dirty
+		dirty    bool
+	}
+
+	// snapshot is the file format of Save and Load
+	snapshot struct {
+		Seq       int64
+		Documents []Document
+	}
+)
+
+// Put indexes doc, replacing the document with its ID
+func (ix *Index) Put(doc Document) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.put(doc)
+}
+
+// Delete removes the document with id
+func (ix *Index) Delete(id int) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(id)
+}
+
+// Len returns the number of documents
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+// Seq returns the position in a change log that the index reflects, as set by SetSeq
+func (ix *Index) Seq() int64 {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.seq
+}
+
+// SetSeq records that the index reflects a change log up to seq
+func (ix *Index) SetSeq(seq int64) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.seq = seq
+}
+
+// Replace swaps the contents of ix with those of other, such as a rebuilt index.
+// other must not be used afterwards.
+func (ix *Index) Replace(other *Index) {
+	other.mu.Lock()
+	defer other.mu.Unlock()
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.seq, ix.docs, ix.postings, ix.terms, ix.dirty = other.seq, other.docs, other.postings, other.terms, other.dirty
+}
+
+// Search returns the limit best hits of query, best first. Hits with the same score
+// are ordered by ID.
+func (ix *Index) Search(query string, limit int) []Hit {
+	var words []string
+	seen := map[string]bool{}
+	for _, token := range Tokenize(query) {
+		if !seen[token.Term] {
+			seen[token.Term] = true
+			words = append(words, token.Term)
+		}
+	}
+	if len(words) == 0 || limit <= 0 {
+		return nil
+	}
+	ix.sortTerms()
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	// Each document keeps the sum of the best match of every word, and only those
+	// matching every word are hits
+	scores := map[int]float64{}
+	matched := map[int]int{}
+	highlighted := map[string]bool{}
+	for i, word := range words {
+		best := map[int]float64{}
+		for term, quality := range ix.expand(word) {
+			highlighted[term] = true
+			documents := ix.postings[term]
+			idf := math.Log(1 + float64(len(ix.docs))/float64(len(documents)))
+			for id, fields := range documents {
+				if matched[id] != i {
+					continue
+				}
+				if score := quality * idf * ix.weight(fields); score > best[id] {
+					best[id] = score
+				}
+			}
+		}
+		for id, score := range best {
+			scores[id] += score
+			matched[id]++
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		if matched[id] == len(words) {
+			hits = append(hits, Hit{ID: id, Score: score})
+		}
+	}
+	sort.Slice(hits, func(a, b int) bool {
+		if hits[a].Score != hits[b].Score {
+			return hits[a].Score > hits[b].Score
+		}
+		return hits[a].ID < hits[b].ID
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	for i := range hits {
+		hits[i].Highlights = ix.highlight(ix.docs[hits[i].ID], highlighted)
+	}
+	return hits
+}
+
+// Save writes the documents and the Seq of the index to the file at path, replacing
+// it atomically
+func (ix *Index) Save(path string) error {
+	ix.mu.RLock()
+	state := snapshot{Seq: ix.seq, Documents: make([]Document, 0, len(ix.docs))}
+	for _, doc := range ix.docs {
+		state.Documents = append(state.Documents, doc)
+	}
+	ix.mu.RUnlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), ".index-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if err := gob.NewEncoder(file).Encode(&state); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// Load replaces the contents of the index with the file written by Save at path. It
+// returns an error satisfying os.IsNotExist if there is none.
+func (ix *Index) Load(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var state snapshot
+	if err := gob.NewDecoder(file).Decode(&state); err != nil {
+		return err
+	}
+	loaded := &Index{Fields: ix.Fields, seq: state.Seq}
+	for _, doc := range state.Documents {
+		loaded.put(doc)
+	}
+	ix.Replace(loaded)
+	return nil
+}
+
+func (ix *Index) put(doc Document) {
+	if ix.docs == nil {
+		ix.docs, ix.postings = map[int]Document{}, map[string]map[int]uint64{}
+	}
+	ix.remove(doc.ID)
+	ix.docs[doc.ID] = doc
+	for i, field := range ix.Fields {
+		for _, token := range Tokenize(doc.Fields[field.Name]) {
+			documents, ok := ix.postings[token.Term]
+			if !ok {
+				documents = map[int]uint64{}
+				ix.postings[token.Term] = documents
+				ix.terms = append(ix.terms, token.Term)
+				ix.dirty = true
+			}
+			documents[doc.ID] |= 1 << i
+		}
+	}
+}
+
+func (ix *Index) remove(id int) {
+	doc, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+	delete(ix.docs, id)
+	for _, field := range ix.Fields {
+		for _, token := range Tokenize(doc.Fields[field.Name]) {
+			documents := ix.postings[token.Term]
+			delete(documents, id)
+			if len(documents) == 0 {
+				// The term is dropped from terms on the next sort
+				delete(ix.postings, token.Term)
+				ix.dirty = true
+			}
+		}
+	}
+}
+
+// sortTerms brings terms in line with postings after changes, so that prefixes can be
+// looked up with a binary search
+func (ix *Index) sortTerms() {
+	ix.mu.RLock()
+	dirty := ix.dirty
+	ix.mu.RUnlock()
+	if !dirty {
+		return
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	terms := ix.terms[:0]
+	for term := range ix.postings {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+	ix.terms, ix.dirty = terms, false
+}
+
+// expand returns the terms that word matches with the quality of each match
+func (ix *Index) expand(word string) map[string]float64 {
+	terms := map[string]float64{}
+	if _, ok := ix.postings[word]; ok {
+		terms[word] = 1
+	}
+	wordLength := utf8.RuneCountInString(word)
+	for i := sort.SearchStrings(ix.terms, word); i < len(ix.terms) && len(terms) <= maxPrefixExpansions; i++ {
+		term := ix.terms[i]
+		if !strings.HasPrefix(term, word) {
+			break
+		}
+		if term != word {
+			// Longer completions are weaker matches
+			terms[term] = prefixScore + (1-prefixScore)*float64(wordLength)/float64(utf8.RuneCountInString(term))
+		}
+	}
+	maxEdits := 0
+	switch {
+	case wordLength >= twoTyposLength:
+		maxEdits = 2
+	case wordLength >= oneTypoLength:
+		maxEdits = 1
+	}
+	if maxEdits == 0 {
+		return terms
+	}
+	runes := []rune(word)
+	for _, term := range ix.terms {
+		if _, ok := terms[term]; ok {
+			continue
+		}
+		if edits := editDistance(runes, []rune(term), maxEdits); edits <= maxEdits {
+			terms[term] = fuzzyScore / float64(edits)
+		}
+	}
+	return terms
+}
+
+// weight returns the weight of the heaviest of the fields in the bit set fields
+func (ix *Index) weight(fields uint64) float64 {
+	weight := 0.0
+	for i, field := range ix.Fields {
+		if fields&(1<<i) != 0 && field.Weight > weight {
+			weight = field.Weight
+		}
+	}
+	return weight
+}
+
+// highlight returns the fields of doc that contain terms, with the words of terms in
+// mark elements
+func (ix *Index) highlight(doc Document, terms map[string]bool) map[string]string {
+	highlights := map[string]string{}
+	for _, field := range ix.Fields {
+		text := doc.Fields[field.Name]
+		var b strings.Builder
+		end, marked := 0, false
+		for _, token := range Tokenize(text) {
+			if !terms[token.Term] {
+				continue
+			}
+			b.WriteString(html.EscapeString(text[end:token.Start]))
+			b.WriteString("<mark>" + html.EscapeString(text[token.Start:token.End]) + "</mark>")
+			end, marked = token.End, true
+		}
+		if marked {
+			b.WriteString(html.EscapeString(text[end:]))
+			highlights[field.Name] = b.String()
+		}
+	}
+	return highlights
+}
+
+// editDistance returns the number of single letter insertions, deletions,
+// substitutions and transpositions that turn a into b, or limit+1 if it exceeds limit
+func editDistance(a, b []rune, limit int) int {
+	if d := len(a) - len(b); d > limit || -d > limit {
+		return limit + 1
+	}
+	previous2 := make([]int, len(b)+1)
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		rowMin := current[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				current[j] = min(current[j], previous2[j-2]+1)
+			}
+			rowMin = min(rowMin, current[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		previous2, previous, current = previous, current, previous2
+	}
+	return min(previous[len(b)], limit+1)
+}
@@ -0,0 +1,174 @@
+package search_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jordantipton/golang-restful-webservice/search"
+)
+
+/*
+	Test objects
+*/
+
+func newIndex(names map[int]string) *search.Index {
+	index := &search.Index{Fields: []search.Field{{Name: "name", Weight: 2}, {Name: "email", Weight: 1}}}
+	for id, name := range names {
+		index.Put(search.Document{ID: id, Fields: map[string]string{"name": name}})
+	}
+	return index
+}
+
+func hitIDs(hits []search.Hit) []int {
+	ids := []int{}
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
+
+/*
+	Test functions
+*/
+
+func TestTokenize(t *testing.T) {
+	// Execute
+	tokens := search.Tokenize("Zoë O'Brien-Straße, ada@example.com")
+
+	// Assert
+	expected := []search.Token{
+		{Term: "zoe", Start: 0, End: 4},
+		{Term: "o", Start: 5, End: 6},
+		{Term: "brien", Start: 7, End: 12},
+		{Term: "strasse", Start: 13, End: 20},
+		{Term: "ada", Start: 22, End: 25},
+		{Term: "example", Start: 26, End: 33},
+		{Term: "com", Start: 34, End: 37},
+	}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("Tokens, expected: %v, got: %v", expected, tokens)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	index := newIndex(map[int]string{
+		1: "Zoë Saldaña",
+		2: "Zoe Kravitz",
+		3: "Ada Lovelace",
+		4: "Adam Smith",
+		5: "Grace Hopper",
+		6: "Katherine Johnson",
+	})
+	tests := []struct {
+		name        string
+		query       string
+		expectedIDs []int
+	}{
+		{name: "case and accent insensitive", query: "ZOE", expectedIDs: []int{1, 2}},
+		{name: "accents in the query", query: "saldaña", expectedIDs: []int{1}},
+		{name: "exact matches rank before prefixes", query: "ada", expectedIDs: []int{3, 4}},
+		{name: "prefix", query: "lov", expectedIDs: []int{3}},
+		{name: "every word must match", query: "zoe kra", expectedIDs: []int{2}},
+		{name: "one typo", query: "hoper", expectedIDs: []int{5}},
+		{name: "transposed letters", query: "jonhson", expectedIDs: []int{6}},
+		{name: "two typos in a long word", query: "katherien", expectedIDs: []int{6}},
+		{name: "no typos in short words", query: "adu", expectedIDs: []int{}},
+		{name: "no match", query: "turing", expectedIDs: []int{}},
+		{name: "no words", query: " -- ", expectedIDs: []int{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Execute
+			hits := index.Search(test.query, 10)
+
+			// Assert
+			if ids := hitIDs(hits); !reflect.DeepEqual(ids, test.expectedIDs) {
+				t.Errorf("Hits of %q, expected: %v, got: %v", test.query, test.expectedIDs, ids)
+			}
+		})
+	}
+}
+
+func TestSearchRanksRareWordsAndHeavyFields(t *testing.T) {
+	// Setup
+	index := newIndex(map[int]string{1: "Ada Smith", 2: "Bob Smith", 3: "Carl Smith"})
+	index.Put(search.Document{ID: 4, Fields: map[string]string{"name": "Dee Jones", "email": "ada@example.com"}})
+
+	// Execute
+	hits := index.Search("ada", 10)
+	limited := index.Search("smith", 2)
+
+	// Assert
+	if ids := hitIDs(hits); !reflect.DeepEqual(ids, []int{1, 4}) {
+		t.Errorf("Hits, expected: the name match first, got: %v", ids)
+	}
+	if ids := hitIDs(limited); !reflect.DeepEqual(ids, []int{1, 2}) {
+		t.Errorf("Limited hits, expected: the first 2 by ID, got: %v", ids)
+	}
+}
+
+func TestSearchHighlights(t *testing.T) {
+	// Setup
+	index := newIndex(map[int]string{1: "Zoë <Bob> Saldaña"})
+
+	// Execute
+	hits := index.Search("zoe sald", 10)
+
+	// Assert
+	if len(hits) != 1 {
+		t.Fatalf("Hits, expected: 1, got: %d", len(hits))
+	}
+	expected := map[string]string{"name": "<mark>Zoë</mark> &lt;Bob&gt; <mark>Saldaña</mark>"}
+	if !reflect.DeepEqual(hits[0].Highlights, expected) {
+		t.Errorf("Highlights, expected: %v, got: %v", expected, hits[0].Highlights)
+	}
+}
+
+func TestPutReplacesAndDeleteRemoves(t *testing.T) {
+	// Setup
+	index := newIndex(map[int]string{1: "Ada Lovelace", 2: "Grace Hopper"})
+
+	// Execute
+	index.Put(search.Document{ID: 1, Fields: map[string]string{"name": "Ada King"}})
+	index.Delete(2)
+
+	// Assert
+	if hits := index.Search("lovelace", 10); len(hits) != 0 {
+		t.Errorf("Hits of the old name, expected: none, got: %v", hitIDs(hits))
+	}
+	if ids := hitIDs(index.Search("king", 10)); !reflect.DeepEqual(ids, []int{1}) {
+		t.Errorf("Hits of the new name, expected: [1], got: %v", ids)
+	}
+	if hits := index.Search("grace", 10); len(hits) != 0 || index.Len() != 1 {
+		t.Errorf("Deleted document, expected: gone, got: %v, %d documents", hitIDs(hits), index.Len())
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	// Setup
+	path := filepath.Join(t.TempDir(), "search", "users.idx")
+	index := newIndex(map[int]string{1: "Ada Lovelace", 2: "Grace Hopper"})
+	index.SetSeq(42)
+	loaded := newIndex(map[int]string{3: "Alan Turing"})
+
+	// Execute
+	missingErr := loaded.Load(path)
+	saveErr := index.Save(path)
+	loadErr := loaded.Load(path)
+
+	// Assert
+	if !os.IsNotExist(missingErr) {
+		t.Errorf("Error of a missing file, expected: not exist, got: %v", missingErr)
+	}
+	if saveErr != nil || loadErr != nil {
+		t.Fatalf("Errors, expected: none, got: %v, %v", saveErr, loadErr)
+	}
+	if loaded.Seq() != 42 || loaded.Len() != 2 {
+		t.Errorf("Loaded index, expected: seq 42 with 2 documents, got: %d, %d", loaded.Seq(), loaded.Len())
+	}
+	if ids := hitIDs(loaded.Search("hop", 10)); !reflect.DeepEqual(ids, []int{2}) {
+		t.Errorf("Hits, expected: [2], got: %v", ids)
+	}
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // registers GIF with image.Decode
+	"image/jpeg"
+	"image/png"
+	"log"
+
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/services/interfaces"
+)
+
+// avatarJPEGQuality is the quality of the thumbnails of JPEG avatars
+const avatarJPEGQuality = 85
+
+type (
+	// AvatarsServicer interface for avatar services
+	AvatarsServicer interface {
+		SetAvatar(userID int, body []byte) (*models.User, error)
+		GetAvatar(userID, size int) (*models.Blob, error)
+	}
+
+	// AvatarsService stores the avatars of users and their thumbnails in BlobStore and
+	// records their version with AvatarsPersister. Changes are published to
+	// EventPublisher when one is set.
+	AvatarsService struct {
+		Users            UsersServicer
+		AvatarsPersister interfaces.AvatarsPersister
+		BlobStore        interfaces.BlobStore
+		EventPublisher   interfaces.EventPublisher
+	}
+)
+
+// SetAvatar replaces the avatar of a user with the PNG, JPEG or GIF image body and
+// returns the updated user
+func (avatarsService *AvatarsService) SetAvatar(userID int, body []byte) (*models.User, error) {
+	if len(body) > models.AvatarMaxBytes {
+		return nil, errors.InvalidArgument{Message: fmt.Sprintf("Avatar cannot be larger than %d bytes", models.AvatarMaxBytes)}
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.InvalidArgument{Message: "Avatar must be a PNG, JPEG or GIF image"}
+	}
+	if config.Width > models.AvatarMaxDimension || config.Height > models.AvatarMaxDimension {
+		return nil, errors.InvalidArgument{Message: fmt.Sprintf("Avatar cannot be larger than %dx%d pixels", models.AvatarMaxDimension, models.AvatarMaxDimension)}
+	}
+	if _, err := avatarsService.Users.GetUser(userID); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.InvalidArgument{Message: "Avatar must be a PNG, JPEG or GIF image"}
+	}
+
+	if err := avatarsService.BlobStore.Put(avatarKey(userID, 0), &models.Blob{Body: body, ContentType: "image/" + format}); err != nil {
+		return nil, err
+	}
+	for _, size := range models.AvatarSizes {
+		blob, err := encodeThumbnail(img, format, size)
+		if err != nil {
+			return nil, err
+		}
+		if err := avatarsService.BlobStore.Put(avatarKey(userID, size), blob); err != nil {
+			return nil, err
+		}
+	}
+
+	sum := sha256.Sum256(body)
+	avatar := hex.EncodeToString(sum[:8])
+	if err := avatarsService.AvatarsPersister.SetUserAvatar(userID, avatar); err != nil {
+		return nil, err
+	}
+	if avatarsService.EventPublisher != nil {
+		avatarsService.EventPublisher.Publish(models.UserAvatarChanged{UserID: userID, Avatar: avatar})
+	}
+	return avatarsService.Users.GetUser(userID)
+}
+
+// GetAvatar returns the smallest thumbnail of the avatar of a user that is at least
+// size pixels wide, or the original image if size is 0 or larger than every thumbnail
+func (avatarsService *AvatarsService) GetAvatar(userID, size int) (*models.Blob, error) {
+	if size < 0 {
+		return nil, errors.InvalidArgument{Message: "Avatar size cannot be negative"}
+	}
+	user, err := avatarsService.Users.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Avatar == "" {
+		return nil, errors.NotFound{Message: fmt.Sprintf("User with ID %d has no avatar", userID)}
+	}
+	return avatarsService.BlobStore.Get(avatarKey(userID, avatarSize(size)))
+}
+
+// DeleteUserAvatar deletes the avatar images of deleted users. It should be subscribed
+// to the user domain events.
+func (avatarsService *AvatarsService) DeleteUserAvatar(event models.Event) {
+	deleted, ok := event.(models.UserDeleted)
+	if !ok {
+		return
+	}
+	keys := []string{avatarKey(deleted.UserID, 0)}
+	for _, size := range models.AvatarSizes {
+		keys = append(keys, avatarKey(deleted.UserID, size))
+	}
+	if err := avatarsService.BlobStore.Delete(keys...); err != nil {
+		log.Printf("avatars: failed to delete the avatar of user %d: %v", deleted.UserID, err)
+	}
+}
+
+// avatarSize returns the stored size that serves a request for size, 0 for the original
+func avatarSize(size int) int {
+	if size == 0 {
+		return 0
+	}
+	for _, stored := range models.AvatarSizes {
+		if stored >= size {
+			return stored
+		}
+	}
+	return 0
+}
+
+// avatarKey returns the blob key of the thumbnail of size of the avatar of a user, or of
+// the original image if size is 0
+func avatarKey(userID, size int) string {
+	if size == 0 {
+		return fmt.Sprintf("avatars/%d/original", userID)
+	}
+	return fmt.Sprintf("avatars/%d/%d", userID, size)
+}
+
+// encodeThumbnail returns the size by size thumbnail of img, whose format is format. JPEG
+// images keep their format; the others become PNG.
+func encodeThumbnail(img image.Image, format string, size int) (*models.Blob, error) {
+	scaled := thumbnail(img, size)
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		if err := jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: avatarJPEGQuality}); err != nil {
+			return nil, err
+		}
+		return &models.Blob{Body: buf.Bytes(), ContentType: "image/jpeg"}, nil
+	}
+	if err := png.Encode(&buf, scaled); err != nil {
+		return nil, err
+	}
+	return &models.Blob{Body: buf.Bytes(), ContentType: "image/png"}, nil
+}
+
+// thumbnail crops the largest centered square of img and scales it to size by size,
+// averaging the source pixels that fall into each thumbnail pixel
+func thumbnail(img image.Image, size int) *image.RGBA {
+	bounds := img.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	origin := image.Pt(bounds.Min.X+(bounds.Dx()-side)/2, bounds.Min.Y+(bounds.Dy()-side)/2)
+	square := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(square, square.Bounds(), img, origin, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		y0, y1 := span(y, side, size)
+		for x := 0; x < size; x++ {
+			x0, x1 := span(x, side, size)
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := square.RGBAAt(sx, sy)
+					r, g, b, a = r+uint32(c.R), g+uint32(c.G), b+uint32(c.B), a+uint32(c.A)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)})
+		}
+	}
+	return dst
+}
+
+// span returns the source pixels [from, to) of destination pixel i when scaling side
+// pixels to size, at least one
+func span(i, side, size int) (int, int) {
+	from, to := i*side/size, (i+1)*side/size
+	if to <= from {
+		to = from + 1
+	}
+	return from, to
+}
@@ -0,0 +1,219 @@
+package services_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"testing"
+
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/services"
+)
+
+/*
+	Test objects
+*/
+
+type mockAvatarsPersister struct {
+	avatars map[int]string
+}
+
+func (m *mockAvatarsPersister) SetUserAvatar(userID int, avatar string) error {
+	if m.avatars == nil {
+		m.avatars = map[int]string{}
+	}
+	m.avatars[userID] = avatar
+	return nil
+}
+
+type mockBlobStore struct {
+	blobs map[string]*models.Blob
+}
+
+func (m *mockBlobStore) Put(key string, blob *models.Blob) error {
+	if m.blobs == nil {
+		m.blobs = map[string]*models.Blob{}
+	}
+	m.blobs[key] = blob
+	return nil
+}
+
+func (m *mockBlobStore) Get(key string) (*models.Blob, error) {
+	blob, ok := m.blobs[key]
+	if !ok {
+		return nil, errors.NotFound{Message: "Blob " + key + " not found"}
+	}
+	return blob, nil
+}
+
+func (m *mockBlobStore) Delete(keys ...string) error {
+	for _, key := range keys {
+		delete(m.blobs, key)
+	}
+	return nil
+}
+
+// encodePNG returns a width by height PNG
+func encodePNG(t *testing.T, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func newAvatarsService(persister *mockAvatarsPersister, store *mockBlobStore, publisher *mockEventPublisher) *services.AvatarsService {
+	users := &services.UsersService{UsersPersister: &mockUserPersister{
+		mockGetUser: func(userID int) (*models.User, error) {
+			if userID != 1 {
+				return nil, errors.NotFound{Message: "User not found"}
+			}
+			return &models.User{ID: 1, Name: "Ada", Avatar: persister.avatars[1]}, nil
+		},
+	}}
+	return &services.AvatarsService{Users: users, AvatarsPersister: persister, BlobStore: store, EventPublisher: publisher}
+}
+
+/*
+	Test functions
+*/
+
+func TestSetAvatarInvalidImage(t *testing.T) {
+	// Setup
+	persister, store := &mockAvatarsPersister{}, &mockBlobStore{}
+	service := newAvatarsService(persister, store, &mockEventPublisher{})
+
+	// Execute
+	_, err := service.SetAvatar(1, []byte("GIF89a, but not really"))
+
+	// Assert
+	if _, ok := err.(errors.InvalidArgument); !ok {
+		t.Errorf("Error, expected: InvalidArgument, got: %v", err)
+	}
+	if len(store.blobs) != 0 || len(persister.avatars) != 0 {
+		t.Errorf("Stored, expected: nothing, got: %d blobs, %v", len(store.blobs), persister.avatars)
+	}
+}
+
+func TestSetAvatarStoresThumbnails(t *testing.T) {
+	// Setup
+	persister, store, publisher := &mockAvatarsPersister{}, &mockBlobStore{}, &mockEventPublisher{}
+	service := newAvatarsService(persister, store, publisher)
+	body := encodePNG(t, 300, 200)
+
+	// Execute
+	user, err := service.SetAvatar(1, body)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("SetAvatar returned error: %v", err)
+	}
+	if len(user.Avatar) != 16 || persister.avatars[1] != user.Avatar {
+		t.Errorf("Avatar, expected: a 16 character version, got: %q, stored: %q", user.Avatar, persister.avatars[1])
+	}
+	if !bytes.Equal(store.blobs["avatars/1/original"].Body, body) {
+		t.Errorf("Original, expected: the uploaded image")
+	}
+	for _, size := range models.AvatarSizes {
+		blob := store.blobs["avatars/1/"+strconv.Itoa(size)]
+		if blob == nil || blob.ContentType != "image/png" {
+			t.Fatalf("Thumbnail %d, expected: a PNG, got: %v", size, blob)
+		}
+		config, err := png.DecodeConfig(bytes.NewReader(blob.Body))
+		if err != nil || config.Width != size || config.Height != size {
+			t.Errorf("Thumbnail %d, expected: %dx%d, got: %dx%d, %v", size, size, size, config.Width, config.Height, err)
+		}
+	}
+	if len(publisher.published) != 1 || publisher.published[0] != (models.UserAvatarChanged{UserID: 1, Avatar: user.Avatar}) {
+		t.Errorf("Published events, expected: UserAvatarChanged, got: %v", publisher.published)
+	}
+}
+
+func TestSetAvatarUserNotFound(t *testing.T) {
+	// Setup
+	store := &mockBlobStore{}
+	service := newAvatarsService(&mockAvatarsPersister{}, store, &mockEventPublisher{})
+
+	// Execute
+	_, err := service.SetAvatar(2, encodePNG(t, 10, 10))
+
+	// Assert
+	if _, ok := err.(errors.NotFound); !ok {
+		t.Errorf("Error, expected: NotFound, got: %v", err)
+	}
+	if len(store.blobs) != 0 {
+		t.Errorf("Stored blobs, expected: 0, got: %d", len(store.blobs))
+	}
+}
+
+func TestGetAvatarSize(t *testing.T) {
+	tests := []struct {
+		size        int
+		expectedKey string
+	}{
+		{size: 0, expectedKey: "avatars/1/original"},
+		{size: 32, expectedKey: "avatars/1/64"},
+		{size: 64, expectedKey: "avatars/1/64"},
+		{size: 100, expectedKey: "avatars/1/128"},
+		{size: 256, expectedKey: "avatars/1/256"},
+		{size: 512, expectedKey: "avatars/1/original"},
+	}
+	for _, test := range tests {
+		t.Run(test.expectedKey, func(t *testing.T) {
+			// Setup
+			store := &mockBlobStore{}
+			for _, key := range []string{"avatars/1/original", "avatars/1/64", "avatars/1/128", "avatars/1/256"} {
+				store.Put(key, &models.Blob{Body: []byte(key)})
+			}
+			service := newAvatarsService(&mockAvatarsPersister{avatars: map[int]string{1: "0123456789abcdef"}}, store, &mockEventPublisher{})
+
+			// Execute
+			blob, err := service.GetAvatar(1, test.size)
+
+			// Assert
+			if err != nil || string(blob.Body) != test.expectedKey {
+				t.Errorf("Avatar of size %d, expected: %s, got: %v, %v", test.size, test.expectedKey, blob, err)
+			}
+		})
+	}
+}
+
+func TestGetAvatarNone(t *testing.T) {
+	// Setup
+	service := newAvatarsService(&mockAvatarsPersister{}, &mockBlobStore{}, &mockEventPublisher{})
+
+	// Execute
+	_, err := service.GetAvatar(1, 0)
+
+	// Assert
+	if _, ok := err.(errors.NotFound); !ok || err.Error() != "User with ID 1 has no avatar" {
+		t.Errorf("Error, expected: User with ID 1 has no avatar, got: %v", err)
+	}
+}
+
+func TestDeleteUserAvatar(t *testing.T) {
+	// Setup
+	store := &mockBlobStore{}
+	store.Put("avatars/1/original", &models.Blob{})
+	store.Put("avatars/1/64", &models.Blob{})
+	store.Put("avatars/2/original", &models.Blob{})
+	service := newAvatarsService(&mockAvatarsPersister{}, store, &mockEventPublisher{})
+
+	// Execute
+	service.DeleteUserAvatar(models.UserRenamed{UserID: 2})
+	service.DeleteUserAvatar(models.UserDeleted{UserID: 1})
+
+	// Assert
+	if len(store.blobs) != 1 || store.blobs["avatars/2/original"] == nil {
+		t.Errorf("Blobs, expected: only those of user 2, got: %v", store.blobs)
+	}
+}
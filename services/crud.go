@@ -0,0 +1,159 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/services/interfaces"
+)
+
+type (
+	// Servicer interface for services of entities of type M with IDs of type ID
+	Servicer[M any, ID comparable] interface {
+		Get(id ID) (*M, error)
+		GetMany(ids []ID) ([]*M, error)
+		List(options models.ListOptions[ID]) ([]*M, error)
+		Create(m *M) (*M, error)
+		Update(m *M) (*M, error)
+		Delete(id ID) error
+	}
+
+	// Service provides the operations of Servicer over a Persister. Entities are
+	// checked with Validate before they are written, and the On hooks are called after
+	// successful writes, such as to publish events.
+	Service[M any, ID comparable] struct {
+		Persister interfaces.Persister[M, ID]
+		// Name and Plural name the entity in errors, such as "User" and "users"
+		Name   string
+		Plural string
+		// ID returns the ID of m
+		ID func(m *M) ID
+		// Validate returns an InvalidArgument error if m cannot be created or updated. It
+		// is called with nil entities too.
+		Validate func(m *M) error
+		// DefaultLimit and MaxLimit bound the Limit of List. MaxBatch bounds the IDs of
+		// GetMany. Zero means no bound.
+		DefaultLimit int
+		MaxLimit     int
+		MaxBatch     int
+
+		OnCreate func(created *M)
+		OnUpdate func(old, updated *M)
+		OnDelete func(deleted *M)
+	}
+)
+
+// Get by ID
+func (service *Service[M, ID]) Get(id ID) (*M, error) {
+	m, err := service.Persister.Get(id)
+	if err != nil {
+		if _, ok := err.(errors.NotFound); ok {
+			return nil, service.notFound(id)
+		}
+		return nil, err
+	}
+	if m == nil {
+		return nil, service.notFound(id)
+	}
+	return m, nil
+}
+
+// GetMany by ID. Entities are returned in the order of their first occurrence in ids;
+// IDs without an entity are skipped.
+func (service *Service[M, ID]) GetMany(ids []ID) ([]*M, error) {
+	if service.MaxBatch > 0 && len(ids) > service.MaxBatch {
+		return nil, errors.InvalidArgument{Message: fmt.Sprintf("Cannot get more than %d %s at once", service.MaxBatch, service.Plural)}
+	}
+	if len(ids) == 0 {
+		return []*M{}, nil
+	}
+	found, err := service.Persister.GetMany(ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[ID]*M, len(found))
+	for _, m := range found {
+		byID[service.ID(m)] = m
+	}
+	entities := make([]*M, 0, len(found))
+	for _, id := range ids {
+		if m, ok := byID[id]; ok {
+			entities = append(entities, m)
+			delete(byID, id)
+		}
+	}
+	return entities, nil
+}
+
+// List returns a page of entities ordered by ID
+func (service *Service[M, ID]) List(options models.ListOptions[ID]) ([]*M, error) {
+	if options.Limit <= 0 {
+		options.Limit = service.DefaultLimit
+	}
+	if service.MaxLimit > 0 && options.Limit > service.MaxLimit {
+		options.Limit = service.MaxLimit
+	}
+	return service.Persister.List(options)
+}
+
+// Create and return the created entity
+func (service *Service[M, ID]) Create(m *M) (*M, error) {
+	if err := service.validate(m); err != nil {
+		return nil, err
+	}
+	created, err := service.Persister.Create(m)
+	if err != nil {
+		return nil, err
+	}
+	if service.OnCreate != nil {
+		service.OnCreate(created)
+	}
+	return created, nil
+}
+
+// Update an existing entity and return the result
+func (service *Service[M, ID]) Update(m *M) (*M, error) {
+	if err := service.validate(m); err != nil {
+		return nil, err
+	}
+	// The previous entity is read by the update under its lock, so that the events of
+	// concurrent updates follow each other
+	old, updated, err := service.Persister.Update(m)
+	if err != nil {
+		if _, ok := err.(errors.NotFound); ok {
+			return nil, service.notFound(service.ID(m))
+		}
+		return nil, err
+	}
+	if service.OnUpdate != nil {
+		service.OnUpdate(old, updated)
+	}
+	return updated, nil
+}
+
+// Delete by ID
+func (service *Service[M, ID]) Delete(id ID) error {
+	deleted, err := service.Persister.Delete(id)
+	if err != nil {
+		return err
+	}
+	if service.OnDelete != nil {
+		service.OnDelete(deleted)
+	}
+	return nil
+}
+
+func (service *Service[M, ID]) validate(m *M) error {
+	if service.Validate != nil {
+		return service.Validate(m)
+	}
+	if m == nil {
+		return errors.InvalidArgument{Message: service.Name + " cannot be nil"}
+	}
+	return nil
+}
+
+func (service *Service[M, ID]) notFound(id ID) error {
+	return errors.NotFound{Message: fmt.Sprintf("%s with ID %v not found", service.Name, id)}
+}
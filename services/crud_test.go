@@ -0,0 +1,149 @@
+package services_test
+
+import (
+	"testing"
+
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/services"
+)
+
+/*
+	Test objects
+*/
+
+type widget struct {
+	ID   int
+	Name string
+}
+
+type mockWidgetPersister struct {
+	mockGetMany func(ids []int) ([]*widget, error)
+	mockCreate  func(w *widget) (*widget, error)
+	mockList    func(options models.ListOptions[int]) ([]*widget, error)
+}
+
+func (m *mockWidgetPersister) Get(id int) (*widget, error) { return nil, nil }
+
+func (m *mockWidgetPersister) GetMany(ids []int) ([]*widget, error) {
+	if m.mockGetMany != nil {
+		return m.mockGetMany(ids)
+	}
+	return nil, nil
+}
+
+func (m *mockWidgetPersister) List(options models.ListOptions[int]) ([]*widget, error) {
+	if m.mockList != nil {
+		return m.mockList(options)
+	}
+	return nil, nil
+}
+
+func (m *mockWidgetPersister) Create(w *widget) (*widget, error) {
+	if m.mockCreate != nil {
+		return m.mockCreate(w)
+	}
+	return nil, nil
+}
+
+func (m *mockWidgetPersister) Update(w *widget) (old, updated *widget, err error) {
+	return nil, nil, nil
+}
+
+func (m *mockWidgetPersister) Delete(id int) (deleted *widget, err error) { return &widget{}, nil }
+
+/*
+	Test functions
+*/
+
+func TestServiceGetNotFound(t *testing.T) {
+	// Setup
+	service := services.Service[widget, int]{Persister: &mockWidgetPersister{}, Name: "Widget"}
+
+	// Execute
+	_, err := service.Get(7)
+
+	// Assert
+	if _, ok := err.(errors.NotFound); !ok || err.Error() != "Widget with ID 7 not found" {
+		t.Errorf("Error, expected: NotFound Widget with ID 7 not found, got: %v", err)
+	}
+}
+
+func TestServiceGetManyPreservesOrder(t *testing.T) {
+	// Setup
+	service := services.Service[widget, int]{
+		Persister: &mockWidgetPersister{
+			mockGetMany: func(ids []int) ([]*widget, error) {
+				return []*widget{{ID: 1}, {ID: 3}}, nil
+			},
+		},
+		ID: func(w *widget) int { return w.ID },
+	}
+
+	// Execute
+	widgets, err := service.GetMany([]int{3, 2, 1, 3})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("GetMany returned error: %s", err.Error())
+	}
+	if len(widgets) != 2 || widgets[0].ID != 3 || widgets[1].ID != 1 {
+		t.Errorf("Widgets, expected IDs: 3, 1, got: %v", widgets)
+	}
+}
+
+func TestServiceCreateValidatesAndNotifies(t *testing.T) {
+	// Setup
+	created := 0
+	service := services.Service[widget, int]{
+		Persister: &mockWidgetPersister{
+			mockCreate: func(w *widget) (*widget, error) { return &widget{ID: 1, Name: w.Name}, nil },
+		},
+		Validate: func(w *widget) error {
+			if w == nil || w.Name == "" {
+				return errors.InvalidArgument{Message: "Widget name cannot be empty"}
+			}
+			return nil
+		},
+		OnCreate: func(w *widget) { created++ },
+	}
+
+	// Execute
+	_, invalidErr := service.Create(&widget{})
+	_, err := service.Create(&widget{Name: "Sprocket"})
+
+	// Assert
+	if _, ok := invalidErr.(errors.InvalidArgument); !ok {
+		t.Errorf("Error, expected: InvalidArgument, got: %v", invalidErr)
+	}
+	if err != nil {
+		t.Errorf("Create returned error: %s", err.Error())
+	}
+	if created != 1 {
+		t.Errorf("OnCreate calls, expected: %d, got: %d", 1, created)
+	}
+}
+
+func TestServiceListBoundsLimit(t *testing.T) {
+	// Setup
+	var limits []int
+	service := services.Service[widget, int]{
+		Persister: &mockWidgetPersister{
+			mockList: func(options models.ListOptions[int]) ([]*widget, error) {
+				limits = append(limits, options.Limit)
+				return nil, nil
+			},
+		},
+		DefaultLimit: 10,
+		MaxLimit:     20,
+	}
+
+	// Execute
+	service.List(models.ListOptions[int]{})
+	service.List(models.ListOptions[int]{Limit: 50})
+
+	// Assert
+	if len(limits) != 2 || limits[0] != 10 || limits[1] != 20 {
+		t.Errorf("Limits, expected: [10 20], got: %v", limits)
+	}
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/services/interfaces"
+)
+
+// List limits of group memberships
+const (
+	DefaultListGroupMembersLimit = 50
+	MaxListGroupMembersLimit     = 1000
+)
+
+type (
+	// GroupMembersServicer interface for group membership services
+	GroupMembersServicer interface {
+		AddGroupMember(member *models.GroupMember) (*models.GroupMember, error)
+		RemoveGroupMember(groupID, userID int) error
+		ListGroupMembers(groupID int, options models.ListOptions[int]) ([]*models.GroupMember, error)
+		ListUserGroups(userID int, options models.ListOptions[int]) ([]*models.GroupMember, error)
+	}
+
+	// GroupMembersService provides group membership services. Groups and Users tell
+	// empty lists apart from missing groups and users.
+	GroupMembersService struct {
+		GroupMembersPersister interfaces.GroupMembersPersister
+		Groups                GroupsServicer
+		Users                 UsersServicer
+	}
+)
+
+// AddGroupMember adds a user to a group with a role, or changes the role of an
+// existing member
+func (groupMembersService *GroupMembersService) AddGroupMember(member *models.GroupMember) (*models.GroupMember, error) {
+	if member == nil {
+		return nil, errors.InvalidArgument{Message: "Group member cannot be nil"}
+	}
+	if !models.ValidGroupRole(member.Role) {
+		return nil, errors.InvalidArgument{Message: "Group member role must be owner or member"}
+	}
+	return groupMembersService.GroupMembersPersister.AddGroupMember(member)
+}
+
+// RemoveGroupMember removes a user from a group
+func (groupMembersService *GroupMembersService) RemoveGroupMember(groupID, userID int) error {
+	return groupMembersService.GroupMembersPersister.RemoveGroupMember(groupID, userID)
+}
+
+// ListGroupMembers returns a page of the members of a group ordered by user ID
+func (groupMembersService *GroupMembersService) ListGroupMembers(groupID int, options models.ListOptions[int]) ([]*models.GroupMember, error) {
+	options, err := boundGroupMembersOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := groupMembersService.Groups.Get(groupID); err != nil {
+		return nil, err
+	}
+	return groupMembersService.GroupMembersPersister.ListGroupMembers(groupID, options)
+}
+
+// ListUserGroups returns a page of the memberships of a user ordered by group ID
+func (groupMembersService *GroupMembersService) ListUserGroups(userID int, options models.ListOptions[int]) ([]*models.GroupMember, error) {
+	options, err := boundGroupMembersOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := groupMembersService.Users.GetUser(userID); err != nil {
+		return nil, err
+	}
+	return groupMembersService.GroupMembersPersister.ListUserGroups(userID, options)
+}
+
+// boundGroupMembersOptions checks options and bounds their limit
+func boundGroupMembersOptions(options models.ListOptions[int]) (models.ListOptions[int], error) {
+	if options.AfterID < 0 {
+		return options, errors.InvalidArgument{Message: "AfterID cannot be negative"}
+	}
+	if options.Limit <= 0 {
+		options.Limit = DefaultListGroupMembersLimit
+	}
+	if options.Limit > MaxListGroupMembersLimit {
+		options.Limit = MaxListGroupMembersLimit
+	}
+	return options, nil
+}
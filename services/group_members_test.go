@@ -0,0 +1,125 @@
+package services_test
+
+import (
+	"testing"
+
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/services"
+)
+
+/*
+	Test objects
+*/
+
+type mockGroupMembersPersister struct {
+	mockAddGroupMember   func(member *models.GroupMember) (*models.GroupMember, error)
+	mockListGroupMembers func(groupID int, options models.ListOptions[int]) ([]*models.GroupMember, error)
+	mockListUserGroups   func(userID int, options models.ListOptions[int]) ([]*models.GroupMember, error)
+}
+
+func (m *mockGroupMembersPersister) AddGroupMember(member *models.GroupMember) (*models.GroupMember, error) {
+	if m.mockAddGroupMember != nil {
+		return m.mockAddGroupMember(member)
+	}
+	return nil, nil
+}
+
+func (m *mockGroupMembersPersister) RemoveGroupMember(groupID, userID int) error { return nil }
+
+func (m *mockGroupMembersPersister) ListGroupMembers(groupID int, options models.ListOptions[int]) ([]*models.GroupMember, error) {
+	if m.mockListGroupMembers != nil {
+		return m.mockListGroupMembers(groupID, options)
+	}
+	return nil, nil
+}
+
+func (m *mockGroupMembersPersister) ListUserGroups(userID int, options models.ListOptions[int]) ([]*models.GroupMember, error) {
+	if m.mockListUserGroups != nil {
+		return m.mockListUserGroups(userID, options)
+	}
+	return nil, nil
+}
+
+/*
+	Test functions
+*/
+
+func TestAddGroupMemberInvalidRole(t *testing.T) {
+	// Setup
+	added := 0
+	service := services.GroupMembersService{
+		GroupMembersPersister: &mockGroupMembersPersister{
+			mockAddGroupMember: func(member *models.GroupMember) (*models.GroupMember, error) {
+				added++
+				return member, nil
+			},
+		},
+	}
+
+	// Execute
+	_, invalidErr := service.AddGroupMember(&models.GroupMember{GroupID: 1, UserID: 2, Role: "admin"})
+	_, err := service.AddGroupMember(&models.GroupMember{GroupID: 1, UserID: 2, Role: models.GroupRoleOwner})
+
+	// Assert
+	if _, ok := invalidErr.(errors.InvalidArgument); !ok {
+		t.Errorf("Error, expected: InvalidArgument, got: %v", invalidErr)
+	}
+	if err != nil || added != 1 {
+		t.Errorf("AddGroupMember, expected: 1 member added, got: %d, %v", added, err)
+	}
+}
+
+func TestListGroupMembersGroupNotFound(t *testing.T) {
+	// Setup
+	listed := false
+	service := services.GroupMembersService{
+		GroupMembersPersister: &mockGroupMembersPersister{
+			mockListGroupMembers: func(groupID int, options models.ListOptions[int]) ([]*models.GroupMember, error) {
+				listed = true
+				return nil, nil
+			},
+		},
+		Groups: &services.GroupsService{GroupsPersister: &mockGroupsPersister{}},
+	}
+
+	// Execute
+	_, err := service.ListGroupMembers(7, models.ListOptions[int]{})
+
+	// Assert
+	if _, ok := err.(errors.NotFound); !ok || err.Error() != "Group with ID 7 not found" {
+		t.Errorf("Error, expected: NotFound Group with ID 7 not found, got: %v", err)
+	}
+	if listed {
+		t.Errorf("ListGroupMembers, expected: no query for a missing group")
+	}
+}
+
+func TestListUserGroupsBoundsLimit(t *testing.T) {
+	// Setup
+	var limits []int
+	service := services.GroupMembersService{
+		GroupMembersPersister: &mockGroupMembersPersister{
+			mockListUserGroups: func(userID int, options models.ListOptions[int]) ([]*models.GroupMember, error) {
+				limits = append(limits, options.Limit)
+				return nil, nil
+			},
+		},
+		Users: &services.UsersService{UsersPersister: &mockUserPersister{
+			mockGetUser: func(userID int) (*models.User, error) { return &models.User{ID: userID, Name: "Bob"}, nil },
+		}},
+	}
+
+	// Execute
+	service.ListUserGroups(2, models.ListOptions[int]{})
+	service.ListUserGroups(2, models.ListOptions[int]{Limit: 5000})
+	_, negativeErr := service.ListUserGroups(2, models.ListOptions[int]{AfterID: -1})
+
+	// Assert
+	if len(limits) != 2 || limits[0] != services.DefaultListGroupMembersLimit || limits[1] != services.MaxListGroupMembersLimit {
+		t.Errorf("Limits, expected: [%d %d], got: %v", services.DefaultListGroupMembersLimit, services.MaxListGroupMembersLimit, limits)
+	}
+	if _, ok := negativeErr.(errors.InvalidArgument); !ok {
+		t.Errorf("Error, expected: InvalidArgument, got: %v", negativeErr)
+	}
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/services/interfaces"
+)
+
+// List and batch limits of groups
+const (
+	DefaultListGroupsLimit = 50
+	MaxListGroupsLimit     = 1000
+	MaxGetGroupsBatch      = 100
+)
+
+type (
+	// GroupsServicer interface for group services
+	GroupsServicer interface {
+		Servicer[models.Group, int]
+	}
+
+	// GroupsService provides group services
+	GroupsService struct {
+		GroupsPersister interfaces.GroupsPersister
+	}
+)
+
+// Get by ID
+func (groupsService *GroupsService) Get(groupID int) (*models.Group, error) {
+	return groupsService.crud().Get(groupID)
+}
+
+// GetMany by ID. Groups are returned in the order of their first occurrence in
+// groupIDs; IDs without a group are skipped.
+func (groupsService *GroupsService) GetMany(groupIDs []int) ([]*models.Group, error) {
+	return groupsService.crud().GetMany(groupIDs)
+}
+
+// List returns a page of groups ordered by ID
+func (groupsService *GroupsService) List(options models.ListOptions[int]) ([]*models.Group, error) {
+	if options.AfterID < 0 {
+		return nil, errors.InvalidArgument{Message: "AfterID cannot be negative"}
+	}
+	return groupsService.crud().List(options)
+}
+
+// Create and return the created group
+func (groupsService *GroupsService) Create(group *models.Group) (*models.Group, error) {
+	return groupsService.crud().Create(group)
+}
+
+// Update an existing group and return the result
+func (groupsService *GroupsService) Update(group *models.Group) (*models.Group, error) {
+	return groupsService.crud().Update(group)
+}
+
+// Delete by ID
+func (groupsService *GroupsService) Delete(groupID int) error {
+	return groupsService.crud().Delete(groupID)
+}
+
+// crud returns the generic service of groups
+func (groupsService *GroupsService) crud() *Service[models.Group, int] {
+	return &Service[models.Group, int]{
+		Persister:    groupsService.GroupsPersister,
+		Name:         "Group",
+		Plural:       "groups",
+		ID:           func(group *models.Group) int { return group.ID },
+		Validate:     validateGroup,
+		DefaultLimit: DefaultListGroupsLimit,
+		MaxLimit:     MaxListGroupsLimit,
+		MaxBatch:     MaxGetGroupsBatch,
+	}
+}
+
+func validateGroup(group *models.Group) error {
+	if group == nil {
+		return errors.InvalidArgument{Message: "Group cannot be nil"}
+	}
+	if group.Name == "" {
+		return errors.InvalidArgument{Message: "Group name is required"}
+	}
+	if utf8.RuneCountInString(group.Name) > models.GroupNameMaxLength {
+		return errors.InvalidArgument{Message: fmt.Sprintf("Group name cannot be longer than %d characters", models.GroupNameMaxLength)}
+	}
+	if utf8.RuneCountInString(group.Description) > models.GroupDescriptionMaxLength {
+		return errors.InvalidArgument{Message: fmt.Sprintf("Group description cannot be longer than %d characters", models.GroupDescriptionMaxLength)}
+	}
+	return nil
+}
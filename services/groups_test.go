@@ -0,0 +1,121 @@
+package services_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/services"
+)
+
+/*
+	Test objects
+*/
+
+type mockGroupsPersister struct {
+	mockGet    func(groupID int) (*models.Group, error)
+	mockCreate func(group *models.Group) (*models.Group, error)
+}
+
+func (m *mockGroupsPersister) Get(groupID int) (*models.Group, error) {
+	if m.mockGet != nil {
+		return m.mockGet(groupID)
+	}
+	return nil, nil
+}
+
+func (m *mockGroupsPersister) GetMany(groupIDs []int) ([]*models.Group, error) {
+	return nil, nil
+}
+
+func (m *mockGroupsPersister) List(options models.ListOptions[int]) ([]*models.Group, error) {
+	return nil, nil
+}
+
+func (m *mockGroupsPersister) Create(group *models.Group) (*models.Group, error) {
+	if m.mockCreate != nil {
+		return m.mockCreate(group)
+	}
+	return nil, nil
+}
+
+func (m *mockGroupsPersister) Update(group *models.Group) (old, updated *models.Group, err error) {
+	return group, group, nil
+}
+
+func (m *mockGroupsPersister) Delete(groupID int) (deleted *models.Group, err error) {
+	return &models.Group{ID: groupID}, nil
+}
+
+func validGroup() *models.Group {
+	return &models.Group{
+		Name:        "Sample",
+		Description: "Sample",
+	}
+}
+
+/*
+	Test functions
+*/
+
+func TestGroupsServiceCreate(t *testing.T) {
+	tests := []struct {
+		name          string
+		group         *models.Group
+		expectInvalid bool
+	}{
+		{name: "valid", group: validGroup()},
+		{name: "nil", group: nil, expectInvalid: true},
+		{name: "without name", group: func() *models.Group {
+			group := validGroup()
+			group.Name = ""
+			return group
+		}(), expectInvalid: true},
+		{name: "with a long name", group: func() *models.Group {
+			group := validGroup()
+			group.Name = strings.Repeat("x", 255+1)
+			return group
+		}(), expectInvalid: true},
+		{name: "with a long description", group: func() *models.Group {
+			group := validGroup()
+			group.Description = strings.Repeat("x", 1000+1)
+			return group
+		}(), expectInvalid: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Setup
+			service := services.GroupsService{
+				GroupsPersister: &mockGroupsPersister{
+					mockCreate: func(group *models.Group) (*models.Group, error) {
+						created := *group
+						created.ID = 1
+						return &created, nil
+					},
+				},
+			}
+
+			// Execute
+			_, err := service.Create(test.group)
+
+			// Assert
+			if _, ok := err.(errors.InvalidArgument); ok != test.expectInvalid {
+				t.Errorf("InvalidArgument, expected: %t, got: %v", test.expectInvalid, err)
+			}
+		})
+	}
+}
+
+func TestGroupsServiceGetNotFound(t *testing.T) {
+	// Setup
+	service := services.GroupsService{GroupsPersister: &mockGroupsPersister{}}
+
+	// Execute
+	_, err := service.Get(1)
+
+	// Assert
+	if _, ok := err.(errors.NotFound); !ok {
+		t.Errorf("Error, expected: NotFound, got: %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+package interfaces
+
+import "github.com/jordantipton/golang-restful-webservice/models"
+
+type (
+	// GroupsPersister interface for group repositories
+	GroupsPersister interface {
+		Persister[models.Group, int]
+	}
+
+	// GroupMembersPersister interface for group membership repositories. Memberships
+	// are listed in pages ordered by the ID of the other side of the relationship.
+	GroupMembersPersister interface {
+		AddGroupMember(member *models.GroupMember) (*models.GroupMember, error)
+		RemoveGroupMember(groupID, userID int) error
+		ListGroupMembers(groupID int, options models.ListOptions[int]) ([]*models.GroupMember, error)
+		ListUserGroups(userID int, options models.ListOptions[int]) ([]*models.GroupMember, error)
+	}
+)
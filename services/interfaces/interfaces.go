@@ -3,9 +3,63 @@ package interfaces
 import "github.com/jordantipton/golang-restful-webservice/models"
 
 type (
-	// UsersPersister interface for user repositories
+	// Persister interface for repositories of entities of type M with IDs of type ID.
+	// Update returns the entity as it was locked by the update along with the stored
+	// entity, and Delete the entity as it was locked by the delete.
+	Persister[M any, ID comparable] interface {
+		Get(id ID) (*M, error)
+		GetMany(ids []ID) ([]*M, error)
+		List(options models.ListOptions[ID]) ([]*M, error)
+		Create(m *M) (*M, error)
+		Update(m *M) (old, updated *M, err error)
+		Delete(id ID) (deleted *M, err error)
+	}
+
+	// UsersPersister interface for user repositories. UpdateUser and RenameUser return
+	// the user as it was locked by the update along with the stored user, and
+	// DeleteUser the user as it was locked by the delete.
 	UsersPersister interface {
 		GetUser(userID int) (*models.User, error)
+		GetUsers(userIDs []int) ([]*models.User, error)
+		ListUsers(options models.UserListOptions) ([]*models.User, error)
 		CreateUser(user *models.User) (*models.User, error)
+		UpdateUser(user *models.User) (old, updated *models.User, err error)
+		RenameUser(userID int, name string) (old, updated *models.User, err error)
+		DeleteUser(userID int) (deleted *models.User, err error)
+		ImportUsers(users []*models.User, options models.ImportOptions) ([]models.ImportResult, error)
+		ExportUsers(filter models.UserFilter, fn func(user *models.User) error) error
+	}
+
+	// AvatarsPersister interface for repositories of the avatar versions of users
+	AvatarsPersister interface {
+		SetUserAvatar(userID int, avatar string) error
+	}
+
+	// BlobStore interface for stores of binary objects such as avatar images. Get
+	// returns NotFound for missing keys and Delete ignores them. The ETag of blobs
+	// passed to Put is ignored; stores derive it from the content.
+	BlobStore interface {
+		Put(key string, blob *models.Blob) error
+		Get(key string) (*models.Blob, error)
+		Delete(keys ...string) error
+	}
+
+	// UserEventsPersister interface for user event repositories
+	UserEventsPersister interface {
+		ListUserEvents(afterSeq int64, limit int) ([]*models.UserEvent, error)
+		GetLatestUserEventSeq() (int64, error)
+	}
+
+	// JobsPersister interface for job repositories
+	JobsPersister interface {
+		CreateJob(job *models.Job) (*models.Job, error)
+		GetJob(jobID int) (*models.Job, error)
+		UpdateJob(job *models.Job) error
+		ListJobsByStatus(statuses []string) ([]*models.Job, error)
+	}
+
+	// EventPublisher interface for domain event buses
+	EventPublisher interface {
+		Publish(event models.Event)
 	}
 )
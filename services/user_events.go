@@ -0,0 +1,39 @@
+package services
+
+import (
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/services/interfaces"
+)
+
+// MaxUserEventsLimit is the largest number of events returned by a single ListUserEvents call
+const MaxUserEventsLimit = 1000
+
+type (
+	// UserEventsServicer interface for user event services
+	UserEventsServicer interface {
+		ListUserEvents(afterSeq int64, limit int) ([]*models.UserEvent, error)
+		GetLatestUserEventSeq() (int64, error)
+	}
+
+	// UserEventsService provides the user change feed
+	UserEventsService struct {
+		UserEventsPersister interfaces.UserEventsPersister
+	}
+)
+
+// ListUserEvents returns events recorded after afterSeq in sequence order
+func (userEventsService *UserEventsService) ListUserEvents(afterSeq int64, limit int) ([]*models.UserEvent, error) {
+	if afterSeq < 0 {
+		return nil, errors.InvalidArgument{Message: "Event sequence cannot be negative"}
+	}
+	if limit <= 0 || limit > MaxUserEventsLimit {
+		limit = MaxUserEventsLimit
+	}
+	return userEventsService.UserEventsPersister.ListUserEvents(afterSeq, limit)
+}
+
+// GetLatestUserEventSeq returns the sequence number of the most recent event, or 0 if there are none
+func (userEventsService *UserEventsService) GetLatestUserEventSeq() (int64, error) {
+	return userEventsService.UserEventsPersister.GetLatestUserEventSeq()
+}
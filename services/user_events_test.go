@@ -0,0 +1,80 @@
+package services_test
+
+import (
+	"testing"
+
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/services"
+)
+
+/*
+	Test objects
+*/
+
+type mockUserEventsPersister struct {
+	mockListUserEvents        func(afterSeq int64, limit int) ([]*models.UserEvent, error)
+	mockGetLatestUserEventSeq func() (int64, error)
+}
+
+func (m *mockUserEventsPersister) ListUserEvents(afterSeq int64, limit int) ([]*models.UserEvent, error) {
+	if m.mockListUserEvents != nil {
+		return m.mockListUserEvents(afterSeq, limit)
+	}
+	return nil, nil
+}
+
+func (m *mockUserEventsPersister) GetLatestUserEventSeq() (int64, error) {
+	if m.mockGetLatestUserEventSeq != nil {
+		return m.mockGetLatestUserEventSeq()
+	}
+	return 0, nil
+}
+
+/*
+	Test functions
+*/
+
+func TestListUserEvents(t *testing.T) {
+	// Setup
+	var requestedSeq int64
+	var requestedLimit int
+	mockUserEventsPersister := mockUserEventsPersister{
+		mockListUserEvents: func(afterSeq int64, limit int) ([]*models.UserEvent, error) {
+			requestedSeq, requestedLimit = afterSeq, limit
+			return []*models.UserEvent{{Seq: afterSeq + 1, Type: models.UserEventCreated, UserID: 1, Name: "Name"}}, nil
+		},
+	}
+
+	userEventsService := services.UserEventsService{UserEventsPersister: &mockUserEventsPersister}
+
+	// Execute
+	events, err := userEventsService.ListUserEvents(41, 0)
+
+	// Assert
+	if err != nil {
+		t.Errorf("ListUserEvents returned error: %s", err.Error())
+	}
+	if requestedSeq != 41 {
+		t.Errorf("afterSeq, expected: %d, got: %d", 41, requestedSeq)
+	}
+	if requestedLimit != services.MaxUserEventsLimit {
+		t.Errorf("limit, expected: %d, got: %d", services.MaxUserEventsLimit, requestedLimit)
+	}
+	if len(events) != 1 || events[0].Seq != 42 {
+		t.Errorf("Events, expected single event with seq 42, got: %v", events)
+	}
+}
+
+func TestListUserEventsNegativeSeq(t *testing.T) {
+	// Setup
+	userEventsService := services.UserEventsService{UserEventsPersister: &mockUserEventsPersister{}}
+
+	// Execute
+	_, err := userEventsService.ListUserEvents(-1, 10)
+
+	// Assert
+	if _, ok := err.(errors.InvalidArgument); !ok {
+		t.Errorf("Error, expected: InvalidArgument, got: %v", err)
+	}
+}
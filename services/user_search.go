@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/search"
+)
+
+// Search limits
+const (
+	DefaultSearchUsersLimit = 20
+	MaxSearchUsersLimit     = MaxGetUsersBatch
+	MaxSearchQueryLength    = 256
+)
+
+// UserSearchFields are the fields of users in the search index. Names weigh more than
+// emails, so that a query matching both ranks the name first. New fields need a weight
+// here and a value in userDocument.
+var UserSearchFields = []search.Field{{Name: "name", Weight: 2}, {Name: "email", Weight: 1}}
+
+type (
+	// UserSearchServicer interface for user search services
+	UserSearchServicer interface {
+		SearchUsers(query string, limit int) ([]*models.UserSearchHit, error)
+		RebuildUserSearchIndex(ctx context.Context) (int, error)
+	}
+
+	// UserSearchService searches users in Index, whose Fields should be
+	// UserSearchFields. The index follows the user change feed of UserEvents, so it
+	// sees the changes of every instance and of imports. SnapshotPath, if set, is where
+	// the index is saved, so that a restart only catches up with the feed instead of
+	// rebuilding.
+	UserSearchService struct {
+		Index        *search.Index
+		Users        UsersServicer
+		UserEvents   UserEventsServicer
+		SnapshotPath string
+
+		// mu serializes the updates of the index
+		mu sync.Mutex
+	}
+)
+
+// SearchUsers returns the limit users that best match query, best first
+func (userSearchService *UserSearchService) SearchUsers(query string, limit int) ([]*models.UserSearchHit, error) {
+	if len(search.Tokenize(query)) == 0 {
+		return nil, errors.InvalidArgument{Message: "Query must contain a letter or digit"}
+	}
+	if utf8.RuneCountInString(query) > MaxSearchQueryLength {
+		return nil, errors.InvalidArgument{Message: fmt.Sprintf("Query cannot be longer than %d characters", MaxSearchQueryLength)}
+	}
+	if limit <= 0 {
+		limit = DefaultSearchUsersLimit
+	}
+	if limit > MaxSearchUsersLimit {
+		limit = MaxSearchUsersLimit
+	}
+	hits := userSearchService.Index.Search(query, limit)
+	userIDs := make([]int, len(hits))
+	for i, hit := range hits {
+		userIDs[i] = hit.ID
+	}
+	users, err := userSearchService.Users.GetUsers(userIDs)
+	if err != nil {
+		return nil, err
+	}
+	usersByID := make(map[int]*models.User, len(users))
+	for _, user := range users {
+		usersByID[user.ID] = user
+	}
+	// Users deleted since the index last caught up are left out
+	results := []*models.UserSearchHit{}
+	for _, hit := range hits {
+		if user, ok := usersByID[hit.ID]; ok {
+			results = append(results, &models.UserSearchHit{User: user, Score: hit.Score, Highlights: hit.Highlights})
+		}
+	}
+	return results, nil
+}
+
+// RebuildUserSearchIndex indexes every user anew and returns their number. Searches
+// are served from the previous index until the new one is complete.
+func (userSearchService *UserSearchService) RebuildUserSearchIndex(ctx context.Context) (int, error) {
+	userSearchService.mu.Lock()
+	defer userSearchService.mu.Unlock()
+	// Changes made during the export are applied again afterwards, which is harmless
+	seq, err := userSearchService.UserEvents.GetLatestUserEventSeq()
+	if err != nil {
+		return 0, err
+	}
+	rebuilt := &search.Index{Fields: userSearchService.Index.Fields}
+	count := 0
+	err = userSearchService.Users.ExportUsers(models.UserFilter{}, func(user *models.User) error {
+		rebuilt.Put(userDocument(user.ID, user.Name, user.Email))
+		count++
+		return ctx.Err()
+	})
+	if err != nil {
+		return count, err
+	}
+	rebuilt.SetSeq(seq)
+	userSearchService.Index.Replace(rebuilt)
+	if err := userSearchService.catchUp(); err != nil {
+		return count, err
+	}
+	return count, userSearchService.save()
+}
+
+// CatchUp applies the user changes recorded since the index was last updated
+func (userSearchService *UserSearchService) CatchUp() error {
+	userSearchService.mu.Lock()
+	defer userSearchService.mu.Unlock()
+	return userSearchService.catchUp()
+}
+
+// Follow loads the saved index, or builds it if there is none, then catches up with
+// the user changes every interval until ctx is done. It saves the index before it
+// returns. Errors are logged and retried.
+func (userSearchService *UserSearchService) Follow(ctx context.Context, interval time.Duration) {
+	if err := userSearchService.load(); err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("user search: failed to load the index, rebuilding it: %v", err)
+		}
+		if _, err := userSearchService.RebuildUserSearchIndex(ctx); err != nil {
+			log.Printf("user search: failed to build the index: %v", err)
+		}
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := userSearchService.save(); err != nil {
+				log.Printf("user search: failed to save the index: %v", err)
+			}
+			return
+		case <-ticker.C:
+			if err := userSearchService.CatchUp(); err != nil {
+				log.Printf("user search: failed to catch up with user changes: %v", err)
+			}
+		}
+	}
+}
+
+func (userSearchService *UserSearchService) catchUp() error {
+	for {
+		events, err := userSearchService.UserEvents.ListUserEvents(userSearchService.Index.Seq(), MaxUserEventsLimit)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			switch event.Type {
+			case models.UserEventCreated, models.UserEventRenamed, models.UserEventEmailChanged:
+				userSearchService.Index.Put(userDocument(event.UserID, event.Name, event.Email))
+			case models.UserEventDeleted:
+				userSearchService.Index.Delete(event.UserID)
+			}
+		}
+		if len(events) > 0 {
+			userSearchService.Index.SetSeq(events[len(events)-1].Seq)
+		}
+		if len(events) < MaxUserEventsLimit {
+			return nil
+		}
+	}
+}
+
+func (userSearchService *UserSearchService) load() error {
+	if userSearchService.SnapshotPath == "" {
+		return os.ErrNotExist
+	}
+	userSearchService.mu.Lock()
+	defer userSearchService.mu.Unlock()
+	return userSearchService.Index.Load(userSearchService.SnapshotPath)
+}
+
+func (userSearchService *UserSearchService) save() error {
+	if userSearchService.SnapshotPath == "" {
+		return nil
+	}
+	return userSearchService.Index.Save(userSearchService.SnapshotPath)
+}
+
+// userDocument returns the search document of a user
+func userDocument(userID int, name, email string) search.Document {
+	return search.Document{ID: userID, Fields: map[string]string{"name": name, "email": email}}
+}
@@ -0,0 +1,195 @@
+package services_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/jordantipton/golang-restful-webservice/models"
+	"github.com/jordantipton/golang-restful-webservice/models/errors"
+	"github.com/jordantipton/golang-restful-webservice/search"
+	"github.com/jordantipton/golang-restful-webservice/services"
+)
+
+/*
+	Test objects
+*/
+
+// newUserSearchService returns a service over users and the change feed events
+func newUserSearchService(users map[int]string, events []*models.UserEvent) *services.UserSearchService {
+	persister := &mockUserPersister{
+		mockGetUsers: func(userIDs []int) ([]*models.User, error) {
+			found := []*models.User{}
+			for _, id := range userIDs {
+				if name, ok := users[id]; ok {
+					found = append(found, &models.User{ID: id, Name: name})
+				}
+			}
+			return found, nil
+		},
+		mockExportUsers: func(filter models.UserFilter, fn func(user *models.User) error) error {
+			for id := 1; id <= len(users); id++ {
+				if err := fn(&models.User{ID: id, Name: users[id]}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	eventsPersister := &mockUserEventsPersister{
+		mockListUserEvents: func(afterSeq int64, limit int) ([]*models.UserEvent, error) {
+			listed := []*models.UserEvent{}
+			for _, event := range events {
+				if event.Seq > afterSeq && len(listed) < limit {
+					listed = append(listed, event)
+				}
+			}
+			return listed, nil
+		},
+		mockGetLatestUserEventSeq: func() (int64, error) {
+			if len(events) == 0 {
+				return 0, nil
+			}
+			return events[len(events)-1].Seq, nil
+		},
+	}
+	return &services.UserSearchService{
+		Index:      &search.Index{Fields: services.UserSearchFields},
+		Users:      &services.UsersService{UsersPersister: persister},
+		UserEvents: &services.UserEventsService{UserEventsPersister: eventsPersister},
+	}
+}
+
+/*
+	Test functions
+*/
+
+func TestSearchUsers(t *testing.T) {
+	// Setup
+	service := newUserSearchService(map[int]string{1: "Ada Lovelace", 2: "Adam Smith", 3: "Grace Hopper"}, nil)
+	if _, err := service.RebuildUserSearchIndex(context.Background()); err != nil {
+		t.Fatalf("RebuildUserSearchIndex returned error: %v", err)
+	}
+
+	// Execute
+	hits, err := service.SearchUsers("ada", 0)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("SearchUsers returned error: %v", err)
+	}
+	if len(hits) != 2 || hits[0].User.ID != 1 || hits[1].User.ID != 2 {
+		t.Fatalf("Hits, expected: users 1 and 2, got: %v", hits)
+	}
+	if hits[0].Score <= hits[1].Score || hits[0].Highlights["name"] != "<mark>Ada</mark> Lovelace" {
+		t.Errorf("First hit, expected: the exact match, highlighted, got: %v, %v", hits[0].Score, hits[0].Highlights)
+	}
+}
+
+func TestSearchUsersInvalidQuery(t *testing.T) {
+	// Setup
+	service := newUserSearchService(nil, nil)
+
+	// Execute
+	_, err := service.SearchUsers(" @ ", 10)
+
+	// Assert
+	if _, ok := err.(errors.InvalidArgument); !ok {
+		t.Errorf("Error, expected: InvalidArgument, got: %v", err)
+	}
+}
+
+func TestSearchUsersFollowsChanges(t *testing.T) {
+	// Setup
+	users := map[int]string{1: "Ada Lovelace", 2: "Grace Hopper"}
+	events := []*models.UserEvent{
+		{Seq: 1, Type: models.UserEventCreated, UserID: 1, Name: "Ada Lovelace"},
+		{Seq: 2, Type: models.UserEventCreated, UserID: 2, Name: "Grace Hopper"},
+		{Seq: 3, Type: models.UserEventCreated, UserID: 3, Name: "Alan Turing"},
+		{Seq: 4, Type: models.UserEventRenamed, UserID: 1, Name: "Ada King"},
+		{Seq: 5, Type: models.UserEventDeleted, UserID: 3},
+	}
+	service := newUserSearchService(users, events)
+
+	// Execute
+	err := service.CatchUp()
+
+	// Assert
+	if err != nil {
+		t.Fatalf("CatchUp returned error: %v", err)
+	}
+	if service.Index.Seq() != 5 || service.Index.Len() != 2 {
+		t.Errorf("Index, expected: seq 5 with 2 users, got: %d, %d", service.Index.Seq(), service.Index.Len())
+	}
+	if hits, _ := service.SearchUsers("king", 10); len(hits) != 1 || hits[0].User.ID != 1 {
+		t.Errorf("Hits of the new name, expected: user 1, got: %v", hits)
+	}
+	if hits, _ := service.SearchUsers("turing", 10); len(hits) != 0 {
+		t.Errorf("Hits of the deleted user, expected: none, got: %v", hits)
+	}
+}
+
+func TestSearchUsersMatchesEmails(t *testing.T) {
+	// Setup
+	users := map[int]string{1: "Ada Lovelace", 2: "Grace Hopper"}
+	events := []*models.UserEvent{
+		{Seq: 1, Type: models.UserEventCreated, UserID: 1, Name: "Ada Lovelace", Email: "ada@analytical.org"},
+		{Seq: 2, Type: models.UserEventCreated, UserID: 2, Name: "Grace Hopper", Email: "grace@navy.mil"},
+		{Seq: 3, Type: models.UserEventEmailChanged, UserID: 2, Name: "Grace Hopper", Email: "hopper@cobol.org"},
+	}
+	service := newUserSearchService(users, events)
+	if err := service.CatchUp(); err != nil {
+		t.Fatalf("CatchUp returned error: %v", err)
+	}
+
+	// Execute
+	hits, err := service.SearchUsers("cobol", 10)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("SearchUsers returned error: %v", err)
+	}
+	if len(hits) != 1 || hits[0].User.ID != 2 || hits[0].Highlights["email"] != "hopper@<mark>cobol</mark>.org" {
+		t.Errorf("Hits of the new email, expected: user 2, highlighted, got: %v", hits)
+	}
+	if hits, _ := service.SearchUsers("navy", 10); len(hits) != 0 {
+		t.Errorf("Hits of the old email, expected: none, got: %v", hits)
+	}
+}
+
+func TestSearchUsersSkipsMissingUsers(t *testing.T) {
+	// Setup
+	service := newUserSearchService(map[int]string{1: "Ada Lovelace"}, nil)
+	service.Index.Put(search.Document{ID: 2, Fields: map[string]string{"name": "Ada Byron"}})
+
+	// Execute
+	hits, err := service.SearchUsers("ada", 10)
+
+	// Assert
+	if err != nil || len(hits) != 0 {
+		t.Errorf("Hits, expected: none since user 2 was deleted, got: %v, %v", hits, err)
+	}
+}
+
+func TestRebuildUserSearchIndex(t *testing.T) {
+	// Setup
+	events := []*models.UserEvent{{Seq: 7, Type: models.UserEventCreated, UserID: 2, Name: "Grace Hopper"}}
+	service := newUserSearchService(map[int]string{1: "Ada Lovelace", 2: "Grace Hopper"}, events)
+	service.SnapshotPath = filepath.Join(t.TempDir(), "users.idx")
+	service.Index.Put(search.Document{ID: 9, Fields: map[string]string{"name": "Stale"}})
+
+	// Execute
+	count, err := service.RebuildUserSearchIndex(context.Background())
+
+	// Assert
+	if err != nil || count != 2 {
+		t.Fatalf("Rebuild, expected: 2 users, got: %d, %v", count, err)
+	}
+	if service.Index.Len() != 2 || service.Index.Seq() != 7 {
+		t.Errorf("Index, expected: seq 7 with 2 users, got: %d, %d", service.Index.Seq(), service.Index.Len())
+	}
+	saved := &search.Index{Fields: services.UserSearchFields}
+	if err := saved.Load(service.SnapshotPath); err != nil || saved.Len() != 2 {
+		t.Errorf("Snapshot, expected: 2 users, got: %d, %v", saved.Len(), err)
+	}
+}
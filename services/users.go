@@ -2,51 +2,259 @@ package services
 
 import (
 	"fmt"
+	"unicode/utf8"
 
 	"github.com/jordantipton/golang-restful-webservice/models"
 	"github.com/jordantipton/golang-restful-webservice/models/errors"
 	"github.com/jordantipton/golang-restful-webservice/services/interfaces"
 )
 
+// List and batch limits
+const (
+	DefaultListUsersLimit = 50
+	MaxListUsersLimit     = 1000
+	MaxGetUsersBatch      = 100
+	MaxImportBatch        = 1000
+)
+
 type (
 	// UsersServicer interface for user services
 	UsersServicer interface {
 		GetUser(userID int) (*models.User, error)
+		GetUsers(userIDs []int) ([]*models.User, error)
+		ListUsers(options models.UserListOptions) ([]*models.User, error)
 		CreateUser(user *models.User) (*models.User, error)
+		UpdateUser(user *models.User) (*models.User, error)
+		RenameUser(userID int, name string) (*models.User, error)
+		DeleteUser(userID int) error
+		ImportUsers(users []*models.User, options models.ImportOptions) ([]models.ImportResult, error)
+		ExportUsers(filter models.UserFilter, fn func(user *models.User) error) error
 	}
 
-	// UsersService providers user information services
+	// UsersService providers user information services. Successful mutations are
+	// published to EventPublisher when one is set.
 	UsersService struct {
 		UsersPersister interfaces.UsersPersister
+		EventPublisher interfaces.EventPublisher
 	}
 )
 
 // GetUser by ID
 func (usersService *UsersService) GetUser(userID int) (*models.User, error) {
-	user, err := usersService.UsersPersister.GetUser(userID)
+	return usersService.crud().Get(userID)
+}
+
+// GetUsers by ID. Users are returned in the order of their first occurrence in
+// userIDs; IDs without a user are skipped.
+func (usersService *UsersService) GetUsers(userIDs []int) ([]*models.User, error) {
+	return usersService.crud().GetMany(userIDs)
+}
+
+// ListUsers returns a page of users ordered by ID
+func (usersService *UsersService) ListUsers(options models.UserListOptions) ([]*models.User, error) {
+	if options.AfterID < 0 {
+		return nil, errors.InvalidArgument{Message: "AfterID cannot be negative"}
+	}
+	return usersService.crud().List(models.ListOptions[int]{AfterID: options.AfterID, Limit: options.Limit, Prefix: options.NamePrefix})
+}
+
+// CreateUser and return created user
+func (usersService *UsersService) CreateUser(user *models.User) (*models.User, error) {
+	return usersService.crud().Create(user)
+}
+
+// UpdateUser writes the name and email of an existing user and returns the updated user
+func (usersService *UsersService) UpdateUser(user *models.User) (*models.User, error) {
+	return usersService.crud().Update(user)
+}
+
+// RenameUser changes only the name of an existing user and returns the updated user.
+// Unlike UpdateUser it keeps the stored email, even one changed concurrently.
+func (usersService *UsersService) RenameUser(userID int, name string) (*models.User, error) {
+	if err := validateUser(&models.User{ID: userID, Name: name}); err != nil {
+		return nil, err
+	}
+	crud := usersService.crud()
+	old, updated, err := usersService.UsersPersister.RenameUser(userID, name)
 	if err != nil {
 		if _, ok := err.(errors.NotFound); ok {
-			return nil, errors.NotFound{Message: fmt.Sprintf("User with ID %d not found", userID)}
+			return nil, crud.notFound(userID)
 		}
 		return nil, err
 	}
-	if user == nil {
-		return nil, errors.NotFound{Message: fmt.Sprintf("User with ID %d not found", userID)}
+	crud.OnUpdate(old, updated)
+	return updated, nil
+}
+
+// DeleteUser by ID
+func (usersService *UsersService) DeleteUser(userID int) error {
+	return usersService.crud().Delete(userID)
+}
+
+// ImportUsers validates users with the CreateUser rules and writes the valid ones in
+// one batch. Results are in the order of users. A conflict in fail mode returns
+// AlreadyExists along with the results, and nothing from the batch is written.
+func (usersService *UsersService) ImportUsers(users []*models.User, options models.ImportOptions) ([]models.ImportResult, error) {
+	switch options.OnConflict {
+	case "":
+		options.OnConflict = models.ImportConflictFail
+	case models.ImportConflictFail, models.ImportConflictSkip, models.ImportConflictUpdate:
+	default:
+		return nil, errors.InvalidArgument{Message: "on_conflict must be one of fail, skip or update"}
 	}
-	return user, nil
+	if len(users) > MaxImportBatch {
+		return nil, errors.InvalidArgument{Message: fmt.Sprintf("Cannot import more than %d users at once", MaxImportBatch)}
+	}
+	results := make([]models.ImportResult, len(users))
+	var valid []*models.User
+	var validIndexes []int
+	seenIDs := map[int]bool{}
+	for i, user := range users {
+		err := validateUser(user)
+		if err == nil && user.ID < 0 {
+			err = errors.InvalidArgument{Message: "User ID cannot be negative"}
+		}
+		if err == nil && user.ID != 0 && seenIDs[user.ID] {
+			err = errors.InvalidArgument{Message: fmt.Sprintf("User ID %d appears more than once", user.ID)}
+		}
+		if err != nil {
+			results[i] = models.ImportResult{Status: models.ImportInvalid, Error: err.Error()}
+			continue
+		}
+		seenIDs[user.ID] = true
+		valid = append(valid, user)
+		validIndexes = append(validIndexes, i)
+	}
+	persisted, err := usersService.UsersPersister.ImportUsers(valid, options)
+	if persisted == nil {
+		return nil, err
+	}
+	for k, i := range validIndexes {
+		results[i] = persisted[k]
+	}
+	if err != nil || options.DryRun {
+		return results, err
+	}
+	for k, result := range persisted {
+		switch result.Status {
+		case models.ImportCreated:
+			usersService.publish(models.UserCreated{User: models.User{ID: result.ID, Name: valid[k].Name, Email: valid[k].Email}})
+		case models.ImportUpdated:
+			if result.PreviousName != valid[k].Name {
+				usersService.publish(models.UserRenamed{UserID: result.ID, OldName: result.PreviousName, NewName: valid[k].Name})
+			}
+			if result.PreviousEmail != valid[k].Email {
+				usersService.publish(models.UserEmailChanged{UserID: result.ID, Name: valid[k].Name, OldEmail: result.PreviousEmail, NewEmail: valid[k].Email})
+			}
+		}
+	}
+	return results, nil
 }
 
-// CreateUser and return created user
-func (usersService *UsersService) CreateUser(user *models.User) (*models.User, error) {
+// ExportUsers calls fn for every user matching filter in ID order. It stops at the
+// first error returned by fn.
+func (usersService *UsersService) ExportUsers(filter models.UserFilter, fn func(user *models.User) error) error {
+	return usersService.UsersPersister.ExportUsers(filter, fn)
+}
+
+// crud returns the generic service of users, which publishes their changes
+func (usersService *UsersService) crud() *Service[models.User, int] {
+	return &Service[models.User, int]{
+		Persister:    usersPersister{usersService.UsersPersister},
+		Name:         "User",
+		Plural:       "users",
+		ID:           func(user *models.User) int { return user.ID },
+		Validate:     validateUser,
+		DefaultLimit: DefaultListUsersLimit,
+		MaxLimit:     MaxListUsersLimit,
+		MaxBatch:     MaxGetUsersBatch,
+		OnCreate: func(created *models.User) {
+			usersService.publish(models.UserCreated{User: *created})
+		},
+		OnUpdate: func(old, updated *models.User) {
+			if old.Name != updated.Name {
+				usersService.publish(models.UserRenamed{UserID: updated.ID, OldName: old.Name, NewName: updated.Name})
+			}
+			if old.Email != updated.Email {
+				usersService.publish(models.UserEmailChanged{UserID: updated.ID, Name: updated.Name, OldEmail: old.Email, NewEmail: updated.Email})
+			}
+		},
+		OnDelete: func(deleted *models.User) {
+			usersService.publish(models.UserDeleted{UserID: deleted.ID, Name: deleted.Name})
+		},
+	}
+}
+
+// usersPersister adapts a UsersPersister to the generic Persister of users
+type usersPersister struct {
+	interfaces.UsersPersister
+}
+
+func (p usersPersister) Get(userID int) (*models.User, error) { return p.GetUser(userID) }
+
+func (p usersPersister) GetMany(userIDs []int) ([]*models.User, error) { return p.GetUsers(userIDs) }
+
+func (p usersPersister) List(options models.ListOptions[int]) ([]*models.User, error) {
+	return p.ListUsers(models.UserListOptions{AfterID: options.AfterID, Limit: options.Limit, NamePrefix: options.Prefix})
+}
+
+func (p usersPersister) Create(user *models.User) (*models.User, error) { return p.CreateUser(user) }
+
+func (p usersPersister) Update(user *models.User) (old, updated *models.User, err error) {
+	return p.UpdateUser(user)
+}
+
+func (p usersPersister) Delete(userID int) (*models.User, error) { return p.DeleteUser(userID) }
+
+func validateUser(user *models.User) error {
 	if user == nil {
-		return nil, errors.InvalidArgument{Message: "User cannot be nil"}
+		return errors.InvalidArgument{Message: "User cannot be nil"}
 	}
 	if user.Name == "" {
-		return nil, errors.InvalidArgument{Message: "User name cannot be empty"}
+		return errors.InvalidArgument{Message: "User name cannot be empty"}
 	}
-	resultUser, err := usersService.UsersPersister.CreateUser(user)
-	if err != nil {
-		return nil, err
+	if utf8.RuneCountInString(user.Name) > models.UserNameMaxLength {
+		return errors.InvalidArgument{Message: fmt.Sprintf("User name cannot be longer than %d characters", models.UserNameMaxLength)}
+	}
+	if !models.ValidUserNameCharset(user.Name) {
+		return errors.InvalidArgument{Message: "User name can only contain letters, digits, spaces and . , ' -"}
+	}
+	if utf8.RuneCountInString(user.Email) > models.UserEmailMaxLength {
+		return errors.InvalidArgument{Message: fmt.Sprintf("User email cannot be longer than %d characters", models.UserEmailMaxLength)}
+	}
+	if user.Email != "" && !models.ValidUserEmail(user.Email) {
+		return errors.InvalidArgument{Message: "User email must be an address such as ada@example.com"}
 	}
-	return resultUser, nil
+	return nil
+}
+
+func (usersService *UsersService) publish(event models.Event) {
+	if usersService.EventPublisher != nil {
+		usersService.EventPublisher.Publish(event)
+	}
+}
+
+// UsersCRUD adapts a UsersServicer to the generic Servicer of users
+type UsersCRUD struct {
+	UsersServicer
+}
+
+// Get by ID
+func (s UsersCRUD) Get(userID int) (*models.User, error) { return s.GetUser(userID) }
+
+// GetMany by ID
+func (s UsersCRUD) GetMany(userIDs []int) ([]*models.User, error) { return s.GetUsers(userIDs) }
+
+// List returns a page of users ordered by ID. The Prefix matches their names.
+func (s UsersCRUD) List(options models.ListOptions[int]) ([]*models.User, error) {
+	return s.ListUsers(models.UserListOptions{AfterID: options.AfterID, Limit: options.Limit, NamePrefix: options.Prefix})
 }
+
+// Create a user
+func (s UsersCRUD) Create(user *models.User) (*models.User, error) { return s.CreateUser(user) }
+
+// Update a user
+func (s UsersCRUD) Update(user *models.User) (*models.User, error) { return s.UpdateUser(user) }
+
+// Delete by ID
+func (s UsersCRUD) Delete(userID int) error { return s.DeleteUser(userID) }
@@ -2,6 +2,7 @@ package services_test
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/jordantipton/golang-restful-webservice/models"
@@ -14,8 +15,15 @@ import (
 */
 
 type mockUserPersister struct {
-	mockGetUser    func(userID int) (*models.User, error)
-	mockCreateUser func(user *models.User) (*models.User, error)
+	mockGetUser     func(userID int) (*models.User, error)
+	mockGetUsers    func(userIDs []int) ([]*models.User, error)
+	mockListUsers   func(options models.UserListOptions) ([]*models.User, error)
+	mockCreateUser  func(user *models.User) (*models.User, error)
+	mockUpdateUser  func(user *models.User) (old, updated *models.User, err error)
+	mockRenameUser  func(userID int, name string) (old, updated *models.User, err error)
+	mockDeleteUser  func(userID int) (*models.User, error)
+	mockImportUsers func(users []*models.User, options models.ImportOptions) ([]models.ImportResult, error)
+	mockExportUsers func(filter models.UserFilter, fn func(user *models.User) error) error
 }
 
 func (m *mockUserPersister) GetUser(userID int) (*models.User, error) {
@@ -32,6 +40,63 @@ func (m *mockUserPersister) CreateUser(user *models.User) (*models.User, error)
 	return nil, nil
 }
 
+func (m *mockUserPersister) GetUsers(userIDs []int) ([]*models.User, error) {
+	if m.mockGetUsers != nil {
+		return m.mockGetUsers(userIDs)
+	}
+	return nil, nil
+}
+
+func (m *mockUserPersister) ListUsers(options models.UserListOptions) ([]*models.User, error) {
+	if m.mockListUsers != nil {
+		return m.mockListUsers(options)
+	}
+	return nil, nil
+}
+
+func (m *mockUserPersister) UpdateUser(user *models.User) (old, updated *models.User, err error) {
+	if m.mockUpdateUser != nil {
+		return m.mockUpdateUser(user)
+	}
+	return nil, nil, nil
+}
+
+func (m *mockUserPersister) RenameUser(userID int, name string) (old, updated *models.User, err error) {
+	if m.mockRenameUser != nil {
+		return m.mockRenameUser(userID, name)
+	}
+	return nil, nil, nil
+}
+
+func (m *mockUserPersister) DeleteUser(userID int) (deleted *models.User, err error) {
+	if m.mockDeleteUser != nil {
+		return m.mockDeleteUser(userID)
+	}
+	return &models.User{ID: userID}, nil
+}
+
+func (m *mockUserPersister) ImportUsers(users []*models.User, options models.ImportOptions) ([]models.ImportResult, error) {
+	if m.mockImportUsers != nil {
+		return m.mockImportUsers(users, options)
+	}
+	return make([]models.ImportResult, len(users)), nil
+}
+
+func (m *mockUserPersister) ExportUsers(filter models.UserFilter, fn func(user *models.User) error) error {
+	if m.mockExportUsers != nil {
+		return m.mockExportUsers(filter, fn)
+	}
+	return nil
+}
+
+type mockEventPublisher struct {
+	published []models.Event
+}
+
+func (m *mockEventPublisher) Publish(event models.Event) {
+	m.published = append(m.published, event)
+}
+
 /*
 	Test functions
 */
@@ -168,6 +233,22 @@ func TestCreateUserNoName(t *testing.T) {
 	}
 }
 
+func TestCreateUserInvalidName(t *testing.T) {
+	// Setup
+	usersService := services.UsersService{UsersPersister: &mockUserPersister{}}
+	names := []string{" Bob", "Bob ", "Bob\n", "<script>", strings.Repeat("a", models.UserNameMaxLength+1)}
+
+	for _, name := range names {
+		// Execute
+		_, err := usersService.CreateUser(&models.User{Name: name})
+
+		// Assert
+		if _, ok := err.(errors.InvalidArgument); !ok {
+			t.Errorf("Error for %q, expected: InvalidArgument, got: %v", name, err)
+		}
+	}
+}
+
 func TestCreateUserError(t *testing.T) {
 	// Setup
 	repositoryUser := &models.User{ID: 1, Name: "Name"}
@@ -187,3 +268,387 @@ func TestCreateUserError(t *testing.T) {
 		t.Errorf("Expected error to be returned but is nil")
 	}
 }
+
+func TestCreateUserPublishesUserCreated(t *testing.T) {
+	// Setup
+	repositoryUser := &models.User{ID: 1, Name: "Name"}
+	mockUserPersister := mockUserPersister{
+		mockCreateUser: func(*models.User) (*models.User, error) {
+			return repositoryUser, nil
+		},
+	}
+	publisher := mockEventPublisher{}
+
+	usersService := services.UsersService{UsersPersister: &mockUserPersister, EventPublisher: &publisher}
+
+	// Execute
+	_, err := usersService.CreateUser(&models.User{Name: repositoryUser.Name})
+
+	// Assert
+	if err != nil {
+		t.Errorf("CreateUser returned error: %s", err.Error())
+	}
+	if len(publisher.published) != 1 {
+		t.Fatalf("Published events, expected: %d, got: %d", 1, len(publisher.published))
+	}
+	created, ok := publisher.published[0].(models.UserCreated)
+	if !ok {
+		t.Fatalf("Event, expected: UserCreated, got: %s", publisher.published[0].EventType())
+	}
+	if created.User != *repositoryUser {
+		t.Errorf("User, expected: %v, got: %v", *repositoryUser, created.User)
+	}
+}
+
+func TestCreateUserErrorPublishesNothing(t *testing.T) {
+	// Setup
+	mockUserPersister := mockUserPersister{
+		mockCreateUser: func(*models.User) (*models.User, error) {
+			return nil, fmt.Errorf("some error")
+		},
+	}
+	publisher := mockEventPublisher{}
+
+	usersService := services.UsersService{UsersPersister: &mockUserPersister, EventPublisher: &publisher}
+
+	// Execute
+	usersService.CreateUser(&models.User{Name: "Name"})
+
+	// Assert
+	if len(publisher.published) != 0 {
+		t.Errorf("Published events, expected: %d, got: %d", 0, len(publisher.published))
+	}
+}
+
+func TestListUsersDefaultsLimit(t *testing.T) {
+	// Setup
+	var requested models.UserListOptions
+	mockUserPersister := mockUserPersister{
+		mockListUsers: func(options models.UserListOptions) ([]*models.User, error) {
+			requested = options
+			return []*models.User{{ID: 3, Name: "Name"}}, nil
+		},
+	}
+
+	usersService := services.UsersService{UsersPersister: &mockUserPersister}
+
+	// Execute
+	users, err := usersService.ListUsers(models.UserListOptions{AfterID: 2})
+
+	// Assert
+	if err != nil {
+		t.Errorf("ListUsers returned error: %s", err.Error())
+	}
+	if requested.AfterID != 2 || requested.Limit != services.DefaultListUsersLimit {
+		t.Errorf("Options, expected: AfterID 2 Limit %d, got: %v", services.DefaultListUsersLimit, requested)
+	}
+	if len(users) != 1 {
+		t.Errorf("Users, expected: %d, got: %d", 1, len(users))
+	}
+}
+
+func TestListUsersNegativeAfterID(t *testing.T) {
+	// Setup
+	usersService := services.UsersService{UsersPersister: &mockUserPersister{}}
+
+	// Execute
+	_, err := usersService.ListUsers(models.UserListOptions{AfterID: -1})
+
+	// Assert
+	if _, ok := err.(errors.InvalidArgument); !ok {
+		t.Errorf("Error, expected: InvalidArgument, got: %v", err)
+	}
+}
+
+func TestUpdateUserPublishesUserRenamed(t *testing.T) {
+	// Setup
+	mockUserPersister := mockUserPersister{
+		mockGetUser: func(userID int) (*models.User, error) {
+			// A stale read, renamed since by another update
+			return &models.User{ID: userID, Name: "Stale"}, nil
+		},
+		mockUpdateUser: func(user *models.User) (*models.User, *models.User, error) {
+			return &models.User{ID: user.ID, Name: "Old"}, &models.User{ID: user.ID, Name: user.Name}, nil
+		},
+	}
+	publisher := mockEventPublisher{}
+
+	usersService := services.UsersService{UsersPersister: &mockUserPersister, EventPublisher: &publisher}
+
+	// Execute
+	user, err := usersService.UpdateUser(&models.User{ID: 1, Name: "New"})
+
+	// Assert
+	if err != nil {
+		t.Errorf("UpdateUser returned error: %s", err.Error())
+	}
+	if user == nil || user.Name != "New" {
+		t.Errorf("User name, expected: New, got: %v", user)
+	}
+	expected := models.UserRenamed{UserID: 1, OldName: "Old", NewName: "New"}
+	if len(publisher.published) != 1 || publisher.published[0] != expected {
+		t.Errorf("Published events, expected: [%v], got: %v", expected, publisher.published)
+	}
+}
+
+func TestUpdateUserNotFound(t *testing.T) {
+	// Setup
+	mockUserPersister := mockUserPersister{
+		mockUpdateUser: func(user *models.User) (*models.User, *models.User, error) {
+			return nil, nil, errors.NotFound{Message: "sql: no rows in result set"}
+		},
+	}
+
+	usersService := services.UsersService{UsersPersister: &mockUserPersister}
+
+	// Execute
+	_, err := usersService.UpdateUser(&models.User{ID: 1, Name: "New"})
+
+	// Assert
+	if _, ok := err.(errors.NotFound); !ok {
+		t.Errorf("Error, expected: NotFound, got: %v", err)
+	}
+}
+
+func TestUpdateUserNoName(t *testing.T) {
+	// Setup
+	usersService := services.UsersService{UsersPersister: &mockUserPersister{}}
+
+	// Execute
+	_, err := usersService.UpdateUser(&models.User{ID: 1})
+
+	// Assert
+	if _, ok := err.(errors.InvalidArgument); !ok {
+		t.Errorf("Error, expected: InvalidArgument, got: %v", err)
+	}
+}
+
+func TestUpdateUserInvalidEmail(t *testing.T) {
+	// Setup
+	usersService := services.UsersService{UsersPersister: &mockUserPersister{}}
+
+	// Execute
+	_, err := usersService.UpdateUser(&models.User{ID: 1, Name: "Ada", Email: "Ada <ada@example.com>"})
+
+	// Assert
+	if _, ok := err.(errors.InvalidArgument); !ok {
+		t.Errorf("Error, expected: InvalidArgument, got: %v", err)
+	}
+}
+
+func TestUpdateUserPublishesUserEmailChanged(t *testing.T) {
+	// Setup
+	mockUserPersister := mockUserPersister{
+		mockUpdateUser: func(user *models.User) (*models.User, *models.User, error) {
+			return &models.User{ID: user.ID, Name: user.Name, Email: "ada@old.org"}, user, nil
+		},
+	}
+	publisher := mockEventPublisher{}
+
+	usersService := services.UsersService{UsersPersister: &mockUserPersister, EventPublisher: &publisher}
+
+	// Execute
+	_, err := usersService.UpdateUser(&models.User{ID: 1, Name: "Ada", Email: "ada@example.com"})
+
+	// Assert
+	if err != nil {
+		t.Errorf("UpdateUser returned error: %s", err.Error())
+	}
+	expected := models.UserEmailChanged{UserID: 1, Name: "Ada", OldEmail: "ada@old.org", NewEmail: "ada@example.com"}
+	if len(publisher.published) != 1 || publisher.published[0] != expected {
+		t.Errorf("Published events, expected: [%v], got: %v", expected, publisher.published)
+	}
+}
+
+func TestRenameUserKeepsEmail(t *testing.T) {
+	// Setup
+	mockUserPersister := mockUserPersister{
+		mockRenameUser: func(userID int, name string) (*models.User, *models.User, error) {
+			return &models.User{ID: userID, Name: "Old", Email: "ada@example.com"}, &models.User{ID: userID, Name: name, Email: "ada@example.com"}, nil
+		},
+	}
+	publisher := mockEventPublisher{}
+
+	usersService := services.UsersService{UsersPersister: &mockUserPersister, EventPublisher: &publisher}
+
+	// Execute
+	user, err := usersService.RenameUser(1, "New")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("RenameUser returned error: %s", err.Error())
+	}
+	if user.Name != "New" || user.Email != "ada@example.com" {
+		t.Errorf("User, expected: New ada@example.com, got: %v", user)
+	}
+	expected := models.UserRenamed{UserID: 1, OldName: "Old", NewName: "New"}
+	if len(publisher.published) != 1 || publisher.published[0] != expected {
+		t.Errorf("Published events, expected: [%v], got: %v", expected, publisher.published)
+	}
+}
+
+func TestRenameUserNotFound(t *testing.T) {
+	// Setup
+	mockUserPersister := mockUserPersister{
+		mockRenameUser: func(userID int, name string) (*models.User, *models.User, error) {
+			return nil, nil, errors.NotFound{Message: "sql: no rows in result set"}
+		},
+	}
+
+	usersService := services.UsersService{UsersPersister: &mockUserPersister}
+
+	// Execute
+	_, err := usersService.RenameUser(1, "New")
+
+	// Assert
+	if _, ok := err.(errors.NotFound); !ok || err.Error() != "User with ID 1 not found" {
+		t.Errorf("Error, expected: NotFound, got: %v", err)
+	}
+}
+
+func TestDeleteUserPublishesUserDeleted(t *testing.T) {
+	// Setup
+	mockUserPersister := mockUserPersister{
+		mockDeleteUser: func(userID int) (*models.User, error) {
+			return &models.User{ID: userID, Name: "Ada"}, nil
+		},
+	}
+	publisher := mockEventPublisher{}
+	usersService := services.UsersService{UsersPersister: &mockUserPersister, EventPublisher: &publisher}
+
+	// Execute
+	err := usersService.DeleteUser(1)
+
+	// Assert
+	if err != nil {
+		t.Errorf("DeleteUser returned error: %s", err.Error())
+	}
+	expected := models.UserDeleted{UserID: 1, Name: "Ada"}
+	if len(publisher.published) != 1 || publisher.published[0] != expected {
+		t.Errorf("Published events, expected: [%v], got: %v", expected, publisher.published)
+	}
+}
+
+func TestDeleteUserError(t *testing.T) {
+	// Setup
+	mockUserPersister := mockUserPersister{
+		mockDeleteUser: func(userID int) (*models.User, error) {
+			return nil, errors.NotFound{Message: "not found"}
+		},
+	}
+	publisher := mockEventPublisher{}
+	usersService := services.UsersService{UsersPersister: &mockUserPersister, EventPublisher: &publisher}
+
+	// Execute
+	err := usersService.DeleteUser(1)
+
+	// Assert
+	if _, ok := err.(errors.NotFound); !ok {
+		t.Errorf("Error, expected: NotFound, got: %v", err)
+	}
+	if len(publisher.published) != 0 {
+		t.Errorf("Published events, expected: %d, got: %d", 0, len(publisher.published))
+	}
+}
+
+func TestGetUsersPreservesOrder(t *testing.T) {
+	// Setup
+	mockUserPersister := mockUserPersister{
+		mockGetUsers: func(userIDs []int) ([]*models.User, error) {
+			return []*models.User{{ID: 1, Name: "One"}, {ID: 3, Name: "Three"}}, nil
+		},
+	}
+
+	usersService := services.UsersService{UsersPersister: &mockUserPersister}
+
+	// Execute
+	users, err := usersService.GetUsers([]int{3, 2, 1, 3})
+
+	// Assert
+	if err != nil {
+		t.Errorf("GetUsers returned error: %s", err.Error())
+	}
+	if len(users) != 2 || users[0].ID != 3 || users[1].ID != 1 {
+		t.Errorf("Users, expected IDs: 3, 1, got: %v", users)
+	}
+}
+
+func TestGetUsersTooMany(t *testing.T) {
+	// Setup
+	usersService := services.UsersService{UsersPersister: &mockUserPersister{}}
+
+	// Execute
+	_, err := usersService.GetUsers(make([]int, services.MaxGetUsersBatch+1))
+
+	// Assert
+	if _, ok := err.(errors.InvalidArgument); !ok {
+		t.Errorf("Error, expected: InvalidArgument, got: %v", err)
+	}
+}
+
+func TestImportUsersValidatesRows(t *testing.T) {
+	// Setup
+	var persisted []*models.User
+	mockUserPersister := mockUserPersister{
+		mockImportUsers: func(users []*models.User, options models.ImportOptions) ([]models.ImportResult, error) {
+			persisted = users
+			results := make([]models.ImportResult, len(users))
+			for i := range users {
+				results[i] = models.ImportResult{ID: 10 + i, Status: models.ImportCreated}
+			}
+			return results, nil
+		},
+	}
+	publisher := mockEventPublisher{}
+	usersService := services.UsersService{UsersPersister: &mockUserPersister, EventPublisher: &publisher}
+
+	// Execute
+	results, err := usersService.ImportUsers([]*models.User{{Name: "Alice"}, {Name: ""}, {ID: 5, Name: "Bob"}, {ID: 5, Name: "Bobby"}}, models.ImportOptions{})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("ImportUsers returned error: %s", err.Error())
+	}
+	if len(persisted) != 2 {
+		t.Errorf("Persisted users, expected: %d, got: %d", 2, len(persisted))
+	}
+	expectedStatuses := []string{models.ImportCreated, models.ImportInvalid, models.ImportCreated, models.ImportInvalid}
+	for i, expected := range expectedStatuses {
+		if results[i].Status != expected {
+			t.Errorf("Row %d status, expected: %s, got: %s", i, expected, results[i].Status)
+		}
+	}
+	if len(publisher.published) != 2 {
+		t.Errorf("Published events, expected: %d, got: %d", 2, len(publisher.published))
+	}
+}
+
+func TestImportUsersDryRunPublishesNothing(t *testing.T) {
+	// Setup
+	publisher := mockEventPublisher{}
+	usersService := services.UsersService{UsersPersister: &mockUserPersister{}, EventPublisher: &publisher}
+
+	// Execute
+	_, err := usersService.ImportUsers([]*models.User{{Name: "Alice"}}, models.ImportOptions{DryRun: true})
+
+	// Assert
+	if err != nil {
+		t.Errorf("ImportUsers returned error: %s", err.Error())
+	}
+	if len(publisher.published) != 0 {
+		t.Errorf("Published events, expected: %d, got: %d", 0, len(publisher.published))
+	}
+}
+
+func TestImportUsersInvalidConflictMode(t *testing.T) {
+	// Setup
+	usersService := services.UsersService{UsersPersister: &mockUserPersister{}}
+
+	// Execute
+	_, err := usersService.ImportUsers([]*models.User{{Name: "Alice"}}, models.ImportOptions{OnConflict: "merge"})
+
+	// Assert
+	if _, ok := err.(errors.InvalidArgument); !ok {
+		t.Errorf("Error, expected: InvalidArgument, got: %v", err)
+	}
+}